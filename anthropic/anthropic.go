@@ -2,13 +2,16 @@ package anthropic
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/blixt/go-llms/content"
 	"github.com/blixt/go-llms/llms"
@@ -21,17 +24,39 @@ type Model struct {
 	endpoint          string
 	company           string
 	debug             bool
+	httpClient        *http.Client
 	maxTokens         int
 	maxThinkingTokens int
+	temperature       float64
+	topP              float64
+	topK              int
+
+	emptyContentPlaceholder string
+	disableParallelToolUse  bool
+	toolChoice              string
+	betas                   []string
+	userID                  string
+	noStreaming             bool
+	maxRetries              int
+	retryBaseDelay          time.Duration
+
+	// Settings for using Claude through a cloud platform.
+	platform           platform
+	accessToken        string
+	region             string
+	awsAccessKeyID     string
+	awsSecretAccessKey string
+	awsSessionToken    string
 }
 
 func New(apiKey, model string) *Model {
 	return &Model{
-		apiKey:    apiKey,
-		model:     model,
-		endpoint:  "https://api.anthropic.com/v1/messages",
-		company:   "Anthropic",
-		maxTokens: 1024,
+		apiKey:      apiKey,
+		model:       model,
+		endpoint:    "https://api.anthropic.com/v1/messages",
+		company:     "Anthropic",
+		temperature: math.NaN(),
+		topP:        math.NaN(),
 	}
 }
 
@@ -40,6 +65,23 @@ func (m *Model) WithDebug() *Model {
 	return m
 }
 
+// WithHTTPClient sets the HTTP client that requests are sent with, for
+// example to set timeouts, proxies or instrumented transports. Without one,
+// the client of the context is used (see llms.ContextWithHTTPClient), or else
+// http.DefaultClient.
+func (m *Model) WithHTTPClient(client *http.Client) *Model {
+	m.httpClient = client
+	return m
+}
+
+// client returns the HTTP client to send requests with.
+func (m *Model) client(ctx context.Context) *http.Client {
+	if m.httpClient != nil {
+		return m.httpClient
+	}
+	return llms.HTTPClientFromContext(ctx)
+}
+
 // WithEndpoint sets the endpoint (and company name) so Anthropic-compatible
 // endpoints can be used.
 func (m *Model) WithEndpoint(endpoint, company string) *Model {
@@ -48,16 +90,85 @@ func (m *Model) WithEndpoint(endpoint, company string) *Model {
 	return m
 }
 
+// WithMaxTokens sets the maximum number of tokens of a response, not counting
+// the thinking budget. By default it's the most that the model can produce
+// (see DefaultMaxTokens), so that long responses aren't cut off. Requests
+// fail without being sent if the model is known to not allow as many tokens.
 func (m *Model) WithMaxTokens(maxTokens int) *Model {
 	m.maxTokens = maxTokens
 	return m
 }
 
+// WithStreaming controls whether responses are streamed, which is the
+// default. Without streaming, the whole response is requested at once and
+// replayed by the stream, for environments where server-sent events are
+// blocked. Text then arrives in a single update.
+func (m *Model) WithStreaming(enabled bool) *Model {
+	m.noStreaming = !enabled
+	return m
+}
+
+// WithTemperature sets the randomness of responses, from 0.0 to 1.0.
+func (m *Model) WithTemperature(temperature float64) *Model {
+	m.temperature = temperature
+	return m
+}
+
+// WithTopP makes the model only pick from the most likely tokens whose
+// probabilities add up to topP. Anthropic recommends changing either the
+// temperature or this, but not both.
+func (m *Model) WithTopP(topP float64) *Model {
+	m.topP = topP
+	return m
+}
+
+// WithTopK makes the model only pick from the topK most likely tokens.
+func (m *Model) WithTopK(topK int) *Model {
+	m.topK = topK
+	return m
+}
+
+// WithBeta opts into beta features of the API, such as
+// "context-1m-2025-08-07" or "output-128k-2025-02-19", by sending them in the
+// anthropic-beta header.
+func (m *Model) WithBeta(features ...string) *Model {
+	m.betas = append(m.betas, features...)
+	return m
+}
+
+// WithEmptyContentPlaceholder makes messages that have no content (for
+// example a response that was only whitespace) get sent with the placeholder
+// text instead of being left out. Anthropic combines consecutive messages with
+// the same role, so leaving them out is usually fine, but a placeholder keeps
+// every turn of the transcript visible to the model.
+func (m *Model) WithEmptyContentPlaceholder(text string) *Model {
+	m.emptyContentPlaceholder = text
+	return m
+}
+
+// WithParallelToolUse controls whether the model may call more than one tool in
+// a single response. It's enabled by default. Disable it when tools must run
+// strictly one at a time, with the model seeing each result before it makes
+// the next call.
+func (m *Model) WithParallelToolUse(enabled bool) *Model {
+	m.disableParallelToolUse = !enabled
+	return m
+}
+
+// WithToolChoice controls which tool the model calls when it starts responding
+// to the user: "auto" (the default), "none", "any" (or "required") for any
+// tool, or the function name of a specific tool. It only applies to requests
+// that don't follow tool results, so the model is free to respond once the
+// tool has run.
+func (m *Model) WithToolChoice(choice string) *Model {
+	m.toolChoice = choice
+	return m
+}
+
+// WithThinking enables extended thinking with a budget of tokens that the
+// model can think with, on top of the max tokens of the response. The thinking
+// is kept in the message, since it must be sent back with tool results.
 func (m *Model) WithThinking(budgetTokens int) *Model {
-	// FIXME: The codebase needs to be updated to support thinking models.
-	if budgetTokens > 0 {
-		panic("thinking models are not yet supported")
-	}
 	m.maxThinkingTokens = budgetTokens
 	return m
 }
@@ -70,19 +181,137 @@ func (m *Model) Model() string {
 	return m.model
 }
 
-func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, tools *tools.Toolbox) llms.ProviderStream {
+func (m *Model) String() string {
+	return llms.ProviderName(m)
+}
+
+func (m *Model) ToolResultTypes() []content.Type {
+	return []content.Type{content.TypeText, content.TypeJSON, content.TypeImageURL}
+}
+
+// Ping looks up the model in the Anthropic API, which checks that the API is
+// reachable, that the API key is valid and that the model exists. Claude on
+// cloud platforms is checked with llms.PingWithGenerate instead.
+func (m *Model) Ping(ctx context.Context) error {
+	base, ok := strings.CutSuffix(m.endpoint, "/messages")
+	if m.platform != platformAnthropic || !ok {
+		return llms.PingWithGenerate(ctx, m)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/models/"+url.PathEscape(m.model), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", llms.UserAgent())
+	m.authorize(req, nil)
+	resp, err := m.client(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
+// convertMessages converts the messages to the API format.
+func (m *Model) convertMessages(messages []llms.Message) []message {
 	var apiMessages []message
 	for _, msg := range messages {
-		apiMessages = append(apiMessages, messageFromLLM(msg))
+		apiMsg := messageFromLLM(msg)
+		if len(apiMsg.Content) == 0 {
+			// The API rejects messages without content (and empty text blocks).
+			if m.emptyContentPlaceholder == "" {
+				continue
+			}
+			apiMsg.Content = contentList{{Type: "text", Text: m.emptyContentPlaceholder}}
+		}
+		apiMessages = append(apiMessages, apiMsg)
 	}
+	// A trailing assistant message is a prefill that the response continues,
+	// and the API rejects prefills that end with whitespace.
+	if n := len(apiMessages); n > 0 && apiMessages[n-1].Role == "assistant" {
+		last := &apiMessages[n-1]
+		if i := len(last.Content) - 1; last.Content[i].Type == "text" {
+			last.Content[i].Text = strings.TrimRightFunc(last.Content[i].Text, unicode.IsSpace)
+			if last.Content[i].Text == "" {
+				last.Content = last.Content[:i]
+			}
+			if len(last.Content) == 0 {
+				apiMessages = apiMessages[:n-1]
+			}
+		}
+	}
+	return apiMessages
+}
 
+// CountTokens returns the number of input tokens that a request with the
+// system prompt, messages and tools would use, with the token counting
+// endpoint of the Anthropic API. It can be used to check that a request fits
+// the context window before sending it. Token counting isn't available for
+// Claude on cloud platforms.
+func (m *Model) CountTokens(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) (int, error) {
+	if m.platform != platformAnthropic {
+		return 0, fmt.Errorf("token counting is only supported by the Anthropic API")
+	}
 	payload := map[string]any{
 		"model":    m.model,
-		"messages": apiMessages,
-		"stream":   true,
-		// We make an opinionated choice here to calculate thinking tokens on
-		// top of the max output tokens.
-		"max_tokens": m.maxTokens + m.maxThinkingTokens,
+		"messages": m.convertMessages(messages),
+	}
+	if systemPrompt != nil {
+		payload["system"] = contentFromLLM(systemPrompt)
+	}
+	if toolbox != nil {
+		payload["tools"] = Tools(toolbox)
+	}
+	if m.maxThinkingTokens > 0 {
+		payload["thinking"] = map[string]any{
+			"type":          "enabled",
+			"budget_tokens": m.maxThinkingTokens,
+		}
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	resp, err := m.post(ctx, m.endpoint+"/count_tokens", jsonData)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("error decoding response: %w", err)
+	}
+	return result.InputTokens, nil
+}
+
+func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, tools *tools.Toolbox) llms.ProviderStream {
+	maxTokens, err := m.requestMaxTokens()
+	if err != nil {
+		return &Stream{err: err}
+	}
+	payload := map[string]any{
+		"model":      m.model,
+		"messages":   m.convertMessages(messages),
+		"stream":     !m.noStreaming,
+		"max_tokens": maxTokens,
+	}
+
+	if !math.IsNaN(m.temperature) {
+		payload["temperature"] = m.temperature
+	}
+	if !math.IsNaN(m.topP) {
+		payload["top_p"] = m.topP
+	}
+	if m.topK > 0 {
+		payload["top_k"] = m.topK
+	}
+	if id := m.requestUserID(ctx); id != "" {
+		payload["metadata"] = map[string]any{"user_id": id}
 	}
 
 	if systemPrompt != nil {
@@ -91,7 +320,22 @@ func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, mess
 
 	if tools != nil {
 		payload["tools"] = Tools(tools)
-		payload["tool_choice"] = map[string]string{"type": "auto"}
+		toolChoice := map[string]any{"type": "auto"}
+		if m.toolChoice != "" && (len(messages) == 0 || messages[len(messages)-1].Role != "tool") {
+			switch m.toolChoice {
+			case "auto", "none", "any":
+				toolChoice["type"] = m.toolChoice
+			case "required":
+				toolChoice["type"] = "any"
+			default:
+				toolChoice["type"] = "tool"
+				toolChoice["name"] = m.toolChoice
+			}
+		}
+		if m.disableParallelToolUse && toolChoice["type"] != "none" {
+			toolChoice["disable_parallel_tool_use"] = true
+		}
+		payload["tool_choice"] = toolChoice
 	}
 
 	if m.maxThinkingTokens > 0 {
@@ -101,6 +345,8 @@ func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, mess
 		}
 	}
 
+	m.preparePayload(payload)
+
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return &Stream{err: fmt.Errorf("error encoding JSON: %w", err)}
@@ -110,41 +356,22 @@ func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, mess
 		fmt.Printf("Request: %s\n%s\n", m.endpoint, string(jsonData))
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", m.endpoint, bytes.NewReader(jsonData))
+	resp, err := m.post(ctx, m.messagesEndpoint(), jsonData)
 	if err != nil {
-		return &Stream{err: fmt.Errorf("error creating request: %w", err)}
+		return &Stream{err: err}
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", m.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return &Stream{err: fmt.Errorf("error making request: %w", err)}
-	}
-	if resp.StatusCode != http.StatusOK {
+	body := m.responseStream(resp.Body)
+	if m.noStreaming {
 		defer resp.Body.Close()
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-		if readErr == nil && len(bodyBytes) > 0 {
-			var anthropicErr struct {
-				Type  string `json:"type"`
-				Error struct {
-					Type    string `json:"type"`
-					Message string `json:"message"`
-				} `json:"error"`
-			}
-			if jsonErr := json.Unmarshal(bodyBytes, &anthropicErr); jsonErr == nil && anthropicErr.Type == "error" {
-				// Successfully parsed the Anthropic error format
-				return &Stream{err: fmt.Errorf("%s: %s: %s", resp.Status, anthropicErr.Error.Type, anthropicErr.Error.Message)}
-			}
-			// Body read okay, but JSON parsing failed or structure mismatch.
-			// Fall through to return status only.
+		body, err = messageEvents(resp.Body)
+		if err != nil {
+			return &Stream{err: err}
 		}
-		// Default fallback: Read error, empty body, or failed/unexpected JSON parse.
-		return &Stream{err: fmt.Errorf("%s", resp.Status)}
 	}
-
-	return &Stream{ctx: ctx, model: m.model, stream: resp.Body}
+	stream := &Stream{ctx: ctx, model: m.model, stream: body}
+	stream.rateLimits, stream.hasRateLimits = llms.ParseRateLimits(resp.Header)
+	return stream
 }
 
 type Stream struct {
@@ -155,7 +382,14 @@ type Stream struct {
 	message  llms.Message
 	lastText string
 
+	stopReason string
+
 	inputTokens, outputTokens int
+	// Anthropic reports cache writes and reads separately from input tokens.
+	cacheWriteTokens, cacheReadTokens int
+
+	rateLimits    llms.RateLimits
+	hasRateLimits bool
 }
 
 func (s *Stream) Err() error {
@@ -166,6 +400,40 @@ func (s *Stream) Message() llms.Message {
 	return s.message
 }
 
+// Model returns the model that served the response, which can be a specific
+// snapshot of the requested model.
+func (s *Stream) Model() string {
+	return s.model
+}
+
+// RateLimits returns the rate limits that Anthropic reported with the
+// response.
+func (s *Stream) RateLimits() (limits llms.RateLimits, ok bool) {
+	return s.rateLimits, s.hasRateLimits
+}
+
+// StopReason returns the stop_reason that Anthropic reported once the stream
+// is done, such as "end_turn", "tool_use" or "max_tokens".
+func (s *Stream) StopReason() string {
+	return s.stopReason
+}
+
+// FinishReason returns the stop reason as one of the llms.FinishReason
+// constants, or as is if there's no matching constant.
+func (s *Stream) FinishReason() llms.FinishReason {
+	switch s.stopReason {
+	case "end_turn", "stop_sequence":
+		return llms.FinishReasonStop
+	case "max_tokens":
+		return llms.FinishReasonLength
+	case "tool_use":
+		return llms.FinishReasonToolCalls
+	case "refusal":
+		return llms.FinishReasonContentFilter
+	}
+	return llms.FinishReason(s.stopReason)
+}
+
 func (s *Stream) Text() string {
 	return s.lastText
 }
@@ -177,8 +445,33 @@ func (s *Stream) ToolCall() llms.ToolCall {
 	return s.message.ToolCalls[len(s.message.ToolCalls)-1]
 }
 
+// Usage returns the tokens of the response, where the input tokens include
+// the ones that were written to or read from the prompt cache.
 func (s *Stream) Usage() (inputTokens, outputTokens int) {
-	return s.inputTokens, s.outputTokens
+	return s.inputTokens + s.cacheWriteTokens + s.cacheReadTokens, s.outputTokens
+}
+
+// UsageDetails returns how many of the input tokens were read from and
+// written to the prompt cache.
+func (s *Stream) UsageDetails() llms.UsageDetails {
+	return llms.UsageDetails{CachedInputTokens: s.cacheReadTokens, CacheWriteInputTokens: s.cacheWriteTokens}
+}
+
+func (s *Stream) addUsage(u *usage) {
+	// The counts of message_delta events are totals, which repeat or replace
+	// the ones of message_start, so they aren't added up.
+	if u.InputTokens > 0 {
+		s.inputTokens = u.InputTokens
+	}
+	if u.OutputTokens > 0 {
+		s.outputTokens = u.OutputTokens
+	}
+	if u.CacheCreationInputTokens > 0 {
+		s.cacheWriteTokens = u.CacheCreationInputTokens
+	}
+	if u.CacheReadInputTokens > 0 {
+		s.cacheReadTokens = u.CacheReadInputTokens
+	}
 }
 
 func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
@@ -187,6 +480,7 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 		defer io.Copy(io.Discard, s.stream)
 		lastToolCallIndex := -1
 		var resetNextArgumentsDelta bool
+		var pendingCitations []*content.Citation
 		// The Anthropic SSE stream follows this pattern:
 		// 1. message_start - contains initial message metadata
 		// 2. For each content block:
@@ -228,9 +522,11 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 			case "message_start":
 				// Initialize the message with the role from the message_start event
 				s.message.Role = event.Message.Role
+				if event.Message.Model != "" {
+					s.model = event.Message.Model
+				}
 				if event.Message.Usage != nil {
-					s.inputTokens += event.Message.Usage.InputTokens
-					s.outputTokens += event.Message.Usage.OutputTokens
+					s.addUsage(event.Message.Usage)
 				}
 			case "content_block_start":
 				// For now, we only need special handling for tool_use and thinking blocks
@@ -246,10 +542,18 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 					if !yield(llms.StreamStatusToolCallBegin) {
 						return
 					}
+				case "text":
+					// Start a new text item for blocks that may be cited, so
+					// that their citations can follow the text they support.
+					if len(event.ContentBlock.Citations) > 0 {
+						s.message.Content = append(s.message.Content, &content.Text{})
+					}
 				case "redacted_thinking":
-					// TODO: We need to track thinking blocks.
+					// Redacted thinking can't be read, but it must be sent
+					// back for the conversation to continue.
+					s.message.Content = append(s.message.Content, &content.Thinking{Redacted: event.ContentBlock.Data})
 				case "thinking":
-					// TODO: We need to track thinking blocks.
+					s.message.Content = append(s.message.Content, &content.Thinking{})
 				}
 			case "content_block_delta":
 				switch event.Delta.Type {
@@ -279,13 +583,29 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 						return
 					}
 				case "thinking_delta":
-					// TODO: We need to track thinking blocks.
-					continue
+					s.lastText = event.Delta.Thinking
+					s.message.Content.AppendThinking(s.lastText)
+					if !yield(llms.StreamStatusThinking) {
+						return
+					}
 				case "signature_delta":
-					// TODO: We need to track thinking blocks.
-					continue
+					if n := len(s.message.Content); n > 0 {
+						if thinking, ok := s.message.Content[n-1].(*content.Thinking); ok {
+							thinking.Signature += event.Delta.Signature
+						}
+					}
+				case "citations_delta":
+					if event.Delta.Citation != nil {
+						pendingCitations = append(pendingCitations, citationToLLM(*event.Delta.Citation))
+					}
 				}
 			case "content_block_stop":
+				// Citations are streamed before the text they support, but
+				// they go after it in the message.
+				for _, c := range pendingCitations {
+					s.message.Content = append(s.message.Content, c)
+				}
+				pendingCitations = nil
 				// Signal the end of a content block
 				// For tool calls, signal that the tool call is ready
 				if event.Index == lastToolCallIndex {
@@ -296,15 +616,10 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 			case "message_delta":
 				// Update usage statistics
 				if event.Delta.Usage != nil {
-					s.inputTokens += event.Delta.Usage.InputTokens
-					s.outputTokens += event.Delta.Usage.OutputTokens
+					s.addUsage(event.Delta.Usage)
 				}
-				// Check stop reason, but allow tool_use and end_turn
-				if event.Delta.StopReason != "" &&
-					event.Delta.StopReason != "tool_use" &&
-					event.Delta.StopReason != "end_turn" {
-					s.err = fmt.Errorf("unexpected stop reason: %q", event.Delta.StopReason)
-					return
+				if event.Delta.StopReason != "" {
+					s.stopReason = event.Delta.StopReason
 				}
 			case "message_stop":
 				// End of the message stream
@@ -315,7 +630,7 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 			case "error":
 				// Handle error events
 				if event.Error != nil {
-					s.err = fmt.Errorf("API error: %s - %s", event.Error.Type, event.Error.Message)
+					s.err = &APIError{Type: event.Error.Type, Message: event.Error.Message}
 					return
 				}
 			default:
@@ -355,7 +670,11 @@ func contentFromLLM(llmContent content.Content) (cl contentList) {
 			if dataValue, found := strings.CutPrefix(v.URL, "data:"); found {
 				mimeType, data, found := strings.Cut(dataValue, ";base64,")
 				if !found {
-					panic(fmt.Sprintf("unsupported data URI format %q", v.URL))
+					// Only base64 encoded images can be sent, so let Claude know
+					// there was one rather than failing the whole request.
+					ci.Type = "text"
+					ci.Text = "[image omitted, since it was not base64 encoded]"
+					break
 				}
 				ci.Source = &source{
 					Type:      "base64",
@@ -363,12 +682,59 @@ func contentFromLLM(llmContent content.Content) (cl contentList) {
 					Data:      data,
 				}
 			} else {
-				// TODO: Download the image URL and turn it into base64.
-				panic("Anthropic does not support URLs for images")
+				ci.Source = &source{
+					Type: "url",
+					URL:  v.URL,
+				}
 			}
 		case *content.JSON:
 			ci.Type = "text"
 			ci.Text = string(v.Data)
+		case *content.Document:
+			ci.Type = "document"
+			switch {
+			case v.URL != "":
+				ci.Source = &source{Type: "url", URL: v.URL}
+			case v.MediaType == "text/plain":
+				ci.Source = &source{Type: "text", MediaType: v.MediaType, Data: v.Data}
+			default:
+				ci.Source = &source{Type: "base64", MediaType: v.MediaType, Data: v.Data}
+			}
+			ci.Title = v.Title
+			ci.Context = v.Context
+			if v.Citations {
+				ci.Citations = citationsConfig{Enabled: true}
+			}
+		case *content.Citation:
+			// Citations belong to the text block they support. Citations of
+			// web pages found by other providers can't be sent back.
+			if len(cl) == 0 || cl[len(cl)-1].Type != "text" || v.URL != "" {
+				continue
+			}
+			last := &cl[len(cl)-1]
+			citations, _ := last.Citations.([]citation)
+			last.Citations = append(citations, citationFromLLM(v))
+			continue
+		case *content.File:
+			// Files are only available to the provider they were uploaded to.
+			continue
+		case *content.Audio:
+			// Claude can't listen to audio, so let it know that there was some.
+			ci.Type = "text"
+			ci.Text = fmt.Sprintf("[%s audio omitted, since this model does not support audio input]", v.Format)
+		case *content.Thinking:
+			if v.Redacted != "" {
+				ci.Type = "redacted_thinking"
+				ci.Data = v.Redacted
+				break
+			}
+			// Only signed thinking blocks can be sent back to Anthropic.
+			if v.Signature == "" {
+				continue
+			}
+			ci.Type = "thinking"
+			ci.Thinking = v.Text
+			ci.Signature = v.Signature
 		default:
 			panic(fmt.Sprintf("unhandled content item type %T", item))
 		}
@@ -402,11 +768,56 @@ func messageFromLLM(m llms.Message) message {
 			})
 		}
 	}
-	if len(m.Content) == 0 {
-		apiContent = []contentItem{{Type: "text", Text: ""}}
-	}
 	return message{
 		Role:    m.Role,
 		Content: apiContent,
 	}
 }
+
+func citationFromLLM(c *content.Citation) citation {
+	apiCitation := citation{
+		CitedText:     c.CitedText,
+		DocumentIndex: c.DocumentIndex,
+		DocumentTitle: c.DocumentTitle,
+	}
+	start, end := c.Start, c.End
+	switch c.Location {
+	case "page":
+		apiCitation.Type = "page_location"
+		apiCitation.StartPageNumber, apiCitation.EndPageNumber = &start, &end
+	case "block":
+		apiCitation.Type = "content_block_location"
+		apiCitation.StartBlockIndex, apiCitation.EndBlockIndex = &start, &end
+	default:
+		apiCitation.Type = "char_location"
+		apiCitation.StartCharIndex, apiCitation.EndCharIndex = &start, &end
+	}
+	return apiCitation
+}
+
+func citationToLLM(c citation) *content.Citation {
+	llmCitation := &content.Citation{
+		CitedText:     c.CitedText,
+		DocumentIndex: c.DocumentIndex,
+		DocumentTitle: c.DocumentTitle,
+	}
+	var start, end *int
+	switch c.Type {
+	case "page_location":
+		llmCitation.Location = "page"
+		start, end = c.StartPageNumber, c.EndPageNumber
+	case "content_block_location":
+		llmCitation.Location = "block"
+		start, end = c.StartBlockIndex, c.EndBlockIndex
+	default:
+		llmCitation.Location = "char"
+		start, end = c.StartCharIndex, c.EndCharIndex
+	}
+	if start != nil {
+		llmCitation.Start = *start
+	}
+	if end != nil {
+		llmCitation.End = *end
+	}
+	return llmCitation
+}
@@ -5,11 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/history"
 	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -132,8 +136,9 @@ func TestAnthropicStreamHandling(t *testing.T) {
 
 		inTokens, outTokens := stream.Usage()
 		assert.Equal(t, 10, inTokens, "Input tokens mismatch")
-		// Note: Output tokens accumulate: 1 (start) + 5 (delta) = 6
-		assert.Equal(t, 6, outTokens, "Output tokens mismatch")
+		// The output tokens of message_delta are the total, replacing the 1 of
+		// message_start.
+		assert.Equal(t, 5, outTokens, "Output tokens mismatch")
 	})
 
 	t.Run("Simple Argument Tool Call (Single Delta)", func(t *testing.T) {
@@ -467,9 +472,162 @@ func TestContentFromLLMEdgeCases(t *testing.T) {
 		assert.Equal(t, jsonValue, apiContent[0].Text, "JSON content should be converted to text")
 	})
 
+	t.Run("Documents and Citations", func(t *testing.T) {
+		llmContent := append(content.FromTextDocument("Notes", "The sky is blue."), content.FromText("It's blue.")...)
+		llmContent = append(llmContent, &content.Citation{CitedText: "The sky is blue.", Location: "char", Start: 0, End: 16})
+		data, err := json.Marshal(contentFromLLM(llmContent))
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"type":"document","source":{"type":"text","media_type":"text/plain","data":"The sky is blue."},"title":"Notes","citations":{"enabled":true}},
+			{"type":"text","text":"It's blue.","citations":[{"type":"char_location","cited_text":"The sky is blue.","document_index":0,"start_char_index":0,"end_char_index":16}]}
+		]`, string(data))
+	})
+
+	t.Run("Image URLs", func(t *testing.T) {
+		llmContent := content.Content{
+			&content.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="},
+			&content.ImageURL{URL: "https://example.com/cat.jpg"},
+			&content.ImageURL{URL: "data:image/svg+xml,%3Csvg%3E%3C/svg%3E"},
+		}
+		data, err := json.Marshal(contentFromLLM(llmContent))
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}},
+			{"type":"image","source":{"type":"url","url":"https://example.com/cat.jpg"}},
+			{"type":"text","text":"[image omitted, since it was not base64 encoded]"}
+		]`, string(data))
+	})
+
+	t.Run("PDF Documents", func(t *testing.T) {
+		llmContent := append(content.FromPDFDocument("Report", []byte("%PDF")), content.FromPDFURL("Paper", "https://example.com/paper.pdf")...)
+		data, err := json.Marshal(contentFromLLM(llmContent))
+		require.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"type":"document","source":{"type":"base64","media_type":"application/pdf","data":"JVBERg=="},"title":"Report","citations":{"enabled":true}},
+			{"type":"document","source":{"type":"url","url":"https://example.com/paper.pdf"},"title":"Paper","citations":{"enabled":true}}
+		]`, string(data))
+	})
+
 	// Add more edge cases for contentFromLLM if needed (e.g., invalid image data URI)
 }
 
+func TestStreamCitations(t *testing.T) {
+	var streamContent strings.Builder
+	for _, event := range []string{
+		`{"type":"message_start","message":{"role":"assistant"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"According to the notes, "}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":"","citations":[]}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"citations_delta","citation":{"type":"page_location","cited_text":"The sky is blue.","document_index":0,"document_title":"Notes","start_page_number":1,"end_page_number":2}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"the sky is blue."}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_stop"}`,
+	} {
+		streamContent.WriteString("data: " + event + "\n\n")
+	}
+	stream := newTestAnthropicStream(context.Background(), "claude-sonnet-4", streamContent.String())
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, content.Content{
+		&content.Text{Text: "According to the notes, "},
+		&content.Text{Text: "the sky is blue."},
+		&content.Citation{CitedText: "The sky is blue.", DocumentIndex: 0, DocumentTitle: "Notes", Location: "page", Start: 1, End: 2},
+	}, stream.Message().Content)
+}
+
+func TestStreamStopReason(t *testing.T) {
+	for _, tt := range []struct {
+		stopReason string
+		want       llms.FinishReason
+	}{
+		{"end_turn", llms.FinishReasonStop},
+		{"stop_sequence", llms.FinishReasonStop},
+		{"max_tokens", llms.FinishReasonLength},
+		{"tool_use", llms.FinishReasonToolCalls},
+		{"refusal", llms.FinishReasonContentFilter},
+		{"pause_turn", "pause_turn"},
+	} {
+		t.Run(tt.stopReason, func(t *testing.T) {
+			var streamContent strings.Builder
+			streamContent.WriteString(sseEvent(streamEvent{Type: "content_block_delta", Delta: delta{Type: "text_delta", Text: "Once upon a"}}))
+			streamContent.WriteString(sseEvent(streamEvent{Type: "message_delta", Delta: delta{StopReason: tt.stopReason}}))
+			streamContent.WriteString(sseEvent(streamEvent{Type: "message_stop"}))
+			stream := newTestAnthropicStream(context.Background(), "claude-sonnet-4", streamContent.String())
+			for range stream.Iter() {
+			}
+			require.NoError(t, stream.Err(), "The stop reason should not fail the stream")
+			assert.Equal(t, tt.stopReason, stream.StopReason())
+			assert.Equal(t, tt.want, stream.FinishReason())
+			assert.Equal(t, content.FromText("Once upon a"), stream.Message().Content)
+		})
+	}
+}
+
+func TestStreamCacheUsage(t *testing.T) {
+	var streamContent strings.Builder
+	streamContent.WriteString(sseEvent(streamEvent{Type: "message_start", Message: &messageEvent{
+		Role:  "assistant",
+		Usage: &usage{InputTokens: 20, OutputTokens: 1, CacheCreationInputTokens: 1500, CacheReadInputTokens: 3000},
+	}}))
+	streamContent.WriteString(sseEvent(streamEvent{Type: "message_delta", Delta: delta{
+		StopReason: "end_turn",
+		Usage:      &usage{InputTokens: 20, OutputTokens: 50, CacheCreationInputTokens: 1500, CacheReadInputTokens: 3000},
+	}}))
+	streamContent.WriteString(sseEvent(streamEvent{Type: "message_stop"}))
+	stream := newTestAnthropicStream(context.Background(), "claude-sonnet-4", streamContent.String())
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+	inputTokens, outputTokens := stream.Usage()
+	assert.Equal(t, 4520, inputTokens, "Input tokens should include cache writes and reads, counted once")
+	assert.Equal(t, 50, outputTokens, "The totals of message_delta shouldn't be added to those of message_start")
+	assert.Equal(t, llms.UsageDetails{CachedInputTokens: 3000, CacheWriteInputTokens: 1500}, stream.UsageDetails())
+}
+
+func TestStreamThinking(t *testing.T) {
+	var streamContent strings.Builder
+	for _, event := range []string{
+		`{"type":"message_start","message":{"role":"assistant"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"think."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"EqQBCgIYAh"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"EmwKAhgBEgy3va3pzix"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Done."}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"}}`,
+		`{"type":"message_stop"}`,
+	} {
+		streamContent.WriteString("data: " + event + "\n\n")
+	}
+	stream := newTestAnthropicStream(context.Background(), "claude-sonnet-4", streamContent.String())
+	var statuses []llms.StreamStatus
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []llms.StreamStatus{llms.StreamStatusThinking, llms.StreamStatusThinking, llms.StreamStatusText}, statuses)
+	msg := stream.Message()
+	assert.Equal(t, content.Content{
+		&content.Thinking{Text: "Let me think.", Signature: "EqQBCgIYAh"},
+		&content.Thinking{Redacted: "EmwKAhgBEgy3va3pzix"},
+		&content.Text{Text: "Done."},
+	}, msg.Content)
+
+	data, err := json.Marshal(messageFromLLM(msg))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"assistant","content":[
+		{"type":"thinking","thinking":"Let me think.","signature":"EqQBCgIYAh"},
+		{"type":"redacted_thinking","data":"EmwKAhgBEgy3va3pzix"},
+		{"type":"text","text":"Done."}
+	]}`, string(data))
+}
+
 func TestMessageFromLLMEdgeCases(t *testing.T) {
 	t.Run("Assistant Message No Tool Calls", func(t *testing.T) {
 		llmMsg := llms.Message{
@@ -526,6 +684,31 @@ func TestMessageFromLLMEdgeCases(t *testing.T) {
 		assert.Equal(t, jsonResult, innerContentItem.Text)
 	})
 
+	t.Run("Tool Message With Images", func(t *testing.T) {
+		llmMsg := llms.Message{
+			Role:       "tool",
+			ToolCallID: "toolu_image_789",
+			Content: content.Content{
+				&content.Text{Text: "Screenshot taken"},
+				&content.ImageURL{URL: "data:image/png;base64,iVBORw0KGgo="},
+				&content.ImageURL{URL: "https://example.com/chart.png"},
+			},
+		}
+		apiMsg := messageFromLLM(llmMsg)
+		assert.Equal(t, "user", apiMsg.Role)
+		// Images belong in the tool_result block itself, not in a separate message.
+		require.Len(t, apiMsg.Content, 1)
+		toolResultItem := apiMsg.Content[0]
+		assert.Equal(t, "tool_result", toolResultItem.Type)
+		assert.Equal(t, "toolu_image_789", toolResultItem.ToolUseID)
+		require.Len(t, toolResultItem.Content, 3)
+		assert.Equal(t, "text", toolResultItem.Content[0].Type)
+		assert.Equal(t, "image", toolResultItem.Content[1].Type)
+		assert.Equal(t, &source{Type: "base64", MediaType: "image/png", Data: "iVBORw0KGgo="}, toolResultItem.Content[1].Source)
+		assert.Equal(t, "image", toolResultItem.Content[2].Type)
+		assert.Equal(t, &source{Type: "url", URL: "https://example.com/chart.png"}, toolResultItem.Content[2].Source)
+	})
+
 	t.Run("Assistant Message With Tool Calls", func(t *testing.T) {
 		llmMsg := llms.Message{
 			Role:    "assistant",
@@ -559,3 +742,357 @@ func TestMessageFromLLMEdgeCases(t *testing.T) {
 		assert.JSONEq(t, `{}`, string(apiMsg.Content[2].Input))
 	})
 }
+
+func TestGenerateEmptyContent(t *testing.T) {
+	var body struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	messages := []llms.Message{
+		{Role: "user", Content: content.FromText("Hi")},
+		{Role: "assistant", Content: content.FromText("")},
+		{Role: "user", Content: content.FromText("Hello?")},
+	}
+	generate := func(m *Model) {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, messages, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+		require.NoError(t, stream.Err())
+	}
+
+	generate(New("key", "claude-3-7-sonnet-latest"))
+	require.Len(t, body.Messages, 2, "The empty message should be left out")
+	assert.Equal(t, "Hi", body.Messages[0]["content"])
+	assert.Equal(t, "Hello?", body.Messages[1]["content"])
+
+	generate(New("key", "claude-3-7-sonnet-latest").WithEmptyContentPlaceholder("(no response)"))
+	require.Len(t, body.Messages, 3)
+	assert.Equal(t, map[string]any{"role": "assistant", "content": "(no response)"}, body.Messages[1])
+}
+
+func TestGeneratePrefill(t *testing.T) {
+	var body struct {
+		Messages []map[string]any `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "content_block_delta", Delta: delta{Type: "text_delta", Text: `"a": 1}`}}))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	generate := func(messages []llms.Message) llms.ProviderStream {
+		stream := New("key", "claude-3-7-sonnet-latest").WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, messages, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+		require.NoError(t, stream.Err())
+		return stream
+	}
+
+	stream := generate([]llms.Message{
+		{Role: "user", Content: content.FromText("Reply with JSON")},
+		{Role: "assistant", Content: content.FromText("{\n ")},
+	})
+	require.Len(t, body.Messages, 2)
+	assert.Equal(t, map[string]any{"role": "assistant", "content": "{"}, body.Messages[1], "Trailing whitespace should be trimmed")
+	assert.Equal(t, content.FromText(`"a": 1}`), stream.Message().Content, "The message should only contain the continuation")
+
+	generate([]llms.Message{
+		{Role: "user", Content: content.FromText("Hi")},
+		{Role: "assistant", Content: content.FromText(" ")},
+	})
+	require.Len(t, body.Messages, 1, "A prefill of only whitespace should be left out")
+
+	generate([]llms.Message{
+		{Role: "assistant", Content: content.FromText("Hello ")},
+		{Role: "user", Content: content.FromText("Hi")},
+	})
+	assert.Equal(t, "Hello ", body.Messages[0]["content"], "Only a trailing assistant message is a prefill")
+}
+
+func TestChatPrefillHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_start", Message: &messageEvent{Role: "assistant"}}))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "content_block_delta", Delta: delta{Type: "text_delta", Text: `"a": 1}`}}))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	store := history.NewMemoryStore()
+	llm := llms.New(New("key", "claude-3-7-sonnet-latest").WithEndpoint(server.URL, "Anthropic")).
+		WithHistoryStore(store, "conversation").
+		WithHistoryValidation(llms.ValidationReport)
+	for range llm.ChatUsingMessages(context.Background(), []llms.Message{
+		{Role: "user", Content: content.FromText("Reply with JSON")},
+		{Role: "assistant", Content: content.FromText("{")},
+	}) {
+	}
+	require.NoError(t, llm.Err())
+
+	messages, err := store.Load(context.Background(), "conversation")
+	require.NoError(t, err)
+	require.NoError(t, llms.ValidateMessages(messages), "The continuation should be merged into the prefill")
+	require.Len(t, messages, 2)
+	assert.Equal(t, content.FromText(`{"a": 1}`), messages[1].Content)
+
+	// The next chat loads and validates the history.
+	for range llm.Chat("Another one") {
+	}
+	require.NoError(t, llm.Err())
+}
+
+func TestGenerateParallelToolUse(t *testing.T) {
+	var body struct {
+		ToolChoice map[string]any `json:"tool_choice"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	toolbox := tools.Box(tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p struct{}) tools.Result {
+		return tools.Success(nil)
+	}))
+	generate := func(m *Model) {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, toolbox)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(New("key", "claude-3-7-sonnet-latest"))
+	assert.Equal(t, map[string]any{"type": "auto"}, body.ToolChoice)
+	generate(New("key", "claude-3-7-sonnet-latest").WithParallelToolUse(false))
+	assert.Equal(t, map[string]any{"type": "auto", "disable_parallel_tool_use": true}, body.ToolChoice)
+}
+
+func TestGenerateToolChoice(t *testing.T) {
+	var body struct {
+		ToolChoice map[string]any `json:"tool_choice"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body.ToolChoice = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	toolbox := tools.Box(tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p struct{}) tools.Result {
+		return tools.Success(nil)
+	}))
+	generate := func(m *Model, messages ...llms.Message) map[string]any {
+		messages = append([]llms.Message{{Role: "user", Content: content.FromText("Hi")}}, messages...)
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, messages, toolbox)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+		return body.ToolChoice
+	}
+
+	assert.Equal(t, map[string]any{"type": "any"}, generate(New("key", "claude-3-7-sonnet-latest").WithToolChoice("required")))
+	assert.Equal(t, map[string]any{"type": "none"}, generate(New("key", "claude-3-7-sonnet-latest").WithToolChoice("none").WithParallelToolUse(false)))
+	m := New("key", "claude-3-7-sonnet-latest").WithToolChoice("lookup").WithParallelToolUse(false)
+	assert.Equal(t, map[string]any{"type": "tool", "name": "lookup", "disable_parallel_tool_use": true}, generate(m))
+	assert.Equal(t, map[string]any{"type": "auto", "disable_parallel_tool_use": true}, generate(m,
+		llms.Message{Role: "assistant", ToolCalls: []llms.ToolCall{{ID: "toolu_1", Name: "lookup", Arguments: json.RawMessage(`{}`)}}},
+		llms.Message{Role: "tool", ToolCallID: "toolu_1", Content: content.FromText("Found it")},
+	), "The model should be free to respond after the tool has run")
+}
+
+func TestGenerateMaxTokens(t *testing.T) {
+	var body struct {
+		MaxTokens int `json:"max_tokens"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	generate := func(m *Model) int {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+		return body.MaxTokens
+	}
+
+	assert.Equal(t, 64000, generate(New("key", "claude-3-7-sonnet-latest")))
+	assert.Equal(t, 32000, generate(New("key", "claude-opus-4-20250514")))
+	assert.Equal(t, 8192, generate(New("key", "anthropic.claude-3-5-haiku-20241022-v1:0")))
+	assert.Equal(t, 4096, generate(New("key", "claude-3-haiku-20240307")))
+	assert.Equal(t, 1000, generate(New("key", "claude-3-7-sonnet-latest").WithMaxTokens(1000)))
+	assert.Equal(t, 128000, generate(New("key", "claude-3-7-sonnet-latest").WithBeta("output-128k-2025-02-19")))
+	assert.Equal(t, 100000, generate(New("key", "claude-3-7-sonnet-latest").WithBeta("output-128k-2025-02-19").WithMaxTokens(100000)))
+	assert.Equal(t, 64000, generate(New("key", "claude-sonnet-4-20250514").WithThinking(10000)), "The default should make room for thinking")
+	assert.Equal(t, 14096, generate(New("key", "claude-future").WithThinking(10000)))
+	assert.Equal(t, 11000, generate(New("key", "claude-sonnet-4-20250514").WithMaxTokens(1000).WithThinking(10000)))
+
+	for _, tt := range []struct {
+		model *Model
+		err   string
+	}{
+		{New("key", "claude-opus-4-20250514").WithMaxTokens(64000), "max tokens of 64000 exceeds the limit of 32000 output tokens for claude-opus-4-20250514"},
+		{New("key", "claude-3-7-sonnet-latest").WithMaxTokens(100000), `max tokens of 100000 exceeds the limit of 64000 output tokens for claude-3-7-sonnet-latest (the "output-128k-2025-02-19" beta raises it to 128000)`},
+		{New("key", "claude-sonnet-4-20250514").WithMaxTokens(60000).WithThinking(10000), "max tokens of 60000, plus a thinking budget of 10000, exceeds the limit of 64000 output tokens for claude-sonnet-4-20250514"},
+		{New("key", "claude-3-5-haiku-latest").WithThinking(10000), "thinking budget of 10000 tokens leaves no room for a response within the limit of 8192 output tokens for claude-3-5-haiku-latest"},
+	} {
+		stream := tt.model.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		assert.EqualError(t, stream.Err(), tt.err)
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages/count_tokens", r.URL.Path)
+		assert.Equal(t, "key", r.Header.Get("x-api-key"))
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["model"] == "claude-missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"not_found_error","message":"model: claude-missing"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"input_tokens":42}`)
+	}))
+	defer server.Close()
+
+	toolbox := tools.Box(tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p struct{}) tools.Result {
+		return tools.Success(nil)
+	}))
+	messages := []llms.Message{{Role: "user", Content: content.FromText("Hi")}}
+	m := New("key", "claude-3-7-sonnet-latest").WithEndpoint(server.URL+"/v1/messages", "Anthropic")
+	tokens, err := m.CountTokens(context.Background(), content.FromText("Be brief."), messages, toolbox)
+	require.NoError(t, err)
+	assert.Equal(t, 42, tokens)
+	assert.Contains(t, body, "system")
+	assert.Contains(t, body, "tools")
+	assert.NotContains(t, body, "max_tokens")
+	assert.NotContains(t, body, "stream")
+
+	m = New("key", "claude-missing").WithEndpoint(server.URL+"/v1/messages", "Anthropic")
+	_, err = m.CountTokens(context.Background(), nil, messages, nil)
+	assert.EqualError(t, err, "404 Not Found: not_found_error: model: claude-missing")
+
+	_, err = New("", "claude-3-7-sonnet@20250219").WithVertexAI("token", "project", "us-east5").CountTokens(context.Background(), nil, messages, nil)
+	assert.Error(t, err)
+}
+
+func TestGenerateSamplingParameters(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	generate := func(m *Model) {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(New("key", "claude-3-7-sonnet-latest"))
+	assert.NotContains(t, body, "temperature")
+	assert.NotContains(t, body, "top_p")
+	assert.NotContains(t, body, "top_k")
+
+	generate(New("key", "claude-3-7-sonnet-latest").WithTemperature(0).WithTopP(0.9).WithTopK(40))
+	assert.Equal(t, 0.0, body["temperature"])
+	assert.Equal(t, 0.9, body["top_p"])
+	assert.Equal(t, 40.0, body["top_k"])
+}
+
+func TestGenerateBeta(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	generate := func(m *Model) {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(New("key", "claude-sonnet-4-20250514"))
+	assert.Empty(t, header.Get("anthropic-beta"))
+	generate(New("key", "claude-sonnet-4-20250514").WithBeta("context-1m-2025-08-07").WithBeta("files-api-2025-04-14"))
+	assert.Equal(t, "context-1m-2025-08-07,files-api-2025-04-14", header.Get("anthropic-beta"))
+}
+
+func TestGenerateUserID(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	generate := func(ctx context.Context, m *Model) {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(ctx, nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(context.Background(), New("key", "claude-3-7-sonnet-latest"))
+	assert.NotContains(t, body, "metadata")
+
+	m := New("key", "claude-3-7-sonnet-latest").WithUserID("tenant-1")
+	generate(context.Background(), m)
+	assert.Equal(t, map[string]any{"user_id": "tenant-1"}, body["metadata"])
+
+	generate(ContextWithUserID(context.Background(), "user-42"), m)
+	assert.Equal(t, map[string]any{"user_id": "user-42"}, body["metadata"])
+}
+
+// roundTripFunc lets a function be used as an HTTP transport.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestGenerateHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	newClient := func(requests *int) *http.Client {
+		return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			*requests++
+			return http.DefaultTransport.RoundTrip(req)
+		})}
+	}
+	var modelRequests, contextRequests int
+	generate := func(ctx context.Context, m *Model) {
+		stream := m.WithEndpoint(server.URL, "Anthropic").Generate(ctx, nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+	ctx := llms.ContextWithHTTPClient(context.Background(), newClient(&contextRequests))
+
+	generate(ctx, New("key", "claude-sonnet-4-0"))
+	assert.Equal(t, 1, contextRequests)
+	generate(ctx, New("key", "claude-sonnet-4-0").WithHTTPClient(newClient(&modelRequests)))
+	assert.Equal(t, 1, modelRequests, "The client of the model should take precedence")
+	assert.Equal(t, 1, contextRequests)
+}
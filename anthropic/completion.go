@@ -0,0 +1,86 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// messageResponse is a response to a messages request that wasn't streamed.
+type messageResponse struct {
+	messageEvent
+	Content []struct {
+		Type      string          `json:"type"`
+		Text      string          `json:"text"`
+		Citations []citation      `json:"citations"`
+		ID        string          `json:"id"`
+		Name      string          `json:"name"`
+		Input     json.RawMessage `json:"input"`
+		Thinking  string          `json:"thinking"`
+		Signature string          `json:"signature"`
+		Data      string          `json:"data"`
+	} `json:"content"`
+	StopReason   string `json:"stop_reason"`
+	StopSequence string `json:"stop_sequence"`
+}
+
+// messageEvents reads a response that wasn't streamed and returns it as the
+// server-sent events that would have streamed it, so that it is processed like
+// a streamed response.
+func messageEvents(r io.Reader) (io.Reader, error) {
+	var response messageResponse
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	var events bytes.Buffer
+	write := func(event streamEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("error encoding JSON: %w", err)
+		}
+		fmt.Fprintf(&events, "event: %s\ndata: %s\n\n", event.Type, data)
+		return nil
+	}
+	if err := write(streamEvent{Type: "message_start", Message: &response.messageEvent}); err != nil {
+		return nil, err
+	}
+	for i, block := range response.Content {
+		start := &contentBlock{Type: block.Type, ID: block.ID, Name: block.Name, Input: block.Input, Data: block.Data}
+		var deltas []delta
+		switch block.Type {
+		case "text":
+			if block.Citations != nil {
+				start.Citations = json.RawMessage("[]")
+			}
+			for _, c := range block.Citations {
+				deltas = append(deltas, delta{Type: "citations_delta", Citation: &c})
+			}
+			deltas = append(deltas, delta{Type: "text_delta", Text: block.Text})
+		case "thinking":
+			deltas = append(deltas, delta{Type: "thinking_delta", Thinking: block.Thinking})
+			if block.Signature != "" {
+				deltas = append(deltas, delta{Type: "signature_delta", Signature: block.Signature})
+			}
+		}
+		if err := write(streamEvent{Type: "content_block_start", Index: i, ContentBlock: start}); err != nil {
+			return nil, err
+		}
+		for _, d := range deltas {
+			if err := write(streamEvent{Type: "content_block_delta", Index: i, Delta: d}); err != nil {
+				return nil, err
+			}
+		}
+		if err := write(streamEvent{Type: "content_block_stop", Index: i}); err != nil {
+			return nil, err
+		}
+	}
+	// The usage was reported with message_start.
+	if err := write(streamEvent{Type: "message_delta", Delta: delta{StopReason: response.StopReason, StopSequence: response.StopSequence}}); err != nil {
+		return nil, err
+	}
+	if err := write(streamEvent{Type: "message_stop"}); err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
@@ -0,0 +1,71 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWithoutStreaming(t *testing.T) {
+	var payload map[string]any
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		header = r.Header
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "msg_1",
+			"type": "message",
+			"role": "assistant",
+			"model": "claude-sonnet-4-20250514",
+			"content": [
+				{"type": "thinking", "thinking": "The notes say so.", "signature": "EqQBCgIYAh"},
+				{"type": "text", "text": "The sky is blue.", "citations": [{"type": "char_location", "cited_text": "The sky is blue.", "document_index": 0, "start_char_index": 0, "end_char_index": 16}]},
+				{"type": "tool_use", "id": "toolu_1", "name": "lookup", "input": {"q": "sky"}}
+			],
+			"stop_reason": "tool_use",
+			"usage": {"input_tokens": 12, "output_tokens": 30, "cache_read_input_tokens": 100}
+		}`))
+	}))
+	defer server.Close()
+
+	m := New("key", "claude-sonnet-4-0").WithEndpoint(server.URL, "Anthropic").WithStreaming(false)
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil).(*Stream)
+	var statuses []llms.StreamStatus
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, false, payload["stream"])
+	assert.Equal(t, "application/json", header.Get("Accept"))
+	assert.Equal(t, []llms.StreamStatus{
+		llms.StreamStatusThinking,
+		llms.StreamStatusText,
+		llms.StreamStatusToolCallBegin,
+		llms.StreamStatusToolCallReady,
+	}, statuses)
+
+	msg := stream.Message()
+	assert.Equal(t, "assistant", msg.Role)
+	start, end := 0, 16
+	assert.Equal(t, content.Content{
+		&content.Thinking{Text: "The notes say so.", Signature: "EqQBCgIYAh"},
+		&content.Text{Text: "The sky is blue."},
+		citationToLLM(citation{Type: "char_location", CitedText: "The sky is blue.", StartCharIndex: &start, EndCharIndex: &end}),
+	}, msg.Content)
+	require.Len(t, msg.ToolCalls, 1)
+	assert.Equal(t, llms.ToolCall{ID: "toolu_1", Name: "lookup", Arguments: json.RawMessage(`{"q":"sky"}`)}, msg.ToolCalls[0])
+	assert.Equal(t, "claude-sonnet-4-20250514", stream.Model())
+	assert.Equal(t, llms.FinishReasonToolCalls, stream.FinishReason())
+	inputTokens, outputTokens := stream.Usage()
+	assert.Equal(t, 112, inputTokens)
+	assert.Equal(t, 30, outputTokens)
+}
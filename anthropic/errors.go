@@ -0,0 +1,108 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// APIError is returned for requests that the API responded to with an error,
+// either with an error status or with an error event in the middle of the
+// stream. Use errors.As to get it from the error of a stream.
+type APIError struct {
+	// StatusCode and Status are the HTTP status of the response, such as 429
+	// and "429 Too Many Requests". They're empty for errors sent in the
+	// stream.
+	StatusCode int
+	Status     string
+	// Type is the type of the error, such as "overloaded_error",
+	// "rate_limit_error" or "invalid_request_error", if the API said.
+	Type    string
+	Message string
+	// RequestID identifies the request, for reports to Anthropic.
+	RequestID string
+	// RetryAfter is how long the API asked to wait before trying again, or 0
+	// if it didn't say.
+	RetryAfter time.Duration
+	// RateLimits are the rate limits reported with the response, if
+	// HasRateLimits is true.
+	RateLimits    llms.RateLimits
+	HasRateLimits bool
+}
+
+// HTTPStatus returns the status code of the response, which is 0 for errors
+// in the middle of a stream, so that APIError implements llms.HTTPError.
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// RetryDelay returns RetryAfter, so that APIError implements llms.HTTPError.
+func (e *APIError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.StatusCode == 0:
+		return fmt.Sprintf("API error: %s - %s", e.Type, e.Message)
+	case e.Message == "":
+		return e.Status
+	case e.Type == "":
+		return fmt.Sprintf("%s: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Status, e.Type, e.Message)
+}
+
+// Retryable reports whether the request may succeed if it's sent again
+// later, because the API was overloaded, rate limited or had an internal
+// error.
+func (e *APIError) Retryable() bool {
+	switch e.Type {
+	case "overloaded_error", "rate_limit_error", "api_error":
+		return true
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// newAPIError reads the error from a response with an error status, in the
+// format of the Anthropic API or of AWS Bedrock.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RequestID:  resp.Header.Get("Request-Id"),
+	}
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds * float64(time.Second))
+	}
+	apiErr.RateLimits, apiErr.HasRateLimits = llms.ParseRateLimits(resp.Header)
+	data, err := io.ReadAll(resp.Body)
+	if err != nil || len(data) == 0 {
+		return apiErr
+	}
+	var body struct {
+		Type      string    `json:"type"`
+		Error     errorInfo `json:"error"`
+		RequestID string    `json:"request_id"`
+		// Bedrock uses its own error format.
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &body) != nil {
+		return apiErr
+	}
+	if body.Type == "error" {
+		apiErr.Type = body.Error.Type
+		apiErr.Message = body.Error.Message
+	} else {
+		apiErr.Message = body.Message
+	}
+	if body.RequestID != "" {
+		apiErr.RequestID = body.RequestID
+	}
+	return apiErr
+}
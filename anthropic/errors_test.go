@@ -0,0 +1,74 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAPIError(t *testing.T) {
+	var status int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Request-Id", "req_header")
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	generate := func() error {
+		m := New("key", "claude-3-7-sonnet-latest").WithEndpoint(server.URL, "Anthropic")
+		return m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil).Err()
+	}
+
+	status, body = 529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"},"request_id":"req_body"}`
+	err := generate()
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, 529, apiErr.StatusCode)
+	assert.Equal(t, "overloaded_error", apiErr.Type)
+	assert.Equal(t, "Overloaded", apiErr.Message)
+	assert.Equal(t, "req_body", apiErr.RequestID)
+	assert.Equal(t, 7*time.Second, apiErr.RetryAfter)
+	assert.True(t, apiErr.Retryable())
+
+	status, body = http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: too large"}}`
+	err = generate()
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "400 Bad Request: invalid_request_error: max_tokens: too large", err.Error())
+	assert.Equal(t, "req_header", apiErr.RequestID)
+	assert.False(t, apiErr.Retryable())
+
+	status, body = http.StatusTooManyRequests, `{"message":"Too many requests, please wait before trying again."}`
+	err = generate()
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "429 Too Many Requests: Too many requests, please wait before trying again.", err.Error())
+	assert.True(t, apiErr.Retryable())
+	assert.True(t, llms.IsRateLimitError(err))
+}
+
+func TestStreamAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`+"\n\n")
+	}))
+	defer server.Close()
+
+	m := New("key", "claude-3-7-sonnet-latest").WithEndpoint(server.URL, "Anthropic")
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	var apiErr *APIError
+	require.ErrorAs(t, stream.Err(), &apiErr)
+	assert.Equal(t, "API error: overloaded_error - Overloaded", apiErr.Error())
+	assert.True(t, apiErr.Retryable())
+}
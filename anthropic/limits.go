@@ -0,0 +1,85 @@
+package anthropic
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// outputTokenLimits are the most tokens that Claude models can produce in a
+// response, by a part of their name that is also found in the names used by
+// Vertex AI and AWS Bedrock. The first match wins.
+var outputTokenLimits = []struct {
+	name  string
+	limit int
+}{
+	{"claude-opus-4", 32000},
+	{"claude-sonnet-4", 64000},
+	{"claude-3-7-sonnet", 64000},
+	{"claude-3-5-", 8192},
+	{"claude-3-opus", 4096},
+	{"claude-3-sonnet", 4096},
+	{"claude-3-haiku", 4096},
+}
+
+// output128kBeta is the beta feature that lets Claude 3.7 Sonnet produce up
+// to 128,000 tokens in a response.
+const output128kBeta = "output-128k-2025-02-19"
+
+// DefaultMaxTokens returns the most tokens that the model can produce in a
+// response, which is the default for WithMaxTokens. Unknown models get 4096
+// tokens.
+func DefaultMaxTokens(model string) int {
+	if limit, ok := outputTokenLimit(model); ok {
+		return limit
+	}
+	return 4096
+}
+
+func outputTokenLimit(model string) (limit int, ok bool) {
+	for _, l := range outputTokenLimits {
+		if strings.Contains(model, l.name) {
+			return l.limit, true
+		}
+	}
+	return 0, false
+}
+
+// outputTokenLimit returns the output token limit of the model, taking beta
+// features that raise it into account.
+func (m *Model) outputTokenLimit() (limit int, ok bool) {
+	if strings.Contains(m.model, "claude-3-7-sonnet") && slices.Contains(m.betas, output128kBeta) {
+		return 128000, true
+	}
+	return outputTokenLimit(m.model)
+}
+
+// requestMaxTokens returns the max_tokens of a request, which includes the
+// thinking budget on top of the max tokens of the response. The default max
+// tokens of known models are reduced to make room for the thinking budget,
+// but max tokens set with WithMaxTokens are an error if they don't fit.
+func (m *Model) requestMaxTokens() (int, error) {
+	limit, ok := m.outputTokenLimit()
+	switch {
+	case m.maxTokens > 0:
+		// Checked below.
+	case !ok:
+		return DefaultMaxTokens(m.model) + m.maxThinkingTokens, nil
+	case m.maxThinkingTokens >= limit:
+		return 0, fmt.Errorf("thinking budget of %d tokens leaves no room for a response within the limit of %d output tokens for %s", m.maxThinkingTokens, limit, m.model)
+	default:
+		return limit, nil
+	}
+	maxTokens := m.maxTokens + m.maxThinkingTokens
+	if ok && maxTokens > limit {
+		err := fmt.Errorf("max tokens of %d exceeds the limit of %d output tokens for %s", maxTokens, limit, m.model)
+		if m.maxThinkingTokens > 0 {
+			err = fmt.Errorf("max tokens of %d, plus a thinking budget of %d, exceeds the limit of %d output tokens for %s", m.maxTokens, m.maxThinkingTokens, limit, m.model)
+		}
+		if strings.Contains(m.model, "claude-3-7-sonnet") && limit < 128000 {
+			err = fmt.Errorf("%w (the %q beta raises it to 128000)", err, output128kBeta)
+		}
+		return 0, err
+	}
+	return maxTokens, nil
+}
@@ -0,0 +1,324 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type platform int
+
+const (
+	platformAnthropic platform = iota
+	platformVertexAI
+	platformBedrock
+)
+
+// WithVertexAI makes the model use Claude on Google Cloud Vertex AI. The model
+// name should be a Vertex AI model ID, such as "claude-3-7-sonnet@20250219".
+func (m *Model) WithVertexAI(accessToken, projectID, region string) *Model {
+	m.platform = platformVertexAI
+	m.accessToken = accessToken
+	m.endpoint = fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:streamRawPredict", region, projectID, region, m.model)
+	return m
+}
+
+// WithBedrock makes the model use Claude on AWS Bedrock, signing requests with
+// the given AWS credentials. The session token is only needed for temporary
+// credentials. The model name should be a Bedrock model ID, such as
+// "anthropic.claude-3-7-sonnet-20250219-v1:0".
+func (m *Model) WithBedrock(region, accessKeyID, secretAccessKey, sessionToken string) *Model {
+	m.platform = platformBedrock
+	m.region = region
+	m.awsAccessKeyID = accessKeyID
+	m.awsSecretAccessKey = secretAccessKey
+	m.awsSessionToken = sessionToken
+	m.endpoint = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/invoke-with-response-stream", region, awsURIEncode(m.model, true))
+	return m
+}
+
+// preparePayload adapts the request body to the platform. Vertex AI and
+// Bedrock take the model from the URL and the API version from the body.
+func (m *Model) preparePayload(payload map[string]any) {
+	switch m.platform {
+	case platformVertexAI:
+		delete(payload, "model")
+		payload["anthropic_version"] = "vertex-2023-10-16"
+	case platformBedrock:
+		delete(payload, "model")
+		delete(payload, "stream")
+		payload["anthropic_version"] = "bedrock-2023-05-31"
+		// Bedrock takes beta features from the body instead of a header.
+		if len(m.betas) > 0 {
+			payload["anthropic_beta"] = m.betas
+		}
+	}
+}
+
+// messagesEndpoint returns the endpoint that messages are sent to, which on
+// cloud platforms depends on whether the response is streamed.
+func (m *Model) messagesEndpoint() string {
+	if !m.noStreaming {
+		return m.endpoint
+	}
+	switch m.platform {
+	case platformVertexAI:
+		if endpoint, ok := strings.CutSuffix(m.endpoint, ":streamRawPredict"); ok {
+			return endpoint + ":rawPredict"
+		}
+	case platformBedrock:
+		if endpoint, ok := strings.CutSuffix(m.endpoint, "/invoke-with-response-stream"); ok {
+			return endpoint + "/invoke"
+		}
+	}
+	return m.endpoint
+}
+
+// authorize sets the headers that authenticate the request on the platform,
+// along with the beta features it uses.
+func (m *Model) authorize(req *http.Request, body []byte) {
+	if len(m.betas) > 0 && m.platform != platformBedrock {
+		req.Header.Set("anthropic-beta", strings.Join(m.betas, ","))
+	}
+	switch m.platform {
+	case platformVertexAI:
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.accessToken))
+	case platformBedrock:
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+		signAWSv4(req, body, m.region, "bedrock", m.awsAccessKeyID, m.awsSecretAccessKey, m.awsSessionToken, time.Now())
+	default:
+		req.Header.Set("X-API-Key", m.apiKey)
+		req.Header.Set("anthropic-version", "2023-06-01")
+	}
+}
+
+// responseStream returns the response body as a stream of SSE lines.
+func (m *Model) responseStream(body io.Reader) io.Reader {
+	if m.platform == platformBedrock {
+		return newEventStreamReader(body)
+	}
+	return body
+}
+
+// signAWSv4 signs the request using AWS Signature Version 4.
+func signAWSv4(req *http.Request, body []byte, region, service, accessKeyID, secretAccessKey, sessionToken string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		key = strings.ToLower(key)
+		if key == "content-type" || strings.HasPrefix(key, "x-amz-") {
+			headers[key] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		// Services other than S3 expect the already escaped path to be
+		// escaped once more.
+		awsURIEncode(req.URL.EscapedPath(), false),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", amzDate, scope, hex.EncodeToString(canonicalHash[:]))
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsURIEncode escapes everything except unreserved characters the way AWS
+// expects it. Slashes are only escaped if encodeSlash is true.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// eventStreamReader decodes the AWS event stream encoding used by Bedrock and
+// re-encodes the events as the SSE lines the Anthropic API would have sent, so
+// the regular stream parsing can be used.
+type eventStreamReader struct {
+	r   *bufio.Reader
+	buf bytes.Buffer
+	err error
+}
+
+func newEventStreamReader(r io.Reader) *eventStreamReader {
+	return &eventStreamReader{r: bufio.NewReader(r)}
+}
+
+func (e *eventStreamReader) Read(p []byte) (int, error) {
+	for e.buf.Len() == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		e.err = e.readMessage()
+	}
+	return e.buf.Read(p)
+}
+
+func (e *eventStreamReader) readMessage() error {
+	var prelude [12]byte
+	if _, err := io.ReadFull(e.r, prelude[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("truncated event stream message: %w", err)
+		}
+		return err
+	}
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return errors.New("event stream prelude checksum mismatch")
+	}
+	if totalLen < 16+headersLen {
+		return errors.New("invalid event stream message length")
+	}
+	rest := make([]byte, totalLen-12)
+	if _, err := io.ReadFull(e.r, rest); err != nil {
+		return fmt.Errorf("truncated event stream message: %w", err)
+	}
+	crc := crc32.NewIEEE()
+	crc.Write(prelude[:])
+	crc.Write(rest[:len(rest)-4])
+	if crc.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return errors.New("event stream message checksum mismatch")
+	}
+	headers, err := parseEventStreamHeaders(rest[:headersLen])
+	if err != nil {
+		return err
+	}
+	payload := rest[headersLen : len(rest)-4]
+
+	var data []byte
+	switch headers[":message-type"] {
+	case "event":
+		if headers[":event-type"] != "chunk" {
+			return nil
+		}
+		var chunk struct {
+			Bytes string `json:"bytes"`
+		}
+		if err := json.Unmarshal(payload, &chunk); err != nil {
+			return fmt.Errorf("error unmarshalling event stream chunk: %w", err)
+		}
+		data, err = base64.StdEncoding.DecodeString(chunk.Bytes)
+		if err != nil {
+			return fmt.Errorf("error decoding event stream chunk: %w", err)
+		}
+	case "exception", "error":
+		var exception struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(payload, &exception)
+		errorType := headers[":exception-type"]
+		if errorType == "" {
+			errorType = headers[":error-code"]
+		}
+		data, _ = json.Marshal(streamEvent{Type: "error", Error: &errorInfo{Type: errorType, Message: exception.Message}})
+	default:
+		return nil
+	}
+	e.buf.WriteString("data: ")
+	e.buf.Write(data)
+	e.buf.WriteString("\n\n")
+	return nil
+}
+
+// parseEventStreamHeaders returns the string headers of an event stream
+// message. Headers of other types are skipped.
+func parseEventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	errInvalid := errors.New("invalid event stream headers")
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 2+nameLen {
+			return nil, errInvalid
+		}
+		name := string(data[1 : 1+nameLen])
+		valueType := data[1+nameLen]
+		data = data[2+nameLen:]
+		var size int
+		switch valueType {
+		case 0, 1: // Boolean true and false have no value.
+			size = 0
+		case 2:
+			size = 1
+		case 3:
+			size = 2
+		case 4:
+			size = 4
+		case 5, 8:
+			size = 8
+		case 9:
+			size = 16
+		case 6, 7:
+			if len(data) < 2 {
+				return nil, errInvalid
+			}
+			size = 2 + int(binary.BigEndian.Uint16(data))
+		default:
+			return nil, errInvalid
+		}
+		if len(data) < size {
+			return nil, errInvalid
+		}
+		if valueType == 7 {
+			headers[name] = string(data[2:size])
+		}
+		data = data[size:]
+	}
+	return headers, nil
+}
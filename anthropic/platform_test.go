@@ -0,0 +1,168 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeEventStreamMessage encodes a message in the AWS event stream format
+// with string headers.
+func encodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	var h bytes.Buffer
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(7)
+		binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+	var msg bytes.Buffer
+	binary.Write(&msg, binary.BigEndian, uint32(16+h.Len()+len(payload)))
+	binary.Write(&msg, binary.BigEndian, uint32(h.Len()))
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	msg.Write(h.Bytes())
+	msg.Write(payload)
+	binary.Write(&msg, binary.BigEndian, crc32.ChecksumIEEE(msg.Bytes()))
+	return msg.Bytes()
+}
+
+func bedrockChunk(event any) []byte {
+	data, _ := json.Marshal(event)
+	payload, _ := json.Marshal(map[string]string{"bytes": base64.StdEncoding.EncodeToString(data)})
+	return encodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "chunk"}, payload)
+}
+
+func TestSignAWSv4(t *testing.T) {
+	// The "get-vanilla" case from the AWS Signature Version 4 test suite.
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+	signAWSv4(req, nil, "us-east-1", "service", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	assert.Equal(t, "anthropic.claude-v2%3A1", awsURIEncode("anthropic.claude-v2:1", true))
+	assert.Equal(t, "/model/anthropic.claude-v2%253A1/invoke", awsURIEncode("/model/anthropic.claude-v2%3A1/invoke", false))
+}
+
+func TestEventStreamReader(t *testing.T) {
+	var body bytes.Buffer
+	body.Write(bedrockChunk(streamEvent{Type: "message_start", Message: &messageEvent{Role: "assistant", Usage: &usage{InputTokens: 3}}}))
+	body.Write(encodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "other"}, []byte(`{}`)))
+	body.Write(bedrockChunk(streamEvent{Type: "content_block_delta", Delta: delta{Type: "text_delta", Text: "Hello"}}))
+	body.Write(bedrockChunk(streamEvent{Type: "message_stop"}))
+
+	stream := &Stream{ctx: context.Background(), stream: newEventStreamReader(&body)}
+	var statuses []llms.StreamStatus
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []llms.StreamStatus{llms.StreamStatusText}, statuses)
+	assert.Equal(t, content.FromText("Hello"), stream.Message().Content)
+	inputTokens, _ := stream.Usage()
+	assert.Equal(t, 3, inputTokens)
+}
+
+func TestEventStreamReaderErrors(t *testing.T) {
+	t.Run("Exception", func(t *testing.T) {
+		msg := encodeEventStreamMessage(map[string]string{":message-type": "exception", ":exception-type": "throttlingException"}, []byte(`{"message":"Too many requests"}`))
+		stream := &Stream{ctx: context.Background(), stream: newEventStreamReader(bytes.NewReader(msg))}
+		for range stream.Iter() {
+		}
+		require.Error(t, stream.Err())
+		assert.Contains(t, stream.Err().Error(), "throttlingException")
+		assert.Contains(t, stream.Err().Error(), "Too many requests")
+	})
+
+	t.Run("Corrupt", func(t *testing.T) {
+		msg := bedrockChunk(streamEvent{Type: "message_stop"})
+		msg[len(msg)-6] ^= 1
+		_, err := io.ReadAll(newEventStreamReader(bytes.NewReader(msg)))
+		assert.ErrorContains(t, err, "checksum")
+	})
+}
+
+func TestVertexAIRequest(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+	}))
+	defer server.Close()
+
+	m := New("", "claude-3-7-sonnet@20250219").WithVertexAI("token", "project", "us-east5")
+	assert.Equal(t, "https://us-east5-aiplatform.googleapis.com/v1/projects/project/locations/us-east5/publishers/anthropic/models/claude-3-7-sonnet@20250219:streamRawPredict", m.endpoint)
+	m.WithEndpoint(server.URL, "Anthropic")
+
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+	assert.Empty(t, header.Get("X-API-Key"))
+	assert.Equal(t, "vertex-2023-10-16", body["anthropic_version"])
+	assert.NotContains(t, body, "model")
+	assert.Equal(t, true, body["stream"])
+}
+
+func TestBedrockRequest(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		path = r.URL.EscapedPath()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Write(bedrockChunk(streamEvent{Type: "content_block_delta", Delta: delta{Type: "text_delta", Text: "Hi"}}))
+	}))
+	defer server.Close()
+
+	m := New("", "anthropic.claude-3-7-sonnet-20250219-v1:0").WithBedrock("us-west-2", "AKID", "secret", "session").WithBeta("output-128k-2025-02-19")
+	assert.Equal(t, "https://bedrock-runtime.us-west-2.amazonaws.com/model/anthropic.claude-3-7-sonnet-20250219-v1%3A0/invoke-with-response-stream", m.endpoint)
+	m.WithEndpoint(server.URL+"/model/anthropic.claude-3-7-sonnet-20250219-v1%3A0/invoke-with-response-stream", "Anthropic")
+
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, "/model/anthropic.claude-3-7-sonnet-20250219-v1%3A0/invoke-with-response-stream", path)
+	assert.Contains(t, header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/")
+	assert.Contains(t, header.Get("Authorization"), "/us-west-2/bedrock/aws4_request")
+	assert.Equal(t, "session", header.Get("X-Amz-Security-Token"))
+	assert.Equal(t, "bedrock-2023-05-31", body["anthropic_version"])
+	assert.NotContains(t, body, "model")
+	assert.NotContains(t, body, "stream")
+	assert.Equal(t, []any{"output-128k-2025-02-19"}, body["anthropic_beta"])
+	assert.Empty(t, header.Get("anthropic-beta"))
+	assert.Equal(t, content.FromText("Hi"), stream.Message().Content)
+}
+
+func TestMessagesEndpointWithoutStreaming(t *testing.T) {
+	m := New("", "claude-3-7-sonnet@20250219").WithVertexAI("token", "project", "us-east5").WithStreaming(false)
+	assert.Equal(t, "https://us-east5-aiplatform.googleapis.com/v1/projects/project/locations/us-east5/publishers/anthropic/models/claude-3-7-sonnet@20250219:rawPredict", m.messagesEndpoint())
+	m = New("", "anthropic.claude-3-7-sonnet-20250219-v1:0").WithBedrock("us-west-2", "AKID", "secret", "").WithStreaming(false)
+	assert.Equal(t, "https://bedrock-runtime.us-west-2.amazonaws.com/model/anthropic.claude-3-7-sonnet-20250219-v1%3A0/invoke", m.messagesEndpoint())
+	m = New("key", "claude-sonnet-4-0").WithStreaming(false)
+	assert.Equal(t, "https://api.anthropic.com/v1/messages", m.messagesEndpoint())
+}
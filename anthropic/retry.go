@@ -0,0 +1,80 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blixt/go-llms/internal/backoff"
+	"github.com/blixt/go-llms/llms"
+)
+
+// maxRetryDelay is the longest the provider waits before retrying a request,
+// unless the API asks for longer with Retry-After.
+const maxRetryDelay = time.Minute
+
+// WithRetries makes requests that fail because the API is overloaded (status
+// 529) or rate limited (status 429) be retried up to maxRetries times. The
+// delay before each retry doubles from baseDelay, which defaults to a second
+// if it's zero, unless the API says how long to wait with Retry-After.
+// Errors in the middle of a stream aren't retried, since part of the response
+// has already been received.
+func (m *Model) WithRetries(maxRetries int, baseDelay time.Duration) *Model {
+	if baseDelay <= 0 {
+		baseDelay = time.Second
+	}
+	m.maxRetries = maxRetries
+	m.retryBaseDelay = baseDelay
+	return m
+}
+
+// post sends the JSON body to the endpoint, retrying as configured with
+// WithRetries, and returns the response if it has an OK status.
+func (m *Model) post(ctx context.Context, endpoint string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", llms.UserAgent())
+		m.authorize(req, body)
+		if m.noStreaming {
+			req.Header.Set("Accept", "application/json")
+		}
+
+		resp, err := m.client(ctx).Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error making request: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		apiErr := newAPIError(resp)
+		resp.Body.Close()
+		if attempt >= m.maxRetries || !shouldRetry(apiErr) {
+			return nil, apiErr
+		}
+		select {
+		case <-time.After(m.retryDelay(attempt, apiErr)):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// shouldRetry reports whether the error is one that WithRetries retries.
+func shouldRetry(err *APIError) bool {
+	return err.StatusCode == http.StatusTooManyRequests || err.StatusCode == 529 || err.Type == "overloaded_error"
+}
+
+// retryDelay returns how long to wait before retrying after the attempt,
+// counted from zero, failed with the error.
+func (m *Model) retryDelay(attempt int, err *APIError) time.Duration {
+	if err.RetryAfter > 0 {
+		return err.RetryAfter
+	}
+	return backoff.Delay(m.retryBaseDelay, maxRetryDelay, attempt)
+}
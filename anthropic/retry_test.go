@@ -0,0 +1,88 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRetries(t *testing.T) {
+	var requests int
+	statuses := []int{529, http.StatusTooManyRequests, http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[min(requests, len(statuses)-1)]
+		requests++
+		switch status {
+		case 529:
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+		case http.StatusTooManyRequests:
+			w.Header().Set("Retry-After", "0.01")
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"type":"error","error":{"type":"rate_limit_error","message":"Slow down"}}`)
+		default:
+			fmt.Fprint(w, sseEvent(streamEvent{Type: "content_block_delta", Delta: delta{Type: "text_delta", Text: "Hi"}}))
+			fmt.Fprint(w, sseEvent(streamEvent{Type: "message_stop"}))
+		}
+	}))
+	defer server.Close()
+
+	generate := func(m *Model) llms.ProviderStream {
+		return m.WithEndpoint(server.URL, "Anthropic").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	}
+
+	stream := generate(New("key", "claude-sonnet-4-0").WithRetries(2, time.Millisecond))
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, content.FromText("Hi"), stream.Message().Content)
+
+	requests = 0
+	stream = generate(New("key", "claude-sonnet-4-0").WithRetries(1, time.Millisecond))
+	var apiErr *APIError
+	require.True(t, errors.As(stream.Err(), &apiErr))
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, 2, requests)
+
+	requests = 0
+	stream = generate(New("key", "claude-sonnet-4-0"))
+	require.True(t, errors.As(stream.Err(), &apiErr))
+	assert.Equal(t, 529, apiErr.StatusCode)
+	assert.Equal(t, 1, requests, "Requests shouldn't be retried by default")
+}
+
+func TestGenerateRetriesOnlyOverloadedAndRateLimited(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"api_error","message":"Internal error"}}`)
+	}))
+	defer server.Close()
+
+	m := New("key", "claude-sonnet-4-0").WithEndpoint(server.URL, "Anthropic").WithRetries(3, time.Millisecond)
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	assert.Error(t, stream.Err())
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryDelay(t *testing.T) {
+	m := New("key", "claude-sonnet-4-0").WithRetries(10, 0)
+	assert.Equal(t, time.Second, m.retryDelay(0, &APIError{}))
+	assert.Equal(t, 4*time.Second, m.retryDelay(2, &APIError{}))
+	assert.Equal(t, time.Minute, m.retryDelay(9, &APIError{}))
+	assert.Equal(t, time.Minute, m.retryDelay(40, &APIError{}), "The delay of late attempts shouldn't overflow")
+	assert.Equal(t, time.Minute, m.retryDelay(1_000_000, &APIError{}))
+	assert.Equal(t, 5*time.Second, m.retryDelay(0, &APIError{RetryAfter: 5 * time.Second}))
+}
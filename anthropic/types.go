@@ -25,7 +25,7 @@ type contentList []contentItem
 // If the list contains exactly one text item, it marshals as a string.
 // Otherwise, it marshals as a normal JSON array.
 func (cl contentList) MarshalJSON() ([]byte, error) {
-	if len(cl) == 1 && cl[0].Type == "text" {
+	if len(cl) == 1 && cl[0].Type == "text" && cl[0].Citations == nil {
 		return json.Marshal(cl[0].Text)
 	}
 	// Marshal as regular array for all other cases
@@ -51,9 +51,11 @@ type contentItem struct {
 	ToolUseID string      `json:"tool_use_id,omitempty"` // ID of the tool_use block this result responds to
 	Content   contentList `json:"content,omitempty"`     // Content of the tool result
 
-	// Citations supporting the text block
+	// Documents, and the citations of them that support a text block.
 
-	Citations []citation `json:"citations,omitempty"` // Source citations for the text
+	Title     string `json:"title,omitempty"`     // Title of a document
+	Context   string `json:"context,omitempty"`   // Context of a document, which is not cited
+	Citations any    `json:"citations,omitempty"` // []citation for text, citationsConfig for documents
 
 	// Thinking content from extended thinking feature
 
@@ -67,9 +69,10 @@ type contentItem struct {
 
 // source represents the source of an image
 type source struct {
-	Type      string `json:"type"`       // Always "base64" for Anthropic
-	MediaType string `json:"media_type"` // MIME type of the image (e.g., "image/jpeg")
-	Data      string `json:"data"`       // Base64-encoded image data
+	Type      string `json:"type"`                 // Either "base64" or "url"
+	MediaType string `json:"media_type,omitempty"` // MIME type of the image (e.g., "image/jpeg")
+	Data      string `json:"data,omitempty"`       // Base64-encoded image data
+	URL       string `json:"url,omitempty"`        // URL of the image, for the "url" type
 }
 
 // streamEvent represents an event in the streaming response
@@ -86,57 +89,60 @@ type streamEvent struct {
 type messageEvent struct {
 	ID    string `json:"id"`              // Unique message ID
 	Role  string `json:"role"`            // Either "user" or "assistant"
+	Model string `json:"model,omitempty"` // The model that served the request
 	Usage *usage `json:"usage,omitempty"` // Token usage statistics
 }
 
 // contentBlock represents the initial state of a content block
 type contentBlock struct {
-	Type  string          `json:"type"`            // Type of content block: "text", "tool_use", "thinking"
+	Type  string          `json:"type"`            // Type of content block: "text", "tool_use", "thinking", "redacted_thinking"
 	Text  string          `json:"text,omitempty"`  // Initial text content (typically empty)
 	ID    string          `json:"id,omitempty"`    // Unique ID for the content block (used for tool_use blocks)
 	Name  string          `json:"name,omitempty"`  // For tool_use blocks, name of the tool being called
 	Input json.RawMessage `json:"input,omitempty"` // Arguments passed to the tool
+
+	Citations json.RawMessage `json:"citations,omitempty"` // Present for text blocks that may have citations
+
+	Data string `json:"data,omitempty"` // Encrypted reasoning of redacted_thinking blocks
 }
 
 // delta represents incremental updates in content_block_delta events
 type delta struct {
-	Type         string `json:"type"`                    // Type of delta: "text_delta", "input_json_delta", "thinking_delta", etc.
-	PartialJSON  string `json:"partial_json,omitempty"`  // For tool_use blocks, fragments of JSON for the input field
-	Text         string `json:"text,omitempty"`          // Text fragment for text content blocks
-	Thinking     string `json:"thinking,omitempty"`      // Thinking fragment for thinking content blocks
-	Signature    string `json:"signature,omitempty"`     // Used in signature_delta events to verify thinking content
-	Usage        *usage `json:"usage,omitempty"`         // Token usage updates
-	StopReason   string `json:"stop_reason,omitempty"`   // Reason for stopping: "end_turn", "tool_use", etc.
-	StopSequence string `json:"stop_sequence,omitempty"` // Custom stop sequence if that caused the stop
+	Type         string    `json:"type"`                    // Type of delta: "text_delta", "input_json_delta", "thinking_delta", etc.
+	PartialJSON  string    `json:"partial_json,omitempty"`  // For tool_use blocks, fragments of JSON for the input field
+	Text         string    `json:"text,omitempty"`          // Text fragment for text content blocks
+	Thinking     string    `json:"thinking,omitempty"`      // Thinking fragment for thinking content blocks
+	Signature    string    `json:"signature,omitempty"`     // Used in signature_delta events to verify thinking content
+	Citation     *citation `json:"citation,omitempty"`      // Used in citations_delta events
+	Usage        *usage    `json:"usage,omitempty"`         // Token usage updates
+	StopReason   string    `json:"stop_reason,omitempty"`   // Reason for stopping: "end_turn", "tool_use", etc.
+	StopSequence string    `json:"stop_sequence,omitempty"` // Custom stop sequence if that caused the stop
 }
 
 type usage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"` // Not included in the input tokens
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`     // Not included in the input tokens
 }
 
-// citation represents a source citation in a text content block
+// citation represents a citation of a document that supports a text block
 type citation struct {
-	// The type of citation will depend on the type of document being cited
-	PageLocation         *pageLocation         `json:"page_location,omitempty"`          // For PDF document citations
-	CharLocation         *charLocation         `json:"char_location,omitempty"`          // For text document citations
-	ContentBlockLocation *contentBlockLocation `json:"content_block_location,omitempty"` // For citations to other content blocks
-}
-
-// pageLocation identifies a location in a PDF document
-type pageLocation struct {
-	PageNumber int `json:"page_number"` // Page number in the PDF
-}
-
-// charLocation identifies a span of characters in a text document
-type charLocation struct {
-	StartChar int `json:"start_char"` // Start character index
-	EndChar   int `json:"end_char"`   // End character index
+	Type            string `json:"type"` // "char_location", "page_location" or "content_block_location"
+	CitedText       string `json:"cited_text"`
+	DocumentIndex   int    `json:"document_index"`
+	DocumentTitle   string `json:"document_title,omitempty"`
+	StartCharIndex  *int   `json:"start_char_index,omitempty"`  // For text documents
+	EndCharIndex    *int   `json:"end_char_index,omitempty"`    // For text documents
+	StartPageNumber *int   `json:"start_page_number,omitempty"` // For PDF documents
+	EndPageNumber   *int   `json:"end_page_number,omitempty"`   // For PDF documents
+	StartBlockIndex *int   `json:"start_block_index,omitempty"` // For custom content documents
+	EndBlockIndex   *int   `json:"end_block_index,omitempty"`   // For custom content documents
 }
 
-// contentBlockLocation identifies a content block
-type contentBlockLocation struct {
-	BlockID string `json:"block_id"` // ID of the content block being cited
+// citationsConfig enables citations of a document
+type citationsConfig struct {
+	Enabled bool `json:"enabled"`
 }
 
 // errorInfo contains error details in error events
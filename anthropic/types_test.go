@@ -134,8 +134,20 @@ func TestMessageFromLLM_Anthropic(t *testing.T) {
 		{
 			name:  "Empty message",
 			input: llms.Message{Role: "assistant", Content: nil, ToolCalls: nil},
-			// The new logic in messageFromLLM ensures empty content gets [{type: "text", text: ""}].
-			expected: message{Role: "assistant", Content: contentList{{Type: "text", Text: ""}}},
+			// Generate decides what to do with messages that have no content.
+			expected: message{Role: "assistant", Content: contentList{}},
+		},
+		{
+			name: "Assistant message with only tool calls",
+			input: llms.Message{
+				Role:      "assistant",
+				Content:   content.FromText(" "),
+				ToolCalls: []llms.ToolCall{{ID: "toolu_4", Name: "get_time", Arguments: json.RawMessage(`{}`)}},
+			},
+			expected: message{
+				Role:    "assistant",
+				Content: contentList{{Type: "tool_use", ID: "toolu_4", Name: "get_time", Input: json.RawMessage(`{}`)}},
+			},
 		},
 	}
 
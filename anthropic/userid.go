@@ -0,0 +1,35 @@
+package anthropic
+
+import "context"
+
+type userIDKey struct{}
+
+// WithUserID identifies the end user that requests are made for, which
+// Anthropic uses to detect abuse. It should be an opaque identifier such as a
+// UUID or a hash, and not contain names, email addresses or phone numbers.
+// The user of a single request can be set with ContextWithUserID.
+func (m *Model) WithUserID(id string) *Model {
+	m.userID = id
+	return m
+}
+
+// ContextWithUserID returns a context that makes requests identify the given
+// end user, overriding the one set with WithUserID.
+func ContextWithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, userIDKey{}, id)
+}
+
+// UserIDFromContext returns the user ID set with ContextWithUserID, or an
+// empty string.
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userIDKey{}).(string)
+	return id
+}
+
+// requestUserID returns the user ID of the context, or else of the model.
+func (m *Model) requestUserID(ctx context.Context) string {
+	if id := UserIDFromContext(ctx); id != "" {
+		return id
+	}
+	return m.userID
+}
@@ -0,0 +1,172 @@
+// Package bundle collapses a finished conversation into a compact, portable
+// bundle of what came out of it, which can seed later conversations without
+// carrying over the full history.
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Bundle is the outcome of a conversation. It encodes to JSON, so it can be
+// stored anywhere and read back with Read.
+type Bundle struct {
+	// Summary describes what the conversation was about and how it ended.
+	Summary string `json:"summary"`
+	// Facts are the decisions, preferences and findings that a later
+	// conversation should know about.
+	Facts []string `json:"facts,omitempty"`
+	// Artifacts are the final versions of what the conversation produced,
+	// such as documents or code.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// Usage is the total usage of the conversation.
+	Usage     Usage     `json:"usage"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Artifact is something the conversation produced, kept verbatim.
+type Artifact struct {
+	Name    string `json:"name" description:"A short name for the artifact, such as a file name or title."`
+	Content string `json:"content" description:"The full, final content of the artifact, copied exactly."`
+}
+
+// Usage is the total usage of a conversation.
+type Usage struct {
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// UsageOf returns the total usage of the LLM so far.
+func UsageOf(l *llms.LLM) Usage {
+	inputTokens, outputTokens := l.Usage()
+	return Usage{InputTokens: inputTokens, OutputTokens: outputTokens, CostUSD: l.CostUSD()}
+}
+
+type bundleParams struct {
+	Summary   string     `json:"summary" description:"A summary of what the conversation was about, what was done and how it ended, in a few sentences."`
+	Facts     []string   `json:"facts" description:"Decisions, user preferences and findings that someone continuing the work should know, one per item."`
+	Artifacts []Artifact `json:"artifacts" description:"The final versions of any documents, code or other deliverables that were produced. Leave out drafts that were replaced."`
+}
+
+// Compact asks the provider to collapse the conversation into a bundle. The
+// usage of the conversation is recorded in the bundle as is, since the
+// messages don't carry it (see UsageOf).
+func Compact(ctx context.Context, provider llms.Provider, messages []llms.Message, usage Usage) (*Bundle, error) {
+	var bundle *Bundle
+	submit := tools.Func("Submit bundle", "Submit the summary, facts and artifacts of the conversation.", "submit_bundle", func(r tools.Runner, p bundleParams) tools.Result {
+		bundle = &Bundle{Summary: p.Summary, Facts: p.Facts, Artifacts: p.Artifacts}
+		return tools.Success(nil)
+	})
+	toolbox := tools.Box(submit)
+
+	systemPrompt := content.FromText("Collapse the conversation transcript the user provides into a bundle that lets a later conversation pick up where it left off, by calling submit_bundle exactly once. Keep the summary short, keep every fact that matters later, and copy artifacts exactly as they were last written.")
+	transcript := []llms.Message{{Role: "user", Content: content.FromText(Transcript(messages))}}
+	stream := provider.Generate(ctx, systemPrompt, transcript, toolbox)
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("LLM returned error response: %w", err)
+	}
+	for status := range stream.Iter() {
+		if status != llms.StreamStatusToolCallReady {
+			continue
+		}
+		toolCall := stream.ToolCall()
+		runner := tools.NewRunner(ctx, toolbox, func(string) {})
+		result := toolbox.Run(runner, toolCall.Name, json.RawMessage(toolCall.Arguments))
+		if err := result.Error(); err != nil {
+			return nil, fmt.Errorf("invalid bundle: %w", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stream: %w", err)
+	}
+	if bundle == nil {
+		return nil, fmt.Errorf("LLM did not submit a bundle: %s", textOf(stream.Message().Content))
+	}
+	bundle.Usage = usage
+	bundle.CreatedAt = time.Now()
+	return bundle, nil
+}
+
+// Transcript formats the conversation as plain text, including tool calls and
+// their results. Content other than text and JSON is left out.
+func Transcript(messages []llms.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		text := textOf(msg.Content)
+		switch msg.Role {
+		case "user":
+			fmt.Fprintf(&b, "User: %s\n\n", text)
+		case "assistant":
+			if text != "" {
+				fmt.Fprintf(&b, "Assistant: %s\n\n", text)
+			}
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&b, "Assistant called %s with %s\n\n", call.Name, call.Arguments)
+			}
+		case "tool":
+			fmt.Fprintf(&b, "Tool result: %s\n\n", text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Read decodes a bundle that was encoded as JSON.
+func Read(r io.Reader) (*Bundle, error) {
+	var bundle Bundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	return &bundle, nil
+}
+
+// Content formats the bundle as context for a new conversation.
+func (b *Bundle) Content() content.Content {
+	var s strings.Builder
+	s.WriteString("Context from an earlier conversation:\n\n")
+	s.WriteString(b.Summary)
+	if len(b.Facts) > 0 {
+		s.WriteString("\n\nKey facts:\n")
+		for _, fact := range b.Facts {
+			fmt.Fprintf(&s, "- %s\n", fact)
+		}
+	}
+	for _, artifact := range b.Artifacts {
+		fmt.Fprintf(&s, "\n<artifact name=%q>\n%s\n</artifact>\n", artifact.Name, artifact.Content)
+	}
+	return content.FromText(strings.TrimSpace(s.String()))
+}
+
+// Inject adds the bundle to the system prompt of the LLM, after its own system
+// prompt if it has one.
+func (b *Bundle) Inject(l *llms.LLM) {
+	systemPrompt := l.SystemPrompt
+	l.SystemPrompt = func() content.Content {
+		var c content.Content
+		if systemPrompt != nil {
+			c = append(c, systemPrompt()...)
+		}
+		return append(c, b.Content()...)
+	}
+}
+
+func textOf(c content.Content) string {
+	var parts []string
+	for _, item := range c {
+		switch v := item.(type) {
+		case *content.Text:
+			parts = append(parts, v.Text)
+		case *content.JSON:
+			parts = append(parts, string(v.Data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
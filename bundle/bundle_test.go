@@ -0,0 +1,100 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider responds with a single message, which may contain a tool call,
+// and remembers the last request.
+type fakeProvider struct {
+	message      llms.Message
+	systemPrompt content.Content
+	messages     []llms.Message
+}
+
+func (p *fakeProvider) Company() string { return "Fake" }
+func (p *fakeProvider) Model() string   { return "fake" }
+func (p *fakeProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *fakeProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	p.systemPrompt, p.messages = systemPrompt, messages
+	return &fakeStream{message: p.message}
+}
+
+type fakeStream struct {
+	message llms.Message
+}
+
+func (s *fakeStream) Err() error              { return nil }
+func (s *fakeStream) Message() llms.Message   { return s.message }
+func (s *fakeStream) Text() string            { return "" }
+func (s *fakeStream) ToolCall() llms.ToolCall { return s.message.ToolCalls[0] }
+func (s *fakeStream) Usage() (int, int)       { return 0, 0 }
+
+func (s *fakeStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		if len(s.message.ToolCalls) > 0 {
+			yield(llms.StreamStatusToolCallReady)
+		}
+	}
+}
+
+func TestCompact(t *testing.T) {
+	provider := &fakeProvider{message: llms.Message{
+		Role: "assistant",
+		ToolCalls: []llms.ToolCall{{
+			ID:        "call_1",
+			Name:      "submit_bundle",
+			Arguments: json.RawMessage(`{"summary":"Wrote a haiku about Go.","facts":["The user prefers lowercase."],"artifacts":[{"name":"haiku.txt","content":"gophers dig deep"}]}`),
+		}},
+	}}
+	history := []llms.Message{
+		{Role: "user", Content: content.FromText("Write a haiku about Go")},
+		{Role: "assistant", ToolCalls: []llms.ToolCall{{ID: "1", Name: "save", Arguments: json.RawMessage(`{"name":"haiku.txt"}`)}}},
+		{Role: "tool", ToolCallID: "1", Content: content.FromText("saved")},
+		{Role: "assistant", Content: content.FromText("Done!")},
+	}
+	b, err := Compact(context.Background(), provider, history, Usage{InputTokens: 100, OutputTokens: 20})
+	require.NoError(t, err)
+	assert.Equal(t, "Wrote a haiku about Go.", b.Summary)
+	assert.Equal(t, []string{"The user prefers lowercase."}, b.Facts)
+	assert.Equal(t, []Artifact{{Name: "haiku.txt", Content: "gophers dig deep"}}, b.Artifacts)
+	assert.Equal(t, 100, b.Usage.InputTokens)
+	assert.False(t, b.CreatedAt.IsZero())
+	require.Len(t, provider.messages, 1)
+	assert.Equal(t, content.FromText("User: Write a haiku about Go\n\nAssistant called save with {\"name\":\"haiku.txt\"}\n\nTool result: saved\n\nAssistant: Done!"), provider.messages[0].Content)
+
+	provider.message = llms.Message{Role: "assistant", Content: content.FromText("No.")}
+	_, err = Compact(context.Background(), provider, history, Usage{})
+	assert.EqualError(t, err, "LLM did not submit a bundle: No.")
+}
+
+func TestReadAndInject(t *testing.T) {
+	b := &Bundle{Summary: "Planned a trip.", Facts: []string{"Budget is $2000."}, Artifacts: []Artifact{{Name: "itinerary", Content: "Day 1: Rome"}}}
+	data, err := json.Marshal(b)
+	require.NoError(t, err)
+	loaded, err := Read(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, b.Summary, loaded.Summary)
+
+	provider := &fakeProvider{message: llms.Message{Role: "assistant", Content: content.FromText("Hi")}}
+	llm := llms.New(provider)
+	llm.SystemPrompt = func() content.Content { return content.FromText("You are a travel agent.") }
+	loaded.Inject(llm)
+	for range llm.Chat("Where were we?") {
+	}
+	require.NoError(t, llm.Err())
+	assert.Equal(t, content.Content{
+		&content.Text{Text: "You are a travel agent."},
+		&content.Text{Text: "Context from an earlier conversation:\n\nPlanned a trip.\n\nKey facts:\n- Budget is $2000.\n\n<artifact name=\"itinerary\">\nDay 1: Rome\n</artifact>"},
+	}, provider.systemPrompt)
+}
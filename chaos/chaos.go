@@ -0,0 +1,249 @@
+// Package chaos provides a provider that injects faults into the responses of
+// another provider, so that applications can test how they handle slow
+// responses, rate limits and broken streams without waiting for them to
+// happen for real.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// ErrInjected is wrapped by all the errors that the provider injects.
+var ErrInjected = errors.New("injected fault")
+
+// Faults configures which faults are injected. Rates are probabilities from 0
+// to 1 that a request gets the fault. At most one of the errors is injected
+// per request, checked in the order of the fields.
+type Faults struct {
+	// Latency is added before the response starts.
+	Latency time.Duration
+	// ChunkLatency is added before every part of the response.
+	ChunkLatency time.Duration
+	// RateLimitRate is how often requests fail with a 429 error, which
+	// llms.IsRateLimitError recognizes.
+	RateLimitRate float64
+	// ErrorRate is how often requests fail with a 500 error.
+	ErrorRate float64
+	// DropRate is how often a response is cut off, as if the connection was
+	// lost.
+	DropRate float64
+	// MalformedRate is how often a response fails as if the provider had sent
+	// a chunk that couldn't be decoded.
+	MalformedRate float64
+	// FailAfter is how many parts of the response are streamed before a
+	// dropped or malformed response fails. It defaults to 1, so that the
+	// failure happens mid-message.
+	FailAfter int
+}
+
+// Provider wraps another provider and injects faults into its responses.
+type Provider struct {
+	provider llms.Provider
+	faults   Faults
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New returns a provider that injects the given faults into the responses of
+// p. Faults are picked by a random number generator with a fixed seed, so the
+// same sequence of requests gets the same faults every time.
+func New(p llms.Provider, faults Faults) *Provider {
+	if faults.FailAfter <= 0 {
+		faults.FailAfter = 1
+	}
+	return &Provider{provider: p, faults: faults, rand: rand.New(rand.NewPCG(0, 0))}
+}
+
+// WithSeed sets the seed of the random number generator that picks the faults.
+func (p *Provider) WithSeed(seed uint64) *Provider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rand = rand.New(rand.NewPCG(seed, 0))
+	return p
+}
+
+func (p *Provider) Company() string {
+	return p.provider.Company()
+}
+
+func (p *Provider) Model() string {
+	return p.provider.Model()
+}
+
+func (p *Provider) String() string {
+	return llms.ProviderName(p)
+}
+
+func (p *Provider) ToolResultTypes() []content.Type {
+	if typer, ok := p.provider.(llms.ToolResultTyper); ok {
+		return typer.ToolResultTypes()
+	}
+	return nil
+}
+
+type fault int
+
+const (
+	noFault fault = iota
+	rateLimitFault
+	errorFault
+	dropFault
+	malformedFault
+)
+
+// pick decides which fault, if any, the next request gets. One number is
+// drawn for every rate so that changing one rate doesn't change which
+// requests get the other faults.
+func (p *Provider) pick() fault {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	picked := noFault
+	for i, rate := range []float64{p.faults.RateLimitRate, p.faults.ErrorRate, p.faults.DropRate, p.faults.MalformedRate} {
+		if p.rand.Float64() < rate && picked == noFault {
+			picked = fault(i + 1)
+		}
+	}
+	return picked
+}
+
+func (p *Provider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	f := p.pick()
+	if err := sleep(ctx, p.faults.Latency); err != nil {
+		return &Stream{err: err}
+	}
+	switch f {
+	case rateLimitFault:
+		return &Stream{err: &httpError{429, "429 Too Many Requests: rate limit exceeded"}}
+	case errorFault:
+		return &Stream{err: &httpError{500, "500 Internal Server Error"}}
+	}
+	stream := &Stream{ProviderStream: p.provider.Generate(ctx, systemPrompt, messages, toolbox), ctx: ctx, chunkLatency: p.faults.ChunkLatency}
+	switch f {
+	case dropFault:
+		stream.failAfter = p.faults.FailAfter
+		stream.failure = fmt.Errorf("error scanning stream: unexpected EOF: %w", ErrInjected)
+	case malformedFault:
+		stream.failAfter = p.faults.FailAfter
+		stream.failure = fmt.Errorf("error unmarshalling chunk: invalid character '}' looking for beginning of value: %w", ErrInjected)
+	}
+	return stream
+}
+
+// httpError is an injected error status, which implements llms.HTTPError so
+// that retry policies treat it like the real thing.
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e *httpError) Error() string             { return fmt.Sprintf("%s: %s", e.message, ErrInjected) }
+func (e *httpError) Unwrap() error             { return ErrInjected }
+func (e *httpError) HTTPStatus() int           { return e.status }
+func (e *httpError) RetryDelay() time.Duration { return 0 }
+
+// Stream is a response that may be slowed down or fail partway through.
+type Stream struct {
+	llms.ProviderStream
+	ctx          context.Context
+	chunkLatency time.Duration
+	failAfter    int
+	failure      error
+	err          error
+}
+
+func (s *Stream) Err() error {
+	if s.err != nil || s.ProviderStream == nil {
+		return s.err
+	}
+	return s.ProviderStream.Err()
+}
+
+// Model returns the model that served the response.
+func (s *Stream) Model() string {
+	if ms, ok := s.ProviderStream.(llms.ModelStream); ok {
+		return ms.Model()
+	}
+	return ""
+}
+
+func (s *Stream) Audio() []byte {
+	if as, ok := s.ProviderStream.(llms.AudioStream); ok {
+		return as.Audio()
+	}
+	return nil
+}
+
+func (s *Stream) UsageDetails() llms.UsageDetails {
+	if us, ok := s.ProviderStream.(llms.UsageDetailsStream); ok {
+		return us.UsageDetails()
+	}
+	return llms.UsageDetails{}
+}
+
+func (s *Stream) RateLimits() (limits llms.RateLimits, ok bool) {
+	if rs, ok := s.ProviderStream.(llms.RateLimitStream); ok {
+		return rs.RateLimits()
+	}
+	return llms.RateLimits{}, false
+}
+
+func (s *Stream) Truncated() (reason string, ok bool) {
+	if ts, ok := s.ProviderStream.(llms.TruncationStream); ok {
+		return ts.Truncated()
+	}
+	return "", false
+}
+
+func (s *Stream) FinishReason() llms.FinishReason {
+	if fs, ok := s.ProviderStream.(llms.FinishReasonStream); ok {
+		return fs.FinishReason()
+	}
+	return ""
+}
+
+func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		if s.ProviderStream == nil {
+			return
+		}
+		var n int
+		for status := range s.ProviderStream.Iter() {
+			if s.failure != nil && n == s.failAfter {
+				s.err = s.failure
+				return
+			}
+			if err := sleep(s.ctx, s.chunkLatency); err != nil {
+				s.err = err
+				return
+			}
+			n++
+			if !yield(status) {
+				return
+			}
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
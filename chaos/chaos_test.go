@@ -0,0 +1,160 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// wordProvider streams its words one text chunk at a time.
+type wordProvider struct {
+	words []string
+}
+
+func (p *wordProvider) Company() string { return "Test" }
+func (p *wordProvider) Model() string   { return "test-model" }
+func (p *wordProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *wordProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	return &wordStream{words: p.words}
+}
+
+type wordStream struct {
+	words   []string
+	message llms.Message
+}
+
+func (s *wordStream) Err() error              { return nil }
+func (s *wordStream) Message() llms.Message   { return s.message }
+func (s *wordStream) Text() string            { return "" }
+func (s *wordStream) Usage() (int, int)       { return 0, 0 }
+func (s *wordStream) ToolCall() llms.ToolCall { return llms.ToolCall{} }
+
+func (s *wordStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		s.message.Role = "assistant"
+		for _, word := range s.words {
+			s.message.Content.Append(word)
+			if !yield(llms.StreamStatusText) {
+				return
+			}
+		}
+	}
+}
+
+// run generates a response and returns the number of parts streamed.
+func run(ctx context.Context, p llms.Provider) (int, error) {
+	stream := p.Generate(ctx, nil, nil, nil)
+	if err := stream.Err(); err != nil {
+		return 0, err
+	}
+	var n int
+	for range stream.Iter() {
+		n++
+	}
+	return n, stream.Err()
+}
+
+func TestFaults(t *testing.T) {
+	words := &wordProvider{words: []string{"one ", "two ", "three"}}
+
+	n, err := run(context.Background(), New(words, Faults{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	_, err = run(context.Background(), New(words, Faults{RateLimitRate: 1}))
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.True(t, llms.IsRateLimitError(err))
+
+	_, err = run(context.Background(), New(words, Faults{ErrorRate: 1}))
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.False(t, llms.IsRateLimitError(err))
+	var httpErr llms.HTTPError
+	if assert.ErrorAs(t, err, &httpErr, "Injected error statuses should be retried like real ones") {
+		assert.Equal(t, 500, httpErr.HTTPStatus())
+	}
+
+	n, err = run(context.Background(), New(words, Faults{DropRate: 1}))
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.Equal(t, 1, n)
+
+	n, err = run(context.Background(), New(words, Faults{MalformedRate: 1, FailAfter: 2}))
+	assert.ErrorContains(t, err, "error unmarshalling chunk")
+	assert.Equal(t, 2, n)
+}
+
+func TestFaultsAreDeterministic(t *testing.T) {
+	words := &wordProvider{words: []string{"hi"}}
+	outcomes := func(p *Provider) []bool {
+		var failed []bool
+		for range 20 {
+			_, err := run(context.Background(), p)
+			failed = append(failed, errors.Is(err, ErrInjected))
+		}
+		return failed
+	}
+	first := outcomes(New(words, Faults{ErrorRate: 0.5}))
+	assert.Equal(t, first, outcomes(New(words, Faults{ErrorRate: 0.5})))
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+	assert.NotEqual(t, first, outcomes(New(words, Faults{ErrorRate: 0.5}).WithSeed(42)))
+}
+
+func TestLatency(t *testing.T) {
+	words := &wordProvider{words: []string{"hi"}}
+	start := time.Now()
+	_, err := run(context.Background(), New(words, Faults{Latency: 20 * time.Millisecond, ChunkLatency: 20 * time.Millisecond}))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = run(ctx, New(words, Faults{Latency: time.Hour}))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// detailedStream reports everything a stream optionally can.
+type detailedStream struct {
+	wordStream
+}
+
+func (s *detailedStream) Model() string { return "test-model-2025" }
+func (s *detailedStream) Audio() []byte { return []byte("audio") }
+func (s *detailedStream) UsageDetails() llms.UsageDetails {
+	return llms.UsageDetails{CachedInputTokens: 3}
+}
+func (s *detailedStream) RateLimits() (llms.RateLimits, bool) {
+	return llms.RateLimits{RemainingRequests: 7}, true
+}
+func (s *detailedStream) Truncated() (string, bool)       { return "max_tokens", true }
+func (s *detailedStream) FinishReason() llms.FinishReason { return llms.FinishReasonLength }
+
+type detailedProvider struct {
+	wordProvider
+}
+
+func (p *detailedProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	return &detailedStream{wordStream{words: p.words}}
+}
+
+func TestStreamForwardsDetails(t *testing.T) {
+	stream := New(&detailedProvider{wordProvider{words: []string{"Hello"}}}, Faults{}).Generate(context.Background(), nil, nil, nil)
+	for range stream.Iter() {
+	}
+	assert.Equal(t, "test-model-2025", stream.(llms.ModelStream).Model())
+	assert.Equal(t, []byte("audio"), stream.(llms.AudioStream).Audio())
+	assert.Equal(t, llms.UsageDetails{CachedInputTokens: 3}, stream.(llms.UsageDetailsStream).UsageDetails())
+	limits, ok := stream.(llms.RateLimitStream).RateLimits()
+	assert.True(t, ok)
+	assert.Equal(t, 7, limits.RemainingRequests)
+	reason, ok := stream.(llms.TruncationStream).Truncated()
+	assert.True(t, ok)
+	assert.Equal(t, "max_tokens", reason)
+	assert.Equal(t, llms.FinishReasonLength, stream.(llms.FinishReasonStream).FinishReason())
+}
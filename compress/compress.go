@@ -0,0 +1,192 @@
+// Package compress shrinks the message history before it's sent to the
+// provider, for applications where input tokens dominate the cost. It only
+// changes what the provider is sent, never the history kept by the LLM.
+package compress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Options controls which heuristics are applied to the history. Messages near
+// the end of the history are left untouched, since the model usually needs
+// them verbatim.
+type Options struct {
+	// KeepRecent is the number of messages at the end of the history that are
+	// never changed. It defaults to 4.
+	KeepRecent int
+	// MaxToolResultChars truncates older tool results that are longer than
+	// this many characters. It defaults to 2000, and -1 disables truncation.
+	MaxToolResultChars int
+	// MinRepeatChars is the length from which a text or JSON item that's
+	// identical to an earlier one is replaced by a note. It defaults to 200,
+	// and -1 disables the replacement.
+	MinRepeatChars int
+	// OnCompress is called for every request with an estimate of the tokens
+	// that were saved.
+	OnCompress func(Stats)
+}
+
+// Stats describes the effect of compression on a single request. The token
+// counts are estimates (see llms.EstimateTokens).
+type Stats struct {
+	Model        string
+	TokensBefore int
+	TokensAfter  int
+	// SavedUSD is the estimated input cost that was saved, or zero if the
+	// pricing of the model is not known.
+	SavedUSD float64
+}
+
+func (s Stats) TokensSaved() int {
+	return s.TokensBefore - s.TokensAfter
+}
+
+// Provider compresses the history of every request before passing it on to
+// the wrapped provider.
+type Provider struct {
+	llms.Provider
+	opts Options
+}
+
+// Wrap returns a provider that compresses the history before it's sent.
+func Wrap(provider llms.Provider, opts Options) *Provider {
+	if opts.KeepRecent == 0 {
+		opts.KeepRecent = 4
+	}
+	if opts.MaxToolResultChars == 0 {
+		opts.MaxToolResultChars = 2000
+	}
+	if opts.MinRepeatChars == 0 {
+		opts.MinRepeatChars = 200
+	}
+	return &Provider{Provider: provider, opts: opts}
+}
+
+func (p *Provider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	compressed := Messages(messages, p.opts)
+	if p.opts.OnCompress != nil {
+		stats := Stats{
+			Model:        p.Model(),
+			TokensBefore: llms.EstimateMessageTokens(messages),
+			TokensAfter:  llms.EstimateMessageTokens(compressed),
+		}
+		if pricing, ok := llms.LookupPricing(stats.Model); ok {
+			stats.SavedUSD = pricing.Cost(stats.TokensSaved(), 0)
+		}
+		p.opts.OnCompress(stats)
+	}
+	return p.Provider.Generate(ctx, systemPrompt, compressed, toolbox)
+}
+
+// Ping checks the wrapped provider. See llms.Ping.
+func (p *Provider) Ping(ctx context.Context) error {
+	return llms.Ping(ctx, p.Provider)
+}
+
+func (p *Provider) ToolResultTypes() []content.Type {
+	if typer, ok := p.Provider.(llms.ToolResultTyper); ok {
+		return typer.ToolResultTypes()
+	}
+	return nil
+}
+
+// Messages returns a compressed copy of the messages. The messages themselves
+// are not modified, and the result has the same messages in the same order,
+// so tool calls and their results still line up.
+func Messages(messages []llms.Message, opts Options) []llms.Message {
+	result := make([]llms.Message, len(messages))
+	seen := make(map[string]bool)
+	for i, msg := range messages {
+		if i >= len(messages)-opts.KeepRecent || len(msg.Content) == 0 {
+			result[i] = msg
+			continue
+		}
+		c := make(content.Content, 0, len(msg.Content))
+		for _, item := range msg.Content {
+			c = append(c, compressItem(item, msg.Role == "tool", seen, opts))
+		}
+		msg.Content = c
+		result[i] = msg
+	}
+	return result
+}
+
+func compressItem(item content.Item, isToolResult bool, seen map[string]bool, opts Options) content.Item {
+	switch v := item.(type) {
+	case *content.Text:
+		text := Whitespace(v.Text)
+		if repeated(text, seen, opts) {
+			return &content.Text{Text: "(This repeats earlier content, which has been left out.)"}
+		}
+		return &content.Text{Text: text}
+	case *content.JSON:
+		var buf bytes.Buffer
+		data := v.Data
+		if json.Compact(&buf, v.Data) == nil {
+			data = buf.Bytes()
+		}
+		if repeated(string(data), seen, opts) {
+			return &content.JSON{Data: json.RawMessage(`{"note":"Identical to an earlier result, which has been left out."}`)}
+		}
+		if isToolResult && opts.MaxToolResultChars >= 0 && len(data) > opts.MaxToolResultChars {
+			// Keep it JSON, since some providers require tool results to be.
+			truncated, _ := json.Marshal(map[string]any{
+				"truncated": truncate(string(data), opts.MaxToolResultChars),
+				"note":      fmt.Sprintf("%d characters of this older result were left out.", len(data)-opts.MaxToolResultChars),
+			})
+			return &content.JSON{Data: truncated}
+		}
+		return &content.JSON{Data: data}
+	}
+	return item
+}
+
+// repeated records the text and reports whether it was seen before, for texts
+// long enough to be worth replacing.
+func repeated(text string, seen map[string]bool, opts Options) bool {
+	if opts.MinRepeatChars < 0 || len(text) < opts.MinRepeatChars {
+		return false
+	}
+	if seen[text] {
+		return true
+	}
+	seen[text] = true
+	return false
+}
+
+var (
+	trailingSpace = regexp.MustCompile(`[ \t]+\n`)
+	blankLines    = regexp.MustCompile(`\n{3,}`)
+	innerSpace    = regexp.MustCompile(`(\S)[ \t]{2,}`)
+)
+
+// Whitespace removes whitespace that rarely carries meaning: trailing spaces,
+// runs of more than one blank line, and runs of spaces within a line.
+// Indentation is kept, since it matters in code.
+func Whitespace(text string) string {
+	text = trailingSpace.ReplaceAllString(text, "\n")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	text = innerSpace.ReplaceAllString(text, "$1 ")
+	return strings.TrimRight(text, " \t\n")
+}
+
+// truncate cuts the string to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
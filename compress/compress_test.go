@@ -0,0 +1,73 @@
+package compress
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhitespace(t *testing.T) {
+	assert.Equal(t, "Hello world.\n\nfunc main() {\n    fmt.Println(1)\n}",
+		Whitespace("Hello    world.   \n\n\n\n\nfunc main() {\n    fmt.Println(1)\n}\n\n"))
+}
+
+func TestMessages(t *testing.T) {
+	boilerplate := strings.Repeat("Please follow the company guidelines. ", 10)
+	bigResult, _ := json.Marshal(map[string]string{"output": strings.Repeat("x", 100)})
+	messages := []llms.Message{
+		{Role: "user", Content: content.FromText(boilerplate + "\n\n\n\nFirst question")},
+		{Role: "assistant", ToolCalls: []llms.ToolCall{{ID: "call_1", Name: "search", Arguments: json.RawMessage(`{}`)}}},
+		{Role: "tool", ToolCallID: "call_1", Content: content.FromRawJSON(json.RawMessage("{\n  \"output\": \"short\"\n}"))},
+		{Role: "tool", ToolCallID: "call_2", Content: content.FromRawJSON(bigResult)},
+		{Role: "user", Content: content.FromText(boilerplate + "\n\n\n\nFirst question")},
+		{Role: "user", Content: content.FromText("Latest   question")},
+	}
+	opts := Options{KeepRecent: 1, MaxToolResultChars: 50, MinRepeatChars: 100}
+	compressed := Messages(messages, opts)
+
+	require.Len(t, compressed, len(messages))
+	assert.Equal(t, boilerplate[:len(boilerplate)-1]+"\n\nFirst question", compressed[0].Content[0].(*content.Text).Text)
+	assert.Equal(t, messages[1], compressed[1])
+	assert.JSONEq(t, `{"output":"short"}`, string(compressed[2].Content[0].(*content.JSON).Data))
+	assert.Equal(t, `{"output":"short"}`, string(compressed[2].Content[0].(*content.JSON).Data), "JSON should be compacted")
+	var truncated map[string]string
+	require.NoError(t, json.Unmarshal(compressed[3].Content[0].(*content.JSON).Data, &truncated))
+	assert.Len(t, truncated["truncated"], 50)
+	assert.Contains(t, compressed[4].Content[0].(*content.Text).Text, "repeats earlier content")
+	assert.Equal(t, messages[5], compressed[5], "Recent messages should be left as they are")
+	assert.Contains(t, messages[0].Content[0].(*content.Text).Text, "\n\n\n\n", "The original messages must not change")
+}
+
+type recordingProvider struct {
+	messages []llms.Message
+}
+
+func (p *recordingProvider) Company() string { return "OpenAI" }
+func (p *recordingProvider) Model() string   { return "gpt-4o" }
+func (p *recordingProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *recordingProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	p.messages = messages
+	return nil
+}
+
+func TestWrap(t *testing.T) {
+	inner := &recordingProvider{}
+	var stats Stats
+	p := Wrap(inner, Options{KeepRecent: 1, OnCompress: func(s Stats) { stats = s }})
+	p.Generate(context.Background(), nil, []llms.Message{
+		{Role: "user", Content: content.FromText("Hello" + strings.Repeat(" ", 400) + "there")},
+		{Role: "user", Content: content.FromText("Hi")},
+	}, nil)
+	assert.Equal(t, "Hello there", inner.messages[0].Content[0].(*content.Text).Text)
+	assert.Equal(t, "gpt-4o", stats.Model)
+	assert.Equal(t, 100, stats.TokensSaved())
+	assert.InDelta(t, 100*2.5/1_000_000, stats.SavedUSD, 1e-12)
+}
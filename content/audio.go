@@ -0,0 +1,39 @@
+package content
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FromAudio returns a new content item with the given audio, for models that
+// accept audio input. The format is the encoding of the data, such as "wav"
+// or "mp3".
+func FromAudio(data []byte, format string) Content {
+	return Content{
+		&Audio{Data: base64.StdEncoding.EncodeToString(data), Format: format},
+	}
+}
+
+// FromAudioFile reads the audio file at the given path and returns it as
+// content like FromAudio. The format is taken from the file extension.
+func FromAudioFile(path string) (Content, error) {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch format {
+	case "wav", "mp3":
+	default:
+		return nil, fmt.Errorf("unsupported audio format %q", format)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio file: %w", err)
+	}
+	return FromAudio(data, format), nil
+}
+
+// AddAudio adds audio to the content. See FromAudio.
+func (c *Content) AddAudio(data []byte, format string) {
+	*c = append(*c, FromAudio(data, format)...)
+}
@@ -11,6 +11,11 @@ const (
 	TypeText     Type = "text"
 	TypeImageURL Type = "imageURL"
 	TypeJSON     Type = "json"
+	TypeThinking Type = "thinking"
+	TypeAudio    Type = "audio"
+	TypeDocument Type = "document"
+	TypeCitation Type = "citation"
+	TypeFile     Type = "file"
 )
 
 type Item interface {
@@ -41,6 +46,30 @@ func (j *JSON) Type() Type {
 	return TypeJSON
 }
 
+// Thinking is the reasoning a model produced before its final answer. Some
+// providers return a signature which must be sent back along with the text.
+// Reasoning that the provider redacted has no text, and is instead kept as
+// the opaque data that must be sent back unchanged.
+type Thinking struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  string `json:"redacted,omitempty"`
+}
+
+func (t *Thinking) Type() Type {
+	return TypeThinking
+}
+
+// Audio is base64 encoded audio in the given format, such as "wav" or "mp3".
+type Audio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
+func (a *Audio) Type() Type {
+	return TypeAudio
+}
+
 type Content []Item
 
 // FromAny marshals the given value to JSON and returns a new JSON content item
@@ -97,6 +126,18 @@ func (c *Content) Append(text string) {
 	*c = append(*c, &Text{Text: text})
 }
 
+// AppendThinking adds the text to the last content item if it's a thinking
+// item, otherwise it adds a new thinking item to the end of the list.
+func (c *Content) AppendThinking(text string) {
+	if l := len(*c); l > 0 {
+		if tc, ok := (*c)[l-1].(*Thinking); ok {
+			tc.Text += text
+			return
+		}
+	}
+	*c = append(*c, &Thinking{Text: text})
+}
+
 // MarshalJSON implements the json.Marshaler interface for Content.
 func (c Content) MarshalJSON() ([]byte, error) {
 	items := make([]map[string]any, len(c))
@@ -148,6 +189,16 @@ func (c *Content) UnmarshalJSON(data []byte) error {
 			item = &ImageURL{}
 		case TypeJSON:
 			item = &JSON{}
+		case TypeThinking:
+			item = &Thinking{}
+		case TypeAudio:
+			item = &Audio{}
+		case TypeDocument:
+			item = &Document{}
+		case TypeCitation:
+			item = &Citation{}
+		case TypeFile:
+			item = &File{}
 		default:
 			return fmt.Errorf("unknown content item type: %q", typeContainer.Type)
 		}
@@ -33,6 +33,11 @@ func TestContentMarshalJSON(t *testing.T) {
 			content: FromRawJSON(json.RawMessage(`{"foo":"bar"}`)),
 			want:    `[{"data":{"foo":"bar"},"type":"json"}]`,
 		},
+		{
+			name:    "audio content",
+			content: FromAudio([]byte("RIFF"), "wav"),
+			want:    `[{"data":"UklGRg==","format":"wav","type":"audio"}]`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -77,6 +82,11 @@ func TestContentUnmarshalJSON(t *testing.T) {
 			json: `[{"type":"json","data":{"foo":"bar"}}]`,
 			want: FromRawJSON(json.RawMessage(`{"foo":"bar"}`)),
 		},
+		{
+			name: "audio content",
+			json: `[{"type":"audio","data":"UklGRg==","format":"wav"}]`,
+			want: Content{&Audio{Data: "UklGRg==", Format: "wav"}},
+		},
 		{
 			name:    "invalid type",
 			json:    `[{"type":"invalid"}]`,
@@ -136,6 +146,10 @@ func TestContentRoundTrip(t *testing.T) {
 			name:    "json content",
 			content: FromRawJSON(json.RawMessage(`{"foo":"bar"}`)),
 		},
+		{
+			name:    "uploaded file",
+			content: FromFile("file-abc"),
+		},
 		{
 			name: "multiple text items",
 			content: Content{
@@ -143,6 +157,14 @@ func TestContentRoundTrip(t *testing.T) {
 				&Text{Text: "world"},
 			},
 		},
+		{
+			name: "thinking and text",
+			content: Content{
+				&Thinking{Text: "hmm", Signature: "sig"},
+				&Thinking{Redacted: "EmwKAhgBEgy3va3pzix"},
+				&Text{Text: "answer"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -219,3 +241,19 @@ func TestContentAppend(t *testing.T) {
 		assert.Equal(t, "start middle end", textItem.Text)
 	})
 }
+
+func TestContentAppendThinking(t *testing.T) {
+	var c Content
+	c.AppendThinking("Let me ")
+	c.AppendThinking("think.")
+	c.Append("Answer")
+	c.AppendThinking("More")
+	require.Len(t, c, 3)
+	thinking, ok := c[0].(*Thinking)
+	require.True(t, ok)
+	assert.Equal(t, "Let me think.", thinking.Text)
+	_, ok = c[1].(*Text)
+	require.True(t, ok)
+	_, ok = c[2].(*Thinking)
+	require.True(t, ok)
+}
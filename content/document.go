@@ -0,0 +1,91 @@
+package content
+
+import "encoding/base64"
+
+// Document is a document for the model to read, such as a text file or a PDF.
+// Providers that support it can cite the parts of the document that their
+// answer is based on, which are returned as Citation items.
+type Document struct {
+	Title string `json:"title,omitempty"`
+	// Context is information about the document that isn't quoted in
+	// citations, such as where it came from.
+	Context string `json:"context,omitempty"`
+	// MediaType is "text/plain" for documents where Data is the text itself,
+	// or "application/pdf" for PDFs with base64 encoded Data.
+	MediaType string `json:"media_type"`
+	Data      string `json:"data,omitempty"`
+	// URL is where the provider can download a PDF from, instead of it
+	// being sent as Data.
+	URL string `json:"url,omitempty"`
+	// Citations asks the model to cite the document.
+	Citations bool `json:"citations,omitempty"`
+}
+
+func (d *Document) Type() Type {
+	return TypeDocument
+}
+
+// Citation is a quote from a Document, or a web page found by a web search,
+// that supports the text item before it.
+type Citation struct {
+	CitedText     string `json:"cited_text"`
+	DocumentIndex int    `json:"document_index"`
+	DocumentTitle string `json:"document_title,omitempty"`
+	// URL is the address of the web page, for citations of web search
+	// results. DocumentTitle is then the title of the page.
+	URL string `json:"url,omitempty"`
+	// Location is "char" if Start and End are character indices in a text
+	// document, "page" if they're page numbers in a PDF, and "block" if they
+	// are indices of content blocks. End is exclusive. For web pages it's
+	// "url", and Start and End are the character indices of the text of the
+	// response that the page supports.
+	Location string `json:"location"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+func (c *Citation) Type() Type {
+	return TypeCitation
+}
+
+// File is a file that was uploaded to the provider ahead of time, such as a
+// PDF uploaded with the OpenAI Files API, referenced by its ID so that it
+// doesn't have to be sent with every request.
+type File struct {
+	ID string `json:"id"`
+}
+
+func (f *File) Type() Type {
+	return TypeFile
+}
+
+// FromFile returns a new content item that references an uploaded file.
+func FromFile(id string) Content {
+	return Content{
+		&File{ID: id},
+	}
+}
+
+// FromTextDocument returns a new content item with a text document that the
+// model may cite.
+func FromTextDocument(title, text string) Content {
+	return Content{
+		&Document{Title: title, MediaType: "text/plain", Data: text, Citations: true},
+	}
+}
+
+// FromPDFURL returns a new content item with a PDF document that the provider
+// downloads from the URL, and that the model may cite.
+func FromPDFURL(title, url string) Content {
+	return Content{
+		&Document{Title: title, MediaType: "application/pdf", URL: url, Citations: true},
+	}
+}
+
+// FromPDFDocument returns a new content item with a PDF document that the
+// model may cite.
+func FromPDFDocument(title string, pdf []byte) Content {
+	return Content{
+		&Document{Title: title, MediaType: "application/pdf", Data: base64.StdEncoding.EncodeToString(pdf), Citations: true},
+	}
+}
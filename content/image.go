@@ -1,104 +1,57 @@
 package content
 
 import (
-	"encoding/base64"
-	"fmt"
 	"image"
-	_ "image/gif"
-	"image/jpeg"
-	"image/png"
-	"os"
-	"path/filepath"
-	"strings"
 
-	"golang.org/x/image/draw"
-	_ "golang.org/x/image/webp"
+	"github.com/blixt/go-llms/internal/imaging"
 )
 
 // ImageToDataURI reads an image from the given path, resizes it if necessary
 // based on the quality setting, encodes it as base64, and returns its filename
 // and a data URI string.
+//
+// Deprecated: Use images.ToDataURI from github.com/blixt/go-llms/content/images,
+// which keeps the image dependencies out of programs that don't need them.
 func ImageToDataURI(path string, highQuality bool) (name, dataURI string, err error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to open image file: %w", err)
-	}
-	defer file.Close()
-
-	img, format, err := image.Decode(file)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to decode image: %w", err)
-	}
-
-	// Check image dimensions and resize if necessary.
-	var maxDim int
-	if highQuality {
-		// Common max dimension for high quality in models like GPT-4 Vision
-		maxDim = 2048
-	} else {
-		// Common max dimension for low quality / faster processing
-		maxDim = 512
-	}
+	return imaging.ToDataURI(path, highQuality)
+}
 
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-	if width > maxDim || height > maxDim {
-		var newWidth, newHeight int
-		if width > height {
-			newWidth = maxDim
-			newHeight = (height * maxDim) / width
-		} else {
-			newHeight = maxDim
-			newWidth = (width * maxDim) / height
-		}
+// TileOptions controls how large images are split into tiles.
+//
+// Deprecated: Use images.TileOptions from
+// github.com/blixt/go-llms/content/images.
+type TileOptions struct {
+	MaxTileSize int
+	MaxTiles    int
+	Overview    bool
+}
 
-		resizedImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
-		// Use a high-quality scaler
-		draw.CatmullRom.Scale(resizedImg, resizedImg.Bounds(), img, bounds, draw.Over, nil)
-		img = resizedImg // Replace original with resized image
-	}
+// FromLargeImage returns content that shows a large image as a grid of tiles.
+//
+// Deprecated: Use images.FromLargeImage from
+// github.com/blixt/go-llms/content/images.
+func FromLargeImage(img image.Image, opts TileOptions) (Content, error) {
+	tiles, err := imaging.TileImage(img, "png", opts.MaxTileSize, opts.MaxTiles, opts.Overview)
+	return fromTiles(tiles), err
+}
 
-	// Encode the image data into a base64 string.
-	var encodedImage strings.Builder
-	encoder := base64.NewEncoder(base64.StdEncoding, &encodedImage)
+// FromLargeImageFile reads the image at the given path and tiles it like
+// FromLargeImage.
+//
+// Deprecated: Use images.FromLargeImageFile from
+// github.com/blixt/go-llms/content/images.
+func FromLargeImageFile(path string, opts TileOptions) (Content, error) {
+	tiles, err := imaging.TileFile(path, opts.MaxTileSize, opts.MaxTiles, opts.Overview)
+	return fromTiles(tiles), err
+}
 
-	var mimeType string
-	switch format {
-	case "jpeg":
-		err = jpeg.Encode(encoder, img, &jpeg.Options{Quality: 90}) // Use good quality for JPEG
-		mimeType = "image/jpeg"
-	case "png":
-		err = png.Encode(encoder, img)
-		mimeType = "image/png"
-	case "gif":
-		// Note: Encoding animated GIFs is complex; this will encode the first frame.
-		// Consider using a dedicated GIF library if animation needs preservation.
-		err = jpeg.Encode(encoder, img, &jpeg.Options{Quality: 90}) // Encode as JPEG for simplicity
-		mimeType = "image/jpeg"                                     // Pretend it's JPEG
-	case "webp":
-		// Go's standard library doesn't have a webp encoder. Need external library or convert.
-		// Encode as PNG as a fallback.
-		err = png.Encode(encoder, img)
-		mimeType = "image/png"
-	default:
-		// Fallback: attempt to encode as PNG
-		err = png.Encode(encoder, img)
-		mimeType = "image/png"
-		if err != nil {
-			return "", "", fmt.Errorf("unsupported image format %q and failed fallback to PNG: %w", format, err)
+func fromTiles(tiles []imaging.Tile) Content {
+	var c Content
+	for _, tile := range tiles {
+		if tile.Caption != "" {
+			c = append(c, &Text{Text: tile.Caption})
 		}
+		c = append(c, &ImageURL{URL: tile.DataURI})
 	}
-	// Close the encoder *after* encoding attempts.
-	closeErr := encoder.Close()
-	if err != nil {
-		return "", "", fmt.Errorf("failed to encode image as %q: %w", mimeType, err)
-	}
-	if closeErr != nil {
-		// This might happen if the writer (strings.Builder) fails
-		return "", "", fmt.Errorf("failed to close image encoder: %w", closeErr)
-	}
-
-	dataURI = fmt.Sprintf("data:%s;base64,%s", mimeType, encodedImage.String())
-	name = filepath.Base(path)
-	return name, dataURI, nil
+	return c
 }
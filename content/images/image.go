@@ -0,0 +1,12 @@
+package images
+
+import (
+	"github.com/blixt/go-llms/internal/imaging"
+)
+
+// ToDataURI reads an image from the given path, resizes it if necessary
+// based on the quality setting, encodes it as base64, and returns its filename
+// and a data URI string.
+func ToDataURI(path string, highQuality bool) (name, dataURI string, err error) {
+	return imaging.ToDataURI(path, highQuality)
+}
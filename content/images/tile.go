@@ -0,0 +1,49 @@
+package images
+
+import (
+	"image"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/internal/imaging"
+)
+
+// TileOptions controls how large images are split into tiles.
+type TileOptions struct {
+	// MaxTileSize is the maximum width and height of a tile in pixels. It
+	// defaults to 1024.
+	MaxTileSize int
+	// MaxTiles is the maximum number of tiles. Images that would need more
+	// tiles are scaled down first. It defaults to 6.
+	MaxTiles int
+	// Overview adds a scaled down version of the whole image before the tiles,
+	// which helps the model see how the tiles relate to each other.
+	Overview bool
+}
+
+// FromLargeImage returns content that shows a large image, such as a
+// screenshot, as a grid of tiles that vision models can read at full
+// resolution. Each tile is preceded by a caption telling the model which part
+// of the image it shows, in the pixel coordinates of the original image. An
+// image that fits in a single tile is returned as is.
+func FromLargeImage(img image.Image, opts TileOptions) (content.Content, error) {
+	tiles, err := imaging.TileImage(img, "png", opts.MaxTileSize, opts.MaxTiles, opts.Overview)
+	return fromTiles(tiles), err
+}
+
+// FromLargeImageFile reads the image at the given path and tiles it like
+// FromLargeImage.
+func FromLargeImageFile(path string, opts TileOptions) (content.Content, error) {
+	tiles, err := imaging.TileFile(path, opts.MaxTileSize, opts.MaxTiles, opts.Overview)
+	return fromTiles(tiles), err
+}
+
+func fromTiles(tiles []imaging.Tile) content.Content {
+	var c content.Content
+	for _, tile := range tiles {
+		if tile.Caption != "" {
+			c = append(c, &content.Text{Text: tile.Caption})
+		}
+		c = append(c, &content.ImageURL{URL: tile.DataURI})
+	}
+	return c
+}
@@ -0,0 +1,58 @@
+package images
+
+import (
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeTile decodes the PNG data URI of an image item.
+func decodeTile(t *testing.T, item content.Item) image.Image {
+	imageURL, ok := item.(*content.ImageURL)
+	require.True(t, ok, "Item should be an image")
+	data, ok := strings.CutPrefix(imageURL.URL, "data:image/png;base64,")
+	require.True(t, ok, "Image should be a PNG data URI")
+	img, err := png.Decode(base64.NewDecoder(base64.StdEncoding, strings.NewReader(data)))
+	require.NoError(t, err)
+	return img
+}
+
+func TestFromLargeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1500, 900))
+	c, err := FromLargeImage(img, TileOptions{MaxTileSize: 1000})
+	require.NoError(t, err)
+
+	// 2x1 tiles, each preceded by a caption.
+	require.Len(t, c, 4)
+	assert.Equal(t, &content.Text{Text: "Tile 1 of 2 (row 1, column 1), showing x=0 to 750 and y=0 to 900 of the 1500x900 image:"}, c[0])
+	assert.Equal(t, image.Rect(0, 0, 750, 900), decodeTile(t, c[1]).Bounds())
+	assert.Equal(t, &content.Text{Text: "Tile 2 of 2 (row 1, column 2), showing x=750 to 1500 and y=0 to 900 of the 1500x900 image:"}, c[2])
+	assert.Equal(t, image.Rect(0, 0, 750, 900), decodeTile(t, c[3]).Bounds())
+}
+
+func TestFromLargeImageDownscales(t *testing.T) {
+	// A tall screenshot that would need 1x8 tiles at full resolution.
+	img := image.NewRGBA(image.Rect(0, 0, 500, 4000))
+	c, err := FromLargeImage(img, TileOptions{MaxTileSize: 500, MaxTiles: 4, Overview: true})
+	require.NoError(t, err)
+
+	require.Len(t, c, 10, "Expected an overview and 4 tiles")
+	assert.Equal(t, &content.Text{Text: "Overview of the whole 500x4000 image, followed by 4 tiles showing it in more detail:"}, c[0])
+	assert.Equal(t, image.Rect(0, 0, 62, 500), decodeTile(t, c[1]).Bounds())
+	assert.Equal(t, &content.Text{Text: "Tile 4 of 4 (row 4, column 1), showing x=0 to 500 and y=3000 to 4000 of the 500x4000 image:"}, c[8])
+	assert.Equal(t, image.Rect(0, 0, 250, 500), decodeTile(t, c[9]).Bounds())
+}
+
+func TestFromLargeImageSmall(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	c, err := FromLargeImage(img, TileOptions{Overview: true})
+	require.NoError(t, err)
+	require.Len(t, c, 1, "Small images should not be tiled")
+	assert.Equal(t, image.Rect(0, 0, 200, 100), decodeTile(t, c[0]).Bounds())
+}
@@ -0,0 +1,17 @@
+// Package deepseek provides access to DeepSeek's models. DeepSeek serves an
+// OpenAI-compatible API, so this package configures the openai provider for
+// it. The chain-of-thought of deepseek-reasoner is streamed as thinking
+// (llms.ThinkingUpdate) separately from the final answer.
+package deepseek
+
+import (
+	"github.com/blixt/go-llms/openai"
+)
+
+const endpoint = "https://api.deepseek.com/chat/completions"
+
+// New returns a provider for the given DeepSeek model, such as "deepseek-chat"
+// or "deepseek-reasoner".
+func New(apiKey, model string) *openai.Model {
+	return openai.New(apiKey, model).WithEndpoint(endpoint, "DeepSeek")
+}
@@ -0,0 +1,206 @@
+// Package experiment runs controlled rollouts of models and prompts. Every
+// conversation is assigned a variant of the experiment, its usage and updates
+// are tagged with the variant, and outcomes are aggregated per variant so the
+// variants can be compared.
+package experiment
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Variant is one arm of an experiment.
+type Variant struct {
+	// Name identifies the variant in usage, updates and results.
+	Name string
+	// Provider serves the conversations of the variant, configured with the
+	// model and sampling parameters (such as temperature) being tested.
+	Provider llms.Provider
+	// SystemPrompt, if set, is the system prompt of the variant, for testing
+	// prompt versions.
+	SystemPrompt content.Content
+	// Weight is the share of conversations assigned to the variant, relative
+	// to the other variants. It defaults to 1.
+	Weight float64
+}
+
+// Usage is the usage of a turn, tagged with the variant of its conversation.
+type Usage struct {
+	llms.Usage
+	Experiment     string
+	Variant        string
+	ConversationID string
+}
+
+// Update is an update of a chat, tagged with the variant of its conversation.
+type Update struct {
+	llms.Update
+	Variant string
+}
+
+// Result aggregates what the conversations of a variant used and achieved.
+type Result struct {
+	Variant       string
+	Conversations int
+	Turns         int
+	InputTokens   int
+	OutputTokens  int
+	CostUSD       float64
+	// Outcomes is the number of outcomes recorded with RecordOutcome, and
+	// OutcomeSum is the sum of their values.
+	Outcomes   int
+	OutcomeSum float64
+}
+
+// MeanOutcome returns the average value of the recorded outcomes, or zero if
+// there are none.
+func (r Result) MeanOutcome() float64 {
+	if r.Outcomes == 0 {
+		return 0
+	}
+	return r.OutcomeSum / float64(r.Outcomes)
+}
+
+// Experiment assigns conversations to variants. It's safe for concurrent use.
+type Experiment struct {
+	name     string
+	variants []Variant
+	onUsage  func(Usage)
+
+	mu            sync.Mutex
+	results       map[string]*Result
+	conversations map[string]bool
+}
+
+// New returns an experiment with the given variants. The name is part of how
+// conversations are assigned, so different experiments split conversations
+// independently of each other.
+func New(name string, variants ...Variant) *Experiment {
+	if len(variants) == 0 {
+		panic("experiment needs at least one variant")
+	}
+	e := &Experiment{
+		name:          name,
+		variants:      variants,
+		results:       make(map[string]*Result),
+		conversations: make(map[string]bool),
+	}
+	for _, v := range variants {
+		e.results[v.Name] = &Result{Variant: v.Name}
+	}
+	return e
+}
+
+// WithUsageCallback makes the experiment call the provided function with the
+// tagged usage of every turn of its conversations.
+func (e *Experiment) WithUsageCallback(callback func(Usage)) *Experiment {
+	e.onUsage = callback
+	return e
+}
+
+// Name returns the name of the experiment.
+func (e *Experiment) Name() string {
+	return e.name
+}
+
+// Assign returns the variant of the conversation. The assignment only depends
+// on the name of the experiment and the conversation ID, so a conversation
+// gets the same variant in every process.
+func (e *Experiment) Assign(conversationID string) Variant {
+	var total float64
+	for _, v := range e.variants {
+		total += weight(v)
+	}
+	sum := sha256.Sum256([]byte(e.name + "\x00" + conversationID))
+	point := float64(binary.BigEndian.Uint64(sum[:])>>11) / (1 << 53) * total
+	for _, v := range e.variants {
+		if point < weight(v) {
+			return v
+		}
+		point -= weight(v)
+	}
+	return e.variants[len(e.variants)-1]
+}
+
+func weight(v Variant) float64 {
+	if v.Weight <= 0 {
+		return 1
+	}
+	return v.Weight
+}
+
+// New returns an LLM for the conversation, configured with its variant. The
+// usage of the LLM is recorded by the experiment, so use WithUsageCallback on
+// the experiment rather than on the LLM.
+func (e *Experiment) New(conversationID string, allTools ...tools.Tool) (*llms.LLM, Variant) {
+	v := e.Assign(conversationID)
+	e.mu.Lock()
+	if !e.conversations[conversationID] {
+		e.conversations[conversationID] = true
+		e.results[v.Name].Conversations++
+	}
+	e.mu.Unlock()
+	llm := llms.New(v.Provider, allTools...).WithUsageCallback(func(u llms.Usage) {
+		e.recordUsage(conversationID, v, u)
+	})
+	if v.SystemPrompt != nil {
+		llm.SystemPrompt = func() content.Content { return v.SystemPrompt }
+	}
+	return llm, v
+}
+
+func (e *Experiment) recordUsage(conversationID string, v Variant, u llms.Usage) {
+	e.mu.Lock()
+	r := e.results[v.Name]
+	r.Turns++
+	r.InputTokens += u.InputTokens
+	r.OutputTokens += u.OutputTokens
+	r.CostUSD += u.CostUSD
+	e.mu.Unlock()
+	if e.onUsage != nil {
+		e.onUsage(Usage{Usage: u, Experiment: e.name, Variant: v.Name, ConversationID: conversationID})
+	}
+}
+
+// Forward tags the updates of a chat in the conversation with its variant. Use
+// it on the channel returned by the Chat methods.
+func (e *Experiment) Forward(conversationID string, updates <-chan llms.Update) <-chan Update {
+	variant := e.Assign(conversationID).Name
+	out := make(chan Update)
+	go func() {
+		defer close(out)
+		for update := range updates {
+			out <- Update{Update: update, Variant: variant}
+		}
+	}()
+	return out
+}
+
+// RecordOutcome records how well the conversation went, for example 1 if the
+// user's task was completed and 0 if not, or a rating. Its variant's result
+// aggregates all outcomes.
+func (e *Experiment) RecordOutcome(conversationID string, value float64) {
+	v := e.Assign(conversationID)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r := e.results[v.Name]
+	r.Outcomes++
+	r.OutcomeSum += value
+}
+
+// Results returns the results of every variant, in the order they were given
+// to New.
+func (e *Experiment) Results() []Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	results := make([]Result, 0, len(e.variants))
+	for _, v := range e.variants {
+		results = append(results, *e.results[v.Name])
+	}
+	return results
+}
@@ -0,0 +1,109 @@
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textProvider replies with its model name and remembers the system prompt.
+type textProvider struct {
+	model        string
+	systemPrompt content.Content
+}
+
+func (p *textProvider) Company() string { return "Test" }
+func (p *textProvider) Model() string   { return p.model }
+func (p *textProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *textProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	p.systemPrompt = systemPrompt
+	return &textStream{text: p.model}
+}
+
+type textStream struct {
+	text string
+}
+
+func (s *textStream) Err() error              { return nil }
+func (s *textStream) Text() string            { return s.text }
+func (s *textStream) ToolCall() llms.ToolCall { return llms.ToolCall{} }
+func (s *textStream) Usage() (int, int)       { return 10, 5 }
+
+func (s *textStream) Message() llms.Message {
+	return llms.Message{Role: "assistant", Content: content.FromText(s.text)}
+}
+
+func (s *textStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		yield(llms.StreamStatusText)
+	}
+}
+
+func TestAssign(t *testing.T) {
+	e := New("model-test",
+		Variant{Name: "control", Provider: &textProvider{model: "a"}, Weight: 3},
+		Variant{Name: "treatment", Provider: &textProvider{model: "b"}},
+	)
+	counts := map[string]int{}
+	for i := range 4000 {
+		id := fmt.Sprintf("conv-%d", i)
+		v := e.Assign(id)
+		assert.Equal(t, v.Name, e.Assign(id).Name, "Assignments should be stable")
+		counts[v.Name]++
+	}
+	assert.InDelta(t, 3000, counts["control"], 150)
+	assert.InDelta(t, 1000, counts["treatment"], 150)
+}
+
+func TestExperiment(t *testing.T) {
+	prompt := content.FromText("Be brief.")
+	treatment := &textProvider{model: "b"}
+	var usage []Usage
+	e := New("prompt-test",
+		Variant{Name: "control", Provider: &textProvider{model: "a"}},
+		Variant{Name: "treatment", Provider: treatment, SystemPrompt: prompt},
+	).WithUsageCallback(func(u Usage) { usage = append(usage, u) })
+	conversationID := "conv-0"
+	for i := 1; e.Assign(conversationID).Name != "treatment"; i++ {
+		conversationID = fmt.Sprintf("conv-%d", i)
+	}
+
+	llm, v := e.New(conversationID)
+	assert.Equal(t, "treatment", v.Name)
+	var updates []Update
+	for update := range e.Forward(conversationID, llm.Chat("Hello")) {
+		updates = append(updates, update)
+	}
+	require.NoError(t, llm.Err())
+	require.Len(t, updates, 1)
+	assert.Equal(t, "treatment", updates[0].Variant)
+	assert.Equal(t, llms.TextUpdate{Text: "b", CorrelationID: llm.CorrelationID()}, updates[0].Update)
+	assert.Equal(t, prompt, treatment.systemPrompt)
+	require.Len(t, usage, 1)
+	assert.Equal(t, "prompt-test", usage[0].Experiment)
+	assert.Equal(t, "treatment", usage[0].Variant)
+	assert.Equal(t, conversationID, usage[0].ConversationID)
+
+	e.RecordOutcome(conversationID, 1)
+	e.RecordOutcome(conversationID, 0)
+	results := e.Results()
+	require.Len(t, results, 2)
+	assert.Equal(t, Result{Variant: "control"}, results[0])
+	assert.Equal(t, Result{
+		Variant:       "treatment",
+		Conversations: 1,
+		Turns:         1,
+		InputTokens:   10,
+		OutputTokens:  5,
+		Outcomes:      2,
+		OutcomeSum:    1,
+	}, results[1])
+	assert.Equal(t, 0.5, results[1].MeanOutcome())
+}
@@ -0,0 +1,94 @@
+// Package extract uses vision models to turn images of documents into typed
+// Go values.
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Row is a row of an extracted table.
+type Row[T any] struct {
+	Value T
+	// Uncertain lists the JSON names of the fields the model could not read
+	// with confidence, for example because the text was blurry or cut off.
+	// Providers don't expose token probabilities, so this is reported by the
+	// model itself.
+	Uncertain []string
+}
+
+type tableRow[T any] struct {
+	Cells     T        `json:"cells" description:"The values of the cells in the row."`
+	Uncertain []string `json:"uncertain,omitempty" description:"The names of the fields in cells that could not be read with confidence, if any."`
+}
+
+type tableParams[T any] struct {
+	Rows []tableRow[T] `json:"rows" description:"Every row of the table, in order, excluding the header."`
+}
+
+// Table extracts the rows of a table shown in the images of the content, such
+// as a screenshot or the pages of a scanned document, into a slice of T.
+// T must be a struct, and its fields (with their json and description tags)
+// describe the columns to extract. The content may include text with
+// instructions, for example to say which table to extract when there are
+// several. Tables spanning multiple images are extracted as one table, so
+// large images can be tiled with images.FromLargeImage.
+func Table[T any](ctx context.Context, provider llms.Provider, c content.Content) ([]Row[T], error) {
+	// TypeFor also works for interface types, whose zero value has no type.
+	if t := reflect.TypeFor[T](); t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("table rows must be structs, got %s", t)
+	}
+
+	var rows []Row[T]
+	submitted := false
+	submit := tools.Func("Submit table", "Submit the rows of the table shown in the images.", "submit_table", func(r tools.Runner, p tableParams[T]) tools.Result {
+		rows = make([]Row[T], len(p.Rows))
+		for i, row := range p.Rows {
+			rows[i] = Row[T]{Value: row.Cells, Uncertain: row.Uncertain}
+		}
+		submitted = true
+		return tools.Success(map[string]int{"rows": len(rows)})
+	})
+	toolbox := tools.Box(submit)
+
+	systemPrompt := content.FromText("Extract the table shown in the images the user provides by calling submit_table exactly once with every row of the table. Copy the values exactly as written, and leave out header and total rows. If a cell is hard to read, give your best guess and list its field as uncertain.")
+	messages := []llms.Message{{Role: "user", Content: c}}
+	stream := provider.Generate(ctx, systemPrompt, messages, toolbox)
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("LLM returned error response: %w", err)
+	}
+	for status := range stream.Iter() {
+		if status != llms.StreamStatusToolCallReady {
+			continue
+		}
+		toolCall := stream.ToolCall()
+		runner := tools.NewRunner(ctx, toolbox, func(string) {})
+		result := toolbox.Run(runner, toolCall.Name, json.RawMessage(toolCall.Arguments))
+		if err := result.Error(); err != nil {
+			return nil, fmt.Errorf("invalid table: %w", err)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stream: %w", err)
+	}
+	if !submitted {
+		return nil, fmt.Errorf("LLM did not extract a table: %s", textOf(stream.Message().Content))
+	}
+	return rows, nil
+}
+
+func textOf(c content.Content) string {
+	var text string
+	for _, item := range c {
+		if t, ok := item.(*content.Text); ok {
+			text += t.Text
+		}
+	}
+	return text
+}
@@ -0,0 +1,100 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider responds with a single message, which may contain a tool call.
+type fakeProvider struct {
+	message llms.Message
+	toolbox *tools.Toolbox
+}
+
+func (p *fakeProvider) Company() string { return "Fake" }
+func (p *fakeProvider) Model() string   { return "fake" }
+func (p *fakeProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *fakeProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	p.toolbox = toolbox
+	return &fakeStream{message: p.message}
+}
+
+type fakeStream struct {
+	message llms.Message
+}
+
+func (s *fakeStream) Err() error              { return nil }
+func (s *fakeStream) Message() llms.Message   { return s.message }
+func (s *fakeStream) Text() string            { return "" }
+func (s *fakeStream) ToolCall() llms.ToolCall { return s.message.ToolCalls[0] }
+func (s *fakeStream) Usage() (int, int)       { return 0, 0 }
+
+func (s *fakeStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		if len(s.message.ToolCalls) > 0 {
+			yield(llms.StreamStatusToolCallReady)
+		}
+	}
+}
+
+type invoiceLine struct {
+	Description string  `json:"description"`
+	Quantity    int     `json:"quantity"`
+	Price       float64 `json:"price" description:"The unit price."`
+}
+
+func TestTable(t *testing.T) {
+	provider := &fakeProvider{message: llms.Message{
+		Role: "assistant",
+		ToolCalls: []llms.ToolCall{{
+			ID:        "call_1",
+			Name:      "submit_table",
+			Arguments: json.RawMessage(`{"rows":[{"cells":{"description":"Widget","quantity":2,"price":9.5}},{"cells":{"description":"Gadget","quantity":1,"price":120},"uncertain":["price"]}]}`),
+		}},
+	}}
+
+	rows, err := Table[invoiceLine](context.Background(), provider, content.FromTextAndImage("Extract the invoice lines.", "data:image/png;base64,AAAA"))
+	require.NoError(t, err)
+	assert.Equal(t, []Row[invoiceLine]{
+		{Value: invoiceLine{Description: "Widget", Quantity: 2, Price: 9.5}},
+		{Value: invoiceLine{Description: "Gadget", Quantity: 1, Price: 120}, Uncertain: []string{"price"}},
+	}, rows)
+
+	schema := provider.toolbox.Get("submit_table").Schema()
+	cells := (*(*schema.Parameters.Properties)["rows"].Items.Properties)["cells"]
+	assert.Equal(t, "The unit price.", (*cells.Properties)["price"].Description, "Columns should be described by the struct")
+}
+
+func TestTableInvalid(t *testing.T) {
+	provider := &fakeProvider{message: llms.Message{
+		Role: "assistant",
+		ToolCalls: []llms.ToolCall{{
+			ID:        "call_1",
+			Name:      "submit_table",
+			Arguments: json.RawMessage(`{"rows":[{"cells":{"description":"Widget","quantity":"two","price":9.5}}]}`),
+		}},
+	}}
+	_, err := Table[invoiceLine](context.Background(), provider, content.FromText("Extract the table."))
+	assert.ErrorContains(t, err, "invalid table")
+
+	provider = &fakeProvider{message: llms.Message{Role: "assistant", Content: content.FromText("I don't see a table.")}}
+	_, err = Table[invoiceLine](context.Background(), provider, content.FromText("Extract the table."))
+	assert.ErrorContains(t, err, "I don't see a table.")
+}
+
+func TestTableUnsupportedType(t *testing.T) {
+	provider := &fakeProvider{}
+	_, err := Table[string](context.Background(), provider, content.FromText("Extract the table."))
+	assert.EqualError(t, err, "table rows must be structs, got string")
+	_, err = Table[any](context.Background(), provider, content.FromText("Extract the table."))
+	assert.EqualError(t, err, "table rows must be structs, got interface {}", "Interface types should be rejected without panicking")
+	assert.Nil(t, provider.toolbox, "Nothing should be sent to the provider")
+}
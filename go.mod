@@ -6,7 +6,6 @@ require (
 	github.com/joho/godotenv v1.5.1
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/image v0.26.0
-	sigs.k8s.io/yaml v1.4.0
 )
 
 require (
@@ -0,0 +1,58 @@
+package google
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// APIError is returned for requests that the API responded to with an error
+// status. Use errors.As to get it from the error of a stream.
+type APIError struct {
+	// StatusCode and Status are the HTTP status of the response, such as 429
+	// and "429 Too Many Requests".
+	StatusCode int
+	Status     string
+	// Message and Reason are from the error in the response body, if it had
+	// one. Reason is a status such as "RESOURCE_EXHAUSTED".
+	Message string
+	Reason  string
+	// RetryAfter is how long the API asked to wait before trying again, or 0
+	// if it didn't say.
+	RetryAfter time.Duration
+}
+
+// HTTPStatus returns the status code of the response, so that APIError
+// implements llms.HTTPError.
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// RetryDelay returns RetryAfter, so that APIError implements llms.HTTPError.
+func (e *APIError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// newAPIError reads the error from a response with an error status.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode, Status: resp.Status}
+	if seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds * float64(time.Second))
+	}
+	var errResp errorResponse
+	if data, err := io.ReadAll(resp.Body); err == nil && json.Unmarshal(data, &errResp) == nil {
+		apiErr.Message = errResp.Error.Message
+		apiErr.Reason = errResp.Error.Status
+	}
+	return apiErr
+}
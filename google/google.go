@@ -25,6 +25,7 @@ type Model struct {
 	temperature     float64
 	topK            int
 	topP            float64
+	httpClient      *http.Client
 }
 
 func New(model string) *Model {
@@ -49,6 +50,23 @@ func (m *Model) WithVertexAI(accessToken, projectID, region string) *Model {
 	return m
 }
 
+// WithHTTPClient sets the HTTP client that requests are sent with, for
+// example to set timeouts, proxies or instrumented transports. Without one,
+// the client of the context is used (see llms.ContextWithHTTPClient), or else
+// http.DefaultClient.
+func (m *Model) WithHTTPClient(client *http.Client) *Model {
+	m.httpClient = client
+	return m
+}
+
+// client returns the HTTP client to send requests with.
+func (m *Model) client(ctx context.Context) *http.Client {
+	if m.httpClient != nil {
+		return m.httpClient
+	}
+	return llms.HTTPClientFromContext(ctx)
+}
+
 func (m *Model) WithMaxOutputTokens(maxOutputTokens int) *Model {
 	m.maxOutputTokens = maxOutputTokens
 	return m
@@ -77,6 +95,45 @@ func (m *Model) Model() string {
 	return m.model
 }
 
+func (m *Model) String() string {
+	return llms.ProviderName(m)
+}
+
+// ToolResultTypes returns the types that can be sent in function responses,
+// which are always JSON. Images in tool results are sent in a user message
+// after the function response.
+func (m *Model) ToolResultTypes() []content.Type {
+	return []content.Type{content.TypeJSON}
+}
+
+// Ping looks up the model in the Gemini API, which checks that the API is
+// reachable, that the API key is valid and that the model exists. Vertex AI is
+// checked with llms.PingWithGenerate instead.
+func (m *Model) Ping(ctx context.Context) error {
+	if m.endpoint == "" {
+		return fmt.Errorf("must call either WithVertexAI(…) or WithGenerativeLanguageAPI(…) first")
+	}
+	if m.accessToken != "" {
+		return llms.PingWithGenerate(ctx, m)
+	}
+	// The model's metadata lives at the same URL, minus the method.
+	modelURL := strings.Replace(m.endpoint, ":streamGenerateContent?alt=sse&", "?", 1)
+	req, err := http.NewRequestWithContext(ctx, "GET", modelURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("User-Agent", llms.UserAgent())
+	resp, err := m.client(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+	return nil
+}
+
 func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
 	if m.endpoint == "" {
 		return &Stream{err: fmt.Errorf("must call either WithVertexAI(…) or WithGenerativeLanguageAPI(…) first")}
@@ -157,26 +214,15 @@ func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, mess
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.accessToken))
 	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", llms.UserAgent())
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.client(ctx).Do(req)
 	if err != nil {
 		return &Stream{err: fmt.Errorf("error making request: %w", err)}
 	}
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-
-		if readErr == nil && len(bodyBytes) > 0 {
-			var errResp errorResponse // Assumes this struct matches Google's { "error": { ... } } format
-			if jsonErr := json.Unmarshal(bodyBytes, &errResp); jsonErr == nil && errResp.Error.Message != "" {
-				// Successfully parsed the Google error format
-				return &Stream{err: fmt.Errorf("%s: %s", resp.Status, errResp.Error.Message)}
-			}
-			// Body read okay, but JSON parsing failed or structure mismatch.
-			// Fall through to return status only.
-		}
-		// Default fallback: Read error, empty body, or failed/unexpected JSON parse.
-		return &Stream{err: fmt.Errorf("%s", resp.Status)}
+		return &Stream{err: newAPIError(resp)}
 	}
 	return &Stream{ctx: ctx, model: m.model, stream: resp.Body}
 }
@@ -199,6 +245,12 @@ func (s *Stream) Message() llms.Message {
 	return s.message
 }
 
+// Model returns the model that served the response, which can be a specific
+// snapshot of the requested model.
+func (s *Stream) Model() string {
+	return s.model
+}
+
 func (s *Stream) Text() string {
 	return s.lastText
 }
@@ -245,6 +297,9 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 				s.err = fmt.Errorf("error unmarshalling chunk: %w", err)
 				return
 			}
+			if chunk.ModelVersion != "" {
+				s.model = chunk.ModelVersion
+			}
 			if chunk.UsageMetadata != nil {
 				s.usage = chunk.UsageMetadata
 			}
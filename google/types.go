@@ -79,6 +79,29 @@ func convertContent(c content.Content) (p parts) {
 		case *content.JSON:
 			text := string(v.Data)
 			pp.Text = &text
+		case *content.Audio:
+			pp.InlineData = &inlineData{"audio/" + v.Format, v.Data}
+		case *content.Document:
+			if v.MediaType == "text/plain" {
+				text := v.Data
+				if v.Title != "" {
+					text = v.Title + "\n\n" + text
+				}
+				pp.Text = &text
+			} else if v.URL != "" {
+				pp.FileData = &fileData{MimeType: v.MediaType, FileURI: v.URL}
+			} else {
+				pp.InlineData = &inlineData{v.MediaType, v.Data}
+			}
+		case *content.Citation:
+			// Citations are only understood by the provider that made them.
+			continue
+		case *content.File:
+			// Files are only available to the provider they were uploaded to.
+			continue
+		case *content.Thinking:
+			// Thinking from other providers can't be sent to Gemini.
+			continue
 		default:
 			panic(fmt.Sprintf("unhandled content item type %T", item))
 		}
@@ -226,6 +249,7 @@ type usageMetadata struct {
 type streamingResponse struct {
 	Candidates    []candidate    `json:"candidates"`
 	UsageMetadata *usageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string         `json:"modelVersion,omitempty"`
 }
 
 type candidate struct {
@@ -0,0 +1,150 @@
+package history
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// TurnStats describes a single turn of a stored conversation, which is a
+// response of the model together with the results of the tools it called.
+type TurnStats struct {
+	ConversationID string
+	// Turn is the number of the turn within the conversation, from 1.
+	Turn int
+	// UpdatedAt is when the conversation was last updated.
+	UpdatedAt time.Time
+	Model     string
+	// Tools are the names of the tools the model called, in order.
+	Tools                     []string
+	InputTokens, OutputTokens int
+	// Estimated is true if the token counts are estimated from the messages,
+	// because there was no turn record for the turn.
+	Estimated bool
+	CostUSD   float64
+	// Latency is how long the turn took, if it's known from a turn record.
+	Latency time.Duration
+}
+
+// Exporter aggregates the conversations of a store into statistics per turn,
+// for offline analysis of agent behavior. Stored messages don't say how many
+// tokens a turn used or how long it took, so these come from turn records
+// (see llms.LLM.WithRecorder) if they're provided, and are otherwise
+// estimated.
+type Exporter struct {
+	store   Lister
+	model   string
+	records map[string]llms.TurnRecord
+}
+
+// NewExporter returns an exporter for the conversations of the store.
+func NewExporter(store Lister) *Exporter {
+	return &Exporter{store: store}
+}
+
+// WithModel sets the model that is used to price turns without a turn record.
+func (e *Exporter) WithModel(model string) *Exporter {
+	e.model = model
+	return e
+}
+
+// WithRecords provides turn records to get exact token counts, models and
+// latencies from. A record is matched to the turn of a stored conversation
+// whose prompt it contains.
+func (e *Exporter) WithRecords(records []llms.TurnRecord) *Exporter {
+	e.records = make(map[string]llms.TurnRecord, len(records))
+	for _, record := range records {
+		e.records[llms.Fingerprint(nil, record.Messages)] = record
+	}
+	return e
+}
+
+// Turns returns the statistics of every turn of every stored conversation,
+// ordered by conversation ID and turn.
+func (e *Exporter) Turns(ctx context.Context) ([]TurnStats, error) {
+	infos, err := e.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	slices.SortFunc(infos, func(a, b Info) int { return strings.Compare(a.ID, b.ID) })
+	var turns []TurnStats
+	for _, info := range infos {
+		messages, err := e.store.Load(ctx, info.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load conversation %q: %w", info.ID, err)
+		}
+		turns = append(turns, e.conversationTurns(info, messages)...)
+	}
+	return turns, nil
+}
+
+func (e *Exporter) conversationTurns(info Info, messages []llms.Message) []TurnStats {
+	var turns []TurnStats
+	for i, msg := range messages {
+		if msg.Role != "assistant" {
+			continue
+		}
+		stats := TurnStats{
+			ConversationID: info.ID,
+			Turn:           len(turns) + 1,
+			UpdatedAt:      info.UpdatedAt,
+			Model:          e.model,
+		}
+		for _, call := range msg.ToolCalls {
+			stats.Tools = append(stats.Tools, call.Name)
+		}
+		if record, ok := e.records[llms.Fingerprint(nil, messages[:i])]; ok {
+			stats.Model = record.Model
+			stats.InputTokens, stats.OutputTokens = record.InputTokens, record.OutputTokens
+			stats.Latency = record.Duration
+		} else {
+			stats.InputTokens = llms.EstimateMessageTokens(messages[:i])
+			stats.OutputTokens = llms.EstimateMessageTokens(messages[i : i+1])
+			stats.Estimated = true
+		}
+		if pricing, ok := llms.LookupPricing(stats.Model); ok {
+			stats.CostUSD = pricing.Cost(stats.InputTokens, stats.OutputTokens)
+		}
+		turns = append(turns, stats)
+	}
+	return turns
+}
+
+// WriteCSV writes the statistics of every turn as CSV with a header row, for
+// loading into spreadsheets, databases and dataframes. Tools are separated by
+// semicolons, and the latency is in milliseconds.
+func (e *Exporter) WriteCSV(ctx context.Context, w io.Writer) error {
+	turns, err := e.Turns(ctx)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"conversation_id", "turn", "updated_at", "model", "tool_calls", "tools",
+		"input_tokens", "output_tokens", "estimated", "cost_usd", "latency_ms",
+	})
+	for _, t := range turns {
+		writer.Write([]string{
+			t.ConversationID,
+			strconv.Itoa(t.Turn),
+			t.UpdatedAt.UTC().Format(time.RFC3339),
+			t.Model,
+			strconv.Itoa(len(t.Tools)),
+			strings.Join(t.Tools, ";"),
+			strconv.Itoa(t.InputTokens),
+			strconv.Itoa(t.OutputTokens),
+			strconv.FormatBool(t.Estimated),
+			strconv.FormatFloat(t.CostUSD, 'f', -1, 64),
+			strconv.FormatInt(t.Latency.Milliseconds(), 10),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter(t *testing.T) {
+	updatedAt := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	agent := []llms.Message{
+		{Role: "user", Content: content.FromText("What's the weather in Paris and Rome?")},
+		{Role: "assistant", ToolCalls: []llms.ToolCall{
+			{ID: "1", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+			{ID: "2", Name: "get_weather", Arguments: json.RawMessage(`{"city":"Rome"}`)},
+		}},
+		{Role: "tool", ToolCallID: "1", Content: content.FromText("Sunny")},
+		{Role: "tool", ToolCallID: "2", Content: content.FromText("Rainy")},
+		{Role: "assistant", Content: content.FromText("Paris is sunny and Rome is rainy.")},
+	}
+	store := NewMemoryStore()
+	store.conversations["b"] = memoryConversation{messages: testMessages(), updatedAt: updatedAt}
+	store.conversations["a"] = memoryConversation{messages: agent, updatedAt: updatedAt}
+
+	records := []llms.TurnRecord{{
+		Model:        "gpt-4o",
+		Messages:     agent[:1],
+		InputTokens:  1000000,
+		OutputTokens: 20,
+		Duration:     1500 * time.Millisecond,
+	}}
+	exporter := NewExporter(store).WithModel("gpt-4o-mini").WithRecords(records)
+	turns, err := exporter.Turns(context.Background())
+	require.NoError(t, err)
+	require.Len(t, turns, 3)
+
+	assert.Equal(t, "a", turns[0].ConversationID)
+	assert.Equal(t, 1, turns[0].Turn)
+	assert.Equal(t, "gpt-4o", turns[0].Model)
+	assert.Equal(t, []string{"get_weather", "get_weather"}, turns[0].Tools)
+	assert.Equal(t, 1000000, turns[0].InputTokens)
+	assert.False(t, turns[0].Estimated)
+	assert.InDelta(t, 2.5002, turns[0].CostUSD, 1e-9)
+	assert.Equal(t, 1500*time.Millisecond, turns[0].Latency)
+
+	assert.Equal(t, 2, turns[1].Turn)
+	assert.Equal(t, "gpt-4o-mini", turns[1].Model)
+	assert.True(t, turns[1].Estimated)
+	assert.Equal(t, llms.EstimateMessageTokens(agent[:4]), turns[1].InputTokens)
+	assert.Equal(t, llms.EstimateMessageTokens(agent[4:]), turns[1].OutputTokens)
+	assert.Empty(t, turns[1].Tools)
+
+	assert.Equal(t, "b", turns[2].ConversationID)
+
+	var csv strings.Builder
+	require.NoError(t, exporter.WriteCSV(context.Background(), &csv))
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "conversation_id,turn,updated_at,model,tool_calls,tools,input_tokens,output_tokens,estimated,cost_usd,latency_ms", lines[0])
+	assert.Equal(t, "a,1,2025-01-10T12:00:00Z,gpt-4o,2,get_weather;get_weather,1000000,20,false,2.5002,1500", lines[1])
+}
@@ -0,0 +1,206 @@
+// Package history contains implementations of llms.HistoryStore, as well as
+// helpers for locking conversations across multiple LLM instances.
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// Info describes a stored conversation.
+type Info struct {
+	ID        string
+	UpdatedAt time.Time
+}
+
+// Lister is implemented by stores whose conversations can be enumerated and
+// deleted, which is required for retention policies.
+type Lister interface {
+	llms.HistoryStore
+	// List returns information about all stored conversations.
+	List(ctx context.Context) ([]Info, error)
+	// Delete removes the conversation. Deleting a conversation that doesn't
+	// exist is not an error.
+	Delete(ctx context.Context, conversationID string) error
+}
+
+// MemoryStore keeps conversations in memory. It is mostly useful for tests
+// and for sharing conversations between LLM instances in the same process.
+type MemoryStore struct {
+	mu            sync.Mutex
+	conversations map[string]memoryConversation
+}
+
+type memoryConversation struct {
+	messages  []llms.Message
+	updatedAt time.Time
+}
+
+// NewMemoryStore returns a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{conversations: make(map[string]memoryConversation)}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, conversationID string) ([]llms.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Return a copy so appends by the caller never touch the stored slice.
+	return append([]llms.Message(nil), s.conversations[conversationID].messages...), nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, conversationID string, messages []llms.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conversations[conversationID] = memoryConversation{
+		messages:  append([]llms.Message(nil), messages...),
+		updatedAt: time.Now(),
+	}
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]Info, 0, len(s.conversations))
+	for id, conversation := range s.conversations {
+		infos = append(infos, Info{ID: id, UpdatedAt: conversation.updatedAt})
+	}
+	return infos, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, conversationID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conversations, conversationID)
+	return nil
+}
+
+// FileStore keeps each conversation as a JSON file in a directory.
+type FileStore struct {
+	dir  string
+	keys llms.KeyProvider
+}
+
+// NewFileStore returns a FileStore that keeps conversations in dir. The
+// directory is created when the first conversation is saved.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+// WithEncryption makes the store encrypt conversations with AES-GCM using the
+// provided keys. Conversations that were saved without encryption can no
+// longer be loaded.
+func (s *FileStore) WithEncryption(keys llms.KeyProvider) *FileStore {
+	s.keys = keys
+	return s
+}
+
+func (s *FileStore) Load(ctx context.Context, conversationID string) ([]llms.Message, error) {
+	path, err := s.path(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if s.keys != nil {
+		data, err = llms.Decrypt(ctx, s.keys, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt conversation %q: %w", conversationID, err)
+		}
+	}
+	var messages []llms.Message
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("failed to decode conversation %q: %w", conversationID, err)
+	}
+	return messages, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, conversationID string, messages []llms.Message) error {
+	path, err := s.path(conversationID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return fmt.Errorf("failed to encode conversation %q: %w", conversationID, err)
+	}
+	if s.keys != nil {
+		data, err = llms.Encrypt(ctx, s.keys, data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt conversation %q: %w", conversationID, err)
+		}
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a partial file.
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *FileStore) List(ctx context.Context) ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var infos []Info
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || strings.HasPrefix(id, ".") {
+			continue
+		}
+		fileInfo, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			// Deleted since the directory was read.
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		infos = append(infos, Info{ID: id, UpdatedAt: fileInfo.ModTime()})
+	}
+	return infos, nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, conversationID string) error {
+	path, err := s.path(conversationID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) path(conversationID string) (string, error) {
+	if conversationID == "" || strings.ContainsAny(conversationID, `/\`) || strings.HasPrefix(conversationID, ".") {
+		return "", fmt.Errorf("invalid conversation ID %q", conversationID)
+	}
+	return filepath.Join(s.dir, conversationID+".json"), nil
+}
@@ -0,0 +1,119 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessages() []llms.Message {
+	return []llms.Message{
+		{Role: "user", Content: content.FromText("Hello")},
+		{Role: "assistant", Content: content.FromText("Hi!")},
+	}
+}
+
+func TestStores(t *testing.T) {
+	stores := map[string]llms.HistoryStore{
+		"memory": NewMemoryStore(),
+		"file":   NewFileStore(t.TempDir()),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			messages, err := store.Load(ctx, "missing")
+			require.NoError(t, err)
+			assert.Empty(t, messages, "Missing conversations should be empty")
+
+			require.NoError(t, store.Save(ctx, "conv", testMessages()))
+			messages, err = store.Load(ctx, "conv")
+			require.NoError(t, err)
+			assert.Equal(t, testMessages(), messages)
+
+			require.NoError(t, store.Save(ctx, "conv", testMessages()[:1]))
+			messages, err = store.Load(ctx, "conv")
+			require.NoError(t, err)
+			assert.Len(t, messages, 1, "Save should replace the conversation")
+		})
+	}
+}
+
+func TestFileStoreEncryption(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	keys := llms.StaticKey(bytes.Repeat([]byte{7}, 32))
+	store := NewFileStore(dir).WithEncryption(keys)
+	require.NoError(t, store.Save(ctx, "conv", testMessages()))
+
+	data, err := os.ReadFile(filepath.Join(dir, "conv.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "Hello", "Stored data should be encrypted")
+
+	messages, err := store.Load(ctx, "conv")
+	require.NoError(t, err)
+	assert.Equal(t, testMessages(), messages)
+
+	_, err = NewFileStore(dir).WithEncryption(llms.StaticKey(bytes.Repeat([]byte{8}, 32))).Load(ctx, "conv")
+	assert.Error(t, err, "Loading with the wrong key should fail")
+}
+
+func TestFileStoreInvalidID(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	for _, id := range []string{"", "../escape", "a/b", ".hidden"} {
+		assert.Error(t, store.Save(context.Background(), id, testMessages()), "ID %q should be rejected", id)
+	}
+}
+
+func TestMemoryLocker(t *testing.T) {
+	locker := NewMemoryLocker()
+	ctx := context.Background()
+	unlock, err := locker.Lock(ctx, "conv")
+	require.NoError(t, err)
+
+	// A different conversation is not blocked.
+	unlockOther, err := locker.Lock(ctx, "other")
+	require.NoError(t, err)
+	unlockOther()
+
+	// The same conversation is blocked until unlocked.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = locker.Lock(timeoutCtx, "conv")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := locker.Lock(ctx, "conv")
+		if err == nil {
+			unlock()
+		}
+		close(acquired)
+	}()
+	unlock()
+	unlock() // Unlocking twice is harmless.
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Lock was not acquired after unlock")
+	}
+}
+
+func TestLocked(t *testing.T) {
+	store := Locked(NewMemoryStore(), NewMemoryLocker())
+	_, ok := store.(llms.HistoryLocker)
+	assert.True(t, ok, "Locked store should implement llms.HistoryLocker")
+	_, ok = store.(Lister)
+	assert.True(t, ok, "Locked store should implement Lister when the wrapped store does")
+
+	store = Locked(struct{ llms.HistoryStore }{NewMemoryStore()}, NewMemoryLocker())
+	_, ok = store.(Lister)
+	assert.False(t, ok, "Locked store should not implement Lister when the wrapped store doesn't")
+}
@@ -0,0 +1,91 @@
+package history
+
+import (
+	"context"
+	"sync"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// Locked returns a store that saves to and loads from store, and implements
+// llms.HistoryLocker using locker. This makes LLM instances sharing the store
+// take turns when chatting in the same conversation. The returned store
+// implements Lister if store does.
+func Locked(store llms.HistoryStore, locker llms.HistoryLocker) llms.HistoryStore {
+	locked := &lockedStore{store, locker}
+	if lister, ok := store.(Lister); ok {
+		return &lockedLister{locked, lister}
+	}
+	return locked
+}
+
+type lockedStore struct {
+	llms.HistoryStore
+	locker llms.HistoryLocker
+}
+
+type lockedLister struct {
+	*lockedStore
+	lister Lister
+}
+
+func (s *lockedLister) List(ctx context.Context) ([]Info, error) {
+	return s.lister.List(ctx)
+}
+
+func (s *lockedLister) Delete(ctx context.Context, conversationID string) error {
+	return s.lister.Delete(ctx, conversationID)
+}
+
+func (s *lockedStore) Lock(ctx context.Context, conversationID string) (unlock func(), err error) {
+	return s.locker.Lock(ctx, conversationID)
+}
+
+// LockLease passes on the lease of the locker if it has one. Other locks can't
+// be lost, so their context is the one that was given.
+func (s *lockedStore) LockLease(ctx context.Context, conversationID string) (leaseCtx context.Context, unlock func(), err error) {
+	if locker, ok := s.locker.(llms.LeaseLocker); ok {
+		return locker.LockLease(ctx, conversationID)
+	}
+	unlock, err = s.locker.Lock(ctx, conversationID)
+	return ctx, unlock, err
+}
+
+// MemoryLocker locks conversations within a single process.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+// NewMemoryLocker returns a new MemoryLocker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]chan struct{})}
+}
+
+func (l *MemoryLocker) Lock(ctx context.Context, conversationID string) (unlock func(), err error) {
+	for {
+		l.mu.Lock()
+		held, ok := l.locks[conversationID]
+		if !ok {
+			released := make(chan struct{})
+			l.locks[conversationID] = released
+			l.mu.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					l.mu.Lock()
+					delete(l.locks, conversationID)
+					l.mu.Unlock()
+					close(released)
+				})
+			}, nil
+		}
+		l.mu.Unlock()
+		select {
+		case <-held:
+			// Try again, someone else may have gotten there first.
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
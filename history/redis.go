@@ -0,0 +1,144 @@
+package history
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/internal/redis"
+	"github.com/blixt/go-llms/llms"
+)
+
+const (
+	// renewScript extends the lease, but only if it's still ours.
+	renewScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`
+	// releaseScript deletes the lease, but only if it's still ours.
+	releaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+)
+
+// RedisLocker locks conversations using Redis, so that LLM instances in
+// different processes (or on different machines) can share conversations. A
+// lock is a lease which is renewed for as long as it's held, so a crashed
+// process never blocks a conversation for longer than the TTL. If a lease
+// can't be renewed, the chat holding it is stopped with llms.ErrLockLost (see
+// llms.LeaseLocker).
+type RedisLocker struct {
+	addr          string
+	password      string
+	keyPrefix     string
+	ttl           time.Duration
+	retryInterval time.Duration
+}
+
+// NewRedisLocker returns a locker that uses the Redis server at addr (for
+// example "localhost:6379").
+func NewRedisLocker(addr string) *RedisLocker {
+	return &RedisLocker{
+		addr:          addr,
+		keyPrefix:     "go-llms:lock:",
+		ttl:           30 * time.Second,
+		retryInterval: 100 * time.Millisecond,
+	}
+}
+
+func (l *RedisLocker) WithPassword(password string) *RedisLocker {
+	l.password = password
+	return l
+}
+
+// WithKeyPrefix sets the prefix of the Redis keys used for locks. The
+// conversation ID is appended to the prefix.
+func (l *RedisLocker) WithKeyPrefix(prefix string) *RedisLocker {
+	l.keyPrefix = prefix
+	return l
+}
+
+// WithTTL sets how long a lock survives without being renewed. TTLs shorter
+// than a millisecond, which Redis can't expire keys after, are ignored.
+func (l *RedisLocker) WithTTL(ttl time.Duration) *RedisLocker {
+	if ttl >= time.Millisecond {
+		l.ttl = ttl
+	}
+	return l
+}
+
+func (l *RedisLocker) Lock(ctx context.Context, conversationID string) (unlock func(), err error) {
+	_, unlock, err = l.LockLease(ctx, conversationID)
+	return unlock, err
+}
+
+// LockLease locks the conversation like Lock, and returns a context that is
+// canceled with llms.ErrLockLost as its cause if the lease stops being ours
+// before it's released, because it expired and was taken by someone else, or
+// couldn't be renewed before it would expire.
+func (l *RedisLocker) LockLease(ctx context.Context, conversationID string) (leaseCtx context.Context, unlock func(), err error) {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tokenBytes [16]byte
+	rand.Read(tokenBytes[:])
+	token := hex.EncodeToString(tokenBytes[:])
+	key := l.keyPrefix + conversationID
+	ttl := strconv.FormatInt(l.ttl.Milliseconds(), 10)
+	for {
+		reply, err := conn.Do("SET", key, token, "NX", "PX", ttl)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		if reply == "OK" {
+			break
+		}
+		select {
+		case <-time.After(l.retryInterval):
+		case <-ctx.Done():
+			conn.Close()
+			return nil, nil, ctx.Err()
+		}
+	}
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// A reply of 0 means that the lease expired and may have been
+				// taken by someone else. If the lease can't be renewed at all,
+				// it will expire before anyone notices, so it's lost too.
+				reply, err := conn.Do("EVAL", renewScript, "1", key, token, ttl)
+				if err != nil {
+					cancel(fmt.Errorf("%w: %w", llms.ErrLockLost, err))
+					return
+				}
+				if reply == int64(0) {
+					cancel(llms.ErrLockLost)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return leaseCtx, func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+			cancel(nil)
+			conn.Do("EVAL", releaseScript, "1", key, token)
+			conn.Close()
+		})
+	}, nil
+}
+
+func (l *RedisLocker) dial(ctx context.Context) (*redis.Conn, error) {
+	return redis.Dial(ctx, l.addr, l.password)
+}
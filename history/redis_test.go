@@ -0,0 +1,166 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/internal/redis/redistest"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLockServer is a fake Redis server that understands the commands of
+// RedisLocker.
+type fakeLockServer struct {
+	*redistest.Server
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+func newFakeLockServer() *fakeLockServer {
+	s := &fakeLockServer{values: make(map[string]string), expires: make(map[string]time.Time)}
+	s.Server = redistest.NewServer(s.handle)
+	return s
+}
+
+func (s *fakeLockServer) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, expires := range s.expires {
+		if time.Now().After(expires) {
+			delete(s.values, key)
+			delete(s.expires, key)
+		}
+	}
+	switch {
+	case args[0] == "SET" && len(args) == 6:
+		key := args[1]
+		if _, ok := s.values[key]; ok {
+			return redistest.Nil
+		}
+		ttl, _ := time.ParseDuration(args[5] + "ms")
+		s.values[key], s.expires[key] = args[2], time.Now().Add(ttl)
+		return redistest.OK
+	case args[0] == "EVAL" && args[1] == renewScript:
+		key, token := args[3], args[4]
+		if s.values[key] != token {
+			return redistest.Int(0)
+		}
+		ttl, _ := time.ParseDuration(args[5] + "ms")
+		s.expires[key] = time.Now().Add(ttl)
+		return redistest.Int(1)
+	case args[0] == "EVAL" && args[1] == releaseScript:
+		key, token := args[3], args[4]
+		if s.values[key] != token {
+			return redistest.Int(0)
+		}
+		delete(s.values, key)
+		delete(s.expires, key)
+		return redistest.Int(1)
+	}
+	return redistest.Error("ERR unknown command")
+}
+
+// steal replaces the lock of the conversation, as if it had expired and been
+// taken by someone else.
+func (s *fakeLockServer) steal(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = "someone-else"
+	s.expires[key] = time.Now().Add(time.Minute)
+}
+
+func (s *fakeLockServer) held(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.values[key]
+	return ok
+}
+
+func TestRedisLocker(t *testing.T) {
+	server := newFakeLockServer()
+	defer server.Close()
+	locker := NewRedisLocker(server.Addr).WithTTL(60 * time.Millisecond)
+
+	unlock, err := locker.Lock(context.Background(), "conv")
+	require.NoError(t, err)
+	assert.True(t, server.held("go-llms:lock:conv"))
+
+	// The lease is renewed for as long as it's held, so it outlives the TTL.
+	time.Sleep(150 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = locker.Lock(ctx, "conv")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Other conversations aren't affected.
+	unlockOther, err := locker.Lock(context.Background(), "other")
+	require.NoError(t, err)
+	unlockOther()
+
+	// Waiting lockers get the lock once it's released.
+	acquired := make(chan struct{})
+	go func() {
+		unlock, err := locker.Lock(context.Background(), "conv")
+		if assert.NoError(t, err) {
+			unlock()
+		}
+		close(acquired)
+	}()
+	unlock()
+	unlock() // Releasing twice is harmless.
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("The lock wasn't released")
+	}
+	assert.False(t, server.held("go-llms:lock:conv"))
+}
+
+func TestRedisLockerInvalidTTL(t *testing.T) {
+	server := newFakeLockServer()
+	defer server.Close()
+	for _, ttl := range []time.Duration{0, -time.Second, time.Nanosecond} {
+		locker := NewRedisLocker(server.Addr).WithTTL(ttl)
+		assert.Equal(t, 30*time.Second, locker.ttl, "TTL %v should be ignored", ttl)
+		unlock, err := locker.Lock(context.Background(), "conv")
+		require.NoError(t, err)
+		unlock()
+	}
+}
+
+func TestRedisLockerLeaseLost(t *testing.T) {
+	server := newFakeLockServer()
+	defer server.Close()
+	locker := NewRedisLocker(server.Addr).WithTTL(60 * time.Millisecond)
+
+	leaseCtx, unlock, err := locker.LockLease(context.Background(), "conv")
+	require.NoError(t, err)
+	server.steal("go-llms:lock:conv")
+	select {
+	case <-leaseCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("The lease wasn't lost")
+	}
+	assert.ErrorIs(t, context.Cause(leaseCtx), llms.ErrLockLost)
+	unlock()
+	assert.True(t, server.held("go-llms:lock:conv"), "Someone else's lock shouldn't be released")
+
+	// A lease that can't be renewed is lost too.
+	leaseCtx, unlock, err = Locked(NewMemoryStore(), locker).(llms.LeaseLocker).LockLease(context.Background(), "other")
+	require.NoError(t, err)
+	defer unlock()
+	server.CloseConnections()
+	select {
+	case <-leaseCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("The lease wasn't lost")
+	}
+	assert.ErrorIs(t, context.Cause(leaseCtx), llms.ErrLockLost)
+	assert.False(t, errors.Is(context.Cause(leaseCtx), context.Canceled))
+}
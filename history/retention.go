@@ -0,0 +1,141 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// RetentionPolicy decides which conversations should be deleted from a store.
+type RetentionPolicy struct {
+	// MaxAge is how long a conversation is kept after it was last updated. A
+	// value of 0 means conversations never get too old.
+	MaxAge time.Duration
+	// MaxPerUser is the number of conversations kept per user, keeping the
+	// most recently updated ones. A value of 0 means no limit. User must be set
+	// for this to have any effect.
+	MaxPerUser int
+	// User returns the user that owns the conversation.
+	User func(conversationID string) string
+	// BeforeDelete is called with the messages of every conversation about to
+	// be deleted, for example to export it to cold storage. Returning an error
+	// keeps the conversation.
+	BeforeDelete func(ctx context.Context, conversationID string, messages []llms.Message) error
+}
+
+// Sweeper deletes conversations from a store according to a retention policy.
+type Sweeper struct {
+	store  Lister
+	policy RetentionPolicy
+	now    func() time.Time
+}
+
+// NewSweeper returns a sweeper for the store. If the store implements
+// llms.HistoryLocker, conversations are locked while being deleted so that
+// a conversation that is in use is never deleted halfway through a chat, and
+// conversations that were updated while waiting for the lock are kept.
+func NewSweeper(store Lister, policy RetentionPolicy) *Sweeper {
+	return &Sweeper{store: store, policy: policy, now: time.Now}
+}
+
+// Sweep deletes all conversations that violate the retention policy once, and
+// returns the IDs of the deleted conversations. It keeps going when a single
+// conversation fails to be deleted, and returns all such errors joined.
+func (s *Sweeper) Sweep(ctx context.Context) (deleted []string, err error) {
+	infos, err := s.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	var errs []error
+	for _, id := range s.expired(infos) {
+		ok, err := s.delete(ctx, id)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete conversation %q: %w", id, err))
+			continue
+		}
+		if ok {
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// Run sweeps the store every interval until the context is done. Errors are
+// passed to onError, which may be nil.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Sweep(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// expired returns the IDs of the conversations that violate the policy.
+func (s *Sweeper) expired(infos []Info) []string {
+	var ids []string
+	// Newest first, so the conversations to keep per user come first.
+	infos = slices.Clone(infos)
+	slices.SortFunc(infos, func(a, b Info) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	perUser := make(map[string]int)
+	now := s.now()
+	for _, info := range infos {
+		if s.policy.MaxAge > 0 && now.Sub(info.UpdatedAt) > s.policy.MaxAge {
+			ids = append(ids, info.ID)
+			continue
+		}
+		if s.policy.MaxPerUser > 0 && s.policy.User != nil {
+			user := s.policy.User(info.ID)
+			perUser[user]++
+			if perUser[user] > s.policy.MaxPerUser {
+				ids = append(ids, info.ID)
+			}
+		}
+	}
+	return ids
+}
+
+// delete deletes the conversation if it still violates the policy once it has
+// been locked, and reports whether it did.
+func (s *Sweeper) delete(ctx context.Context, conversationID string) (bool, error) {
+	if locker, ok := s.store.(llms.HistoryLocker); ok {
+		unlock, err := locker.Lock(ctx, conversationID)
+		if err != nil {
+			return false, err
+		}
+		defer unlock()
+		// A chat may have updated the conversation while we waited for it.
+		infos, err := s.store.List(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to list conversations: %w", err)
+		}
+		if !slices.Contains(s.expired(infos), conversationID) {
+			return false, nil
+		}
+	}
+	if s.policy.BeforeDelete != nil {
+		messages, err := s.store.Load(ctx, conversationID)
+		if err != nil {
+			return false, err
+		}
+		if err := s.policy.BeforeDelete(ctx, conversationID, messages); err != nil {
+			return false, err
+		}
+	}
+	if err := s.store.Delete(ctx, conversationID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweeper(t *testing.T) {
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	ages := map[string]time.Duration{
+		"alice-1": 1 * time.Hour,
+		"alice-2": 2 * time.Hour,
+		"alice-3": 3 * time.Hour,
+		"bob-1":   1 * time.Hour,
+		"bob-old": 48 * time.Hour,
+	}
+	for id, age := range ages {
+		store.conversations[id] = memoryConversation{messages: testMessages(), updatedAt: now.Add(-age)}
+	}
+
+	var exported []string
+	sweeper := NewSweeper(Locked(store, NewMemoryLocker()).(Lister), RetentionPolicy{
+		MaxAge:     24 * time.Hour,
+		MaxPerUser: 2,
+		User: func(conversationID string) string {
+			user, _, _ := strings.Cut(conversationID, "-")
+			return user
+		},
+		BeforeDelete: func(ctx context.Context, conversationID string, messages []llms.Message) error {
+			if conversationID == "bob-old" {
+				return errors.New("export failed")
+			}
+			assert.Len(t, messages, 2, "Messages should be passed to the export hook")
+			exported = append(exported, conversationID)
+			return nil
+		},
+	})
+	sweeper.now = func() time.Time { return now }
+
+	deleted, err := sweeper.Sweep(context.Background())
+	require.Error(t, err, "Failed exports should be reported")
+	assert.Contains(t, err.Error(), "export failed")
+	assert.Equal(t, []string{"alice-3"}, deleted, "Oldest conversation beyond the per-user limit should be deleted")
+	assert.Equal(t, []string{"alice-3"}, exported)
+
+	infos, err := store.List(context.Background())
+	require.NoError(t, err)
+	var remaining []string
+	for _, info := range infos {
+		remaining = append(remaining, info.ID)
+	}
+	sort.Strings(remaining)
+	assert.Equal(t, []string{"alice-1", "alice-2", "bob-1", "bob-old"}, remaining, "Conversations that failed to export should be kept")
+}
+
+func TestFileStoreListDelete(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileStore(t.TempDir())
+	infos, err := store.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	require.NoError(t, store.Save(ctx, "a", testMessages()))
+	require.NoError(t, store.Save(ctx, "b", testMessages()))
+	infos, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, infos, 2)
+
+	require.NoError(t, store.Delete(ctx, "a"))
+	require.NoError(t, store.Delete(ctx, "a"), "Deleting twice should not fail")
+	infos, err = store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "b", infos[0].ID)
+}
+
+// touchingLocker simulates a chat that updates a conversation while the
+// sweeper waits for its lock.
+type touchingLocker struct {
+	*MemoryLocker
+	store *MemoryStore
+}
+
+func (l *touchingLocker) Lock(ctx context.Context, conversationID string) (unlock func(), err error) {
+	if err := l.store.Save(ctx, conversationID, testMessages()); err != nil {
+		return nil, err
+	}
+	return l.MemoryLocker.Lock(ctx, conversationID)
+}
+
+func TestSweeperSkipsUpdatedConversations(t *testing.T) {
+	store := NewMemoryStore()
+	store.conversations["old"] = memoryConversation{messages: testMessages(), updatedAt: time.Now().Add(-48 * time.Hour)}
+
+	sweeper := NewSweeper(Locked(store, &touchingLocker{NewMemoryLocker(), store}).(Lister), RetentionPolicy{
+		MaxAge: 24 * time.Hour,
+	})
+
+	deleted, err := sweeper.Sweep(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, deleted, "Conversations updated while waiting for the lock should be kept")
+
+	messages, err := store.Load(context.Background(), "old")
+	require.NoError(t, err)
+	assert.Len(t, messages, 2)
+}
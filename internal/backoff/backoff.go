@@ -0,0 +1,17 @@
+// Package backoff computes the delays of exponential backoff.
+package backoff
+
+import "time"
+
+// Delay returns base doubled n times, but at most limit. Unlike shifting base
+// by n, it doesn't overflow for large n.
+func Delay(base, limit time.Duration, n int) time.Duration {
+	d := base
+	for i := 0; i < n && d < limit; i++ {
+		if d > limit/2 {
+			return limit
+		}
+		d *= 2
+	}
+	return min(d, limit)
+}
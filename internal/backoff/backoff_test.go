@@ -0,0 +1,18 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	assert.Equal(t, time.Second, Delay(time.Second, time.Minute, 0))
+	assert.Equal(t, 8*time.Second, Delay(time.Second, time.Minute, 3))
+	assert.Equal(t, time.Minute, Delay(time.Second, time.Minute, 6))
+	assert.Equal(t, time.Minute, Delay(time.Second, time.Minute, 35), "The delay shouldn't overflow")
+	assert.Equal(t, time.Minute, Delay(time.Second, time.Minute, 1<<30))
+	assert.Equal(t, time.Duration(1<<62), Delay(time.Nanosecond, 1<<62, 100))
+	assert.Equal(t, time.Duration(1<<62+1), Delay(time.Nanosecond, 1<<62+1, 100), "Limits near the maximum shouldn't overflow")
+}
@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"golang.org/x/image/draw"
+)
+
+// Tile is a part of a tiled image, with a caption that tells the model which
+// part it is. An image that fits in a single tile has no caption.
+type Tile struct {
+	Caption string
+	DataURI string
+}
+
+// TileFile reads the image at the given path and tiles it like TileImage.
+func TileFile(path string, maxTileSize, maxTiles int, overview bool) ([]Tile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image file: %w", err)
+	}
+	defer file.Close()
+	img, format, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return TileImage(img, format, maxTileSize, maxTiles, overview)
+}
+
+// TileImage splits the image into a grid of at most maxTiles tiles of at most
+// maxTileSize pixels, scaling it down first if needed. With overview, the
+// tiles are preceded by a scaled down version of the whole image.
+func TileImage(img image.Image, format string, maxTileSize, maxTiles int, overview bool) ([]Tile, error) {
+	if maxTileSize <= 0 {
+		maxTileSize = 1024
+	}
+	if maxTiles <= 0 {
+		maxTiles = 6
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	size := maxTileSize
+	cols, rows := ceilDiv(width, size), ceilDiv(height, size)
+	scaledWidth, scaledHeight := width, height
+	if cols*rows > maxTiles {
+		// Pick the grid that lets the image keep the most of its resolution.
+		var scale float64
+		for c := 1; c <= maxTiles; c++ {
+			r := maxTiles / c
+			scale = max(scale, min(float64(c*size)/float64(width), float64(r*size)/float64(height)))
+		}
+		scaledWidth = max(1, int(float64(width)*scale))
+		scaledHeight = max(1, int(float64(height)*scale))
+		img = Scale(img, scaledWidth, scaledHeight)
+		bounds = img.Bounds()
+		cols, rows = ceilDiv(scaledWidth, size), ceilDiv(scaledHeight, size)
+	}
+
+	if cols*rows == 1 {
+		dataURI, err := EncodeDataURI(img, format)
+		if err != nil {
+			return nil, err
+		}
+		return []Tile{{DataURI: dataURI}}, nil
+	}
+
+	var tiles []Tile
+	if overview {
+		overviewWidth, overviewHeight := size, height*size/width
+		if height > width {
+			overviewWidth, overviewHeight = width*size/height, size
+		}
+		dataURI, err := EncodeDataURI(Scale(img, max(1, overviewWidth), max(1, overviewHeight)), format)
+		if err != nil {
+			return nil, err
+		}
+		tiles = append(tiles, Tile{
+			Caption: fmt.Sprintf("Overview of the whole %dx%d image, followed by %d tiles showing it in more detail:", width, height, cols*rows),
+			DataURI: dataURI,
+		})
+	}
+	for row := range rows {
+		for col := range cols {
+			// Split evenly so the last row and column aren't slivers.
+			x0, x1 := col*scaledWidth/cols, (col+1)*scaledWidth/cols
+			y0, y1 := row*scaledHeight/rows, (row+1)*scaledHeight/rows
+			tile := image.NewRGBA(image.Rect(0, 0, x1-x0, y1-y0))
+			draw.Draw(tile, tile.Bounds(), img, bounds.Min.Add(image.Pt(x0, y0)), draw.Src)
+			dataURI, err := EncodeDataURI(tile, format)
+			if err != nil {
+				return nil, err
+			}
+			tiles = append(tiles, Tile{
+				Caption: fmt.Sprintf(
+					"Tile %d of %d (row %d, column %d), showing x=%d to %d and y=%d to %d of the %dx%d image:",
+					row*cols+col+1, cols*rows, row+1, col+1,
+					x0*width/scaledWidth, x1*width/scaledWidth, y0*height/scaledHeight, y1*height/scaledHeight,
+					width, height,
+				),
+				DataURI: dataURI,
+			})
+		}
+	}
+	return tiles, nil
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -0,0 +1,110 @@
+// Package redis is a minimal Redis client speaking just enough of the RESP
+// protocol for the locks and queues in this module, so that using Redis
+// doesn't require a dependency.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Conn is a connection to a Redis server. It must not be used concurrently.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Dial connects to the Redis server at addr, authenticating with the
+// password if it's not empty.
+func Dial(ctx context.Context, addr, password string) (*Conn, error) {
+	var dialer net.Dialer
+	c, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to Redis: %w", err)
+	}
+	conn := &Conn{Conn: c, reader: bufio.NewReader(c)}
+	if password != "" {
+		if _, err := conn.Do("AUTH", password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Do sends a command and returns its reply, which is a string, an int64, a
+// []any of replies, or nil.
+func (c *Conn) Do(args ...string) (any, error) {
+	return c.DoTimeout(10*time.Second, args...)
+}
+
+// DoTimeout is like Do, but waits for the reply for the given duration, which
+// is needed for blocking commands.
+func (c *Conn) DoTimeout(timeout time.Duration, args ...string) (any, error) {
+	c.SetDeadline(time.Now().Add(timeout))
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.Conn, cmd.String()); err != nil {
+		return nil, fmt.Errorf("error sending Redis command: %w", err)
+	}
+	return c.readReply()
+}
+
+func (c *Conn) readReply() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error reading Redis reply: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty Redis reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, fmt.Errorf("error reading Redis reply: %w", err)
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis reply %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unsupported Redis reply %q", line)
+	}
+}
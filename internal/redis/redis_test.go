@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/internal/redis/redistest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]string
+	server := redistest.NewServer(func(args []string) string {
+		mu.Lock()
+		commands = append(commands, args)
+		mu.Unlock()
+		switch args[0] {
+		case "AUTH":
+			return redistest.OK
+		case "STATUS":
+			return "+PONG\r\n"
+		case "FAIL":
+			return redistest.Error("ERR something went wrong")
+		case "INT":
+			return redistest.Int(-42)
+		case "BULK":
+			return redistest.Bulk("line 1\r\nline 2")
+		case "NIL":
+			return redistest.Nil
+		case "NILARRAY":
+			return "*-1\r\n"
+		case "ARRAY":
+			return redistest.Array(redistest.Bulk("a"), redistest.Int(1), redistest.Array(redistest.Nil))
+		}
+		return "?unknown\r\n"
+	})
+	defer server.Close()
+
+	conn, err := Dial(context.Background(), server.Addr, "secret")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reply, err := conn.Do("STATUS", "with space", "")
+	require.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+	_, err = conn.Do("FAIL")
+	assert.EqualError(t, err, "redis: ERR something went wrong")
+	reply, err = conn.Do("INT")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-42), reply)
+	reply, err = conn.Do("BULK")
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\r\nline 2", reply)
+	reply, err = conn.Do("NIL")
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+	reply, err = conn.Do("NILARRAY")
+	require.NoError(t, err)
+	assert.Nil(t, reply)
+	reply, err = conn.Do("ARRAY")
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a", int64(1), []any{nil}}, reply)
+	_, err = conn.Do("UNKNOWN")
+	assert.EqualError(t, err, `unsupported Redis reply "?unknown"`)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"AUTH", "secret"}, commands[0])
+	assert.Equal(t, []string{"STATUS", "with space", ""}, commands[1], "Arguments should be sent as bulk strings")
+}
+
+func TestDialErrors(t *testing.T) {
+	server := redistest.NewServer(func(args []string) string {
+		return redistest.Error("WRONGPASS invalid password")
+	})
+	defer server.Close()
+	_, err := Dial(context.Background(), server.Addr, "wrong")
+	assert.EqualError(t, err, "redis: WRONGPASS invalid password")
+
+	server.Close()
+	_, err = Dial(context.Background(), server.Addr, "")
+	assert.ErrorContains(t, err, "error connecting to Redis")
+}
+
+func TestDoTimeout(t *testing.T) {
+	block := make(chan struct{})
+	server := redistest.NewServer(func(args []string) string {
+		<-block
+		return redistest.Nil
+	})
+	defer server.Close()
+	defer close(block)
+	conn, err := Dial(context.Background(), server.Addr, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.DoTimeout(50*time.Millisecond, "BLPOP", "queue", "0")
+	assert.ErrorContains(t, err, "error reading Redis reply")
+}
+
+func TestConnectionClosed(t *testing.T) {
+	server := redistest.NewServer(func(args []string) string { return redistest.OK })
+	defer server.Close()
+	conn, err := Dial(context.Background(), server.Addr, "")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Do("PING")
+	require.NoError(t, err)
+	server.CloseConnections()
+	_, err = conn.Do("PING")
+	assert.Error(t, err)
+}
@@ -0,0 +1,150 @@
+// Package redistest runs fake Redis servers for tests, which answer commands
+// with canned replies instead of implementing Redis.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is a fake Redis server listening on a local port.
+type Server struct {
+	// Addr is the address of the server, for redis.Dial.
+	Addr string
+
+	listener net.Listener
+	handler  func(args []string) string
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// NewServer starts a server that answers every command, given as its
+// arguments, with the raw RESP reply returned by the handler. The handler may
+// be called concurrently for different connections.
+func NewServer(handler func(args []string) string) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen: %v", err))
+	}
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		handler:  handler,
+		conns:    make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the server and closes all of its connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// CloseConnections closes the connections that are open, like a restart of
+// the server would, but keeps accepting new ones.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+		delete(s.conns, conn)
+	}
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				args, err := readCommand(reader)
+				if err != nil {
+					return
+				}
+				if _, err := io.WriteString(conn, s.handler(args)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSuffix(line, "\r\n"), "*"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSuffix(line, "\r\n"), "$"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid argument %q", line)
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+// OK is the simple string reply "OK".
+const OK = "+OK\r\n"
+
+// Nil is the null bulk string reply.
+const Nil = "$-1\r\n"
+
+// Error returns an error reply.
+func Error(message string) string {
+	return "-" + message + "\r\n"
+}
+
+// Int returns an integer reply.
+func Int(n int64) string {
+	return ":" + strconv.FormatInt(n, 10) + "\r\n"
+}
+
+// Bulk returns a bulk string reply.
+func Bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// Array returns an array reply of the given replies.
+func Array(replies ...string) string {
+	return fmt.Sprintf("*%d\r\n", len(replies)) + strings.Join(replies, "")
+}
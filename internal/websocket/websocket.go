@@ -0,0 +1,276 @@
+// Package websocket is a minimal WebSocket (RFC 6455) implementation with just
+// enough of the protocol for the realtime APIs in this module, so that using
+// them doesn't require a dependency. Messages are read and written whole, and
+// extensions such as compression aren't supported.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OpText   = 1
+	OpBinary = 2
+	opClose  = 8
+	opPing   = 9
+	opPong   = 10
+)
+
+// acceptGUID is appended to the key of the handshake, as the RFC specifies.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxMessageSize protects against peers that announce huge messages.
+const maxMessageSize = 64 << 20
+
+// ErrClosed is returned by Read once the peer has closed the connection.
+var ErrClosed = errors.New("websocket closed")
+
+// Conn is a WebSocket connection. Writes may happen concurrently with each
+// other and with reads, but only one goroutine may read at a time.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	client bool
+
+	writeMu sync.Mutex
+}
+
+// Dial opens a WebSocket connection to the ws:// or wss:// URL, sending the
+// given headers with the handshake.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	host := u.Host
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		dial = (&net.Dialer{}).DialContext
+	case "wss":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		dial = (&tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}).DialContext
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	netConn, err := dial(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+
+	var keyBytes [16]byte
+	rand.Read(keyBytes[:])
+	key := base64.StdEncoding.EncodeToString(keyBytes[:])
+	req := &http.Request{
+		Method: "GET",
+		URL:    u,
+		Host:   u.Host,
+		Header: http.Header{},
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("error sending handshake: %w", err)
+	}
+	reader := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("error reading handshake: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer netConn.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if len(body) > 0 {
+			return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, fmt.Errorf("invalid handshake response")
+	}
+	netConn.SetDeadline(time.Time{})
+	return &Conn{conn: netConn, reader: reader, client: true}, nil
+}
+
+// Upgrade accepts a WebSocket connection on the server side of an HTTP
+// request.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a WebSocket handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "can't upgrade connection", http.StatusInternalServerError)
+		return nil, fmt.Errorf("response writer can't be hijacked")
+	}
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("error hijacking connection: %w", err)
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(r.Header.Get("Sec-WebSocket-Key")))
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return &Conn{conn: netConn, reader: rw.Reader}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Write sends a message with the given opcode, OpText or OpBinary.
+func (c *Conn) Write(opcode byte, data []byte) error {
+	return c.writeFrame(opcode, data)
+}
+
+func (c *Conn) writeFrame(opcode byte, data []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode // The message is a single, final frame.
+	switch n := len(data); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	payload := data
+	if c.client {
+		// Clients must mask what they send.
+		header[1] |= 0x80
+		var mask [4]byte
+		rand.Read(mask[:])
+		header = append(header, mask[:]...)
+		payload = make([]byte, len(data))
+		for i, b := range data {
+			payload[i] = b ^ mask[i%4]
+		}
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return fmt.Errorf("error writing message: %w", err)
+	}
+	return nil
+}
+
+// Read returns the next text or binary message, answering pings along the
+// way. It returns ErrClosed once the peer has closed the connection.
+func (c *Conn) Read() (opcode byte, data []byte, err error) {
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeFrame(opClose, payload)
+			return 0, nil, ErrClosed
+		case 0:
+			// A continuation of the current message.
+		default:
+			opcode = op
+		}
+		message = append(message, payload...)
+		if len(message) > maxMessageSize {
+			return 0, nil, fmt.Errorf("message is too large")
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, fmt.Errorf("error reading message: %w", err)
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	n := uint64(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("error reading message: %w", err)
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("error reading message: %w", err)
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxMessageSize {
+		return false, 0, nil, fmt.Errorf("message is too large")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, fmt.Errorf("error reading message: %w", err)
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, fmt.Errorf("error reading message: %w", err)
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// Close sends a close message and closes the connection.
+func (c *Conn) Close() error {
+	c.writeFrame(opClose, []byte{0x03, 0xe8}) // 1000, a normal closure.
+	return c.conn.Close()
+}
@@ -0,0 +1,162 @@
+// Package yaml is a minimal YAML encoder for human-readable dumps of values,
+// so that the core packages don't need a YAML dependency. Values are encoded
+// like encoding/json would encode them, as block-style YAML with sorted keys.
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Marshal encodes the value as YAML, going through its JSON encoding.
+func Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	switch value.(type) {
+	case map[string]any, []any:
+		if !isEmpty(value) {
+			writeBlock(&buf, value, 0)
+			return buf.Bytes(), nil
+		}
+	}
+	writeScalar(&buf, value, -1)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// writeBlock writes a non-empty map or list, with every line indented.
+func writeBlock(buf *bytes.Buffer, value any, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			buf.WriteString(prefix)
+			writeString(buf, key, -1)
+			buf.WriteByte(':')
+			writeValue(buf, v[key], indent+1, false)
+		}
+	case []any:
+		for _, item := range v {
+			buf.WriteString(prefix)
+			buf.WriteByte('-')
+			writeValue(buf, item, indent+1, true)
+		}
+	}
+}
+
+// writeValue writes the value that follows a key or a list dash, on the same
+// line if it's a scalar and on the following lines if it isn't.
+func writeValue(buf *bytes.Buffer, value any, indent int, inList bool) {
+	if isEmpty(value) {
+		buf.WriteByte(' ')
+		writeScalar(buf, value, indent)
+		buf.WriteByte('\n')
+		return
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		if inList {
+			// The first key goes on the line of the dash.
+			var block bytes.Buffer
+			writeBlock(&block, v, indent)
+			buf.WriteByte(' ')
+			buf.Write(bytes.TrimLeft(block.Bytes(), " "))
+			return
+		}
+		buf.WriteByte('\n')
+		writeBlock(buf, v, indent)
+	case []any:
+		buf.WriteByte('\n')
+		// Lists under keys aren't indented further, like most encoders do.
+		if !inList {
+			indent--
+		}
+		writeBlock(buf, v, indent)
+	default:
+		buf.WriteByte(' ')
+		writeScalar(buf, value, indent)
+		buf.WriteByte('\n')
+	}
+}
+
+func isEmpty(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func writeScalar(buf *bytes.Buffer, value any, indent int) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case json.Number:
+		buf.WriteString(v.String())
+	case string:
+		writeString(buf, v, indent)
+	case map[string]any:
+		buf.WriteString("{}")
+	case []any:
+		buf.WriteString("[]")
+	}
+}
+
+var (
+	plainString    = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_ ./()-]*$`)
+	reservedString = regexp.MustCompile(`^(?i:y|yes|n|no|true|false|on|off|null|~)$`)
+)
+
+// writeString writes the string unquoted if that's unambiguous, as a literal
+// block indented to the given level if it has several lines, and quoted
+// otherwise. A negative indent means that literal blocks aren't allowed.
+func writeString(buf *bytes.Buffer, s string, indent int) {
+	switch {
+	case plainString.MatchString(s) && !reservedString.MatchString(s) && !strings.HasSuffix(s, " "):
+		buf.WriteString(s)
+	case indent > 0 && strings.Contains(s, "\n") && !strings.ContainsAny(s, "\r\t") && strings.TrimLeft(s, " \n") == s:
+		buf.WriteString("|")
+		if !strings.HasSuffix(s, "\n") {
+			buf.WriteString("-")
+		} else if strings.HasSuffix(s, "\n\n") {
+			buf.WriteString("+")
+		}
+		prefix := strings.Repeat("  ", indent)
+		for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+			buf.WriteByte('\n')
+			if line != "" {
+				buf.WriteString(prefix)
+				buf.WriteString(line)
+			}
+		}
+	default:
+		// A JSON string is also a valid double-quoted YAML string.
+		quoted, _ := json.Marshal(s)
+		buf.Write(quoted)
+	}
+}
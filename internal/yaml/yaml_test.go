@@ -0,0 +1,125 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func marshal(t *testing.T, v any) string {
+	t.Helper()
+	data, err := Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMarshalScalars(t *testing.T) {
+	assert.Equal(t, "scalar\n", marshal(t, "scalar"))
+	assert.Equal(t, "null\n", marshal(t, nil))
+	assert.Equal(t, "1.5\n", marshal(t, 1.5))
+	assert.Equal(t, "true\n", marshal(t, true))
+	assert.Equal(t, "{}\n", marshal(t, map[string]any{}))
+	assert.Equal(t, "[]\n", marshal(t, []any{}))
+	// Multiline strings can't be literal blocks without a key or list item.
+	assert.Equal(t, "\"a\\nb\"\n", marshal(t, "a\nb"))
+}
+
+func TestMarshalQuoting(t *testing.T) {
+	assert.Equal(t, `colon: "a: b"
+dash: "-x"
+date: "2025-01-02"
+empty: ""
+hash: "#c"
+lead: " x"
+num: "123"
+plain: hello world
+trail: "x "
+unicode: "héllo"
+`, marshal(t, map[string]any{
+		"plain":   "hello world",
+		"colon":   "a: b",
+		"num":     "123",
+		"date":    "2025-01-02",
+		"trail":   "x ",
+		"lead":    " x",
+		"empty":   "",
+		"hash":    "#c",
+		"dash":    "-x",
+		"unicode": "héllo",
+	}))
+	// Keys are quoted like values.
+	assert.Equal(t, "a b: 1\n\"a:b\": 2\n", marshal(t, map[string]any{"a b": 1, "a:b": 2}))
+}
+
+func TestMarshalReservedWords(t *testing.T) {
+	for _, word := range []string{"y", "Yes", "n", "NO", "true", "False", "on", "off", "null", "NULL", "~"} {
+		assert.Equal(t, `"`+word+`": "`+word+`"`+"\n", marshal(t, map[string]any{word: word}), word)
+	}
+	assert.Equal(t, "yesterday\n", marshal(t, "yesterday"), "Only whole words are reserved")
+}
+
+func TestMarshalMultilineStrings(t *testing.T) {
+	assert.Equal(t, `clip: |
+  line 1
+  line 2
+keep: |+
+  a
+
+  b
+
+lead: "  a\nb"
+strip: |-
+  line 1
+  line 2
+tab: "a\tb\nc"
+`, marshal(t, map[string]any{
+		"strip": "line 1\nline 2",
+		"clip":  "line 1\nline 2\n",
+		"keep":  "a\n\nb\n\n",
+		"tab":   "a\tb\nc",
+		"lead":  "  a\nb",
+	}))
+	assert.Equal(t, "- |-\n  s\n  t\n", marshal(t, []any{"s\nt"}))
+}
+
+func TestMarshalNesting(t *testing.T) {
+	assert.Equal(t, `list:
+- p
+- "y":
+  - 1
+  - 2
+  z: 1
+-
+  - 3
+  - 4
+map:
+  empty: {}
+  inner:
+    key: value
+  none: []
+`, marshal(t, map[string]any{
+		"list": []any{"p", map[string]any{"z": 1, "y": []any{1, 2}}, []any{3, 4}},
+		"map": map[string]any{
+			"inner": map[string]any{"key": "value"},
+			"empty": map[string]any{},
+			"none":  []any{},
+		},
+	}))
+}
+
+func TestMarshalKeyOrder(t *testing.T) {
+	// Keys are sorted, and structs are encoded like encoding/json encodes them.
+	type item struct {
+		Zebra string `json:"zebra"`
+		Alpha int    `json:"alpha"`
+		Skip  string `json:"-"`
+		Empty string `json:"empty,omitempty"`
+	}
+	assert.Equal(t, "alpha: 1\nzebra: z\n", marshal(t, item{Zebra: "z", Alpha: 1, Skip: "s"}))
+	m := map[string]any{}
+	for _, key := range []string{"d", "b", "a", "c", "B"} {
+		m[key] = key
+	}
+	assert.Equal(t, "B: B\na: a\nb: b\nc: c\nd: d\n", marshal(t, m))
+}
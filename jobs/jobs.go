@@ -0,0 +1,99 @@
+// Package jobs runs chats asynchronously, for frontends (such as serverless
+// functions) that can't hold a stream open for the length of a chat. Chat
+// enqueues a job and returns right away, workers run the agent loop, and the
+// result is either polled for with Queue.Get or delivered to a webhook.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned by Queue.Get for unknown (or expired) jobs.
+var ErrNotFound = errors.New("job not found")
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Done reports whether the job has finished, successfully or not.
+func (s Status) Done() bool {
+	return s == StatusSucceeded || s == StatusFailed
+}
+
+// Request describes a chat to run in the background.
+type Request struct {
+	// ConversationID identifies the conversation the message belongs to. Use a
+	// history store in the worker's LLM to continue conversations across jobs.
+	ConversationID string `json:"conversation_id,omitempty"`
+	Message        string `json:"message"`
+	// WebhookURL, if set, receives a POST with the job as JSON once it's done.
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// Job is a chat request along with its state.
+type Job struct {
+	ID string `json:"id"`
+	Request
+	Status Status `json:"status"`
+	// Text is the text the assistant replied with, once the job has succeeded.
+	Text         string    `json:"text,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	InputTokens  int       `json:"input_tokens,omitempty"`
+	OutputTokens int       `json:"output_tokens,omitempty"`
+	CostUSD      float64   `json:"cost_usd,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Queue stores jobs and hands them out to workers. Every job is handed out to
+// a single worker at a time, and saving it as done tells the queue that it
+// was processed.
+type Queue interface {
+	// Push saves the job and adds it to the end of the queue.
+	Push(ctx context.Context, job Job) error
+	// Pop removes the job at the front of the queue and returns it, waiting
+	// for one to be pushed if the queue is empty.
+	Pop(ctx context.Context) (Job, error)
+	// Save updates the stored state of the job.
+	Save(ctx context.Context, job Job) error
+	// Get returns the stored state of the job, or ErrNotFound.
+	Get(ctx context.Context, id string) (Job, error)
+}
+
+// Releaser is implemented by queues that hold on to the jobs they hand out
+// until they're done, such as RedisQueue. Workers call Release once they stop
+// processing a job, whether or not it was saved as done.
+type Releaser interface {
+	// Release stops holding the job. A job that wasn't saved as done, for
+	// example because saving it failed, is handed out again later.
+	Release(id string)
+}
+
+// Chat enqueues a chat and returns the queued job, whose ID can be used to
+// poll for its status.
+func Chat(ctx context.Context, queue Queue, req Request) (Job, error) {
+	var idBytes [16]byte
+	rand.Read(idBytes[:])
+	now := time.Now().UTC()
+	job := Job{
+		ID:        hex.EncodeToString(idBytes[:]),
+		Request:   req,
+		Status:    StatusQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := queue.Push(ctx, job); err != nil {
+		return Job{}, fmt.Errorf("error enqueuing job: %w", err)
+	}
+	return job, nil
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoProvider replies with the text of the last message.
+type echoProvider struct{}
+
+func (p echoProvider) Company() string { return "Fake" }
+func (p echoProvider) Model() string   { return "fake" }
+func (p echoProvider) String() string  { return llms.ProviderName(p) }
+
+func (p echoProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	text := messages[len(messages)-1].Content[0].(*content.Text).Text
+	return &echoStream{text: "You said: " + text}
+}
+
+type echoStream struct {
+	text string
+}
+
+func (s *echoStream) Err() error              { return nil }
+func (s *echoStream) Text() string            { return s.text }
+func (s *echoStream) ToolCall() llms.ToolCall { return llms.ToolCall{} }
+func (s *echoStream) Usage() (int, int)       { return 3, 4 }
+
+func (s *echoStream) Message() llms.Message {
+	return llms.Message{Role: "assistant", Content: content.FromText(s.text)}
+}
+
+func (s *echoStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		yield(llms.StreamStatusText)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	webhook := make(chan Job, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Signature-256"))
+		var job Job
+		assert.NoError(t, json.Unmarshal(body, &job))
+		webhook <- job
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := NewMemoryQueue()
+	job, err := Chat(ctx, queue, Request{ConversationID: "c1", Message: "hello", WebhookURL: server.URL})
+	require.NoError(t, err)
+	polled, err := queue.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusQueued, polled.Status)
+
+	worker := NewWorker(queue, func(ctx context.Context, job Job) (*llms.LLM, error) {
+		assert.Equal(t, "c1", job.ConversationID)
+		return llms.New(echoProvider{}), nil
+	}).WithWebhookSecret("secret")
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	select {
+	case notified := <-webhook:
+		assert.Equal(t, job.ID, notified.ID)
+		assert.Equal(t, StatusSucceeded, notified.Status)
+		assert.Equal(t, "You said: hello", notified.Text)
+		assert.Equal(t, 3, notified.InputTokens)
+		assert.Equal(t, 4, notified.OutputTokens)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	polled, err = queue.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.True(t, polled.Status.Done())
+	assert.Equal(t, "You said: hello", polled.Text)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	_, err = queue.Get(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// flakyQueue fails to pop jobs a number of times before it works.
+type flakyQueue struct {
+	*MemoryQueue
+	mu       sync.Mutex
+	failures int
+}
+
+func (q *flakyQueue) Pop(ctx context.Context) (Job, error) {
+	q.mu.Lock()
+	if q.failures > 0 {
+		q.failures--
+		q.mu.Unlock()
+		return Job{}, errors.New("connection refused")
+	}
+	q.mu.Unlock()
+	return q.MemoryQueue.Pop(ctx)
+}
+
+func TestWorkerRetriesPop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := &flakyQueue{MemoryQueue: NewMemoryQueue(), failures: 2}
+	job, err := Chat(ctx, queue, Request{Message: "hello"})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var errs []error
+	worker := NewWorker(queue, func(ctx context.Context, job Job) (*llms.LLM, error) {
+		return llms.New(echoProvider{}), nil
+	}).WithErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	})
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+
+	assert.Eventually(t, func() bool {
+		polled, err := queue.Get(ctx, job.ID)
+		return err == nil && polled.Status.Done()
+	}, 5*time.Second, 10*time.Millisecond, "The worker should keep going after failing to get a job")
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, errs, 2)
+	assert.ErrorContains(t, errs[0], "error getting job: connection refused")
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryQueue keeps jobs in memory, for running workers in the same process
+// as the frontend, and for tests. Finished jobs are kept until the process
+// exits.
+type MemoryQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]Job
+	pending []string
+	// ready has a value in it whenever pending might be non-empty.
+	ready chan struct{}
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{
+		jobs:  make(map[string]Job),
+		ready: make(chan struct{}, 1),
+	}
+}
+
+func (q *MemoryQueue) Push(ctx context.Context, job Job) error {
+	q.mu.Lock()
+	q.jobs[job.ID] = job
+	q.pending = append(q.pending, job.ID)
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+func (q *MemoryQueue) Pop(ctx context.Context) (Job, error) {
+	for {
+		q.mu.Lock()
+		if len(q.pending) > 0 {
+			id := q.pending[0]
+			q.pending = q.pending[1:]
+			job := q.jobs[id]
+			more := len(q.pending) > 0
+			q.mu.Unlock()
+			if more {
+				// Wake up the next waiting worker too.
+				q.signal()
+			}
+			return job, nil
+		}
+		q.mu.Unlock()
+		select {
+		case <-q.ready:
+		case <-ctx.Done():
+			return Job{}, ctx.Err()
+		}
+	}
+}
+
+func (q *MemoryQueue) Save(ctx context.Context, job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[job.ID] = job
+	return nil
+}
+
+func (q *MemoryQueue) Get(ctx context.Context, id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return job, nil
+}
+
+func (q *MemoryQueue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,260 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/internal/redis"
+)
+
+// reapScript moves the jobs in the processing list whose lease has expired
+// back to the front of the queue, and returns how many it moved.
+const reapScript = `local n = 0
+for _, id in ipairs(redis.call("LRANGE", KEYS[1], 0, -1)) do
+	if redis.call("EXISTS", ARGV[1] .. id) == 0 then
+		redis.call("LREM", KEYS[1], 1, id)
+		redis.call("LPUSH", KEYS[2], id)
+		n = n + 1
+	end
+end
+return n`
+
+// RedisQueue keeps jobs in Redis, so that frontends and workers can run in
+// different processes. Job states are stored as JSON and expire after the TTL,
+// and the queue itself is a Redis list of job IDs. It requires Redis 6.2 or
+// later.
+//
+// Popped jobs are moved to a list of jobs being processed, and hold a lease
+// that is renewed until the job is saved as done or released. If a worker
+// dies, or stops processing a job without saving it as done, its lease
+// expires after the visibility timeout and the job is put back at the front of
+// the queue by the next Pop. Jobs are therefore delivered at least once: a job
+// whose worker stopped renewing its lease for a while may run again.
+type RedisQueue struct {
+	addr       string
+	password   string
+	keyPrefix  string
+	ttl        time.Duration
+	visibility time.Duration
+
+	// mu guards conn, which is shared by everything but Pop. Pop blocks, so it
+	// dials its own connections.
+	mu   sync.Mutex
+	conn *redis.Conn
+
+	// leaseMu guards leases, which stop the renewal of the leases of the jobs
+	// this queue has popped, and lastReap.
+	leaseMu  sync.Mutex
+	leases   map[string]context.CancelFunc
+	lastReap time.Time
+}
+
+// NewRedisQueue returns a queue that uses the Redis server at addr (for
+// example "localhost:6379").
+func NewRedisQueue(addr string) *RedisQueue {
+	return &RedisQueue{
+		addr:       addr,
+		keyPrefix:  "go-llms:jobs:",
+		ttl:        24 * time.Hour,
+		visibility: time.Minute,
+		leases:     make(map[string]context.CancelFunc),
+	}
+}
+
+func (q *RedisQueue) WithPassword(password string) *RedisQueue {
+	q.password = password
+	return q
+}
+
+// WithKeyPrefix sets the prefix of the Redis keys used for the queue and the
+// job states.
+func (q *RedisQueue) WithKeyPrefix(prefix string) *RedisQueue {
+	q.keyPrefix = prefix
+	return q
+}
+
+// WithTTL sets how long job states are kept after they were last updated.
+func (q *RedisQueue) WithTTL(ttl time.Duration) *RedisQueue {
+	q.ttl = ttl
+	return q
+}
+
+// WithVisibilityTimeout sets how long a popped job is held by its worker
+// after the worker stops renewing its lease, before it's handed out again. It
+// defaults to a minute.
+func (q *RedisQueue) WithVisibilityTimeout(timeout time.Duration) *RedisQueue {
+	if timeout > 0 {
+		q.visibility = timeout
+	}
+	return q
+}
+
+func (q *RedisQueue) Push(ctx context.Context, job Job) error {
+	if err := q.Save(ctx, job); err != nil {
+		return err
+	}
+	_, err := q.do(ctx, "RPUSH", q.keyPrefix+"queue", job.ID)
+	return err
+}
+
+func (q *RedisQueue) Pop(ctx context.Context) (Job, error) {
+	conn, err := redis.Dial(ctx, q.addr, q.password)
+	if err != nil {
+		return Job{}, err
+	}
+	defer conn.Close()
+	for {
+		if err := ctx.Err(); err != nil {
+			return Job{}, err
+		}
+		if err := q.reap(ctx); err != nil {
+			return Job{}, err
+		}
+		// Block for a second at a time so cancellation is noticed.
+		reply, err := conn.DoTimeout(5*time.Second, "BLMOVE", q.keyPrefix+"queue", q.keyPrefix+"processing", "LEFT", "RIGHT", "1")
+		if err != nil {
+			return Job{}, err
+		}
+		id, ok := reply.(string)
+		if !ok {
+			continue
+		}
+		if err := q.lease(ctx, id); err != nil {
+			return Job{}, err
+		}
+		job, err := q.Get(ctx, id)
+		if err == nil && !job.Status.Done() {
+			return job, nil
+		}
+		if err != nil && err != ErrNotFound {
+			q.Release(id)
+			return Job{}, err
+		}
+		// The job expired before a worker got to it, or it was put back after
+		// it had finished.
+		if err := q.ack(ctx, id); err != nil {
+			return Job{}, err
+		}
+	}
+}
+
+func (q *RedisQueue) Save(ctx context.Context, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("error encoding job: %w", err)
+	}
+	if _, err := q.do(ctx, "SET", q.keyPrefix+"job:"+job.ID, string(data), "PX", strconv.FormatInt(q.ttl.Milliseconds(), 10)); err != nil {
+		return err
+	}
+	if job.Status.Done() {
+		return q.ack(ctx, job.ID)
+	}
+	return nil
+}
+
+func (q *RedisQueue) Get(ctx context.Context, id string) (Job, error) {
+	reply, err := q.do(ctx, "GET", q.keyPrefix+"job:"+id)
+	if err != nil {
+		return Job{}, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	var job Job
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
+		return Job{}, fmt.Errorf("error decoding job: %w", err)
+	}
+	return job, nil
+}
+
+// lease takes the lease of a popped job, and renews it until the job is
+// acknowledged or released.
+func (q *RedisQueue) lease(ctx context.Context, id string) error {
+	key := q.keyPrefix + "lease:" + id
+	ttl := strconv.FormatInt(q.visibility.Milliseconds(), 10)
+	if _, err := q.do(ctx, "SET", key, "1", "PX", ttl); err != nil {
+		return err
+	}
+	leaseCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	q.leaseMu.Lock()
+	q.leases[id] = cancel
+	q.leaseMu.Unlock()
+	go func() {
+		ticker := time.NewTicker(q.visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-leaseCtx.Done():
+				return
+			case <-ticker.C:
+				// A failed renewal is tried again on the next tick, and the
+				// lease only expires if they keep failing.
+				q.do(leaseCtx, "PEXPIRE", key, ttl)
+			}
+		}
+	}()
+	return nil
+}
+
+// Release stops renewing the lease of a popped job. Unless the job was saved
+// as done, it's handed out again once the lease expires.
+func (q *RedisQueue) Release(id string) {
+	q.leaseMu.Lock()
+	defer q.leaseMu.Unlock()
+	if cancel, ok := q.leases[id]; ok {
+		cancel()
+		delete(q.leases, id)
+	}
+}
+
+// ack removes a job that is done from the jobs being processed. The lease is
+// released even if that fails, so that the job is handed out again.
+func (q *RedisQueue) ack(ctx context.Context, id string) error {
+	q.Release(id)
+	if _, err := q.do(ctx, "LREM", q.keyPrefix+"processing", "0", id); err != nil {
+		return err
+	}
+	_, err := q.do(ctx, "DEL", q.keyPrefix+"lease:"+id)
+	return err
+}
+
+// reap puts the jobs whose lease has expired back in the queue, at most once
+// per visibility timeout.
+func (q *RedisQueue) reap(ctx context.Context) error {
+	q.leaseMu.Lock()
+	due := time.Since(q.lastReap) >= q.visibility
+	if due {
+		q.lastReap = time.Now()
+	}
+	q.leaseMu.Unlock()
+	if !due {
+		return nil
+	}
+	_, err := q.do(ctx, "EVAL", reapScript, "2", q.keyPrefix+"processing", q.keyPrefix+"queue", q.keyPrefix+"lease:")
+	return err
+}
+
+// do runs a command on the shared connection, reconnecting if the previous
+// command failed.
+func (q *RedisQueue) do(ctx context.Context, args ...string) (any, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.conn == nil {
+		conn, err := redis.Dial(ctx, q.addr, q.password)
+		if err != nil {
+			return nil, err
+		}
+		q.conn = conn
+	}
+	reply, err := q.conn.Do(args...)
+	if err != nil {
+		q.conn.Close()
+		q.conn = nil
+	}
+	return reply, err
+}
@@ -0,0 +1,224 @@
+package jobs
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/internal/redis/redistest"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQueueServer is a fake Redis server that understands the commands of
+// RedisQueue. Keys don't expire on their own; use expire instead.
+type fakeQueueServer struct {
+	*redistest.Server
+	mu     sync.Mutex
+	values map[string]string
+	lists  map[string][]string
+	// failDone makes saving jobs that are done fail, and renewals counts
+	// the PEXPIRE commands.
+	failDone bool
+	renewals int
+}
+
+func newFakeQueueServer() *fakeQueueServer {
+	s := &fakeQueueServer{values: make(map[string]string), lists: make(map[string][]string)}
+	s.Server = redistest.NewServer(s.handle)
+	return s
+}
+
+func (s *fakeQueueServer) handle(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch args[0] {
+	case "SET":
+		if s.failDone && (strings.Contains(args[2], `"status":"succeeded"`) || strings.Contains(args[2], `"status":"failed"`)) {
+			return redistest.Error("ERR out of memory")
+		}
+		s.values[args[1]] = args[2]
+		return redistest.OK
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok {
+			return redistest.Nil
+		}
+		return redistest.Bulk(value)
+	case "DEL":
+		delete(s.values, args[1])
+		return redistest.Int(1)
+	case "PEXPIRE":
+		s.renewals++
+		if _, ok := s.values[args[1]]; !ok {
+			return redistest.Int(0)
+		}
+		return redistest.Int(1)
+	case "RPUSH":
+		s.lists[args[1]] = append(s.lists[args[1]], args[2])
+		return redistest.Int(int64(len(s.lists[args[1]])))
+	case "LREM":
+		n := len(s.lists[args[1]])
+		s.lists[args[1]] = slices.DeleteFunc(s.lists[args[1]], func(id string) bool { return id == args[3] })
+		return redistest.Int(int64(n - len(s.lists[args[1]])))
+	case "BLMOVE":
+		source := s.lists[args[1]]
+		if len(source) == 0 {
+			// Don't make the client spin while the list is empty.
+			s.mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			s.mu.Lock()
+			return redistest.Nil
+		}
+		s.lists[args[1]] = source[1:]
+		s.lists[args[2]] = append(s.lists[args[2]], source[0])
+		return redistest.Bulk(source[0])
+	case "EVAL":
+		if args[1] != reapScript {
+			break
+		}
+		processing, queue, prefix := args[3], args[4], args[5]
+		var n int64
+		for _, id := range slices.Clone(s.lists[processing]) {
+			if _, ok := s.values[prefix+id]; !ok {
+				s.lists[processing] = slices.DeleteFunc(s.lists[processing], func(other string) bool { return other == id })
+				s.lists[queue] = append([]string{id}, s.lists[queue]...)
+				n++
+			}
+		}
+		return redistest.Int(n)
+	}
+	return redistest.Error("ERR unknown command")
+}
+
+func (s *fakeQueueServer) list(key string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.lists[key])
+}
+
+func (s *fakeQueueServer) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.values[key]
+	return ok
+}
+
+func (s *fakeQueueServer) setFailDone(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failDone = fail
+}
+
+func (s *fakeQueueServer) renewalCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.renewals
+}
+
+// expire deletes the key, as if its TTL had passed.
+func (s *fakeQueueServer) expire(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+}
+
+func TestRedisQueue(t *testing.T) {
+	server := newFakeQueueServer()
+	defer server.Close()
+	ctx := context.Background()
+	queue := NewRedisQueue(server.Addr)
+
+	job, err := Chat(ctx, queue, Request{Message: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{job.ID}, server.list("go-llms:jobs:queue"))
+
+	popped, err := queue.Pop(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, popped.ID)
+	assert.Equal(t, "hello", popped.Message)
+	assert.Empty(t, server.list("go-llms:jobs:queue"))
+	assert.Equal(t, []string{job.ID}, server.list("go-llms:jobs:processing"), "Popped jobs should be kept until they're done")
+	assert.True(t, server.has("go-llms:jobs:lease:"+job.ID))
+
+	popped.Status = StatusRunning
+	require.NoError(t, queue.Save(ctx, popped))
+	assert.Equal(t, []string{job.ID}, server.list("go-llms:jobs:processing"))
+
+	popped.Status = StatusSucceeded
+	require.NoError(t, queue.Save(ctx, popped))
+	assert.Empty(t, server.list("go-llms:jobs:processing"), "Jobs that are done should be acknowledged")
+	assert.False(t, server.has("go-llms:jobs:lease:"+job.ID))
+
+	got, err := queue.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSucceeded, got.Status)
+}
+
+func TestRedisQueueRedelivery(t *testing.T) {
+	server := newFakeQueueServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	job, err := Chat(ctx, NewRedisQueue(server.Addr), Request{Message: "hello"})
+	require.NoError(t, err)
+	_, err = NewRedisQueue(server.Addr).Pop(ctx)
+	require.NoError(t, err)
+
+	// The worker died, so its lease expired.
+	server.expire("go-llms:jobs:lease:" + job.ID)
+
+	popCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	popped, err := NewRedisQueue(server.Addr).Pop(popCtx)
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, popped.ID, "The job should be handed out again")
+	assert.Equal(t, []string{job.ID}, server.list("go-llms:jobs:processing"))
+}
+
+func TestRedisQueueFailedAck(t *testing.T) {
+	server := newFakeQueueServer()
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue := NewRedisQueue(server.Addr).WithVisibilityTimeout(30 * time.Millisecond)
+	job, err := Chat(ctx, queue, Request{Message: "hello"})
+	require.NoError(t, err)
+
+	// Saving the result, which acknowledges the job, fails.
+	server.setFailDone(true)
+	var runs atomic.Int32
+	worker := NewWorker(queue, func(ctx context.Context, job Job) (*llms.LLM, error) {
+		runs.Add(1)
+		return llms.New(echoProvider{}), nil
+	})
+	done := make(chan error)
+	go func() { done <- worker.Run(ctx) }()
+	assert.Eventually(t, func() bool {
+		queue.leaseMu.Lock()
+		defer queue.leaseMu.Unlock()
+		polled, err := queue.Get(ctx, job.ID)
+		return err == nil && polled.Status == StatusRunning && len(queue.leases) == 0
+	}, 5*time.Second, 10*time.Millisecond, "The lease should be released once the worker is done with the job")
+	assert.Equal(t, []string{job.ID}, server.list("go-llms:jobs:processing"))
+	renewals := server.renewalCount()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, renewals, server.renewalCount(), "The lease should no longer be renewed")
+
+	// The lease expires, so the job is reclaimed and run again.
+	server.setFailDone(false)
+	server.expire("go-llms:jobs:lease:" + job.ID)
+	assert.Eventually(t, func() bool {
+		polled, err := queue.Get(ctx, job.ID)
+		return err == nil && polled.Status == StatusSucceeded && len(server.list("go-llms:jobs:processing")) == 0
+	}, 5*time.Second, 10*time.Millisecond, "The job should be reclaimed")
+	assert.Equal(t, int32(2), runs.Load())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
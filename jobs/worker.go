@@ -0,0 +1,155 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/webhook"
+)
+
+// Worker takes jobs off a queue and runs them.
+type Worker struct {
+	queue         Queue
+	newLLM        func(ctx context.Context, job Job) (*llms.LLM, error)
+	concurrency   int
+	webhookSecret []byte
+	client        *http.Client
+	onError       func(error)
+}
+
+// NewWorker returns a worker for the queue. newLLM is called for every job
+// and should return an LLM set up with the provider, tools and system prompt
+// to use. To continue conversations across jobs, give it a history store for
+// the job's ConversationID.
+func NewWorker(queue Queue, newLLM func(ctx context.Context, job Job) (*llms.LLM, error)) *Worker {
+	return &Worker{
+		queue:       queue,
+		newLLM:      newLLM,
+		concurrency: 1,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithConcurrency sets how many jobs the worker runs at the same time.
+func (w *Worker) WithConcurrency(n int) *Worker {
+	w.concurrency = max(1, n)
+	return w
+}
+
+// WithWebhookSecret makes the worker sign webhook requests. See
+// webhook.Verify.
+func (w *Worker) WithWebhookSecret(secret string) *Worker {
+	w.webhookSecret = []byte(secret)
+	return w
+}
+
+// WithErrorHandler sets a function that is called with the errors of getting
+// jobs from the queue, for example to log that the queue is unreachable.
+func (w *Worker) WithErrorHandler(onError func(error)) *Worker {
+	w.onError = onError
+	return w
+}
+
+// Run processes jobs until the context is canceled, and then waits for the
+// jobs being run to stop. Jobs that are interrupted are marked as failed.
+// Failing to get a job, for example because the queue is briefly unreachable,
+// doesn't stop the worker: it tries again with exponential backoff, up to
+// every 30 seconds.
+func (w *Worker) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for range w.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delay := time.Duration(0)
+			for {
+				job, err := w.queue.Pop(ctx)
+				if ctx.Err() != nil {
+					if err == nil {
+						// The job was taken off the queue, so it must be
+						// marked as done for it not to be lost.
+						w.process(ctx, job)
+					}
+					return
+				}
+				if err != nil {
+					if w.onError != nil {
+						w.onError(fmt.Errorf("error getting job: %w", err))
+					}
+					delay = min(max(2*delay, 100*time.Millisecond), 30*time.Second)
+					timer := time.NewTimer(delay)
+					select {
+					case <-ctx.Done():
+						timer.Stop()
+						return
+					case <-timer.C:
+					}
+					continue
+				}
+				delay = 0
+				w.process(ctx, job)
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (w *Worker) process(ctx context.Context, job Job) {
+	if releaser, ok := w.queue.(Releaser); ok {
+		// However processing ends, the queue shouldn't keep holding the job.
+		defer releaser.Release(job.ID)
+	}
+	job.Status = StatusRunning
+	job.UpdatedAt = time.Now().UTC()
+	// Failing to save the running state only affects polling, so carry on.
+	w.queue.Save(ctx, job)
+
+	if err := w.run(ctx, &job); err != nil {
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = StatusSucceeded
+	}
+	job.UpdatedAt = time.Now().UTC()
+	// Save the result even if the worker is shutting down.
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	w.queue.Save(saveCtx, job)
+	if job.WebhookURL != "" {
+		w.notify(saveCtx, job)
+	}
+}
+
+func (w *Worker) run(ctx context.Context, job *Job) error {
+	llm, err := w.newLLM(ctx, *job)
+	if err != nil {
+		return fmt.Errorf("error creating LLM: %w", err)
+	}
+	var text strings.Builder
+	for update := range llm.ChatWithContext(ctx, job.Message) {
+		if update, ok := update.(llms.TextUpdate); ok {
+			text.WriteString(update.Text)
+		}
+	}
+	job.Text = text.String()
+	job.InputTokens, job.OutputTokens = llm.Usage()
+	job.CostUSD = llm.CostUSD()
+	return llm.Err()
+}
+
+// notify posts the finished job to its webhook. The stored job state remains
+// available for polling even if the webhook can't be reached.
+func (w *Worker) notify(ctx context.Context, job Job) {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return
+	}
+	webhook.Post(ctx, w.client, job.WebhookURL, w.webhookSecret, body)
+}
@@ -0,0 +1,107 @@
+package llms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditAction identifies the kind of mutation that was applied to the
+// conversation history.
+type AuditAction string
+
+const (
+	AuditActionAppend    AuditAction = "append"
+	AuditActionTrim      AuditAction = "trim"
+	AuditActionSummarize AuditAction = "summarize"
+	AuditActionEdit      AuditAction = "edit"
+)
+
+// AuditEvent records a single mutation of the conversation history. The hashes
+// are computed with HashMessages over the history before and after the
+// mutation, so a chain of events proves exactly what the model was sent.
+type AuditEvent struct {
+	Time          time.Time   `json:"time"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	Action        AuditAction `json:"action"`
+	BeforeHash    string      `json:"before_hash"`
+	AfterHash     string      `json:"after_hash"`
+	BeforeCount   int         `json:"before_count"`
+	AfterCount    int         `json:"after_count"`
+}
+
+// HashMessages returns a hex encoded SHA-256 digest of the JSON encoding of
+// each message. The encoding is stable, so the same history always produces
+// the same hash.
+func HashMessages(messages []Message) string {
+	h := sha256.New()
+	encoder := json.NewEncoder(h)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			// Messages that can't be encoded can't be sent either, but make
+			// sure the hash still reflects that something was there.
+			io.WriteString(h, err.Error())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewAuditWriter returns an audit function (for use with LLM.WithAudit) that
+// writes each event to w as a line of JSON. It is safe for concurrent use.
+func NewAuditWriter(w io.Writer) func(AuditEvent) {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	return func(event AuditEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(event)
+	}
+}
+
+// setHistory replaces the message history, records the mutation in the audit
+// log if one has been configured, and saves the history to the history store
+// if one has been configured.
+func (l *LLM) setHistory(ctx context.Context, action AuditAction, messages []Message) error {
+	if l.audit != nil {
+		l.audit(AuditEvent{
+			Time:          time.Now(),
+			CorrelationID: GetCorrelationID(ctx),
+			Action:        action,
+			BeforeHash:    HashMessages(l.lastSentMessages),
+			AfterHash:     HashMessages(messages),
+			BeforeCount:   len(l.lastSentMessages),
+			AfterCount:    len(messages),
+		})
+	}
+	l.lastSentMessages = messages
+	return l.saveHistory(ctx, messages)
+}
+
+// saveHistory saves the messages to the history store, if there is one.
+func (l *LLM) saveHistory(ctx context.Context, messages []Message) error {
+	if l.historyStore == nil {
+		return nil
+	}
+	if errors.Is(context.Cause(ctx), ErrLockLost) {
+		// Someone else may own the conversation now.
+		return fmt.Errorf("failed to save history: %w", ErrLockLost)
+	}
+	if err := l.historyStore.Save(ctx, l.conversationID, messages); err != nil {
+		return fmt.Errorf("failed to save history: %w", err)
+	}
+	return nil
+}
+
+// isAppend returns true if messages starts with the entire current history.
+func (l *LLM) isAppend(messages []Message) bool {
+	if len(messages) < len(l.lastSentMessages) {
+		return false
+	}
+	return HashMessages(messages[:len(l.lastSentMessages)]) == HashMessages(l.lastSentMessages)
+}
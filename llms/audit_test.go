@@ -0,0 +1,73 @@
+package llms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blixt/go-llms/content"
+)
+
+func TestHashMessagesStable(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: content.FromText("Hello")},
+		{Role: "assistant", Content: content.FromText("Hi!")},
+	}
+	assert.Equal(t, HashMessages(messages), HashMessages(messages), "Hash should be stable")
+	assert.NotEqual(t, HashMessages(messages), HashMessages(messages[:1]), "Different histories should hash differently")
+	assert.Len(t, HashMessages(nil), 64, "Hash should be hex encoded SHA-256")
+}
+
+func TestAuditChatFlow(t *testing.T) {
+	mockProv := &mockProvider{toolCallsToMake: []string{"test_tool"}}
+	var events []AuditEvent
+	llm := New(mockProv, testTool).WithAudit(func(event AuditEvent) {
+		events = append(events, event)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runTestChat(ctx, t, llm, "Test message")
+	require.NoError(t, llm.Err())
+
+	// One event for the user message, then one per turn.
+	require.Len(t, events, 3)
+	for _, event := range events {
+		assert.Equal(t, AuditActionAppend, event.Action)
+	}
+	assert.Equal(t, HashMessages(nil), events[0].BeforeHash)
+	assert.Equal(t, 1, events[0].AfterCount)
+	for i := 1; i < len(events); i++ {
+		assert.Equal(t, events[i-1].AfterHash, events[i].BeforeHash, "Events should form a chain")
+	}
+	assert.Equal(t, HashMessages(llm.lastSentMessages), events[2].AfterHash)
+	assert.Equal(t, 4, events[2].AfterCount)
+
+	// Replacing the history with something that doesn't extend it is an edit.
+	events = nil
+	edited := []Message{{Role: "user", Content: content.FromText("Something else")}}
+	for range llm.ChatUsingMessages(ctx, edited) {
+	}
+	require.NotEmpty(t, events)
+	assert.Equal(t, AuditActionEdit, events[0].Action)
+	assert.Equal(t, 4, events[0].BeforeCount)
+}
+
+func TestNewAuditWriter(t *testing.T) {
+	var buf bytes.Buffer
+	record := NewAuditWriter(&buf)
+	record(AuditEvent{Action: AuditActionAppend, AfterHash: "abc", AfterCount: 1})
+	record(AuditEvent{Action: AuditActionTrim, BeforeHash: "abc", BeforeCount: 1})
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var event AuditEvent
+	require.NoError(t, json.Unmarshal(lines[1], &event))
+	assert.Equal(t, AuditActionTrim, event.Action)
+	assert.Equal(t, "abc", event.BeforeHash)
+}
@@ -0,0 +1,107 @@
+package llms
+
+import (
+	"context"
+	"slices"
+
+	"github.com/blixt/go-llms/tools"
+)
+
+// Recovery decides what happens to a turn that was interrupted, for example by
+// a crash, after the model called tools but before all of them returned.
+type Recovery int
+
+const (
+	// RecoveryResume runs the tool calls that have no result yet, and then
+	// continues the chat. Calls that were running when the process stopped are
+	// run again, so tools should be safe to retry.
+	RecoveryResume Recovery = iota
+	// RecoveryRollback removes the interrupted turn from the history, so that
+	// the model is asked again.
+	RecoveryRollback
+)
+
+// WithCheckpoints makes the LLM save the message history to the history store
+// after every tool call, rather than only when a turn is complete, so that
+// little work is lost if the process stops in the middle of a turn. When a
+// chat finds an interrupted turn in the history, it's recovered as specified
+// before the chat continues. See also Resume.
+func (l *LLM) WithCheckpoints(recovery Recovery) *LLM {
+	l.checkpoints = true
+	l.recovery = recovery
+	return l
+}
+
+// Resume continues the conversation from where it was left off without
+// sending a new message, for example in a new process after a crash. An
+// interrupted turn is recovered as specified by WithCheckpoints (or resumed if
+// checkpoints aren't enabled), and the model is asked to respond if the last
+// message isn't already its response. If there's nothing to do, the returned
+// channel is closed without any updates.
+func (l *LLM) Resume(ctx context.Context) <-chan Update {
+	return l.chat(ctx, nil)
+}
+
+// PendingToolCalls returns the tool calls of the last assistant message that
+// have no result in the messages after it, which means the turn was
+// interrupted before it was complete.
+func PendingToolCalls(messages []Message) []ToolCall {
+	_, pending := incompleteTurn(messages)
+	return pending
+}
+
+// incompleteTurn returns the index of the last assistant message and its
+// pending tool calls, if the messages end with an interrupted turn.
+func incompleteTurn(messages []Message) (index int, pending []ToolCall) {
+	done := make(map[string]bool)
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		switch {
+		case msg.Role == "tool":
+			done[msg.ToolCallID] = true
+			continue
+		case msg.Role != "assistant":
+			return -1, nil
+		}
+		for _, call := range msg.ToolCalls {
+			if !done[call.ID] {
+				pending = append(pending, call)
+			}
+		}
+		return i, pending
+	}
+	return -1, nil
+}
+
+// checkpoint saves the history as it would be if the turn ended now. Only the
+// tool calls that are ready are included in the response, since the arguments
+// of the others may still be streaming, and recovering them would run them
+// with partial arguments.
+func (l *LLM) checkpoint(ctx context.Context, response Message, readyCalls []ToolCall, toolMessages []Message) error {
+	if l.historyStore == nil {
+		return nil
+	}
+	response.ToolCalls = slices.Clone(readyCalls)
+	messages := appendResponse(l.lastSentMessages, response)
+	return l.saveHistory(ctx, append(messages, toolMessages...))
+}
+
+// recoverTurn completes or removes an interrupted turn at the end of the history.
+func (l *LLM) recoverTurn(ctx context.Context, updateChan chan<- Update) error {
+	index, pending := incompleteTurn(l.lastSentMessages)
+	if len(pending) == 0 {
+		return nil
+	}
+	if l.checkpoints && l.recovery == RecoveryRollback {
+		return l.setHistory(ctx, AuditActionEdit, slices.Clone(l.lastSentMessages[:index]))
+	}
+	toolbox := l.toolbox
+	if toolbox == nil {
+		toolbox = tools.Box()
+	}
+	messages := slices.Clone(l.lastSentMessages)
+	for _, call := range pending {
+		messages = append(messages, l.runToolCall(ctx, toolbox, call, updateChan))
+	}
+	return l.setHistory(ctx, AuditActionAppend, messages)
+}
@@ -0,0 +1,173 @@
+package llms
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptedHistory is a conversation where the process stopped while the
+// model's tool call was running.
+func interruptedHistory() []Message {
+	return []Message{
+		{Role: "user", Content: content.FromText("Hello")},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call-1", Name: "test_tool", Arguments: json.RawMessage(`{"test_param":"a"}`)},
+			{ID: "call-2", Name: "test_tool", Arguments: json.RawMessage(`{"test_param":"b"}`)},
+		}},
+		{Role: "tool", ToolCallID: "call-1", Content: content.FromText("done")},
+	}
+}
+
+func TestPendingToolCalls(t *testing.T) {
+	pending := PendingToolCalls(interruptedHistory())
+	require.Len(t, pending, 1)
+	assert.Equal(t, "call-2", pending[0].ID)
+
+	assert.Empty(t, PendingToolCalls(interruptedHistory()[:1]))
+	complete := append(interruptedHistory(), Message{Role: "tool", ToolCallID: "call-2"})
+	assert.Empty(t, PendingToolCalls(complete))
+}
+
+func TestCheckpoints(t *testing.T) {
+	store := &testHistoryStore{}
+	var saved []int
+	tool := tools.Func("Test Tool", "A test tool for testing", "test_tool",
+		func(r tools.Runner, p TestToolParams) tools.Result {
+			messages, _ := store.Load(r.Context(), "conv")
+			saved = append(saved, len(messages))
+			return tools.Success(map[string]any{"ok": true})
+		})
+	llm := New(&mockProvider{toolCallsToMake: []string{"test_tool", "test_tool"}}, tool).
+		WithHistoryStore(store, "conv").
+		WithCheckpoints(RecoveryResume)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runTestChat(ctx, t, llm, "Hello")
+	require.NoError(t, llm.Err())
+	// The result of the first call was saved before the second one ran.
+	assert.Equal(t, []int{1, 3}, saved)
+	assert.Len(t, store.conversations["conv"], 5)
+}
+
+// interleavedProvider streams two tool calls where the second one begins,
+// with partial arguments, before the first one is ready. Once the tool calls
+// have results, it responds with text.
+type interleavedProvider struct {
+	mockProvider
+}
+
+func (p *interleavedProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	if messages[len(messages)-1].Role == "tool" {
+		return p.mockProvider.Generate(ctx, systemPrompt, messages, toolbox)
+	}
+	return &interleavedStream{}
+}
+
+type interleavedStream struct {
+	message Message
+	current int
+}
+
+func (s *interleavedStream) Err() error                             { return nil }
+func (s *interleavedStream) Message() Message                       { return s.message }
+func (s *interleavedStream) Text() string                           { return "" }
+func (s *interleavedStream) ToolCall() ToolCall                     { return s.message.ToolCalls[s.current] }
+func (s *interleavedStream) Usage() (inputTokens, outputTokens int) { return 0, 0 }
+
+func (s *interleavedStream) Iter() func(yield func(StreamStatus) bool) {
+	return func(yield func(StreamStatus) bool) {
+		s.message = Message{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call-1", Name: "test_tool", Arguments: json.RawMessage(`{"test_param":"a"}`)},
+			{ID: "call-2", Name: "test_tool", Arguments: json.RawMessage(`{"test_param":`)},
+		}}
+		if !yield(StreamStatusToolCallBegin) || !yield(StreamStatusToolCallBegin) {
+			return
+		}
+		s.current = 0
+		if !yield(StreamStatusToolCallReady) {
+			return
+		}
+		s.message.ToolCalls[1].Arguments = json.RawMessage(`{"test_param":"b"}`)
+		s.current = 1
+		yield(StreamStatusToolCallReady)
+	}
+}
+
+func TestCheckpointsWithPartialToolCalls(t *testing.T) {
+	store := &testHistoryStore{}
+	var checkpoints [][]Message
+	tool := tools.Func("Test Tool", "A test tool for testing", "test_tool",
+		func(r tools.Runner, p TestToolParams) tools.Result {
+			messages, _ := store.Load(r.Context(), "conv")
+			checkpoints = append(checkpoints, messages)
+			return tools.Success(map[string]any{"ok": true})
+		})
+	llm := New(&interleavedProvider{}, tool).
+		WithHistoryStore(store, "conv").
+		WithCheckpoints(RecoveryResume)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runTestChat(ctx, t, llm, "Hello")
+	require.NoError(t, llm.Err())
+
+	// The checkpoint before the second call ran only has the first call, since
+	// the arguments of the second one were still streaming.
+	require.Len(t, checkpoints, 2)
+	require.Len(t, checkpoints[1], 3)
+	assert.Equal(t, []ToolCall{{ID: "call-1", Name: "test_tool", Arguments: json.RawMessage(`{"test_param":"a"}`)}}, checkpoints[1][1].ToolCalls)
+	assert.Empty(t, PendingToolCalls(checkpoints[1]), "Recovery shouldn't run calls with partial arguments")
+
+	// The complete turn has both calls.
+	messages := store.conversations["conv"]
+	assert.Len(t, messages[1].ToolCalls, 2)
+	assert.JSONEq(t, `{"test_param":"b"}`, string(messages[1].ToolCalls[1].Arguments))
+}
+
+func TestResume(t *testing.T) {
+	store := &testHistoryStore{}
+	require.NoError(t, store.Save(context.Background(), "conv", interruptedHistory()))
+	provider := &mockProvider{}
+	llm := New(provider, testTool).WithHistoryStore(store, "conv")
+
+	var updates []Update
+	for update := range llm.Resume(context.Background()) {
+		updates = append(updates, update)
+	}
+	require.NoError(t, llm.Err())
+	// The pending call ran, and the model was asked to respond to the results.
+	require.IsType(t, ToolDoneUpdate{}, updates[0])
+	assert.Equal(t, "call-2", updates[0].(ToolDoneUpdate).ToolCallID)
+	messages := store.conversations["conv"]
+	require.Len(t, messages, 5)
+	assert.Equal(t, "call-2", messages[3].ToolCallID)
+	assert.Equal(t, "assistant", messages[4].Role)
+
+	// There's nothing to do once the model has responded.
+	provider.generateCalled = false
+	for range llm.Resume(context.Background()) {
+	}
+	require.NoError(t, llm.Err())
+	assert.False(t, provider.generateCalled)
+}
+
+func TestRecoveryRollback(t *testing.T) {
+	store := &testHistoryStore{}
+	require.NoError(t, store.Save(context.Background(), "conv", interruptedHistory()))
+	provider := &mockProvider{}
+	llm := New(provider, testTool).WithHistoryStore(store, "conv").WithCheckpoints(RecoveryRollback)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runTestChat(ctx, t, llm, "Are you there?")
+	require.NoError(t, llm.Err())
+	require.Len(t, provider.messages, 2, "The interrupted turn should be removed")
+	assert.Equal(t, content.FromText("Hello"), provider.messages[0].Content)
+	assert.Equal(t, content.FromText("Are you there?"), provider.messages[1].Content)
+}
@@ -0,0 +1,214 @@
+package llms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// summaryName is the name of the synthetic user messages that hold summaries
+// of compacted history.
+const summaryName = "history_summary"
+
+// CompactionPolicy describes when and how the message history is compacted by
+// having a model summarize its older turns.
+type CompactionPolicy struct {
+	// Threshold is the estimated number of input tokens of a request, including
+	// the system prompt and the tool definitions, above which the history is
+	// compacted before the request is sent.
+	Threshold int
+	// KeepLast is the number of messages at the end of the history that are
+	// never summarized. It defaults to 10, and negative values are treated as
+	// 0, which still keeps the last user message.
+	KeepLast int
+	// Provider summarizes the older turns, which can be a cheaper model than
+	// the one the LLM chats with. It defaults to the provider of the LLM.
+	Provider Provider
+}
+
+// WithCompaction makes the LLM compact the message history when a request
+// would exceed the threshold of the policy, so that agents can run
+// indefinitely. The turns before the last messages are replaced by a single
+// user message with a summary of them, followed by the tool calls that were
+// made in them as JSON, so that their IDs, names and arguments survive. System
+// messages are kept as they are. The compaction is recorded in the audit log as
+// AuditActionSummarize, and the usage of the summary is reported like the usage
+// of a turn. The summary request waits for the limiter and is retried by the
+// retry policy like the requests of the chat.
+func (l *LLM) WithCompaction(policy CompactionPolicy) *LLM {
+	if policy.KeepLast == 0 {
+		policy.KeepLast = 10
+	}
+	l.compaction = &policy
+	return l
+}
+
+// summarizedToolCall is a tool call of a compacted turn, as it's kept in the
+// summary.
+type summarizedToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// compactHistory summarizes the older turns of the message history if the
+// next request would exceed the threshold of the compaction policy.
+func (l *LLM) compactHistory(ctx context.Context, systemPrompt content.Content) error {
+	if l.compaction == nil {
+		return nil
+	}
+	estimate, _ := EstimateCost(l.provider, l.lastSentMessages, EstimateOptions{SystemPrompt: systemPrompt, Toolbox: l.toolbox})
+	if estimate.InputTokens <= l.compaction.Threshold {
+		return nil
+	}
+	// The summarized turns end right before a user message, so that no tool
+	// results are separated from their tool calls.
+	messages := l.lastSentMessages
+	end := -1
+	for i := min(len(messages)-max(l.compaction.KeepLast, 0), len(messages)-1); i > 0; i-- {
+		if messages[i].Role == "user" && messages[i].Name != summaryName {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil
+	}
+
+	var kept, summarized []Message
+	var calls []summarizedToolCall
+	fresh := 0
+	for _, msg := range messages[:end] {
+		switch {
+		case msg.Role == "system":
+			kept = append(kept, msg)
+		case msg.Name == summaryName:
+			// The tool calls of earlier summaries are carried over.
+			for _, item := range msg.Content {
+				if j, ok := item.(*content.JSON); ok {
+					var previous []summarizedToolCall
+					if json.Unmarshal(j.Data, &previous) == nil {
+						calls = append(calls, previous...)
+					}
+				}
+			}
+			summarized = append(summarized, msg)
+		default:
+			for _, call := range msg.ToolCalls {
+				calls = append(calls, summarizedToolCall{ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+			}
+			summarized = append(summarized, msg)
+			fresh++
+		}
+	}
+	if fresh == 0 {
+		// Everything before the last messages has been summarized already.
+		return nil
+	}
+	summary, err := l.summarize(ctx, summarized)
+	if err != nil {
+		return fmt.Errorf("error compacting history: %w", err)
+	}
+	summaryContent := content.Textf("Summary of the earlier conversation:\n\n%s", summary)
+	if len(calls) > 0 {
+		data, err := json.Marshal(calls)
+		if err != nil {
+			return fmt.Errorf("error compacting history: %w", err)
+		}
+		summaryContent = append(summaryContent, &content.JSON{Data: data})
+	}
+	compacted := append(kept, Message{Role: "user", Name: summaryName, Content: summaryContent})
+	return l.setHistory(ctx, AuditActionSummarize, append(compacted, slices.Clone(messages[end:])...))
+}
+
+// summarize asks the summarizing provider for a summary of the messages.
+func (l *LLM) summarize(ctx context.Context, messages []Message) (string, error) {
+	provider := l.compaction.Provider
+	if provider == nil {
+		provider = l.provider
+	}
+	systemPrompt := content.FromText("Summarize the conversation transcript the user provides for whoever continues the conversation, who won't see the transcript. Keep the task, every decision, finding and user preference, and what the tool calls did and returned, but leave out anything that no longer matters. Respond with the summary only.")
+	transcript := []Message{{Role: "user", Content: content.FromText(summaryTranscript(messages))}}
+	// The summary counts towards the same rate limits as the chat, and is
+	// retried like it.
+	if err := l.waitForLimiter(ctx, provider, systemPrompt, transcript); err != nil {
+		return "", err
+	}
+	stream := l.generate(ctx, provider, systemPrompt, transcript, nil)
+	if err := stream.Err(); err != nil {
+		return "", fmt.Errorf("LLM returned error response: %w", &ProviderError{Err: err})
+	}
+	for range stream.Iter() {
+	}
+	if err := stream.Err(); err != nil {
+		return "", fmt.Errorf("error iterating stream: %w", &ProviderError{Err: err})
+	}
+
+	model := provider.Model()
+	if s, ok := stream.(ModelStream); ok && s.Model() != "" {
+		model = s.Model()
+	}
+	var details UsageDetails
+	if s, ok := stream.(UsageDetailsStream); ok {
+		details = s.UsageDetails()
+	}
+	inputTokens, outputTokens := stream.Usage()
+	pricing, _ := LookupPricing(model)
+	l.reportUsage(Usage{
+		CorrelationID: GetCorrelationID(ctx),
+		Company:       provider.Company(),
+		Model:         model,
+		InputTokens:   inputTokens,
+		OutputTokens:  outputTokens,
+		UsageDetails:  details,
+		CostUSD:       pricing.CostWithDetails(inputTokens, outputTokens, details),
+	})
+	summary := strings.TrimSpace(textOf(stream.Message().Content))
+	if summary == "" {
+		return "", fmt.Errorf("LLM returned an empty summary")
+	}
+	return summary, nil
+}
+
+// summaryTranscript formats the messages as plain text for summarization.
+func summaryTranscript(messages []Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		text := textOf(msg.Content)
+		switch {
+		case msg.Name == summaryName:
+			fmt.Fprintf(&b, "%s\n\n", text)
+		case msg.Role == "user":
+			fmt.Fprintf(&b, "User: %s\n\n", text)
+		case msg.Role == "assistant":
+			if text != "" {
+				fmt.Fprintf(&b, "Assistant: %s\n\n", text)
+			}
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&b, "Assistant called %s (%s) with %s\n\n", call.Name, call.ID, call.Arguments)
+			}
+		case msg.Role == "tool":
+			fmt.Fprintf(&b, "Tool result for %s: %s\n\n", msg.ToolCallID, text)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// textOf returns the text items of the content, one per line. JSON items are
+// included as is.
+func textOf(c content.Content) string {
+	var parts []string
+	for _, item := range c {
+		switch v := item.(type) {
+		case *content.Text:
+			parts = append(parts, v.Text)
+		case *content.JSON:
+			parts = append(parts, string(v.Data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,89 @@
+package llms
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// summaryToolCalls returns the JSON of the tool calls kept in a summary.
+func summaryToolCalls(t *testing.T, msg Message) string {
+	t.Helper()
+	for _, item := range msg.Content {
+		if j, ok := item.(*content.JSON); ok {
+			return string(j.Data)
+		}
+	}
+	t.Fatal("The summary has no tool calls")
+	return ""
+}
+
+func TestWithCompaction(t *testing.T) {
+	summarizer := &mockProvider{}
+	var events []AuditEvent
+	var usage []Usage
+	llm := New(&mockProvider{}).
+		WithCompaction(CompactionPolicy{Threshold: 300, KeepLast: 2, Provider: summarizer}).
+		WithAudit(func(event AuditEvent) { events = append(events, event) }).
+		WithUsageCallback(func(u Usage) { usage = append(usage, u) })
+	require.NoError(t, llm.SetHistory(trimTestMessages()))
+	for range llm.Chat("question 4") {
+	}
+	require.NoError(t, llm.Err())
+
+	history := llm.History()
+	require.Len(t, history, 6)
+	assert.Equal(t, "system", history[0].Role, "System messages should be kept")
+	summary := history[1]
+	assert.Equal(t, "user", summary.Role)
+	assert.Equal(t, summaryName, summary.Name)
+	assert.Contains(t, textOf(summary.Content), "This is a test message.")
+	assert.JSONEq(t, `[{"id":"1","name":"`+strings.Repeat("x", 400)+`"}]`, summaryToolCalls(t, summary))
+	assert.Equal(t, trimTestMessages()[7:], history[2:4], "The last messages should be kept")
+	assert.Contains(t, textOf(summarizer.messages[0].Content), "Tool result for 1:")
+	assert.Equal(t, AuditActionSummarize, events[2].Action)
+	assert.Len(t, usage, 2, "The usage of the summary should be reported")
+
+	// The tool calls of earlier summaries are carried over.
+	long := strings.Repeat(".", 800)
+	require.NoError(t, llm.AppendMessage(Message{Role: "user", Content: content.FromText(long)}))
+	require.NoError(t, llm.AppendMessage(Message{Role: "assistant", Content: content.FromText(long)}))
+	for range llm.Chat("question 5") {
+	}
+	require.NoError(t, llm.Err())
+	history = llm.History()
+	assert.Equal(t, summaryName, history[1].Name)
+	assert.Contains(t, summaryToolCalls(t, history[1]), `"id":"1"`)
+	assert.Equal(t, content.FromText("question 5"), history[len(history)-2].Content)
+}
+
+func TestCompactionNegativeKeepLast(t *testing.T) {
+	llm := New(&mockProvider{}).WithCompaction(CompactionPolicy{Threshold: 300, KeepLast: -1, Provider: &mockProvider{}})
+	require.NoError(t, llm.SetHistory(trimTestMessages()))
+	for range llm.Chat("question 4") {
+	}
+	require.NoError(t, llm.Err())
+	history := llm.History()
+	assert.Equal(t, summaryName, history[1].Name)
+	assert.Equal(t, content.FromText("question 4"), history[2].Content, "The last user message should be kept")
+}
+
+func TestCompactionRetriesAndLimits(t *testing.T) {
+	summarizer := &flakyProvider{errs: []error{&statusError{status: 503}}}
+	limiter := &recordingLimiter{}
+	llm := New(&mockProvider{}).
+		WithCompaction(CompactionPolicy{Threshold: 300, KeepLast: 2, Provider: summarizer}).
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}).
+		WithLimiter(limiter)
+	require.NoError(t, llm.SetHistory(trimTestMessages()))
+	for range llm.Chat("question 4") {
+	}
+	require.NoError(t, llm.Err())
+	assert.Equal(t, 2, summarizer.calls, "The summary request should be retried")
+	assert.Len(t, limiter.tokens, 3, "The limiter should be consulted for the summary, its retry and the chat")
+	assert.Equal(t, summaryName, llm.History()[1].Name)
+}
@@ -0,0 +1,48 @@
+package llms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// correlationIDKey is the context key for the correlation ID of a chat.
+var correlationIDKey = &contextKey{"correlation-id"}
+
+// ContextWithCorrelationID returns a context that makes chats started with it
+// use the given correlation ID, for example one that came from an incoming
+// request, so that traces across services line up.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// GetCorrelationID returns the correlation ID of the context, or an empty
+// string if it has none. Tools can use it with Runner.Context(), and providers
+// use it to tag their requests where the API supports it.
+func GetCorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
+
+// WithCorrelationID sets the correlation ID that chats use when their context
+// doesn't have one.
+func (l *LLM) WithCorrelationID(id string) *LLM {
+	l.correlationID = id
+	return l
+}
+
+// CorrelationID returns the correlation ID that chats use when their context
+// doesn't have one. Unless one was set with WithCorrelationID, it's the
+// conversation ID of the history store, or else a random ID that stays the
+// same for the lifetime of the LLM.
+func (l *LLM) CorrelationID() string {
+	if l.correlationID == "" {
+		if l.conversationID != "" {
+			return l.conversationID
+		}
+		var idBytes [16]byte
+		rand.Read(idBytes[:])
+		l.correlationID = hex.EncodeToString(idBytes[:])
+	}
+	return l.correlationID
+}
@@ -0,0 +1,52 @@
+package llms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCorrelationID(t *testing.T) {
+	var toolSaw string
+	probe := tools.Func("Test Tool", "A test tool for testing", "test_tool", func(r tools.Runner, p TestToolParams) tools.Result {
+		toolSaw = GetCorrelationID(r.Context())
+		return tools.SuccessFromString("ok")
+	})
+	var usage []Usage
+	llm := New(&mockProvider{toolCallsToMake: []string{"test_tool"}}, probe).WithUsageCallback(func(u Usage) {
+		usage = append(usage, u)
+	})
+
+	ctx := ContextWithCorrelationID(context.Background(), "req-1")
+	var ids []string
+	for update := range llm.ChatWithContext(ctx, "Hello") {
+		switch update := update.(type) {
+		case TextUpdate:
+			ids = append(ids, update.CorrelationID)
+		case ToolStartUpdate:
+			ids = append(ids, update.CorrelationID)
+		case ToolDoneUpdate:
+			ids = append(ids, update.CorrelationID)
+		}
+	}
+	assert.NoError(t, llm.Err())
+	assert.Equal(t, []string{"req-1", "req-1", "req-1", "req-1"}, ids)
+	assert.Equal(t, "req-1", toolSaw)
+	for _, u := range usage {
+		assert.Equal(t, "req-1", u.CorrelationID)
+	}
+
+	// Without an ID in the context, the LLM's own ID is used.
+	id := llm.CorrelationID()
+	assert.NotEmpty(t, id)
+	assert.Equal(t, id, llm.CorrelationID(), "The generated ID should be stable")
+	for update := range llm.Chat("Again") {
+		if update, ok := update.(TextUpdate); ok {
+			assert.Equal(t, id, update.CorrelationID)
+		}
+	}
+	assert.Equal(t, "conversation-1", New(&mockProvider{}).WithHistoryStore(nil, "conversation-1").CorrelationID())
+	assert.Equal(t, "mine", New(&mockProvider{}).WithCorrelationID("mine").CorrelationID())
+}
@@ -0,0 +1,55 @@
+package llms
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCoreDependencies checks that the core packages only depend on the
+// standard library, so that users who only need them don't pull in the
+// dependencies of optional packages. The only exception is the image package
+// behind the deprecated image functions of content, until they're removed.
+func TestCoreDependencies(t *testing.T) {
+	const module = "github.com/blixt/go-llms/"
+	allowed := map[string]bool{module + "internal/imaging": true}
+	seen := map[string]bool{}
+	var external []string
+	var visit func(pkg string)
+	visit = func(pkg string) {
+		if seen[pkg] {
+			return
+		}
+		seen[pkg] = true
+		dir := filepath.Join("..", strings.TrimPrefix(pkg, module))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.ImportsOnly)
+			require.NoError(t, err)
+			for _, spec := range file.Imports {
+				path, _ := strconv.Unquote(spec.Path.Value)
+				switch {
+				case strings.HasPrefix(path, module):
+					visit(path)
+				case strings.Contains(strings.Split(path, "/")[0], ".") && !allowed[pkg]:
+					external = append(external, pkg+" imports "+path)
+				}
+			}
+		}
+	}
+	visit(module + "llms")
+	assert.Empty(t, external)
+	assert.True(t, seen[module+"content"])
+}
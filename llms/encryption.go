@@ -0,0 +1,106 @@
+package llms
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// encryptionMagic prefixes all data produced by Encrypt, so that encrypted
+// data can be told apart from plaintext and the format can evolve.
+var encryptionMagic = []byte("GLE1")
+
+// KeyProvider provides the keys used to encrypt conversations at rest. Every
+// key has an ID which is stored alongside the encrypted data, which makes it
+// possible to rotate keys: new data is encrypted with the current key while
+// older data can still be decrypted with the key it was encrypted with.
+type KeyProvider interface {
+	// CurrentKey returns the ID and value of the key to encrypt new data with.
+	// The key must be 16, 24, or 32 bytes long to select AES-128, AES-192, or
+	// AES-256.
+	CurrentKey(ctx context.Context) (id string, key []byte, err error)
+	// Key returns the value of the key with the given ID.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKey returns a KeyProvider that always uses the same key.
+func StaticKey(key []byte) KeyProvider {
+	return staticKey(key)
+}
+
+type staticKey []byte
+
+func (k staticKey) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return "static", k, nil
+}
+
+func (k staticKey) Key(ctx context.Context, id string) ([]byte, error) {
+	if id != "static" {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return k, nil
+}
+
+// Encrypt encrypts the data with AES-GCM using the current key of the
+// provider. The result can be decrypted with Decrypt.
+func Encrypt(ctx context.Context, keys KeyProvider, plaintext []byte) ([]byte, error) {
+	id, key, err := keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("key ID %q is too long", id)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := append(append(bytes.Clone(encryptionMagic), byte(len(id))), id...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	// The header is authenticated so the key ID can't be tampered with.
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// Decrypt decrypts data that was encrypted with Encrypt.
+func Decrypt(ctx context.Context, keys KeyProvider, ciphertext []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(ciphertext, encryptionMagic)
+	if !ok || len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+		return nil, errors.New("data is not encrypted")
+	}
+	idLen := int(rest[0])
+	id := string(rest[1 : 1+idLen])
+	header := ciphertext[:len(encryptionMagic)+1+idLen]
+	rest = rest[1+idLen:]
+	key, err := keys.Key(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get decryption key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < aead.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,73 @@
+package llms
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rotatingKeys is a KeyProvider with several keys, of which one is current.
+type rotatingKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (k *rotatingKeys) CurrentKey(ctx context.Context) (string, []byte, error) {
+	return k.current, k.keys[k.current], nil
+}
+
+func (k *rotatingKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return key, nil
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	keys := StaticKey(bytes.Repeat([]byte{1}, 32))
+	plaintext := []byte(`[{"role":"user","content":"My SSN is 123"}]`)
+
+	encrypted, err := Encrypt(ctx, keys, plaintext)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "SSN", "Plaintext should not be visible")
+
+	decrypted, err := Decrypt(ctx, keys, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	// Tampering is detected.
+	encrypted[len(encrypted)-1] ^= 1
+	_, err = Decrypt(ctx, keys, encrypted)
+	assert.Error(t, err)
+
+	_, err = Decrypt(ctx, keys, plaintext)
+	assert.Error(t, err, "Plaintext should not decrypt")
+
+	_, err = Encrypt(ctx, StaticKey([]byte("short")), plaintext)
+	assert.Error(t, err, "Invalid key sizes should be rejected")
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	keys := &rotatingKeys{current: "v1", keys: map[string][]byte{
+		"v1": bytes.Repeat([]byte{1}, 16),
+		"v2": bytes.Repeat([]byte{2}, 16),
+	}}
+	old, err := Encrypt(ctx, keys, []byte("old"))
+	require.NoError(t, err)
+
+	keys.current = "v2"
+	decrypted, err := Decrypt(ctx, keys, old)
+	require.NoError(t, err, "Data encrypted with an older key should still decrypt")
+	assert.Equal(t, "old", string(decrypted))
+
+	delete(keys.keys, "v1")
+	_, err = Decrypt(ctx, keys, old)
+	assert.Error(t, err)
+}
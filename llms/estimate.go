@@ -0,0 +1,87 @@
+package llms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+// EstimateTokens estimates the number of tokens of the content, for when the
+// provider doesn't count them. It assumes that a token is about four
+// characters, which holds up reasonably well for English text with most
+// tokenizers. Images count as a fixed amount since their cost can't be known,
+// and audio is estimated from its size.
+func EstimateTokens(c content.Content) int {
+	var chars, tokens int
+	for _, item := range c {
+		switch v := item.(type) {
+		case *content.Text:
+			chars += len(v.Text)
+		case *content.JSON:
+			chars += len(v.Data)
+		case *content.Thinking:
+			chars += len(v.Text)
+		case *content.Document:
+			chars += len(v.Title) + len(v.Data)
+		case *content.ImageURL:
+			tokens += 765
+		case *content.Audio:
+			// About ten tokens per second of 128 kbps audio, which overestimates
+			// uncompressed formats.
+			tokens += base64.StdEncoding.DecodedLen(len(v.Data)) / 1600
+		}
+	}
+	return tokens + (chars+3)/4
+}
+
+// EstimateMessageTokens estimates the number of tokens of the messages,
+// including their tool calls, like EstimateTokens.
+func EstimateMessageTokens(messages []Message) int {
+	var tokens int
+	for _, msg := range messages {
+		var chars int
+		for _, tc := range msg.ToolCalls {
+			chars += len(tc.Name) + len(tc.Arguments)
+		}
+		tokens += EstimateTokens(msg.Content) + (chars+3)/4
+	}
+	return tokens
+}
+
+// EstimateOptions describes the parts of a prospective request other than the
+// messages, for EstimateCost.
+type EstimateOptions struct {
+	SystemPrompt content.Content
+	Toolbox      *tools.Toolbox
+	// OutputTokens is the expected length of the response. It defaults to
+	// 500 tokens.
+	OutputTokens int
+}
+
+// CostEstimate is the estimated size and cost of a request.
+type CostEstimate struct {
+	InputTokens  int
+	OutputTokens int
+	CostUSD      float64
+}
+
+// EstimateCost estimates what a request with the messages would cost with the
+// provider, without making the request, so that budgets can be enforced
+// before sending it. Tokens are estimated like EstimateTokens and priced like
+// LookupPricing, which determines the value of ok.
+func EstimateCost(p Provider, messages []Message, opts EstimateOptions) (estimate CostEstimate, ok bool) {
+	estimate.InputTokens = EstimateTokens(opts.SystemPrompt) + EstimateMessageTokens(messages)
+	if schemas := toolSchemas(opts.Toolbox); len(schemas) > 0 {
+		data, _ := json.Marshal(schemas)
+		estimate.InputTokens += (len(data) + 3) / 4
+	}
+	estimate.OutputTokens = opts.OutputTokens
+	if estimate.OutputTokens <= 0 {
+		estimate.OutputTokens = 500
+	}
+	pricing, ok := LookupPricing(p.Model())
+	estimate.CostUSD = pricing.Cost(estimate.InputTokens, estimate.OutputTokens)
+	return estimate, ok
+}
@@ -0,0 +1,44 @@
+package llms
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// modelProvider is a provider that only has a model name.
+type modelProvider string
+
+func (p modelProvider) Company() string { return "Test" }
+func (p modelProvider) Model() string   { return string(p) }
+func (p modelProvider) String() string  { return ProviderName(p) }
+
+func (p modelProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	return &errorMockStream{}
+}
+
+func TestEstimateCost(t *testing.T) {
+	messages := []Message{{Role: "user", Content: content.FromText(strings.Repeat("a", 4000))}}
+
+	estimate, ok := EstimateCost(modelProvider("gpt-4o-2024-08-06"), messages, EstimateOptions{
+		SystemPrompt: content.FromText(strings.Repeat("b", 400)),
+	})
+	assert.True(t, ok)
+	assert.Equal(t, 1100, estimate.InputTokens)
+	assert.Equal(t, 500, estimate.OutputTokens)
+	assert.InDelta(t, (1100*2.5+500*10)/1e6, estimate.CostUSD, 1e-12)
+
+	// Tools count towards the input.
+	withTools, _ := EstimateCost(modelProvider("gpt-4o"), messages, EstimateOptions{Toolbox: tools.Box(testTool), OutputTokens: 10})
+	assert.Greater(t, withTools.InputTokens, 1000)
+	assert.Equal(t, 10, withTools.OutputTokens)
+
+	estimate, ok = EstimateCost(modelProvider("unknown-model"), messages, EstimateOptions{})
+	assert.False(t, ok)
+	assert.Equal(t, 1000, estimate.InputTokens)
+	assert.Zero(t, estimate.CostUSD)
+}
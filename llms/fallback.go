@@ -0,0 +1,110 @@
+package llms
+
+import (
+	"context"
+	"slices"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+// FallbackChain is a provider that sends requests to a primary provider, and
+// to fallback providers in order when the providers before them fail. The
+// providers can be different models of different companies, since each of
+// them converts the message history to its own API and leaves out content
+// that only the provider it came from understands.
+type FallbackChain struct {
+	providers  []Provider
+	shouldFall func(err error) bool
+	onFallback func(from, to Provider, err error)
+}
+
+// Fallback returns a provider that falls back on the other providers in order
+// when the primary provider fails, for example:
+//
+//	llms.Fallback(
+//		anthropic.New(anthropicKey, "claude-sonnet-4-0"),
+//		openai.New(openaiKey, "gpt-4.1"),
+//	)
+//
+// Usage is attributed to the model that the stream reports it was served by.
+func Fallback(primary Provider, fallbacks ...Provider) *FallbackChain {
+	return &FallbackChain{providers: append([]Provider{primary}, fallbacks...)}
+}
+
+// WithCondition sets which errors make the chain move on to the next
+// provider. By default every error does.
+func (f *FallbackChain) WithCondition(shouldFall func(err error) bool) *FallbackChain {
+	f.shouldFall = shouldFall
+	return f
+}
+
+// WithCallback sets a function that is called whenever the chain falls back
+// from a provider that failed with the error to the next one.
+func (f *FallbackChain) WithCallback(onFallback func(from, to Provider, err error)) *FallbackChain {
+	f.onFallback = onFallback
+	return f
+}
+
+func (f *FallbackChain) Company() string {
+	return f.providers[0].Company()
+}
+
+func (f *FallbackChain) Model() string {
+	return f.providers[0].Model()
+}
+
+func (f *FallbackChain) String() string {
+	return ProviderName(f)
+}
+
+// ToolResultTypes returns the content types that all of the providers can
+// send back in tool results, so that tool results stay valid whichever
+// provider the conversation falls back on.
+func (f *FallbackChain) ToolResultTypes() []content.Type {
+	return commonToolResultTypes(f.providers)
+}
+
+// commonToolResultTypes returns the tool result types that all of the
+// providers that know their types accept, or nil if none of them know.
+func commonToolResultTypes(providers []Provider) []content.Type {
+	var types []content.Type
+	known := false
+	for _, p := range providers {
+		typer, ok := p.(ToolResultTyper)
+		if !ok || typer.ToolResultTypes() == nil {
+			continue
+		}
+		if !known {
+			types, known = slices.Clone(typer.ToolResultTypes()), true
+			continue
+		}
+		types = slices.DeleteFunc(types, func(t content.Type) bool {
+			return !slices.Contains(typer.ToolResultTypes(), t)
+		})
+	}
+	return types
+}
+
+func (f *FallbackChain) Ping(ctx context.Context) error {
+	return Ping(ctx, f.providers[0])
+}
+
+// Generate tries the providers in order until one of them doesn't fail, and
+// returns the error of the last one if they all do. Only errors reported
+// before the stream starts make the chain fall back, since a started response
+// can't be taken back.
+func (f *FallbackChain) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	var stream ProviderStream
+	for i, p := range f.providers {
+		stream = p.Generate(ctx, systemPrompt, messages, toolbox)
+		err := stream.Err()
+		if err == nil || ctx.Err() != nil || (f.shouldFall != nil && !f.shouldFall(err)) {
+			return stream
+		}
+		if f.onFallback != nil && i+1 < len(f.providers) {
+			f.onFallback(p, f.providers[i+1], err)
+		}
+	}
+	return stream
+}
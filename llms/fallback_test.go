@@ -0,0 +1,65 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+)
+
+// typedKeyProvider is a keyProvider that accepts the given tool result types.
+type typedKeyProvider struct {
+	keyProvider
+	types []content.Type
+}
+
+func (p *typedKeyProvider) ToolResultTypes() []content.Type { return p.types }
+
+func TestFallback(t *testing.T) {
+	primary := &keyProvider{err: errors.New("529 Overloaded")}
+	secondary := &keyProvider{err: errors.New("503 Service Unavailable")}
+	last := &keyProvider{}
+	var fallbacks []string
+	chain := Fallback(primary, secondary, last).WithCallback(func(from, to Provider, err error) {
+		fallbacks = append(fallbacks, err.Error())
+	})
+	assert.Equal(t, "test-model", chain.Model())
+
+	assert.NoError(t, chain.Generate(context.Background(), nil, nil, nil).Err())
+	assert.Equal(t, []int{1, 1, 1}, []int{primary.calls, secondary.calls, last.calls})
+	assert.Equal(t, []string{"529 Overloaded", "503 Service Unavailable"}, fallbacks)
+
+	// The primary provider is tried first again once it's healthy.
+	primary.err = nil
+	assert.NoError(t, chain.Generate(context.Background(), nil, nil, nil).Err())
+	assert.Equal(t, []int{2, 1, 1}, []int{primary.calls, secondary.calls, last.calls})
+
+	// When every provider fails, the last error is returned.
+	primary.err, last.err = errors.New("500 Internal Server Error"), errors.New("401 Unauthorized")
+	assert.EqualError(t, chain.Generate(context.Background(), nil, nil, nil).Err(), "401 Unauthorized")
+
+	// Errors that don't meet the condition are returned as is.
+	chain.WithCondition(func(err error) bool { return err.Error() != "400 Bad Request" })
+	primary.err = errors.New("400 Bad Request")
+	assert.EqualError(t, chain.Generate(context.Background(), nil, nil, nil).Err(), "400 Bad Request")
+	assert.Equal(t, 2, secondary.calls)
+
+	// A canceled request isn't sent to the fallbacks.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	primary.err = context.Canceled
+	chain.Generate(ctx, nil, nil, nil)
+	assert.Equal(t, 2, secondary.calls)
+}
+
+func TestFallbackToolResultTypes(t *testing.T) {
+	assert.Nil(t, Fallback(&keyProvider{}, &keyProvider{}).ToolResultTypes())
+	chain := Fallback(
+		&typedKeyProvider{types: []content.Type{content.TypeText, content.TypeImageURL, content.TypeJSON}},
+		&keyProvider{},
+		&typedKeyProvider{types: []content.Type{content.TypeJSON, content.TypeText}},
+	)
+	assert.Equal(t, []content.Type{content.TypeText, content.TypeJSON}, chain.ToolResultTypes())
+}
@@ -0,0 +1,74 @@
+package llms
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// Fingerprint returns a stable hash of a prompt, meant for finding repeated
+// prompts (candidates for caching) in analytics. Unlike HashMessages, it is
+// computed over a normalized form of the prompt: whitespace is collapsed, JSON
+// is re-encoded with sorted keys, and tool call IDs and thinking are ignored,
+// since these vary between otherwise identical requests.
+func Fingerprint(systemPrompt content.Content, messages []Message) string {
+	h := sha256.New()
+	if len(systemPrompt) > 0 {
+		io.WriteString(h, "system\n")
+		writeNormalizedContent(h, systemPrompt)
+	}
+	for _, message := range messages {
+		fmt.Fprintf(h, "%s\n", message.Role)
+		writeNormalizedContent(h, message.Content)
+		for _, toolCall := range message.ToolCalls {
+			fmt.Fprintf(h, "call %s %s\n", toolCall.Name, normalizeJSON(toolCall.Arguments))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FingerprintContent returns a stable hash of the content, normalized the same
+// way as in Fingerprint.
+func FingerprintContent(c content.Content) string {
+	h := sha256.New()
+	writeNormalizedContent(h, c)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeNormalizedContent(h hash.Hash, c content.Content) {
+	for _, item := range c {
+		switch v := item.(type) {
+		case *content.Text:
+			fmt.Fprintf(h, "text %s\n", strings.Join(strings.Fields(v.Text), " "))
+		case *content.ImageURL:
+			fmt.Fprintf(h, "image %s\n", v.URL)
+		case *content.JSON:
+			fmt.Fprintf(h, "json %s\n", normalizeJSON(v.Data))
+		case *content.Thinking:
+			// Thinking is not part of what the model is prompted with.
+		default:
+			data, _ := json.Marshal(item)
+			fmt.Fprintf(h, "%s %s\n", item.Type(), data)
+		}
+	}
+}
+
+// normalizeJSON re-encodes the JSON value so that whitespace and key order
+// don't matter. Invalid JSON is returned as is.
+func normalizeJSON(data json.RawMessage) string {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return string(data)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return string(data)
+	}
+	return string(normalized)
+}
@@ -0,0 +1,57 @@
+package llms
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprintNormalization(t *testing.T) {
+	system := content.FromText("You are  helpful.\n")
+	a := []Message{
+		{Role: "user", Content: content.FromText("What is\tthe weather?")},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "weather", Arguments: json.RawMessage(`{"city": "Paris", "unit": "C"}`)}}},
+		{Role: "tool", ToolCallID: "call_1", Content: content.FromRawJSON(json.RawMessage(`{"temp": 20, "sky": "clear"}`))},
+	}
+	b := []Message{
+		{Role: "user", Content: content.FromText("What is the weather?")},
+		{Role: "assistant", Content: content.Content{&content.Thinking{Text: "Let me check."}}, ToolCalls: []ToolCall{{ID: "call_2", Name: "weather", Arguments: json.RawMessage(`{"unit":"C","city":"Paris"}`)}}},
+		{Role: "tool", ToolCallID: "call_2", Content: content.FromRawJSON(json.RawMessage(`{"sky":"clear","temp":20}`))},
+	}
+	assert.Equal(t, Fingerprint(system, a), Fingerprint(content.FromText("You are helpful."), b), "Equivalent prompts should have the same fingerprint")
+	assert.NotEqual(t, Fingerprint(system, a), Fingerprint(nil, a), "The system prompt should be part of the fingerprint")
+	assert.NotEqual(t, Fingerprint(system, a), Fingerprint(system, a[:1]))
+	assert.NotEqual(t, FingerprintContent(content.FromText("Hello")), FingerprintContent(content.FromText("hello")), "Case should matter")
+}
+
+func TestUsageCallback(t *testing.T) {
+	var usages []Usage
+	llm := New(&mockProvider{toolCallsToMake: []string{"test_tool"}}, testTool).WithUsageCallback(func(u Usage) {
+		usages = append(usages, u)
+	})
+	llm.SystemPrompt = func() content.Content { return content.FromText("System") }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runTestChat(ctx, t, llm, "Test message")
+	require.NoError(t, llm.Err())
+
+	require.Len(t, usages, 2, "Usage should be reported for every turn")
+	for _, u := range usages {
+		assert.Equal(t, "Test Company", u.Company)
+		assert.Equal(t, "test-model", u.Model)
+		assert.Equal(t, 10, u.InputTokens)
+		assert.Equal(t, 20, u.OutputTokens)
+		assert.Equal(t, FingerprintContent(content.FromText("System")), u.SystemPromptFingerprint)
+		assert.NotEmpty(t, u.ResponseFingerprint)
+	}
+	assert.NotEqual(t, usages[0].PromptFingerprint, usages[1].PromptFingerprint)
+	inputTokens, outputTokens := llm.Usage()
+	assert.Equal(t, 20, inputTokens)
+	assert.Equal(t, 40, outputTokens)
+}
@@ -0,0 +1,44 @@
+package llms
+
+const UpdateTypeFinish UpdateType = "finish"
+
+// FinishReason is why the model stopped generating a response.
+type FinishReason string
+
+const (
+	// FinishReasonStop means the model finished its response, or produced a
+	// stop sequence.
+	FinishReasonStop FinishReason = "stop"
+	// FinishReasonLength means the response was cut off because it reached
+	// the maximum number of output tokens or the context window.
+	FinishReasonLength FinishReason = "length"
+	// FinishReasonToolCalls means the model stopped to call tools.
+	FinishReasonToolCalls FinishReason = "tool_calls"
+	// FinishReasonContentFilter means the response was cut off by a content
+	// filter of the provider.
+	FinishReasonContentFilter FinishReason = "content_filter"
+)
+
+// FinishReasonStream is implemented by streams that know why the model
+// stopped generating. Providers may report reasons other than the ones
+// defined here.
+type FinishReasonStream interface {
+	ProviderStream
+	FinishReason() FinishReason
+}
+
+// FinishUpdate is sent at the end of every turn whose stream reports why the
+// model stopped, so that responses that were cut off by the length limit or a
+// content filter can be detected.
+type FinishUpdate struct {
+	Reason        FinishReason `json:"reason"`
+	CorrelationID string       `json:"correlation_id,omitempty"`
+}
+
+func (u FinishUpdate) Type() UpdateType {
+	return UpdateTypeFinish
+}
+
+func init() {
+	RegisterUpdateType[FinishUpdate]()
+}
@@ -0,0 +1,46 @@
+package llms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// filteringProvider returns streams that report that a content filter cut
+// off the response.
+type filteringProvider struct {
+	mockProvider
+}
+
+type filteredStream struct {
+	ProviderStream
+}
+
+func (s filteredStream) FinishReason() FinishReason {
+	return FinishReasonContentFilter
+}
+
+func (p *filteringProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	return filteredStream{p.mockProvider.Generate(ctx, systemPrompt, messages, toolbox)}
+}
+
+func TestFinishUpdate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	updates := runTestChat(ctx, t, New(&mockProvider{}), "Hello")
+	for _, u := range updates {
+		assert.NotEqual(t, UpdateTypeFinish, u.Type(), "Streams without a finish reason send no finish update")
+	}
+
+	llm := New(&filteringProvider{})
+	updates = runTestChat(ctx, t, llm, "Hello")
+	assert.Equal(t, []Update{
+		TextUpdate{Text: "This is a test message.", CorrelationID: llm.CorrelationID()},
+		FinishUpdate{Reason: FinishReasonContentFilter, CorrelationID: llm.CorrelationID()},
+	}, updates)
+}
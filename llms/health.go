@@ -0,0 +1,60 @@
+package llms
+
+import (
+	"context"
+	"errors"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// Pinger is implemented by providers that can check that they're reachable
+// and that their credentials work without generating anything, typically by
+// looking up the model in the API.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Ping checks the health of the provider, for example for a readiness probe,
+// or so that a router can skip a failing provider before it's needed.
+// Providers that don't implement Pinger are checked with PingWithGenerate.
+func Ping(ctx context.Context, p Provider) error {
+	if pinger, ok := p.(Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return PingWithGenerate(ctx, p)
+}
+
+// PingWithGenerate checks the health of the provider by sending it a tiny
+// prompt and waiting for the response to start. The response is abandoned as
+// soon as it starts, but it can still incur a small cost.
+func PingWithGenerate(ctx context.Context, p Provider) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream := p.Generate(ctx, nil, []Message{{Role: "user", Content: content.FromText("ping")}}, nil)
+	if err := stream.Err(); err != nil {
+		return err
+	}
+	var started bool
+	for range stream.Iter() {
+		started = true
+		break
+	}
+	if started {
+		return nil
+	}
+	if err := stream.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Ping succeeds if any of the providers is healthy.
+func (r *KeyRotator) Ping(ctx context.Context) error {
+	var err error
+	for _, i := range r.order() {
+		if err = Ping(ctx, r.providers[i]); err == nil {
+			return nil
+		}
+	}
+	return err
+}
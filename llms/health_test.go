@@ -0,0 +1,28 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPingWithGenerate(t *testing.T) {
+	provider := &mockProvider{}
+	assert.NoError(t, Ping(context.Background(), provider))
+	assert.True(t, provider.generateCalled)
+	assert.Equal(t, "ping", provider.messages[0].Content[0].(*content.Text).Text)
+
+	err := Ping(context.Background(), &errorMockProvider{errorMessage: "401 Unauthorized"})
+	assert.EqualError(t, err, "provider stream error: 401 Unauthorized")
+}
+
+func TestPingKeyRotator(t *testing.T) {
+	rotator := RotateKeys(&keyProvider{err: errors.New("401 Unauthorized")}, &keyProvider{})
+	assert.NoError(t, Ping(context.Background(), rotator))
+
+	rotator = RotateKeys(&keyProvider{err: errors.New("401 Unauthorized")})
+	assert.EqualError(t, Ping(context.Background(), rotator), "401 Unauthorized")
+}
@@ -0,0 +1,189 @@
+package llms
+
+import (
+	"context"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Hedger is a provider that sends every request to two providers at once and
+// uses the response of whichever starts responding first, canceling the
+// other request. It trades cost for tail latency: slow responses of one
+// provider are covered by the other, at the price of paying for some requests
+// twice.
+type Hedger struct {
+	providers []Provider
+	delay     time.Duration
+}
+
+// Hedge returns a provider that races the primary provider against the
+// secondary one, which can be another model or another company, for example:
+//
+//	llms.Hedge(
+//		openai.New(openaiKey, "gpt-4.1-mini"),
+//		anthropic.New(anthropicKey, "claude-3-5-haiku-latest"),
+//	)
+//
+// Usage is attributed to the model that the winning stream reports it was
+// served by.
+func Hedge(primary, secondary Provider) *Hedger {
+	return &Hedger{providers: []Provider{primary, secondary}}
+}
+
+// WithDelay makes the secondary request wait for the given time, or until the
+// primary request fails, and not be sent at all if the primary provider has
+// started responding by then. This limits the extra cost to the requests that
+// are slow.
+func (h *Hedger) WithDelay(delay time.Duration) *Hedger {
+	h.delay = delay
+	return h
+}
+
+func (h *Hedger) Company() string {
+	return h.providers[0].Company()
+}
+
+func (h *Hedger) Model() string {
+	return h.providers[0].Model()
+}
+
+func (h *Hedger) String() string {
+	return ProviderName(h)
+}
+
+// ToolResultTypes returns the content types that both providers can send
+// back in tool results, since either of them may get the next request.
+func (h *Hedger) ToolResultTypes() []content.Type {
+	return commonToolResultTypes(h.providers)
+}
+
+func (h *Hedger) Ping(ctx context.Context) error {
+	return Ping(ctx, h.providers[0])
+}
+
+// hedgeResult is the stream of one of the hedged requests, or nil if the
+// secondary request wasn't sent.
+type hedgeResult struct {
+	index  int
+	stream ProviderStream
+}
+
+// Generate sends the request to both providers and returns the first stream
+// that doesn't fail. If both fail, the error of the last one is returned.
+func (h *Hedger) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	contexts := make([]context.Context, len(h.providers))
+	cancels := make([]context.CancelFunc, len(h.providers))
+	for i := range h.providers {
+		contexts[i], cancels[i] = context.WithCancel(ctx)
+	}
+	results := make(chan hedgeResult, len(h.providers))
+	primaryFailed := make(chan struct{})
+	generate := func(i int) {
+		results <- hedgeResult{i, h.providers[i].Generate(contexts[i], systemPrompt, messages, toolbox)}
+	}
+	go generate(0)
+	go func() {
+		if h.delay > 0 {
+			timer := time.NewTimer(h.delay)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-primaryFailed:
+			case <-contexts[1].Done():
+				// The primary request won, or the whole request was canceled.
+				results <- hedgeResult{1, nil}
+				return
+			}
+		}
+		generate(1)
+	}()
+
+	var failed ProviderStream
+	for range h.providers {
+		r := <-results
+		if r.stream == nil {
+			continue
+		}
+		if err := r.stream.Err(); err != nil {
+			cancels[r.index]()
+			if r.index == 0 {
+				close(primaryFailed)
+			}
+			failed = r.stream
+			continue
+		}
+		// Cancel the other request, whether it has been sent or not.
+		for i, cancel := range cancels {
+			if i != r.index {
+				cancel()
+			}
+		}
+		return &hedgedStream{ProviderStream: r.stream, provider: h.providers[r.index], cancel: cancels[r.index]}
+	}
+	return failed
+}
+
+// hedgedStream is the stream of the request that won the race, which cancels
+// the request's context once it's done.
+type hedgedStream struct {
+	ProviderStream
+	provider Provider
+	cancel   context.CancelFunc
+}
+
+func (s *hedgedStream) Iter() func(yield func(StreamStatus) bool) {
+	return func(yield func(StreamStatus) bool) {
+		defer s.cancel()
+		for status := range s.ProviderStream.Iter() {
+			if !yield(status) {
+				return
+			}
+		}
+	}
+}
+
+// Model returns the model that served the response, which is the model of the
+// provider that won unless its stream knows better.
+func (s *hedgedStream) Model() string {
+	if ms, ok := s.ProviderStream.(ModelStream); ok && ms.Model() != "" {
+		return ms.Model()
+	}
+	return s.provider.Model()
+}
+
+func (s *hedgedStream) Audio() []byte {
+	if as, ok := s.ProviderStream.(AudioStream); ok {
+		return as.Audio()
+	}
+	return nil
+}
+
+func (s *hedgedStream) UsageDetails() UsageDetails {
+	if us, ok := s.ProviderStream.(UsageDetailsStream); ok {
+		return us.UsageDetails()
+	}
+	return UsageDetails{}
+}
+
+func (s *hedgedStream) RateLimits() (limits RateLimits, ok bool) {
+	if rs, ok := s.ProviderStream.(RateLimitStream); ok {
+		return rs.RateLimits()
+	}
+	return RateLimits{}, false
+}
+
+func (s *hedgedStream) Truncated() (reason string, ok bool) {
+	if ts, ok := s.ProviderStream.(TruncationStream); ok {
+		return ts.Truncated()
+	}
+	return "", false
+}
+
+func (s *hedgedStream) FinishReason() FinishReason {
+	if fs, ok := s.ProviderStream.(FinishReasonStream); ok {
+		return fs.FinishReason()
+	}
+	return ""
+}
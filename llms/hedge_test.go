@@ -0,0 +1,115 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowProvider is a provider that takes a while to start responding, and
+// records whether its request was sent and canceled.
+type slowProvider struct {
+	model    string
+	delay    time.Duration
+	err      error
+	calls    atomic.Int32
+	canceled chan struct{}
+	cancel   func()
+}
+
+func newSlowProvider(model string, delay time.Duration, err error) *slowProvider {
+	canceled := make(chan struct{})
+	return &slowProvider{
+		model:    model,
+		delay:    delay,
+		err:      err,
+		canceled: canceled,
+		cancel:   sync.OnceFunc(func() { close(canceled) }),
+	}
+}
+
+func (p *slowProvider) Company() string { return "Test" }
+func (p *slowProvider) Model() string   { return p.model }
+func (p *slowProvider) String() string  { return ProviderName(p) }
+
+func (p *slowProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	p.calls.Add(1)
+	go func() {
+		<-ctx.Done()
+		p.cancel()
+	}()
+	select {
+	case <-time.After(p.delay):
+		return &errorMockStream{err: p.err}
+	case <-ctx.Done():
+		return &errorMockStream{err: ctx.Err()}
+	}
+}
+
+func TestHedge(t *testing.T) {
+	slow := newSlowProvider("slow-model", time.Second, nil)
+	fast := newSlowProvider("fast-model", 0, nil)
+	hedger := Hedge(slow, fast)
+	assert.Equal(t, "slow-model", hedger.Model())
+
+	stream := hedger.Generate(context.Background(), nil, nil, nil)
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, "fast-model", stream.(ModelStream).Model())
+	for range stream.Iter() {
+	}
+	select {
+	case <-slow.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slower request wasn't canceled")
+	}
+	select {
+	case <-fast.canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the winning request wasn't canceled after its stream ended")
+	}
+}
+
+func TestHedgeFailure(t *testing.T) {
+	failing := newSlowProvider("failing-model", 0, errors.New("503 Service Unavailable"))
+	healthy := newSlowProvider("healthy-model", 10*time.Millisecond, nil)
+	stream := Hedge(failing, healthy).Generate(context.Background(), nil, nil, nil)
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, "healthy-model", stream.(ModelStream).Model())
+
+	// When both fail, the error of the last one is returned.
+	healthy.err = errors.New("500 Internal Server Error")
+	stream = Hedge(failing, healthy).Generate(context.Background(), nil, nil, nil)
+	assert.EqualError(t, stream.Err(), "500 Internal Server Error")
+}
+
+func TestHedgeDelay(t *testing.T) {
+	primary := newSlowProvider("primary-model", 0, nil)
+	secondary := newSlowProvider("secondary-model", 0, nil)
+	stream := Hedge(primary, secondary).WithDelay(time.Second).Generate(context.Background(), nil, nil, nil)
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, "primary-model", stream.(ModelStream).Model())
+	// The secondary request isn't sent when the primary one responds in time.
+	assert.Equal(t, int32(0), secondary.calls.Load())
+
+	// It is sent when the primary request is slower than the delay.
+	primary = newSlowProvider("primary-model", time.Second, nil)
+	secondary = newSlowProvider("secondary-model", 0, nil)
+	stream = Hedge(primary, secondary).WithDelay(10*time.Millisecond).Generate(context.Background(), nil, nil, nil)
+	assert.NoError(t, stream.Err())
+	assert.Equal(t, "secondary-model", stream.(ModelStream).Model())
+
+	// It is sent right away when the primary request fails.
+	primary = newSlowProvider("primary-model", 0, errors.New("503 Service Unavailable"))
+	secondary = newSlowProvider("secondary-model", 0, nil)
+	start := time.Now()
+	stream = Hedge(primary, secondary).WithDelay(time.Minute).Generate(context.Background(), nil, nil, nil)
+	assert.NoError(t, stream.Err())
+	assert.Less(t, time.Since(start), time.Second)
+}
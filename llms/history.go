@@ -0,0 +1,118 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// HistoryStore persists the message history of conversations, so that a
+// conversation can continue in another process or after a restart. See the
+// history package for implementations.
+type HistoryStore interface {
+	// Load returns the messages of the conversation, or no messages if the
+	// conversation doesn't exist yet.
+	Load(ctx context.Context, conversationID string) ([]Message, error)
+	// Save replaces the stored messages of the conversation.
+	Save(ctx context.Context, conversationID string, messages []Message) error
+}
+
+// HistoryLocker may be implemented by a HistoryStore to give an LLM exclusive
+// ownership of a conversation while it is chatting, so that two LLM instances
+// (possibly in different processes) never append interleaved turns.
+type HistoryLocker interface {
+	// Lock blocks until the conversation is owned by the caller or the context
+	// is done. The returned function releases ownership.
+	Lock(ctx context.Context, conversationID string) (unlock func(), err error)
+}
+
+// ErrLockLost is the cause of a chat that stopped because the lock of its
+// conversation was lost, so that another LLM instance may own it now.
+var ErrLockLost = errors.New("lost the lock of the conversation")
+
+// LeaseLocker may be implemented by a HistoryLocker whose locks are leases that
+// can be lost while they're held, such as locks in Redis that couldn't be
+// renewed in time. The LLM stops the chat when that happens, so that it doesn't
+// keep writing to a conversation that someone else may own.
+type LeaseLocker interface {
+	HistoryLocker
+	// LockLease is like Lock, but also returns a context derived from ctx
+	// that is canceled with ErrLockLost as its cause if the lock is lost
+	// before it's released.
+	LockLease(ctx context.Context, conversationID string) (leaseCtx context.Context, unlock func(), err error)
+}
+
+// WithHistoryStore makes the LLM load the message history of the conversation
+// from the store at the start of every chat, and save it again whenever it
+// changes. If the store implements HistoryLocker, the conversation is locked
+// for the duration of each chat.
+func (l *LLM) WithHistoryStore(store HistoryStore, conversationID string) *LLM {
+	l.historyStore = store
+	l.conversationID = conversationID
+	return l
+}
+
+// History returns a copy of the message history of the conversation, which
+// is what the next request sends to the provider.
+func (l *LLM) History() []Message {
+	return slices.Clone(l.lastSentMessages)
+}
+
+// SetHistory replaces the message history, for example to seed a conversation
+// or to restore one that was persisted elsewhere. The change is recorded in
+// the audit log and saved to the history store, if they have been configured,
+// and an error is only returned if saving fails. It must not be called while
+// a chat is running.
+func (l *LLM) SetHistory(messages []Message) error {
+	ctx := ContextWithCorrelationID(context.Background(), l.CorrelationID())
+	return l.setHistory(ctx, AuditActionEdit, slices.Clone(messages))
+}
+
+// AppendMessage adds a message to the end of the message history, like
+// SetHistory does with the whole history.
+func (l *LLM) AppendMessage(message Message) error {
+	ctx := ContextWithCorrelationID(context.Background(), l.CorrelationID())
+	return l.setHistory(ctx, AuditActionAppend, append(slices.Clone(l.lastSentMessages), message))
+}
+
+// loadHistory locks the conversation (if supported by the store) and replaces
+// the message history with the stored one. The chat should continue with the
+// returned context, which is canceled if the lock is lost, and the returned
+// function must be called when the chat is over.
+func (l *LLM) loadHistory(ctx context.Context) (_ context.Context, unlock func(), err error) {
+	if l.historyStore == nil {
+		return ctx, func() {}, nil
+	}
+	unlock = func() {}
+	switch locker := l.historyStore.(type) {
+	case LeaseLocker:
+		ctx, unlock, err = locker.LockLease(ctx, l.conversationID)
+	case HistoryLocker:
+		unlock, err = locker.Lock(ctx, l.conversationID)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock conversation %q: %w", l.conversationID, err)
+	}
+	messages, err := l.historyStore.Load(ctx, l.conversationID)
+	if err != nil {
+		unlock()
+		return nil, nil, fmt.Errorf("failed to load history: %w", err)
+	}
+	l.lastSentMessages = messages
+	if !l.validateHistory {
+		return ctx, unlock, nil
+	}
+	if err := ValidateMessages(messages); err != nil {
+		if l.validation != ValidationRepair {
+			unlock()
+			return nil, nil, fmt.Errorf("invalid history of conversation %q: %w", l.conversationID, err)
+		}
+		repaired, _ := RepairMessages(messages)
+		if err := l.setHistory(ctx, AuditActionEdit, repaired); err != nil {
+			unlock()
+			return nil, nil, err
+		}
+	}
+	return ctx, unlock, nil
+}
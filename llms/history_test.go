@@ -0,0 +1,132 @@
+package llms
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testHistoryStore is an in-memory HistoryStore that also counts locks.
+type testHistoryStore struct {
+	mu            sync.Mutex
+	conversations map[string][]Message
+	locks         int
+	unlocks       int
+}
+
+func (s *testHistoryStore) Load(ctx context.Context, conversationID string) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.conversations[conversationID]...), nil
+}
+
+func (s *testHistoryStore) Save(ctx context.Context, conversationID string, messages []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conversations == nil {
+		s.conversations = make(map[string][]Message)
+	}
+	s.conversations[conversationID] = append([]Message(nil), messages...)
+	return nil
+}
+
+func (s *testHistoryStore) Lock(ctx context.Context, conversationID string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks++
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.unlocks++
+	}, nil
+}
+
+func TestHistoryStore(t *testing.T) {
+	store := &testHistoryStore{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	llm := New(&mockProvider{toolCallsToMake: []string{"test_tool"}}, testTool).WithHistoryStore(store, "conv")
+	runTestChat(ctx, t, llm, "Test message")
+	require.NoError(t, llm.Err())
+	assert.Len(t, store.conversations["conv"], 4, "History should be saved to the store")
+	assert.Equal(t, 1, store.locks)
+	assert.Equal(t, 1, store.unlocks)
+
+	// A new LLM instance continues the stored conversation.
+	otherProv := &mockProvider{}
+	other := New(otherProv, testTool).WithHistoryStore(store, "conv")
+	runTestChat(ctx, t, other, "Another message")
+	require.NoError(t, other.Err())
+	require.Len(t, otherProv.messages, 5, "Stored history plus the new message should be sent")
+	assert.Equal(t, "user", otherProv.messages[0].Role)
+	assert.Equal(t, content.FromText("Another message"), otherProv.messages[4].Content)
+	assert.Len(t, store.conversations["conv"], 6)
+	assert.Equal(t, 2, store.locks)
+	assert.Equal(t, 2, store.unlocks)
+}
+
+func TestSetHistory(t *testing.T) {
+	store := &testHistoryStore{}
+	var events []AuditEvent
+	llm := New(&mockProvider{}).
+		WithHistoryStore(store, "conv").
+		WithAudit(func(event AuditEvent) { events = append(events, event) })
+	seed := []Message{
+		{Role: "user", Content: content.FromText("What's the capital of France?")},
+		{Role: "assistant", Content: content.FromText("Paris.")},
+	}
+	require.NoError(t, llm.SetHistory(seed))
+	require.NoError(t, llm.AppendMessage(Message{Role: "user", Content: content.FromText("And of Spain?")}))
+	assert.Len(t, store.conversations["conv"], 3, "The history should be saved to the store")
+	assert.Equal(t, []AuditAction{AuditActionEdit, AuditActionAppend}, []AuditAction{events[0].Action, events[1].Action})
+
+	history := llm.History()
+	assert.Len(t, history, 3)
+	assert.Equal(t, seed, history[:2])
+	history[0].Role = "system"
+	assert.Equal(t, "user", llm.History()[0].Role, "The returned history should be a copy")
+
+	// A chat continues the history that was set.
+	for range llm.Chat("And of Italy?") {
+	}
+	require.NoError(t, llm.Err())
+	assert.Len(t, llm.History(), 5)
+}
+
+// leaseHistoryStore is a testHistoryStore whose locks are leases that the test
+// can make it lose.
+type leaseHistoryStore struct {
+	testHistoryStore
+	lose context.CancelCauseFunc
+}
+
+func (s *leaseHistoryStore) LockLease(ctx context.Context, conversationID string) (context.Context, func(), error) {
+	unlock, err := s.Lock(ctx, conversationID)
+	ctx, s.lose = context.WithCancelCause(ctx)
+	return ctx, unlock, err
+}
+
+func TestHistoryLeaseLost(t *testing.T) {
+	store := &leaseHistoryStore{}
+	tool := tools.Func("Test Tool", "A test tool for testing", "test_tool",
+		func(r tools.Runner, p TestToolParams) tools.Result {
+			store.lose(ErrLockLost)
+			return tools.Success(map[string]any{"ok": true})
+		})
+	llm := New(&mockProvider{toolCallsToMake: []string{"test_tool"}}, tool).
+		WithHistoryStore(store, "conv").
+		WithCheckpoints(RecoveryResume)
+	for range llm.Chat("Hello") {
+	}
+	assert.ErrorIs(t, llm.Err(), ErrLockLost)
+	// Only the user message was saved, before the lease was lost.
+	assert.Len(t, store.conversations["conv"], 1)
+	assert.Equal(t, 1, store.unlocks)
+}
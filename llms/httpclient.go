@@ -0,0 +1,35 @@
+package llms
+
+import (
+	"context"
+	"net/http"
+)
+
+// httpClientKey is the context key for the HTTP client of providers.
+var httpClientKey = &contextKey{"http-client"}
+
+// ContextWithHTTPClient returns a context that makes providers send their
+// requests with the given HTTP client, unless they were given their own with
+// WithHTTPClient. This is how timeouts, proxies, mTLS and instrumented
+// transports can be set for every provider at once.
+func ContextWithHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	return context.WithValue(ctx, httpClientKey, client)
+}
+
+// HTTPClientFromContext returns the HTTP client of the context, or
+// http.DefaultClient if it has none. Providers use it for their requests.
+func HTTPClientFromContext(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(httpClientKey).(*http.Client); ok && client != nil {
+		return client
+	}
+	return http.DefaultClient
+}
+
+// WithHTTPClient makes the providers of chats send their requests with the
+// given HTTP client when the chat's context doesn't have one (see
+// ContextWithHTTPClient). Providers that were given their own HTTP client keep
+// using it.
+func (l *LLM) WithHTTPClient(client *http.Client) *LLM {
+	l.httpClient = client
+	return l
+}
@@ -0,0 +1,47 @@
+package llms
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// clientProvider wraps the mock provider to record the HTTP client that its
+// requests would be sent with.
+type clientProvider struct {
+	mockProvider
+	client *http.Client
+}
+
+func (p *clientProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	p.client = HTTPClientFromContext(ctx)
+	return p.mockProvider.Generate(ctx, systemPrompt, messages, toolbox)
+}
+
+func TestHTTPClient(t *testing.T) {
+	assert.Same(t, http.DefaultClient, HTTPClientFromContext(context.Background()))
+
+	provider := &clientProvider{}
+	llm := New(provider)
+	for range llm.Chat("Hello") {
+	}
+	assert.NoError(t, llm.Err())
+	assert.Same(t, http.DefaultClient, provider.client)
+
+	client := &http.Client{}
+	llm.WithHTTPClient(client)
+	for range llm.Chat("Hello") {
+	}
+	assert.NoError(t, llm.Err())
+	assert.Same(t, client, provider.client)
+
+	other := &http.Client{}
+	for range llm.ChatWithContext(ContextWithHTTPClient(context.Background(), other), "Hello") {
+	}
+	assert.NoError(t, llm.Err())
+	assert.Same(t, other, provider.client, "The client of the context should take precedence")
+}
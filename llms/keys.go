@@ -0,0 +1,131 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+// KeyRotator is a provider that spreads requests over several instances of the
+// same provider, typically configured with different API keys or accounts.
+// It keeps using one instance until it's rate limited or runs out of quota,
+// and then moves on to the next one.
+type KeyRotator struct {
+	providers []Provider
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	current   int
+	coolUntil []time.Time
+}
+
+// RotateKeys returns a provider that rotates between the given providers,
+// which should all be the same model with different credentials, for example:
+//
+//	llms.RotateKeys(
+//		anthropic.New(key1, "claude-3-7-sonnet-latest"),
+//		anthropic.New(key2, "claude-3-7-sonnet-latest"),
+//	)
+func RotateKeys(providers ...Provider) *KeyRotator {
+	if len(providers) == 0 {
+		panic("llms: RotateKeys requires at least one provider")
+	}
+	return &KeyRotator{
+		providers: providers,
+		cooldown:  time.Minute,
+		coolUntil: make([]time.Time, len(providers)),
+	}
+}
+
+// WithCooldown sets how long a rate limited provider is skipped for. The
+// default is one minute.
+func (r *KeyRotator) WithCooldown(cooldown time.Duration) *KeyRotator {
+	r.cooldown = cooldown
+	return r
+}
+
+func (r *KeyRotator) Company() string {
+	return r.providers[0].Company()
+}
+
+func (r *KeyRotator) Model() string {
+	return r.providers[0].Model()
+}
+
+func (r *KeyRotator) String() string {
+	return ProviderName(r)
+}
+
+func (r *KeyRotator) ToolResultTypes() []content.Type {
+	if typer, ok := r.providers[0].(ToolResultTyper); ok {
+		return typer.ToolResultTypes()
+	}
+	return nil
+}
+
+// Generate tries the providers in turn, starting with the one that last
+// succeeded, until one of them isn't rate limited. If they all are, the last
+// error is returned. Only errors reported before the stream starts are
+// retried, since a started response can't be taken back.
+func (r *KeyRotator) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	var stream ProviderStream
+	for _, i := range r.order() {
+		stream = r.providers[i].Generate(ctx, systemPrompt, messages, toolbox)
+		if err := stream.Err(); err == nil || !IsRateLimitError(err) || ctx.Err() != nil {
+			r.mu.Lock()
+			r.current = i
+			r.mu.Unlock()
+			return stream
+		}
+		r.mu.Lock()
+		r.coolUntil[i] = time.Now().Add(r.cooldown)
+		r.mu.Unlock()
+	}
+	return stream
+}
+
+// order returns the indices of the providers in the order they should be
+// tried: the ones that aren't cooling down first, starting with the current
+// one, followed by the ones that are.
+func (r *KeyRotator) order() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var ready, cooling []int
+	for n := range r.providers {
+		i := (r.current + n) % len(r.providers)
+		if now.Before(r.coolUntil[i]) {
+			cooling = append(cooling, i)
+		} else {
+			ready = append(ready, i)
+		}
+	}
+	return append(ready, cooling...)
+}
+
+// rateLimitMessage matches the messages of errors without a status that say
+// they were caused by a rate limit or an exhausted quota. The words must stand
+// on their own, so that "max tokens of 4290" isn't a 429.
+var rateLimitMessage = regexp.MustCompile(`(?i)\b429\b|too many requests|\brate[ _]limit|\bquota\b|insufficient_quota|resource_exhausted`)
+
+// IsRateLimitError reports whether the error from a provider was caused by a
+// rate limit, an exhausted quota or an overloaded API, in which case trying
+// again later (or with another key) may succeed. Errors that implement
+// HTTPError are rate limits if their status is 429 or 529. Other errors are
+// checked by their message.
+func IsRateLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		status := httpErr.HTTPStatus()
+		return status == 429 || status == 529
+	}
+	return rateLimitMessage.MatchString(err.Error())
+}
@@ -0,0 +1,87 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// keyProvider fails with its error, if any, and counts its calls.
+type keyProvider struct {
+	err   error
+	calls int
+}
+
+func (p *keyProvider) Company() string { return "Test" }
+func (p *keyProvider) Model() string   { return "test-model" }
+func (p *keyProvider) String() string  { return ProviderName(p) }
+
+func (p *keyProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	p.calls++
+	return &errorMockStream{err: p.err}
+}
+
+func TestRotateKeys(t *testing.T) {
+	limited := &keyProvider{err: errors.New("429 Too Many Requests: rate limit exceeded")}
+	healthy := &keyProvider{}
+	rotator := RotateKeys(limited, healthy)
+
+	assert.NoError(t, rotator.Generate(context.Background(), nil, nil, nil).Err())
+	assert.Equal(t, 1, limited.calls)
+	assert.Equal(t, 1, healthy.calls)
+
+	// The rate limited key is skipped while it cools down.
+	assert.NoError(t, rotator.Generate(context.Background(), nil, nil, nil).Err())
+	assert.Equal(t, 1, limited.calls)
+	assert.Equal(t, 2, healthy.calls)
+
+	// Other errors are returned as is.
+	healthy.err = errors.New("400 Bad Request: invalid model")
+	assert.EqualError(t, rotator.Generate(context.Background(), nil, nil, nil).Err(), "400 Bad Request: invalid model")
+	assert.Equal(t, 1, limited.calls)
+	assert.Equal(t, 3, healthy.calls)
+
+	// When every key is limited, all are tried and the last error is returned.
+	healthy.err = errors.New("403 Forbidden: insufficient_quota")
+	assert.True(t, IsRateLimitError(rotator.Generate(context.Background(), nil, nil, nil).Err()))
+	assert.Equal(t, 2, limited.calls)
+	assert.Equal(t, 4, healthy.calls)
+}
+
+func TestIsRateLimitError(t *testing.T) {
+	for _, err := range []error{
+		errors.New("429 Too Many Requests"),
+		errors.New("rate_limit_error: slow down"),
+		errors.New("You exceeded your current quota"),
+		errors.New("403 Forbidden: insufficient_quota"),
+		errors.New("RESOURCE_EXHAUSTED"),
+		&statusError{status: 429},
+		&statusError{status: 529},
+	} {
+		assert.True(t, IsRateLimitError(err), err.Error())
+	}
+	for _, err := range []error{
+		nil,
+		errors.New("max tokens of 4290 exceeds the limit of 4096 output tokens"),
+		errors.New("400 Bad Request: prompt is too long: 204290 tokens"),
+		&statusError{status: 400},
+		// A status decides, whatever the message says.
+		fmt.Errorf("rate limit exceeded: %w", &statusError{status: 500}),
+	} {
+		assert.False(t, IsRateLimitError(err), "%v", err)
+	}
+}
+
+func TestRotateKeysKeepsKeyOnOtherErrors(t *testing.T) {
+	first := &keyProvider{err: errors.New("max tokens of 4290 exceeds the limit of 4096 output tokens")}
+	second := &keyProvider{}
+	rotator := RotateKeys(first, second)
+	assert.ErrorContains(t, rotator.Generate(context.Background(), nil, nil, nil).Err(), "4290")
+	assert.Equal(t, 1, first.calls)
+	assert.Equal(t, 0, second.calls, "An error that isn't a rate limit shouldn't rotate the key")
+}
@@ -0,0 +1,158 @@
+package llms
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// Limiter is consulted by the LLM before every request it sends to its
+// provider, to keep bursty agents within the rate limits of the provider
+// instead of having requests rejected. See Budget for a limiter with fixed
+// budgets per minute that also adapts to the rate limits providers report.
+type Limiter interface {
+	// Wait blocks until a request of about the given number of input tokens
+	// can be sent to the provider, or returns an error if the context is done
+	// first.
+	Wait(ctx context.Context, provider Provider, tokens int) error
+}
+
+// RateLimitObserver is implemented by limiters that adapt to the rate limits
+// providers report. The LLM passes on the limits of every response whose
+// stream implements RateLimitStream.
+type RateLimitObserver interface {
+	Limiter
+	ObserveRateLimits(provider Provider, limits RateLimits)
+}
+
+// WithLimiter makes the LLM wait for the limiter before sending each request,
+// including retries. The same limiter can be shared by several LLMs so that
+// they stay within one budget together.
+func (l *LLM) WithLimiter(limiter Limiter) *LLM {
+	l.limiter = limiter
+	return l
+}
+
+// waitForLimiter waits until the limiter allows the next request to the
+// provider, if there is a limiter.
+func (l *LLM) waitForLimiter(ctx context.Context, provider Provider, systemPrompt content.Content, messages []Message) error {
+	if l.limiter == nil {
+		return nil
+	}
+	tokens := EstimateTokens(systemPrompt) + EstimateMessageTokens(messages)
+	if err := l.limiter.Wait(ctx, provider, tokens); err != nil {
+		return fmt.Errorf("error waiting for rate limiter: %w", err)
+	}
+	return nil
+}
+
+// observeRateLimits passes on the rate limits reported with the response to
+// the limiter, if it wants them.
+func (l *LLM) observeRateLimits(provider Provider, stream ProviderStream) {
+	observer, ok := l.limiter.(RateLimitObserver)
+	if !ok {
+		return
+	}
+	if rls, ok := stream.(RateLimitStream); ok {
+		if limits, ok := rls.RateLimits(); ok {
+			observer.ObserveRateLimits(provider, limits)
+		}
+	}
+}
+
+// Budget is a limiter that allows a number of requests and input tokens per
+// minute for each provider, as identified by its String method, over a sliding
+// window. Token counts are estimated from the messages with
+// EstimateMessageTokens. A request that is larger than the whole token budget
+// is let through once the window is empty, since it would never fit.
+//
+// Requests are also held back while the rate limits that the provider last
+// reported say they would be rejected, like LimitRate does, so a provider that
+// is only used through LLMs with a Budget doesn't need LimitRate as well.
+type Budget struct {
+	requestsPerMinute int
+	tokensPerMinute   int
+
+	mu       sync.Mutex
+	requests map[string][]budgetRequest
+	reported map[string]*reportedLimits
+}
+
+type budgetRequest struct {
+	time   time.Time
+	tokens int
+}
+
+// NewBudget returns a limiter that allows the given number of requests and
+// input tokens per minute to each provider. A budget of zero is unlimited.
+func NewBudget(requestsPerMinute, tokensPerMinute int) *Budget {
+	return &Budget{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+		requests:          make(map[string][]budgetRequest),
+		reported:          make(map[string]*reportedLimits),
+	}
+}
+
+func (b *Budget) Wait(ctx context.Context, provider Provider, tokens int) error {
+	key := provider.String()
+	for {
+		wait := b.reserve(key, tokens)
+		if wait <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// ObserveRateLimits replaces the rate limits that the provider last reported.
+func (b *Budget) ObserveRateLimits(provider Provider, limits RateLimits) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reported[provider.String()] = &reportedLimits{limits, true}
+}
+
+// reserve returns how long to wait before a request of the given size fits in
+// the budget of the provider, or 0 if it fits now, in which case it's counted.
+func (b *Budget) reserve(key string, tokens int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	requests := b.requests[key]
+	for len(requests) > 0 && now.Sub(requests[0].time) >= time.Minute {
+		requests = requests[1:]
+	}
+	b.requests[key] = requests
+	used := 0
+	for _, r := range requests {
+		used += r.tokens
+	}
+	fitsRequests := b.requestsPerMinute <= 0 || len(requests) < b.requestsPerMinute
+	fitsTokens := b.tokensPerMinute <= 0 || used+tokens <= b.tokensPerMinute || len(requests) == 0
+	var wait time.Duration
+	if !fitsRequests || !fitsTokens {
+		// Check again when the oldest request leaves the window.
+		wait = requests[0].time.Add(time.Minute).Sub(now)
+	}
+	reported := b.reported[key]
+	if reported != nil {
+		wait = max(wait, reported.wait(now, tokens))
+	}
+	if wait > 0 {
+		return wait
+	}
+	b.requests[key] = append(requests, budgetRequest{now, tokens})
+	if reported != nil {
+		reported.take(now, tokens)
+	}
+	return 0
+}
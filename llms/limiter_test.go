@@ -0,0 +1,92 @@
+package llms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingLimiter is a limiter that records the requests it's consulted for.
+type recordingLimiter struct {
+	tokens []int
+	err    error
+}
+
+func (l *recordingLimiter) Wait(ctx context.Context, provider Provider, tokens int) error {
+	l.tokens = append(l.tokens, tokens)
+	return l.err
+}
+
+func TestWithLimiter(t *testing.T) {
+	limiter := &recordingLimiter{}
+	llm := New(&mockProvider{}).WithLimiter(limiter)
+	for range llm.Chat("Hello") {
+	}
+	assert.NoError(t, llm.Err())
+	assert.Len(t, limiter.tokens, 1)
+	assert.Greater(t, limiter.tokens[0], 0)
+
+	limiter.err = context.DeadlineExceeded
+	provider := &mockProvider{}
+	llm = New(provider).WithLimiter(limiter)
+	for range llm.Chat("Hello") {
+	}
+	assert.ErrorIs(t, llm.Err(), context.DeadlineExceeded)
+	assert.False(t, provider.generateCalled, "The request shouldn't be sent if the limiter fails")
+}
+
+func TestBudget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	budget := NewBudget(2, 0)
+	provider := &keyProvider{}
+	assert.NoError(t, budget.Wait(ctx, provider, 100))
+	assert.NoError(t, budget.Wait(ctx, provider, 100))
+	assert.ErrorIs(t, budget.Wait(ctx, provider, 100), context.DeadlineExceeded)
+	// Every provider has its own budget.
+	assert.NoError(t, budget.Wait(context.Background(), &mockProvider{}, 100))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	budget = NewBudget(0, 1000)
+	assert.NoError(t, budget.Wait(ctx, provider, 600))
+	assert.ErrorIs(t, budget.Wait(ctx, provider, 600), context.DeadlineExceeded)
+	assert.NoError(t, budget.Wait(ctx, &mockProvider{}, 5000), "A request larger than the budget should fit in an empty window")
+}
+
+func TestBudgetWindow(t *testing.T) {
+	budget := NewBudget(1, 0)
+	key := ProviderName(&keyProvider{})
+	assert.Zero(t, budget.reserve(key, 10))
+	wait := budget.reserve(key, 10)
+	assert.True(t, wait > 59*time.Second && wait <= time.Minute, wait)
+
+	// Requests leave the window after a minute.
+	budget.requests[key][0].time = time.Now().Add(-time.Minute)
+	assert.Zero(t, budget.reserve(key, 10))
+}
+
+func TestBudgetReportedLimits(t *testing.T) {
+	provider := &limitedProvider{limits: RateLimits{
+		LimitRequests:     10,
+		RemainingRequests: 1,
+		ResetRequests:     time.Now().Add(time.Hour),
+		LimitTokens:       -1,
+		RemainingTokens:   -1,
+	}}
+	budget := NewBudget(0, 0)
+	llm := New(provider).WithLimiter(budget)
+	for range llm.Chat("Hello") {
+	}
+	assert.NoError(t, llm.Err())
+
+	// The LLM passed on the reported limits, so the one remaining request is
+	// let through but the next one has to wait for the reset.
+	key := ProviderName(provider)
+	assert.Zero(t, budget.reserve(key, 10))
+	wait := budget.reserve(key, 10)
+	assert.True(t, wait > 59*time.Minute && wait <= time.Hour, wait)
+}
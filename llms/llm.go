@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"slices"
-
-	"sigs.k8s.io/yaml"
+	"time"
 
 	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/internal/yaml"
 	"github.com/blixt/go-llms/tools"
 )
 
@@ -22,14 +23,40 @@ var (
 // individual calls, for example when tool calling is being performed. Note that
 // this is NOT thread safe for this reason.
 type LLM struct {
-	provider Provider
-	toolbox  *tools.Toolbox
+	provider           Provider
+	toolbox            *tools.Toolbox
+	toolStatusInterval time.Duration
 
 	turns, maxTurns  int
 	lastSentMessages []Message
 
-	debug bool
-	err   error // Last error encountered during operation
+	inputTokens, outputTokens int
+	usageDetails              UsageDetails
+	costUSD                   float64
+	usageCallback             func(Usage)
+	truncationThreshold       float64
+
+	debug     bool
+	debugKeys KeyProvider
+	err       error // Last error encountered during operation
+
+	audit    func(AuditEvent)
+	recorder func(TurnRecord)
+
+	uiCommands *uiCommands
+
+	historyStore    HistoryStore
+	conversationID  string
+	checkpoints     bool
+	recovery        Recovery
+	validation      HistoryValidation
+	validateHistory bool
+	correlationID   string
+	httpClient      *http.Client
+	retryPolicy     *RetryPolicy
+	limiter         Limiter
+	trimPolicy      *TrimPolicy
+	compaction      *CompactionPolicy
 
 	// SystemPrompt should return the system prompt for the LLM. It's a function
 	// to allow the system prompt to dynamically change throughout a single
@@ -73,10 +100,12 @@ func (l *LLM) ChatWithContext(ctx context.Context, message string) <-chan Update
 // using tools. The provided context can be used to pass values to tools, set
 // deadlines, cancel, etc.
 func (l *LLM) ChatUsingContent(ctx context.Context, message content.Content) <-chan Update {
-	return l.ChatUsingMessages(ctx, append(l.lastSentMessages, Message{
-		Role:    "user",
-		Content: message,
-	}))
+	return l.chat(ctx, func(history []Message) []Message {
+		return append(history, Message{
+			Role:    "user",
+			Content: message,
+		})
+	})
 }
 
 // ChatUsingMessages sends a message history to the LLM and immediately returns
@@ -85,12 +114,27 @@ func (l *LLM) ChatUsingContent(ctx context.Context, message content.Content) <-c
 // provided context can be used to pass values to tools, set deadlines, cancel,
 // etc.
 func (l *LLM) ChatUsingMessages(ctx context.Context, messages []Message) <-chan Update {
-	l.lastSentMessages = messages
+	return l.chat(ctx, func([]Message) []Message {
+		return messages
+	})
+}
+
+// chat starts a chat where the message history is the result of applying
+// mutate to the current history. If mutate is nil, the chat resumes the
+// current history instead.
+func (l *LLM) chat(ctx context.Context, mutate func(history []Message) []Message) <-chan Update {
 	// Reset error state for new chat
 	l.err = nil
 
 	updateChan := make(chan Update)
 
+	if GetCorrelationID(ctx) == "" {
+		ctx = ContextWithCorrelationID(ctx, l.CorrelationID())
+	}
+	if _, ok := ctx.Value(httpClientKey).(*http.Client); !ok && l.httpClient != nil {
+		ctx = ContextWithHTTPClient(ctx, l.httpClient)
+	}
+
 	// Check if context is already cancelled before starting goroutine
 	if err := ctx.Err(); err != nil {
 		l.err = err
@@ -102,17 +146,64 @@ func (l *LLM) ChatUsingMessages(ctx context.Context, messages []Message) <-chan
 	// This goroutine owns the updateChan and ensures it's closed on exit.
 	go func() {
 		defer close(updateChan)
+		ctx, unlock, err := l.loadHistory(ctx)
+		if err != nil {
+			l.err = err
+			return
+		}
+		defer unlock()
+		if l.checkpoints || mutate == nil {
+			stepCtx, done, err := beginStep(ctx)
+			if err == nil {
+				err = l.recoverTurn(stepCtx, updateChan)
+				done()
+			}
+			if err != nil {
+				l.err = err
+				return
+			}
+		}
+		if mutate == nil {
+			if n := len(l.lastSentMessages); n == 0 || l.lastSentMessages[n-1].Role == "assistant" {
+				return
+			}
+		} else {
+			messages := mutate(l.lastSentMessages)
+			action := AuditActionEdit
+			if l.audit != nil && l.isAppend(messages) {
+				action = AuditActionAppend
+			}
+			if err := l.setHistory(ctx, action, messages); err != nil {
+				l.err = err
+				return
+			}
+		}
 		for {
 			select {
 			case <-ctx.Done():
 				l.err = ctx.Err()
+				if errors.Is(context.Cause(ctx), ErrLockLost) {
+					l.err = ErrLockLost
+				}
 				if l.err == nil {
 					l.err = context.Canceled
 				}
 				// Exit goroutine, defer close(updateChan) will run.
 				return
 			default:
-				shouldContinue, err := l.turn(ctx, updateChan)
+				stepCtx, done, err := beginStep(ctx)
+				if err != nil {
+					l.err = err
+					return
+				}
+				shouldContinue, err := l.turn(stepCtx, updateChan)
+				if err != nil && errors.Is(context.Cause(stepCtx), ErrShuttingDown) {
+					err = ErrShuttingDown
+				}
+				if err != nil && errors.Is(context.Cause(ctx), ErrLockLost) {
+					err = ErrLockLost
+				}
+				done()
 				if err != nil {
 					l.err = err
 					// Exit goroutine on error, defer close(updateChan) will run.
@@ -152,14 +243,14 @@ func (l *LLM) AddTool(t tools.Tool) {
 		panic("attempted to add a nil tool to the LLM toolbox")
 	}
 	if l.toolbox == nil {
-		l.toolbox = tools.Box(t)
+		l.toolbox = tools.Box(t).WithStatusInterval(l.toolStatusInterval)
 	} else {
 		l.toolbox.Add(t)
 	}
 }
 
 func (l *LLM) String() string {
-	return fmt.Sprintf("%s (%s)", l.provider.Model(), l.provider.Company())
+	return l.provider.String()
 }
 
 // WithDebug enables debug mode. When debug mode is enabled, the LLM will write
@@ -171,6 +262,25 @@ func (l *LLM) WithDebug() *LLM {
 	return l
 }
 
+// WithEncryptedDebug enables debug mode (see WithDebug), but encrypts the
+// debug data with the provided keys and writes it to debug.yaml.enc instead,
+// since conversations often contain personal information. Use Decrypt to read
+// the file.
+func (l *LLM) WithEncryptedDebug(keys KeyProvider) *LLM {
+	l.debug = true
+	l.debugKeys = keys
+	return l
+}
+
+// WithAudit enables auditing of the message history. The provided function is
+// called synchronously with an event for every mutation of the history, such
+// as appending messages or replacing the history with an edited one. See
+// NewAuditWriter for a simple way to persist these events.
+func (l *LLM) WithAudit(record func(AuditEvent)) *LLM {
+	l.audit = record
+	return l
+}
+
 // WithMaxTurns sets the maximum number of turns the LLM will make. This is
 // useful to prevent infinite loops or excessive usage. A value of 0 means no
 // limit. A value of 1 means the LLM will only ever do one API call, and so on.
@@ -179,6 +289,17 @@ func (l *LLM) WithMaxTurns(maxTurns int) *LLM {
 	return l
 }
 
+// WithToolStatusInterval throttles the status updates of the LLM's tools to at
+// most one per interval, coalescing the ones in between so that only the
+// latest is sent. See tools.Toolbox.WithStatusInterval.
+func (l *LLM) WithToolStatusInterval(interval time.Duration) *LLM {
+	l.toolStatusInterval = interval
+	if l.toolbox != nil {
+		l.toolbox.WithStatusInterval(interval)
+	}
+	return l
+}
+
 // Err returns the last error encountered during LLM operation. This is useful
 // for checking errors after a Chat loop completes. Returns nil if no error
 // occurred.
@@ -186,12 +307,13 @@ func (l *LLM) Err() error {
 	return l.err
 }
 
-func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error) {
+func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (more bool, err error) {
 	if l.maxTurns > 0 && l.turns >= l.maxTurns {
 		return false, ErrMaxTurnsReached
 	}
 	l.turns++
 
+	correlationID := GetCorrelationID(ctx)
 	var systemPrompt content.Content
 	if l.SystemPrompt != nil {
 		systemPrompt = l.SystemPrompt()
@@ -199,22 +321,55 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 
 	// This will hold results from tool calls, to be sent back to the LLM.
 	var toolMessages []Message
+	// These are the tool calls that the stream has handed out as ready.
+	var readyCalls []ToolCall
 
-	stream := l.provider.Generate(ctx, systemPrompt, l.lastSentMessages, l.toolbox)
+	if err := l.compactHistory(ctx, systemPrompt); err != nil {
+		return false, err
+	}
+	if err := l.trimHistory(ctx, systemPrompt); err != nil {
+		return false, err
+	}
+	if err := l.waitForLimiter(ctx, l.provider, systemPrompt, l.lastSentMessages); err != nil {
+		return false, err
+	}
+	start := time.Now()
+	stream := l.generate(ctx, l.provider, systemPrompt, l.lastSentMessages, l.toolbox)
+
+	if l.recorder != nil {
+		record := TurnRecord{
+			Time:          time.Now(),
+			CorrelationID: correlationID,
+			Turn:          l.turns,
+			Company:       l.provider.Company(),
+			Model:         l.provider.Model(),
+			SystemPrompt:  systemPrompt,
+			Messages:      l.lastSentMessages,
+			Tools:         toolSchemas(l.toolbox),
+		}
+		defer func() {
+			record.Response = stream.Message()
+			record.ToolResults = toolMessages
+			record.InputTokens, record.OutputTokens = stream.Usage()
+			record.Duration = time.Since(start)
+			if s, ok := stream.(ModelStream); ok && s.Model() != "" {
+				record.Model = s.Model()
+			}
+			if err != nil {
+				record.Error = err.Error()
+			}
+			l.recorder(record)
+		}()
+	}
 	if err := stream.Err(); err != nil {
-		return false, fmt.Errorf("LLM returned error response: %w", err)
+		return false, fmt.Errorf("LLM returned error response: %w", &ProviderError{Err: err})
 	}
 
 	if l.debug {
 		// Write the entire message history to the file debug.yaml. The function
 		// is deferred so that we get data even if a panic occurs.
 		defer func() {
-			var toolsSchema []*tools.FunctionSchema
-			if l.toolbox != nil {
-				for _, tool := range l.toolbox.All() {
-					toolsSchema = append(toolsSchema, tool.Schema())
-				}
-			}
+			toolsSchema := toolSchemas(l.toolbox)
 			debugData := map[string]any{
 				// Prefixed with numbers so the keys remain in this order.
 				"1_receivedMessage": stream.Message(),
@@ -223,8 +378,14 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 				"4_systemPrompt":    systemPrompt,
 				"5_availableTools":  toolsSchema,
 			}
-			if debugYAML, err := yaml.Marshal(debugData); err == nil {
+			debugYAML, err := yaml.Marshal(debugData)
+			if err != nil {
+				return
+			}
+			if l.debugKeys == nil {
 				os.WriteFile("debug.yaml", debugYAML, 0644)
+			} else if encrypted, err := Encrypt(ctx, l.debugKeys, debugYAML); err == nil {
+				os.WriteFile("debug.yaml.enc", encrypted, 0600)
 			}
 		}()
 	}
@@ -241,7 +402,18 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 		}
 		switch status {
 		case StreamStatusText:
-			updateChan <- TextUpdate{stream.Text()}
+			updateChan <- TextUpdate{Text: stream.Text(), CorrelationID: correlationID}
+
+		case StreamStatusThinking:
+			updateChan <- ThinkingUpdate{Text: stream.Text(), CorrelationID: correlationID}
+
+		case StreamStatusAudio:
+			if s, ok := stream.(AudioStream); ok {
+				updateChan <- AudioUpdate{Audio: s.Audio(), CorrelationID: correlationID}
+			}
+
+		case StreamStatusRefusal:
+			updateChan <- RefusalUpdate{Text: stream.Text(), CorrelationID: correlationID}
 
 		case StreamStatusToolCallBegin:
 			toolCall := stream.ToolCall()
@@ -252,7 +424,7 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 			if tool == nil {
 				return false, fmt.Errorf("tool %q not found", toolCall.Name)
 			}
-			updateChan <- ToolStartUpdate{toolCall.ID, tool}
+			updateChan <- ToolStartUpdate{ToolCallID: toolCall.ID, Tool: tool, CorrelationID: correlationID}
 
 		case StreamStatusToolCallData:
 			// TODO: Update caller with tool JSON delta.
@@ -261,13 +433,20 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 			// TODO: We may want to support parallel tool calls, which
 			// means the results would need to be collected later (and
 			// maybe out of sequence).
-			toolMessage := l.runToolCall(ctx, l.toolbox, stream.ToolCall(), updateChan)
+			toolCall := stream.ToolCall()
+			readyCalls = append(readyCalls, toolCall)
+			toolMessage := l.runToolCall(ctx, l.toolbox, toolCall, updateChan)
 			toolMessages = append(toolMessages, toolMessage)
+			if l.checkpoints {
+				if err := l.checkpoint(ctx, stream.Message(), readyCalls, toolMessages); err != nil {
+					return false, err
+				}
+			}
 		}
 	}
 	// Check stream error after iterating
 	if streamErr := stream.Err(); streamErr != nil {
-		return false, fmt.Errorf("error iterating stream: %w", streamErr)
+		return false, fmt.Errorf("error iterating stream: %w", &ProviderError{Err: streamErr})
 	}
 	// Also check if the context was cancelled *during* stream iteration,
 	// even if the iterator itself didn't return an error.
@@ -275,8 +454,36 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 		return false, ctx.Err()
 	}
 
-	// Add the fully assembled message plus tool call results to the message history.
-	l.lastSentMessages = append(l.lastSentMessages, stream.Message())
+	if s, ok := stream.(FinishReasonStream); ok && s.FinishReason() != "" {
+		updateChan <- FinishUpdate{Reason: s.FinishReason(), CorrelationID: correlationID}
+	}
+	inputTokens, outputTokens := stream.Usage()
+	if update, ok := l.detectTruncation(stream, systemPrompt, inputTokens); ok {
+		update.CorrelationID = correlationID
+		updateChan <- update
+	}
+	model := l.provider.Model()
+	if s, ok := stream.(ModelStream); ok && s.Model() != "" {
+		model = s.Model()
+	}
+	var details UsageDetails
+	if s, ok := stream.(UsageDetailsStream); ok {
+		details = s.UsageDetails()
+	}
+	pricing, _ := LookupPricing(model)
+	l.reportUsage(Usage{
+		CorrelationID:           correlationID,
+		Company:                 l.provider.Company(),
+		Model:                   model,
+		InputTokens:             inputTokens,
+		OutputTokens:            outputTokens,
+		UsageDetails:            details,
+		CostUSD:                 pricing.CostWithDetails(inputTokens, outputTokens, details),
+		PromptFingerprint:       Fingerprint(systemPrompt, l.lastSentMessages),
+		SystemPromptFingerprint: FingerprintContent(systemPrompt),
+		ResponseFingerprint:     Fingerprint(nil, []Message{stream.Message()}),
+	})
+
 	// Role "tool" must always come first.
 	slices.SortStableFunc(toolMessages, func(a, b Message) int {
 		if a.Role == "tool" && b.Role != "tool" {
@@ -287,12 +494,41 @@ func (l *LLM) turn(ctx context.Context, updateChan chan<- Update) (bool, error)
 		}
 		return 0
 	})
-	l.lastSentMessages = append(l.lastSentMessages, toolMessages...)
+	// Add the fully assembled message plus tool call results to the message history.
+	messages := appendResponse(l.lastSentMessages, stream.Message())
+	if err := l.setHistory(ctx, AuditActionAppend, append(messages, toolMessages...)); err != nil {
+		return false, err
+	}
 
 	// Return true if there were tool calls, since the LLM should look at the results.
 	return len(toolMessages) > 0, nil
 }
 
+// appendResponse adds the response to the history. A history that ends with
+// an assistant message without tool calls was a prefill, which the response
+// continues, so the response is merged into it to keep the history valid.
+func appendResponse(history []Message, response Message) []Message {
+	n := len(history)
+	if n == 0 || history[n-1].Role != "assistant" || len(history[n-1].ToolCalls) > 0 || response.Role != "assistant" {
+		return append(slices.Clone(history), response)
+	}
+	prefill := history[n-1]
+	merged := slices.Clone(prefill.Content)
+	rest := response.Content
+	if len(merged) > 0 && len(rest) > 0 {
+		// Join the text on both sides of the seam into a single item, without
+		// changing either message.
+		last, ok1 := merged[len(merged)-1].(*content.Text)
+		first, ok2 := rest[0].(*content.Text)
+		if ok1 && ok2 {
+			merged[len(merged)-1] = &content.Text{Text: last.Text + first.Text}
+			rest = rest[1:]
+		}
+	}
+	response.Content = append(merged, rest...)
+	return append(slices.Clone(history[:n-1]), response)
+}
+
 func (l *LLM) runToolCall(ctx context.Context, toolbox *tools.Toolbox, toolCall ToolCall, updateChan chan<- Update) Message {
 	if toolCall.ID == "" {
 		panic(fmt.Sprintf("tool call (%s) is missing an ID", toolCall.Name))
@@ -306,26 +542,34 @@ func (l *LLM) runToolCall(ctx context.Context, toolbox *tools.Toolbox, toolCall
 	}
 
 	t := toolbox.Get(toolCall.Name)
+	var accepted []content.Type
+	if typer, ok := l.provider.(ToolResultTyper); ok {
+		accepted = typer.ToolResultTypes()
+	}
 	// Create a new context with the ToolCall value
 	ctxWithValue := context.WithValue(ctx, ToolCallContextKey, toolCall)
+	if t != nil {
+		ctxWithValue = tools.ContextWithResultTypes(ctxWithValue, tools.NegotiateResultTypes(t, accepted))
+	}
 	runner := tools.NewRunner(ctxWithValue, toolbox, func(status string) {
 		select {
 		case <-ctx.Done(): // Don't send if already cancelled
 		default:
-			updateChan <- ToolStatusUpdate{toolCall.ID, status, t}
+			updateChan <- ToolStatusUpdate{ToolCallID: toolCall.ID, Status: status, Tool: t, CorrelationID: GetCorrelationID(ctx)}
 		}
 	})
 
+	l.uiCommands.forward(toolCall, GetCorrelationID(ctx), updateChan)
 	result := toolbox.Run(runner, toolCall.Name, json.RawMessage(toolCall.Arguments))
 	select {
 	case <-ctx.Done(): // Don't send if already cancelled
 	default:
-		updateChan <- ToolDoneUpdate{toolCall.ID, result, t}
+		updateChan <- ToolDoneUpdate{ToolCallID: toolCall.ID, Result: result, Tool: t, CorrelationID: GetCorrelationID(ctx)}
 	}
 
 	return Message{
 		Role:       "tool",
-		Content:    result.Content(),
+		Content:    tools.ConvertContent(result.Content(), accepted),
 		ToolCallID: toolCall.ID,
 	}
 }
@@ -60,6 +60,10 @@ func (m *mockProvider) Model() string {
 	return "test-model"
 }
 
+func (m *mockProvider) String() string {
+	return ProviderName(m)
+}
+
 func (m *mockProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
 	m.generateCalled = true
 	m.systemPrompt = systemPrompt
@@ -397,6 +401,12 @@ func TestSuccessfulChatNoError(t *testing.T) {
 	assert.NoError(t, llm.Err(), "LLM.Err() should return nil for successful chat")
 }
 
+func TestProviderName(t *testing.T) {
+	assert.Equal(t, "test-model (Test Company)", ProviderName(&mockProvider{}))
+	assert.Equal(t, "test-model (Test Company)", New(&mockProvider{}).String())
+	assert.Equal(t, "test-model (Test Company)", fmt.Sprint(Fallback(&mockProvider{}, &errorMockProvider{})))
+}
+
 // Mock provider that always returns an error stream
 type errorMockProvider struct {
 	errorMessage string
@@ -410,6 +420,10 @@ func (m *errorMockProvider) Model() string {
 	return "test-model"
 }
 
+func (m *errorMockProvider) String() string {
+	return ProviderName(m)
+}
+
 func (m *errorMockProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
 	return &errorMockStream{
 		err: fmt.Errorf("provider stream error: %s", m.errorMessage),
@@ -440,6 +454,10 @@ func (m *mockEmptyIDProvider) Model() string {
 	return "test-model"
 }
 
+func (m *mockEmptyIDProvider) String() string {
+	return ProviderName(m)
+}
+
 func (m *mockEmptyIDProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
 	return &mockEmptyIDStream{}
 }
@@ -745,7 +763,7 @@ func TestRunToolCallImageHandling(t *testing.T) {
 			// Simulate generating JSON and adding an image
 			jsonData, _ := json.Marshal(map[string]string{"status": "image_included"})
 			resultContent := content.FromRawJSON(jsonData)
-			// In a real tool, you might use content.ImageToDataURI here
+			// In a real tool, you might use images.ToDataURI here
 			resultContent.AddImage("data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=")
 			// Use SuccessWithContent to return multiple content types with a label
 			return tools.SuccessWithContent("Generated test image", resultContent)
@@ -830,6 +848,10 @@ func (m *mockCancellingProvider) Model() string {
 	return "test-model"
 }
 
+func (m *mockCancellingProvider) String() string {
+	return ProviderName(m)
+}
+
 func (m *mockCancellingProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
 	return &mockCancellingStream{ctx: ctx} // Pass context to the stream
 }
@@ -0,0 +1,189 @@
+package llms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+var (
+	updateTypesMu sync.RWMutex
+	updateTypes   = map[UpdateType]func(data json.RawMessage) (Update, error){}
+)
+
+func init() {
+	RegisterUpdateType[ToolStartUpdate]()
+	RegisterUpdateType[ToolStatusUpdate]()
+	RegisterUpdateType[ToolDoneUpdate]()
+	RegisterUpdateType[TextUpdate]()
+	RegisterUpdateType[ThinkingUpdate]()
+	RegisterUpdateType[AudioUpdate]()
+	RegisterUpdateType[RefusalUpdate]()
+}
+
+// RegisterUpdateType makes UnmarshalUpdate able to decode updates of type T.
+// Packages that define their own updates should register them. The payload
+// is encoded with encoding/json, so T can implement json.Marshaler and
+// json.Unmarshaler to control its encoding.
+func RegisterUpdateType[T Update]() {
+	var zero T
+	updateTypesMu.Lock()
+	defer updateTypesMu.Unlock()
+	updateTypes[zero.Type()] = func(data json.RawMessage) (Update, error) {
+		var u T
+		if err := json.Unmarshal(data, &u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+}
+
+type updateEnvelope struct {
+	Type UpdateType      `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// MarshalUpdate encodes the update as a JSON object with its type and its
+// payload, for example {"type":"text","data":{"text":"Hello"}}, so it can be
+// sent over SSE, WebSockets, or a queue and decoded with UnmarshalUpdate.
+func MarshalUpdate(u Update) ([]byte, error) {
+	data, err := json.Marshal(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s update: %w", u.Type(), err)
+	}
+	return json.Marshal(updateEnvelope{Type: u.Type(), Data: data})
+}
+
+// UnmarshalUpdate decodes an update encoded by MarshalUpdate. Tools in the
+// decoded updates only describe the original tool and can't be run.
+func UnmarshalUpdate(data []byte) (Update, error) {
+	var envelope updateEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal update: %w", err)
+	}
+	updateTypesMu.RLock()
+	decode, ok := updateTypes[envelope.Type]
+	updateTypesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown update type %q", envelope.Type)
+	}
+	u, err := decode(envelope.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s update: %w", envelope.Type, err)
+	}
+	return u, nil
+}
+
+// toolJSON describes a tool in an encoded update.
+type toolJSON struct {
+	Label  string                `json:"label"`
+	Schema *tools.FunctionSchema `json:"schema"`
+}
+
+func toolToJSON(t tools.Tool) *toolJSON {
+	if t == nil {
+		return nil
+	}
+	return &toolJSON{Label: t.Label(), Schema: t.Schema()}
+}
+
+func (t *toolJSON) tool() tools.Tool {
+	if t == nil || t.Schema == nil {
+		return nil
+	}
+	name := t.Schema.Name
+	return tools.External(t.Label, t.Schema, func(r tools.Runner, params json.RawMessage) tools.Result {
+		return tools.Errorf("tool %q was decoded from an update and can't be run", name)
+	})
+}
+
+// resultJSON describes a tool result in an encoded update.
+type resultJSON struct {
+	Label   string          `json:"label"`
+	Content content.Content `json:"content"`
+	Error   string          `json:"error,omitempty"`
+}
+
+func resultToJSON(r tools.Result) *resultJSON {
+	if r == nil {
+		return nil
+	}
+	rj := &resultJSON{Label: r.Label(), Content: r.Content()}
+	if err := r.Error(); err != nil {
+		rj.Error = err.Error()
+	}
+	return rj
+}
+
+func (r *resultJSON) result() tools.Result {
+	if r == nil {
+		return nil
+	}
+	if r.Error != "" {
+		return tools.ErrorWithLabel(r.Label, errors.New(r.Error))
+	}
+	return tools.SuccessWithContent(r.Label, r.Content)
+}
+
+type toolStartUpdateJSON struct {
+	ToolCallID    string    `json:"tool_call_id"`
+	Tool          *toolJSON `json:"tool"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+func (u ToolStartUpdate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toolStartUpdateJSON{u.ToolCallID, toolToJSON(u.Tool), u.CorrelationID})
+}
+
+func (u *ToolStartUpdate) UnmarshalJSON(data []byte) error {
+	var v toolStartUpdateJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*u = ToolStartUpdate{ToolCallID: v.ToolCallID, Tool: v.Tool.tool(), CorrelationID: v.CorrelationID}
+	return nil
+}
+
+type toolStatusUpdateJSON struct {
+	ToolCallID    string    `json:"tool_call_id"`
+	Status        string    `json:"status"`
+	Tool          *toolJSON `json:"tool"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+}
+
+func (u ToolStatusUpdate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toolStatusUpdateJSON{u.ToolCallID, u.Status, toolToJSON(u.Tool), u.CorrelationID})
+}
+
+func (u *ToolStatusUpdate) UnmarshalJSON(data []byte) error {
+	var v toolStatusUpdateJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*u = ToolStatusUpdate{ToolCallID: v.ToolCallID, Status: v.Status, Tool: v.Tool.tool(), CorrelationID: v.CorrelationID}
+	return nil
+}
+
+type toolDoneUpdateJSON struct {
+	ToolCallID    string      `json:"tool_call_id"`
+	Result        *resultJSON `json:"result"`
+	Tool          *toolJSON   `json:"tool"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+}
+
+func (u ToolDoneUpdate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(toolDoneUpdateJSON{u.ToolCallID, resultToJSON(u.Result), toolToJSON(u.Tool), u.CorrelationID})
+}
+
+func (u *ToolDoneUpdate) UnmarshalJSON(data []byte) error {
+	var v toolDoneUpdateJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*u = ToolDoneUpdate{ToolCallID: v.ToolCallID, Result: v.Result.result(), Tool: v.Tool.tool(), CorrelationID: v.CorrelationID}
+	return nil
+}
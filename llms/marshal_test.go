@@ -0,0 +1,69 @@
+package llms
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roundTrip(t *testing.T, u Update) Update {
+	data, err := MarshalUpdate(u)
+	require.NoError(t, err)
+	decoded, err := UnmarshalUpdate(data)
+	require.NoError(t, err)
+	return decoded
+}
+
+func TestMarshalUpdate(t *testing.T) {
+	data, err := MarshalUpdate(TextUpdate{Text: "Hello"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"text","data":{"text":"Hello"}}`, string(data))
+
+	assert.Equal(t, TextUpdate{Text: "Hello"}, roundTrip(t, TextUpdate{Text: "Hello"}))
+	assert.Equal(t, ThinkingUpdate{Text: "Hmm"}, roundTrip(t, ThinkingUpdate{Text: "Hmm"}))
+	assert.Equal(t, AudioUpdate{Audio: []byte{1, 2, 3}}, roundTrip(t, AudioUpdate{Audio: []byte{1, 2, 3}}))
+	assert.Equal(t, RefusalUpdate{Text: "I can't help with that."}, roundTrip(t, RefusalUpdate{Text: "I can't help with that."}))
+	assert.Equal(t, FinishUpdate{Reason: FinishReasonLength}, roundTrip(t, FinishUpdate{Reason: FinishReasonLength}))
+
+	start, ok := roundTrip(t, ToolStartUpdate{ToolCallID: "call_1", Tool: testTool}).(ToolStartUpdate)
+	require.True(t, ok)
+	assert.Equal(t, "call_1", start.ToolCallID)
+	assert.Equal(t, testTool.Label(), start.Tool.Label())
+	assert.Equal(t, testTool.Schema(), start.Tool.Schema())
+	assert.Error(t, start.Tool.Run(nil, nil).Error(), "Decoded tools should not run")
+
+	status, ok := roundTrip(t, ToolStatusUpdate{ToolCallID: "call_1", Status: "Working", Tool: testTool}).(ToolStatusUpdate)
+	require.True(t, ok)
+	assert.Equal(t, "Working", status.Status)
+	assert.Equal(t, testTool.FuncName(), status.Tool.FuncName())
+
+	done, ok := roundTrip(t, ToolDoneUpdate{ToolCallID: "call_1", Result: tools.SuccessFromString("42"), Tool: testTool}).(ToolDoneUpdate)
+	require.True(t, ok)
+	assert.Equal(t, "42", done.Result.Label())
+	assert.Equal(t, tools.SuccessFromString("42").Content(), done.Result.Content())
+	assert.NoError(t, done.Result.Error())
+
+	failed, ok := roundTrip(t, ToolDoneUpdate{ToolCallID: "call_2", Result: tools.Error(errors.New("boom"))}).(ToolDoneUpdate)
+	require.True(t, ok)
+	assert.EqualError(t, failed.Result.Error(), "boom")
+	assert.Nil(t, failed.Tool)
+}
+
+type customUpdate struct {
+	Value int `json:"value"`
+}
+
+func (u customUpdate) Type() UpdateType {
+	return "custom"
+}
+
+func TestUnmarshalUpdateRegistered(t *testing.T) {
+	_, err := UnmarshalUpdate([]byte(`{"type":"custom","data":{"value":1}}`))
+	assert.ErrorContains(t, err, `unknown update type "custom"`)
+
+	RegisterUpdateType[customUpdate]()
+	assert.Equal(t, customUpdate{Value: 7}, roundTrip(t, customUpdate{Value: 7}))
+}
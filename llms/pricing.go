@@ -0,0 +1,125 @@
+package llms
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Pricing is the price of using a model, in USD per million tokens.
+type Pricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+	// CachedInputPerMillion is the price of input tokens that were read from
+	// the prompt cache. Zero means cached tokens cost the same as other input.
+	CachedInputPerMillion float64
+	// CacheWriteInputPerMillion is the price of input tokens that were
+	// written to the prompt cache. Zero means they cost the same as other
+	// input.
+	CacheWriteInputPerMillion float64
+}
+
+// Cost returns the cost in USD of the given number of tokens.
+func (p Pricing) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.InputPerMillion + float64(outputTokens)*p.OutputPerMillion) / 1_000_000
+}
+
+// CostWithCache returns the cost in USD of the given number of tokens, where
+// cachedInputTokens of the input tokens were read from the prompt cache.
+func (p Pricing) CostWithCache(inputTokens, cachedInputTokens, outputTokens int) float64 {
+	if p.CachedInputPerMillion == 0 {
+		return p.Cost(inputTokens, outputTokens)
+	}
+	uncached := inputTokens - cachedInputTokens
+	return p.Cost(uncached, outputTokens) + float64(cachedInputTokens)*p.CachedInputPerMillion/1_000_000
+}
+
+// CostWithDetails returns the cost in USD of the given number of tokens,
+// where the details say how many of the input tokens were read from and
+// written to the prompt cache.
+func (p Pricing) CostWithDetails(inputTokens, outputTokens int, details UsageDetails) float64 {
+	cost := p.CostWithCache(inputTokens-details.CacheWriteInputTokens, details.CachedInputTokens, outputTokens)
+	writePrice := p.CacheWriteInputPerMillion
+	if writePrice == 0 {
+		writePrice = p.InputPerMillion
+	}
+	return cost + float64(details.CacheWriteInputTokens)*writePrice/1_000_000
+}
+
+var (
+	pricingMu sync.RWMutex
+	// pricing holds the list prices of popular models, as input, output,
+	// cached input and cache write prices. Prices change, so use SetPricing to
+	// correct them or to add other models.
+	pricing = map[string]Pricing{
+		// Anthropic
+		"claude-opus-4":     {15, 75, 1.5, 18.75},
+		"claude-sonnet-4":   {3, 15, 0.3, 3.75},
+		"claude-3-7-sonnet": {3, 15, 0.3, 3.75},
+		"claude-3-5-sonnet": {3, 15, 0.3, 3.75},
+		"claude-3-5-haiku":  {0.8, 4, 0.08, 1},
+		"claude-3-opus":     {15, 75, 1.5, 18.75},
+		"claude-3-haiku":    {0.25, 1.25, 0.03, 0.30},
+		// DeepSeek
+		"deepseek-chat":     {0.27, 1.10, 0.07, 0},
+		"deepseek-reasoner": {0.55, 2.19, 0.14, 0},
+		// Google
+		"gemini-2.5-pro":        {1.25, 10, 0.31, 0},
+		"gemini-2.5-flash":      {0.30, 2.50, 0.075, 0},
+		"gemini-2.0-flash":      {0.10, 0.40, 0.025, 0},
+		"gemini-2.0-flash-lite": {0.075, 0.30, 0, 0},
+		// OpenAI
+		"gpt-4.1":      {2, 8, 0.5, 0},
+		"gpt-4.1-mini": {0.4, 1.6, 0.1, 0},
+		"gpt-4.1-nano": {0.1, 0.4, 0.025, 0},
+		"gpt-4o":       {2.5, 10, 1.25, 0},
+		"gpt-4o-mini":  {0.15, 0.6, 0.075, 0},
+		"o3":           {2, 8, 0.5, 0},
+		"o3-mini":      {1.1, 4.4, 0.55, 0},
+		"o4-mini":      {1.1, 4.4, 0.275, 0},
+	}
+)
+
+// SetPricing sets the pricing of a model, replacing any earlier pricing.
+func SetPricing(model string, p Pricing) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	pricing[model] = p
+}
+
+// modelVersionSuffix matches the parts of model names that identify a version
+// of a model rather than a different model, such as the date of a snapshot.
+var modelVersionSuffix = regexp.MustCompile(`(-\d{4}-\d{2}-\d{2}|-\d{8}|@\d{8}|-v\d+(:\d+)?|-latest)$`)
+
+// LookupPricing returns the pricing of the model. Snapshots and platform
+// specific names of a model, such as "gpt-4o-2024-08-06",
+// "openai/gpt-4o", or "anthropic.claude-3-7-sonnet-20250219-v1:0", get the
+// pricing of the model they are a version of. Other variants, such as
+// "gpt-4o-audio-preview", are often priced differently, so they aren't found
+// unless they have been added with SetPricing.
+func LookupPricing(model string) (Pricing, bool) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	if p, ok := pricing[model]; ok {
+		return p, true
+	}
+	// Remove prefixes like "openai/" (OpenRouter), "models/" (Gemini), and
+	// "anthropic." or "us.anthropic." (Bedrock).
+	if i := strings.LastIndexByte(model, '/'); i >= 0 {
+		model = model[i+1:]
+	}
+	if i := strings.LastIndex(model, "anthropic."); i >= 0 {
+		model = model[i+len("anthropic."):]
+	}
+	for {
+		if p, ok := pricing[model]; ok {
+			return p, true
+		}
+		trimmed := modelVersionSuffix.ReplaceAllString(model, "")
+		if trimmed == model {
+			break
+		}
+		model = trimmed
+	}
+	return Pricing{}, false
+}
@@ -0,0 +1,96 @@
+package llms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupPricing(t *testing.T) {
+	gpt4o := Pricing{InputPerMillion: 2.5, OutputPerMillion: 10, CachedInputPerMillion: 1.25}
+	for _, model := range []string{"gpt-4o", "gpt-4o-2024-08-06", "openai/gpt-4o"} {
+		p, ok := LookupPricing(model)
+		assert.True(t, ok, model)
+		assert.Equal(t, gpt4o, p, model)
+	}
+	sonnet := Pricing{InputPerMillion: 3, OutputPerMillion: 15, CachedInputPerMillion: 0.3, CacheWriteInputPerMillion: 3.75}
+	for _, model := range []string{"claude-3-7-sonnet-latest", "claude-3-7-sonnet@20250219", "us.anthropic.claude-3-7-sonnet-20250219-v1:0"} {
+		p, ok := LookupPricing(model)
+		assert.True(t, ok, model)
+		assert.Equal(t, sonnet, p, model)
+	}
+	p, ok := LookupPricing("gpt-4o-mini-2024-07-18")
+	assert.True(t, ok)
+	assert.Equal(t, 0.15, p.InputPerMillion, "The most specific model should be matched")
+	for _, model := range []string{"gemini-2.5-flash-preview-05-20", "gpt-4o-audio-preview", "gpt-4.1-turbo"} {
+		_, ok = LookupPricing(model)
+		assert.False(t, ok, "Variants other than snapshots shouldn't get the pricing of another model: %s", model)
+	}
+
+	_, ok = LookupPricing("my-local-model")
+	assert.False(t, ok)
+	SetPricing("my-local-model", Pricing{InputPerMillion: 1, OutputPerMillion: 2})
+	p, ok = LookupPricing("my-local-model")
+	assert.True(t, ok)
+	assert.InDelta(t, 0.005, p.Cost(1000, 2000), 1e-12)
+	assert.InDelta(t, 0.005, p.CostWithCache(1000, 500, 2000), 1e-12, "Cached tokens cost the same without a cached price")
+}
+
+func TestCostWithCache(t *testing.T) {
+	p := Pricing{InputPerMillion: 2, OutputPerMillion: 8, CachedInputPerMillion: 0.5}
+	assert.InDelta(t, 0.01, p.CostWithCache(1_000, 0, 1_000), 1e-12)
+	assert.InDelta(t, 0.00925, p.CostWithCache(1_000, 500, 1_000), 1e-12)
+}
+
+func TestCostWithDetails(t *testing.T) {
+	p := Pricing{InputPerMillion: 3, OutputPerMillion: 15, CachedInputPerMillion: 0.3, CacheWriteInputPerMillion: 3.75}
+	assert.InDelta(t, 18, p.CostWithDetails(1_000_000, 1_000_000, UsageDetails{}), 1e-12)
+	// 100k uncached at $3, 400k written at $3.75 and 500k read at $0.30.
+	details := UsageDetails{CachedInputTokens: 500_000, CacheWriteInputTokens: 400_000}
+	assert.InDelta(t, 0.3+1.5+0.15, p.CostWithDetails(1_000_000, 0, details), 1e-12)
+	p.CacheWriteInputPerMillion = 0
+	assert.InDelta(t, 0.3+1.2+0.15, p.CostWithDetails(1_000_000, 0, details), 1e-12, "Cache writes cost the same as input without a write price")
+}
+
+// servedModelProvider wraps the mock provider so its streams report that a
+// specific snapshot served the response.
+type servedModelProvider struct {
+	*mockProvider
+	servedModel string
+}
+
+func (p *servedModelProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	return &servedModelStream{p.mockProvider.Generate(ctx, systemPrompt, messages, toolbox), p.servedModel}
+}
+
+type servedModelStream struct {
+	ProviderStream
+	model string
+}
+
+func (s *servedModelStream) Model() string {
+	return s.model
+}
+
+func TestUsageServedModel(t *testing.T) {
+	var usages []Usage
+	provider := &servedModelProvider{&mockProvider{}, "gpt-4o-2024-08-06"}
+	llm := New(provider).WithUsageCallback(func(u Usage) {
+		usages = append(usages, u)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	runTestChat(ctx, t, llm, "Test message")
+	require.NoError(t, llm.Err())
+
+	require.Len(t, usages, 1)
+	assert.Equal(t, "gpt-4o-2024-08-06", usages[0].Model, "The served model should be reported instead of the requested one")
+	assert.InDelta(t, (10*2.5+20*10)/1_000_000.0, usages[0].CostUSD, 1e-12)
+	assert.InDelta(t, usages[0].CostUSD, llm.CostUSD(), 1e-12)
+}
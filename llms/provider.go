@@ -2,6 +2,7 @@ package llms
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/blixt/go-llms/content"
 	"github.com/blixt/go-llms/tools"
@@ -23,10 +24,55 @@ type ProviderStream interface {
 }
 
 type Provider interface {
+	// Company returns the name of the company or service that serves the
+	// model, such as "OpenAI" or "Anthropic".
 	Company() string
+	// Model returns the name of the requested model, as it's sent to the API.
 	Model() string
+	// String returns a stable name for the provider, for use in logs, metrics
+	// labels and cost reports. Most providers return ProviderName.
+	String() string
 	// Generate takes a system prompt, message history, and optional toolbox,
 	// returning a stream for the LLM's response. The provided context should
 	// be respected for cancellation.
 	Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream
 }
+
+// ProviderName returns a name for the provider made from its model and
+// company, such as "gpt-4.1 (OpenAI)". Providers can use it to implement
+// String.
+func ProviderName(p Provider) string {
+	return fmt.Sprintf("%s (%s)", p.Model(), p.Company())
+}
+
+// ModelStream is implemented by streams that know which model served the
+// response. It can differ from the requested model, for example when an alias
+// resolves to a dated snapshot, or when a router picks another model.
+type ModelStream interface {
+	ProviderStream
+	Model() string
+}
+
+// AudioStream is implemented by streams that can produce audio, as signaled by
+// StreamStatusAudio.
+type AudioStream interface {
+	ProviderStream
+	// Audio returns the latest chunk of audio, in the format that was
+	// requested from the provider.
+	Audio() []byte
+}
+
+// UsageDetailsStream is implemented by streams that report a breakdown of the
+// tokens counted by Usage.
+type UsageDetailsStream interface {
+	ProviderStream
+	UsageDetails() UsageDetails
+}
+
+// ToolResultTyper is implemented by providers that know which content types
+// they can send back to the model in tool results. Tool results are converted
+// to these types before they're added to the conversation. See
+// tools.ConvertContent.
+type ToolResultTyper interface {
+	ToolResultTypes() []content.Type
+}
@@ -0,0 +1,220 @@
+package llms
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+// RateLimits is the rate limit state that a provider reported with a
+// response. Counts that weren't reported are -1.
+type RateLimits struct {
+	LimitRequests     int
+	RemainingRequests int
+	ResetRequests     time.Time
+	LimitTokens       int
+	RemainingTokens   int
+	ResetTokens       time.Time
+}
+
+// RateLimitStream is implemented by streams that know the rate limits the
+// provider reported with the response.
+type RateLimitStream interface {
+	ProviderStream
+	RateLimits() (limits RateLimits, ok bool)
+}
+
+// ParseRateLimits reads the rate limit headers of a response, which can be
+// either OpenAI's x-ratelimit-* headers or Anthropic's anthropic-ratelimit-*
+// headers. It reports false if there weren't any.
+func ParseRateLimits(header http.Header) (RateLimits, bool) {
+	now := time.Now()
+	limits := RateLimits{LimitRequests: -1, RemainingRequests: -1, LimitTokens: -1, RemainingTokens: -1}
+	var found bool
+	count := func(dst *int, names ...string) {
+		for _, name := range names {
+			if n, err := strconv.Atoi(header.Get(name)); err == nil {
+				*dst, found = n, true
+				return
+			}
+		}
+	}
+	reset := func(dst *time.Time, names ...string) {
+		for _, name := range names {
+			value := header.Get(name)
+			if value == "" {
+				continue
+			}
+			// OpenAI sends a duration, such as "6m0s", and Anthropic a time.
+			if d, err := time.ParseDuration(value); err == nil {
+				*dst, found = now.Add(d), true
+				return
+			}
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				*dst, found = t, true
+				return
+			}
+		}
+	}
+	count(&limits.LimitRequests, "x-ratelimit-limit-requests", "anthropic-ratelimit-requests-limit")
+	count(&limits.RemainingRequests, "x-ratelimit-remaining-requests", "anthropic-ratelimit-requests-remaining")
+	reset(&limits.ResetRequests, "x-ratelimit-reset-requests", "anthropic-ratelimit-requests-reset")
+	count(&limits.LimitTokens, "x-ratelimit-limit-tokens", "anthropic-ratelimit-tokens-limit", "anthropic-ratelimit-input-tokens-limit")
+	count(&limits.RemainingTokens, "x-ratelimit-remaining-tokens", "anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-input-tokens-remaining")
+	reset(&limits.ResetTokens, "x-ratelimit-reset-tokens", "anthropic-ratelimit-tokens-reset", "anthropic-ratelimit-input-tokens-reset")
+	return limits, found
+}
+
+// RateLimiter is a provider that holds back requests when the last rate
+// limits reported by the wrapped provider say they would be rejected, until
+// the limits reset. It adapts to whatever limits the account has, so there is
+// nothing to configure. Requests are only held back while the limits are
+// known, which requires the streams of the provider to implement
+// RateLimitStream.
+type RateLimiter struct {
+	provider Provider
+	callback func(RateLimits)
+
+	mu       sync.Mutex
+	reported reportedLimits
+}
+
+// LimitRate returns a provider that waits for the rate limits of the given
+// provider to reset before sending requests that would exceed them.
+func LimitRate(p Provider) *RateLimiter {
+	return &RateLimiter{provider: p}
+}
+
+// WithCallback sets a function that is called with the rate limits reported
+// with every response, for example to monitor how close to them an
+// application is running.
+func (r *RateLimiter) WithCallback(callback func(RateLimits)) *RateLimiter {
+	r.callback = callback
+	return r
+}
+
+// RateLimits returns the last rate limits reported by the provider, with the
+// requests that have been sent since subtracted.
+func (r *RateLimiter) RateLimits() (limits RateLimits, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reported.limits, r.reported.known
+}
+
+func (r *RateLimiter) Company() string {
+	return r.provider.Company()
+}
+
+func (r *RateLimiter) Model() string {
+	return r.provider.Model()
+}
+
+func (r *RateLimiter) String() string {
+	return ProviderName(r)
+}
+
+func (r *RateLimiter) ToolResultTypes() []content.Type {
+	if typer, ok := r.provider.(ToolResultTyper); ok {
+		return typer.ToolResultTypes()
+	}
+	return nil
+}
+
+func (r *RateLimiter) Ping(ctx context.Context) error {
+	return Ping(ctx, r.provider)
+}
+
+// Generate waits until the request fits within the known rate limits and then
+// sends it. If the context is done while waiting, the request is passed on
+// right away so that the provider reports the error.
+func (r *RateLimiter) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	tokens := EstimateTokens(systemPrompt) + EstimateMessageTokens(messages)
+	for wait := r.reserve(tokens); wait > 0 && ctx.Err() == nil; wait = r.reserve(tokens) {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	stream := r.provider.Generate(ctx, systemPrompt, messages, toolbox)
+	if rls, ok := stream.(RateLimitStream); ok {
+		if limits, ok := rls.RateLimits(); ok {
+			r.mu.Lock()
+			r.reported = reportedLimits{limits, true}
+			r.mu.Unlock()
+			if r.callback != nil {
+				r.callback(limits)
+			}
+		}
+	}
+	return stream
+}
+
+// reserve returns how long to wait before a request of the given size can be
+// sent, or 0 if it can be sent now, in which case it's subtracted from the
+// remaining requests and tokens.
+func (r *RateLimiter) reserve(tokens int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if wait := r.reported.wait(now, tokens); wait > 0 {
+		return wait
+	}
+	r.reported.take(now, tokens)
+	return 0
+}
+
+// reportedLimits are the rate limits that a provider last reported, with the
+// requests that have been sent since subtracted. It's used by both RateLimiter
+// and Budget.
+type reportedLimits struct {
+	limits RateLimits
+	known  bool
+}
+
+// wait returns how long to wait before a request of the given size fits
+// within the limits, or 0 if it fits now.
+func (r *reportedLimits) wait(now time.Time, tokens int) time.Duration {
+	if !r.known {
+		return 0
+	}
+	if r.limits.RemainingRequests == 0 && now.Before(r.limits.ResetRequests) {
+		return r.limits.ResetRequests.Sub(now)
+	}
+	// A request that is larger than the whole limit would never fit, so it's
+	// only held back until the tokens have been refilled.
+	needed := tokens
+	if r.limits.LimitTokens >= 0 {
+		needed = min(needed, r.limits.LimitTokens)
+	}
+	if r.limits.RemainingTokens >= 0 && r.limits.RemainingTokens < needed && now.Before(r.limits.ResetTokens) {
+		return r.limits.ResetTokens.Sub(now)
+	}
+	return 0
+}
+
+// take subtracts a request of the given size from the remaining requests and
+// tokens, refilling them first if they have been reset.
+func (r *reportedLimits) take(now time.Time, tokens int) {
+	if !r.known {
+		return
+	}
+	if !now.Before(r.limits.ResetRequests) && r.limits.LimitRequests >= 0 {
+		r.limits.RemainingRequests = r.limits.LimitRequests
+	}
+	if !now.Before(r.limits.ResetTokens) && r.limits.LimitTokens >= 0 {
+		r.limits.RemainingTokens = r.limits.LimitTokens
+	}
+	if r.limits.RemainingRequests > 0 {
+		r.limits.RemainingRequests--
+	}
+	if r.limits.RemainingTokens > 0 {
+		r.limits.RemainingTokens = max(0, r.limits.RemainingTokens-tokens)
+	}
+}
@@ -0,0 +1,102 @@
+package llms
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	_, ok := ParseRateLimits(http.Header{})
+	assert.False(t, ok)
+
+	header := http.Header{}
+	header.Set("x-ratelimit-limit-requests", "60")
+	header.Set("x-ratelimit-remaining-requests", "59")
+	header.Set("x-ratelimit-reset-requests", "1s")
+	header.Set("x-ratelimit-remaining-tokens", "149984")
+	limits, ok := ParseRateLimits(header)
+	assert.True(t, ok)
+	assert.Equal(t, 60, limits.LimitRequests)
+	assert.Equal(t, 59, limits.RemainingRequests)
+	assert.WithinDuration(t, time.Now().Add(time.Second), limits.ResetRequests, 100*time.Millisecond)
+	assert.Equal(t, -1, limits.LimitTokens)
+	assert.Equal(t, 149984, limits.RemainingTokens)
+	assert.True(t, limits.ResetTokens.IsZero())
+
+	header = http.Header{}
+	header.Set("anthropic-ratelimit-tokens-limit", "80000")
+	header.Set("anthropic-ratelimit-tokens-remaining", "0")
+	header.Set("anthropic-ratelimit-tokens-reset", "2025-01-02T03:04:05Z")
+	limits, ok = ParseRateLimits(header)
+	assert.True(t, ok)
+	assert.Equal(t, -1, limits.RemainingRequests)
+	assert.Equal(t, 80000, limits.LimitTokens)
+	assert.Equal(t, 0, limits.RemainingTokens)
+	assert.Equal(t, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC), limits.ResetTokens)
+}
+
+// limitedProvider reports the given rate limits with every response.
+type limitedProvider struct {
+	limits RateLimits
+	calls  []time.Time
+}
+
+func (p *limitedProvider) Company() string { return "Test" }
+func (p *limitedProvider) Model() string   { return "test-model" }
+func (p *limitedProvider) String() string  { return ProviderName(p) }
+
+func (p *limitedProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	p.calls = append(p.calls, time.Now())
+	return &limitedStream{limits: p.limits}
+}
+
+type limitedStream struct {
+	errorMockStream
+	limits RateLimits
+}
+
+func (s *limitedStream) RateLimits() (RateLimits, bool) { return s.limits, true }
+
+func TestRateLimiter(t *testing.T) {
+	provider := &limitedProvider{limits: RateLimits{
+		LimitRequests:     10,
+		RemainingRequests: 1,
+		ResetRequests:     time.Now().Add(200 * time.Millisecond),
+		LimitTokens:       -1,
+		RemainingTokens:   -1,
+	}}
+	var reported []RateLimits
+	limiter := LimitRate(provider).WithCallback(func(limits RateLimits) {
+		reported = append(reported, limits)
+	})
+
+	// Nothing is known before the first response.
+	assert.NoError(t, limiter.Generate(context.Background(), nil, nil, nil).Err())
+	assert.Len(t, reported, 1)
+
+	// The one remaining request is sent right away, but the next one has to
+	// wait for the reset.
+	assert.NoError(t, limiter.Generate(context.Background(), nil, nil, nil).Err())
+	limits, ok := limiter.RateLimits()
+	assert.True(t, ok)
+	assert.Equal(t, 1, limits.RemainingRequests)
+	provider.limits.RemainingRequests = 0
+	limiter.Generate(context.Background(), nil, nil, nil)
+	assert.NoError(t, limiter.Generate(context.Background(), nil, nil, nil).Err())
+	assert.Len(t, provider.calls, 4)
+	assert.GreaterOrEqual(t, provider.calls[3].Sub(provider.calls[2]), 100*time.Millisecond)
+
+	// A canceled request isn't held back.
+	provider.limits.ResetRequests = time.Now().Add(time.Hour)
+	limiter.Generate(context.Background(), nil, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.Generate(ctx, nil, nil, nil)
+	assert.Len(t, provider.calls, 6)
+}
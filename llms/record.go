@@ -0,0 +1,66 @@
+package llms
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+)
+
+// TurnRecord captures everything that went into and came out of a single turn,
+// so the turn can be inspected or replayed later (see the replay package).
+type TurnRecord struct {
+	Time          time.Time `json:"time"`
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	// Turn is the number of the turn within the LLM's lifetime, from 1.
+	Turn         int                     `json:"turn"`
+	Company      string                  `json:"company"`
+	Model        string                  `json:"model"`
+	SystemPrompt content.Content         `json:"system_prompt,omitempty"`
+	Messages     []Message               `json:"messages"`
+	Tools        []*tools.FunctionSchema `json:"tools,omitempty"`
+	Response     Message                 `json:"response"`
+	ToolResults  []Message               `json:"tool_results,omitempty"`
+	InputTokens  int                     `json:"input_tokens"`
+	OutputTokens int                     `json:"output_tokens"`
+	// Duration is how long the turn took, from the request until the response
+	// and the tool calls were done.
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// WithRecorder makes the LLM call the provided function with a record of every
+// turn once it's done, including turns that failed. See NewRecordWriter for a
+// simple way to persist the records.
+func (l *LLM) WithRecorder(record func(TurnRecord)) *LLM {
+	l.recorder = record
+	return l
+}
+
+// NewRecordWriter returns a recorder function (for use with LLM.WithRecorder)
+// that writes each record to w as a line of JSON. It is safe for concurrent
+// use.
+func NewRecordWriter(w io.Writer) func(TurnRecord) {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	return func(record TurnRecord) {
+		mu.Lock()
+		defer mu.Unlock()
+		encoder.Encode(record)
+	}
+}
+
+// toolSchemas returns the schemas of the tools in the toolbox.
+func toolSchemas(toolbox *tools.Toolbox) []*tools.FunctionSchema {
+	if toolbox == nil {
+		return nil
+	}
+	var schemas []*tools.FunctionSchema
+	for _, tool := range toolbox.All() {
+		schemas = append(schemas, tool.Schema())
+	}
+	return schemas
+}
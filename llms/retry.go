@@ -0,0 +1,113 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/internal/backoff"
+	"github.com/blixt/go-llms/tools"
+)
+
+// HTTPError is implemented by the errors of providers whose API responded
+// with an error status, such as openai.APIError and anthropic.APIError.
+type HTTPError interface {
+	error
+	// HTTPStatus returns the status code of the response.
+	HTTPStatus() int
+	// RetryDelay returns how long the API asked to wait before trying again,
+	// or 0 if it didn't say.
+	RetryDelay() time.Duration
+}
+
+// RetryPolicy decides how requests to the provider are retried when they
+// fail with a transient error, which is an error status that is listed in
+// RetryableStatusCodes or a network error. Errors without a status, such as
+// the local checks of providers, are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the most times a request is sent, including the first
+	// time. Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, which doubles for every
+	// following retry up to MaxDelay. They default to a second and a minute.
+	// A delay that the API asks for with Retry-After is used instead.
+	BaseDelay, MaxDelay time.Duration
+	// Jitter is the fraction of each delay that is randomized, from 0 to 1,
+	// so that clients that failed together don't retry together.
+	Jitter float64
+	// RetryableStatusCodes are the error statuses that are retried, which
+	// default to 429 and the server errors 500, 502, 503, 504 and 529.
+	RetryableStatusCodes []int
+}
+
+var defaultRetryableStatusCodes = []int{429, 500, 502, 503, 504, 529}
+
+// WithRetryPolicy makes the LLM retry requests to the provider that fail with
+// a transient error before any of the response has been received, so that
+// rate limits and outages that pass quickly don't end the conversation.
+func (l *LLM) WithRetryPolicy(policy RetryPolicy) *LLM {
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = time.Second
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = time.Minute
+	}
+	if policy.RetryableStatusCodes == nil {
+		policy.RetryableStatusCodes = defaultRetryableStatusCodes
+	}
+	l.retryPolicy = &policy
+	return l
+}
+
+// generate starts a response from the provider, retrying according to the
+// retry policy as long as the stream fails before it begins.
+func (l *LLM) generate(ctx context.Context, provider Provider, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	for attempt := 1; ; attempt++ {
+		stream := provider.Generate(ctx, systemPrompt, messages, toolbox)
+		l.observeRateLimits(provider, stream)
+		err := stream.Err()
+		if err == nil || l.retryPolicy == nil || attempt >= l.retryPolicy.MaxAttempts || ctx.Err() != nil {
+			return stream
+		}
+		delay, ok := l.retryPolicy.delay(attempt, err)
+		if !ok {
+			return stream
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return stream
+		}
+		if l.waitForLimiter(ctx, provider, systemPrompt, messages) != nil {
+			return stream
+		}
+	}
+}
+
+// delay returns how long to wait before retrying after the attempt, counted
+// from 1, failed with the error, or false if the error isn't transient.
+func (p *RetryPolicy) delay(attempt int, err error) (time.Duration, bool) {
+	var httpErr HTTPError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &httpErr):
+		if !slices.Contains(p.RetryableStatusCodes, httpErr.HTTPStatus()) {
+			return 0, false
+		}
+		if d := httpErr.RetryDelay(); d > 0 {
+			return d, true
+		}
+	case errors.As(err, &netErr):
+	default:
+		return 0, false
+	}
+	delay := backoff.Delay(p.BaseDelay, p.MaxDelay, attempt-1)
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(delay))
+	}
+	return delay, true
+}
@@ -0,0 +1,110 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+)
+
+// statusError is an error status of a provider's API.
+type statusError struct {
+	status     int
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string             { return fmt.Sprintf("status %d", e.status) }
+func (e *statusError) HTTPStatus() int           { return e.status }
+func (e *statusError) RetryDelay() time.Duration { return e.retryAfter }
+
+// flakyProvider fails with the given errors before it responds normally.
+type flakyProvider struct {
+	mockProvider
+	errs  []error
+	calls int
+}
+
+func (p *flakyProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return &errorMockStream{err: err}
+	}
+	return p.mockProvider.Generate(ctx, systemPrompt, messages, toolbox)
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	provider := &flakyProvider{errs: []error{
+		&statusError{status: 529},
+		fmt.Errorf("error making request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}),
+	}}
+	llm := New(provider).WithRetryPolicy(policy)
+	for range llm.Chat("Hello") {
+	}
+	assert.NoError(t, llm.Err())
+	assert.Equal(t, 3, provider.calls)
+
+	provider = &flakyProvider{errs: []error{&statusError{status: 429}, &statusError{status: 429}, &statusError{status: 429}}}
+	llm = New(provider).WithRetryPolicy(policy)
+	for range llm.Chat("Hello") {
+	}
+	var httpErr HTTPError
+	assert.True(t, errors.As(llm.Err(), &httpErr))
+	assert.Equal(t, 3, provider.calls, "There should be no more than MaxAttempts attempts")
+
+	provider = &flakyProvider{errs: []error{&statusError{status: 400}}}
+	llm = New(provider).WithRetryPolicy(policy)
+	for range llm.Chat("Hello") {
+	}
+	assert.Error(t, llm.Err())
+	assert.Equal(t, 1, provider.calls, "Client errors shouldn't be retried")
+
+	provider = &flakyProvider{errs: []error{&statusError{status: 503}}}
+	llm = New(provider)
+	for range llm.Chat("Hello") {
+	}
+	assert.Error(t, llm.Err())
+	assert.Equal(t, 1, provider.calls, "There should be no retries without a policy")
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second, RetryableStatusCodes: []int{503}}
+	delay, ok := p.delay(1, &statusError{status: 503})
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, delay)
+	delay, _ = p.delay(3, &statusError{status: 503})
+	assert.Equal(t, 4*time.Second, delay)
+	delay, _ = p.delay(10, &statusError{status: 503})
+	assert.Equal(t, 5*time.Second, delay)
+	delay, _ = p.delay(1, &statusError{status: 503, retryAfter: 30 * time.Second})
+	assert.Equal(t, 30*time.Second, delay, "Retry-After should be honored")
+	_, ok = p.delay(1, &statusError{status: 429})
+	assert.False(t, ok)
+	_, ok = p.delay(1, errors.New("invalid request"))
+	assert.False(t, ok)
+	p.RetryableStatusCodes = []int{429, 503}
+	_, ok = p.delay(1, errors.New("max tokens of 4290 exceeds the limit of 4096 output tokens"))
+	assert.False(t, ok, "Local errors without a status should never be retried")
+	_, ok = p.delay(1, errors.New("429 Too Many Requests"))
+	assert.False(t, ok, "Only errors with a status should be retried as rate limits")
+	delay, ok = p.delay(40, &statusError{status: 503})
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, delay, "The delay of late attempts shouldn't overflow")
+	delay, _ = p.delay(1_000_000, &statusError{status: 503})
+	assert.Equal(t, 5*time.Second, delay)
+
+	p.Jitter = 0.5
+	for range 20 {
+		delay, _ = p.delay(2, &statusError{status: 503})
+		assert.True(t, delay > time.Second && delay <= 2*time.Second, delay)
+	}
+}
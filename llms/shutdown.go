@@ -0,0 +1,110 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown is returned by chats that are refused or cut short because
+// Shutdown was called.
+var ErrShuttingDown = errors.New("shutting down")
+
+var shutdownState struct {
+	mu       sync.Mutex
+	draining bool
+	steps    map[*int]context.CancelCauseFunc
+	idle     chan struct{} // Closed when the last step finishes while draining.
+	hooks    []func(ctx context.Context) error
+}
+
+// OnShutdown registers a function that Shutdown calls once all steps have
+// finished, such as the Close method of a sink that batches usage or metrics.
+func OnShutdown(hook func(ctx context.Context) error) {
+	shutdownState.mu.Lock()
+	defer shutdownState.mu.Unlock()
+	shutdownState.hooks = append(shutdownState.hooks, hook)
+}
+
+// Shutdown gracefully stops all chats in the process, for example before a
+// rolling deploy replaces it. No new steps (requests to the provider, along
+// with the tool calls in the response) are started, and chats that would
+// continue end with ErrShuttingDown instead. Steps that are already running
+// may finish, including their tool calls, until ctx is done. Any that remain
+// are canceled, which leaves their conversation at the last completed step in
+// the history store, so they can be resumed by another process.
+//
+// Finally, the hooks registered with OnShutdown are called. If ctx is already
+// done by then, they get ten seconds of their own to flush.
+func Shutdown(ctx context.Context) error {
+	s := &shutdownState
+	s.mu.Lock()
+	s.draining = true
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+		if len(s.steps) == 0 {
+			close(s.idle)
+		}
+	}
+	idle := s.idle
+	s.mu.Unlock()
+
+	var errs []error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		errs = append(errs, ctx.Err())
+		s.mu.Lock()
+		for _, cancel := range s.steps {
+			cancel(ErrShuttingDown)
+		}
+		s.mu.Unlock()
+	}
+
+	flushCtx := ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		flushCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+	}
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+	for _, hook := range hooks {
+		if err := hook(flushCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// beginStep registers a step, returning a context that is canceled if Shutdown
+// runs out of time, and a function to call when the step is done.
+func beginStep(ctx context.Context) (context.Context, func(), error) {
+	s := &shutdownState
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return nil, nil, ErrShuttingDown
+	}
+	if s.steps == nil {
+		s.steps = make(map[*int]context.CancelCauseFunc)
+	}
+	key := new(int)
+	ctx, cancel := context.WithCancelCause(ctx)
+	s.steps[key] = cancel
+	return ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.steps, key)
+		cancel(nil)
+		if s.idle != nil && len(s.steps) == 0 {
+			select {
+			case <-s.idle:
+			default:
+				close(s.idle)
+			}
+		}
+	}, nil
+}
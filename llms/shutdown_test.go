@@ -0,0 +1,100 @@
+package llms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetShutdown(t *testing.T) {
+	t.Cleanup(func() {
+		shutdownState.mu.Lock()
+		defer shutdownState.mu.Unlock()
+		shutdownState.draining = false
+		shutdownState.idle = nil
+		shutdownState.hooks = nil
+	})
+}
+
+// blockingTool signals started when it's called, then waits for release or
+// for its context to be canceled.
+func blockingTool(started chan<- struct{}, release <-chan struct{}) tools.Tool {
+	return tools.Func("Test Tool", "A test tool for testing", "test_tool",
+		func(r tools.Runner, p TestToolParams) tools.Result {
+			close(started)
+			select {
+			case <-release:
+				return tools.Success(map[string]any{"done": true})
+			case <-r.Context().Done():
+				return tools.Error(r.Context().Err())
+			}
+		})
+}
+
+func TestShutdownLetsStepsFinish(t *testing.T) {
+	resetShutdown(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	provider := &mockProvider{toolCallsToMake: []string{"test_tool"}}
+	llm := New(provider, blockingTool(started, release))
+	var flushed bool
+	OnShutdown(func(ctx context.Context) error {
+		flushed = true
+		return nil
+	})
+
+	updates := llm.Chat("Hello")
+	done := make(chan struct{})
+	go func() {
+		for range updates {
+		}
+		close(done)
+	}()
+	<-started
+	shutdownErr := make(chan error)
+	go func() { shutdownErr <- Shutdown(context.Background()) }()
+	assert.Eventually(t, func() bool {
+		shutdownState.mu.Lock()
+		defer shutdownState.mu.Unlock()
+		return shutdownState.draining
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	require.NoError(t, <-shutdownErr)
+	<-done
+	assert.True(t, flushed)
+	// The step with the tool call completed, but the next one was refused.
+	assert.ErrorIs(t, llm.Err(), ErrShuttingDown)
+	require.Len(t, llm.lastSentMessages, 3)
+	assert.Equal(t, "tool", llm.lastSentMessages[2].Role)
+
+	<-llm.Chat("Hello again")
+	assert.ErrorIs(t, llm.Err(), ErrShuttingDown)
+}
+
+func TestShutdownCancelsStepsAfterGracePeriod(t *testing.T) {
+	resetShutdown(t)
+	started := make(chan struct{})
+	provider := &mockProvider{toolCallsToMake: []string{"test_tool"}}
+	llm := New(provider, blockingTool(started, nil))
+
+	updates := llm.Chat("Hello")
+	done := make(chan struct{})
+	go func() {
+		for range updates {
+		}
+		close(done)
+	}()
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, Shutdown(ctx), context.DeadlineExceeded)
+	<-done
+	assert.ErrorIs(t, llm.Err(), ErrShuttingDown)
+	// The interrupted step isn't added to the history.
+	assert.Len(t, llm.lastSentMessages, 1)
+}
@@ -0,0 +1,77 @@
+package llms
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrBudgetExceeded is returned (possibly wrapped) by providers and tools that
+// refuse to spend more than they have been allowed to.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// StopCause says why a chat stopped, so that UIs can show the right message
+// and callers can decide whether it's safe to retry.
+type StopCause string
+
+const (
+	// StopCauseDone means the chat completed normally.
+	StopCauseDone StopCause = "done"
+	// StopCauseCanceled means the context of the chat was canceled, which is
+	// usually because the user aborted it.
+	StopCauseCanceled StopCause = "canceled"
+	// StopCauseDeadline means the deadline of the context passed.
+	StopCauseDeadline StopCause = "deadline"
+	// StopCauseBudget means a budget was exceeded (see ErrBudgetExceeded).
+	StopCauseBudget StopCause = "budget"
+	// StopCauseMaxTurns means the chat used the turns it was allowed (see
+	// WithMaxTurns).
+	StopCauseMaxTurns StopCause = "max_turns"
+	// StopCauseShutdown means the process is shutting down (see Shutdown).
+	StopCauseShutdown StopCause = "shutdown"
+	// StopCauseProvider means the provider failed to respond, for example
+	// because of a network problem, a rate limit or an invalid request.
+	StopCauseProvider StopCause = "provider"
+	// StopCauseError means the chat failed for another reason.
+	StopCauseError StopCause = "error"
+)
+
+// ProviderError wraps errors returned by the stream of a provider.
+type ProviderError struct {
+	Err error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// StopCauseOf returns why a chat that ended with the given error stopped.
+func StopCauseOf(err error) StopCause {
+	var providerErr *ProviderError
+	switch {
+	case err == nil:
+		return StopCauseDone
+	case errors.Is(err, ErrShuttingDown):
+		return StopCauseShutdown
+	case errors.Is(err, ErrBudgetExceeded):
+		return StopCauseBudget
+	case errors.Is(err, ErrMaxTurnsReached):
+		return StopCauseMaxTurns
+	case errors.Is(err, context.DeadlineExceeded):
+		return StopCauseDeadline
+	case errors.Is(err, context.Canceled):
+		return StopCauseCanceled
+	case errors.As(err, &providerErr):
+		return StopCauseProvider
+	}
+	return StopCauseError
+}
+
+// StopCause returns why the last chat stopped, based on the error returned by
+// Err.
+func (l *LLM) StopCause() StopCause {
+	return StopCauseOf(l.err)
+}
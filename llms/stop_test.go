@@ -0,0 +1,63 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopCauseOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want StopCause
+	}{
+		{nil, StopCauseDone},
+		{context.Canceled, StopCauseCanceled},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), StopCauseDeadline},
+		{fmt.Errorf("tenant %w", ErrBudgetExceeded), StopCauseBudget},
+		{ErrMaxTurnsReached, StopCauseMaxTurns},
+		{ErrShuttingDown, StopCauseShutdown},
+		{fmt.Errorf("LLM returned error response: %w", &ProviderError{Err: errors.New("500")}), StopCauseProvider},
+		// A provider that failed because the context ended stopped for that reason.
+		{&ProviderError{Err: context.Canceled}, StopCauseCanceled},
+		{errors.New("something else"), StopCauseError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, StopCauseOf(tt.err), "%v", tt.err)
+	}
+}
+
+func TestLLMStopCause(t *testing.T) {
+	llm := New(&mockProvider{})
+	for range llm.Chat("Hello") {
+	}
+	assert.Equal(t, StopCauseDone, llm.StopCause())
+
+	llm = New(&errorMockProvider{errorMessage: "overloaded"})
+	for range llm.Chat("Hello") {
+	}
+	assert.Equal(t, StopCauseProvider, llm.StopCause())
+	assert.ErrorContains(t, llm.Err(), "provider stream error: overloaded")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range llm.ChatWithContext(ctx, "Hello") {
+	}
+	assert.Equal(t, StopCauseCanceled, llm.StopCause())
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	for range llm.ChatWithContext(ctx, "Hello") {
+	}
+	assert.Equal(t, StopCauseDeadline, llm.StopCause())
+
+	llm = New(&mockProvider{toolCallsToMake: []string{"test_tool"}}, testTool).WithMaxTurns(1)
+	for range llm.Chat("Hello") {
+	}
+	assert.Equal(t, StopCauseMaxTurns, llm.StopCause())
+}
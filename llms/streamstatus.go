@@ -13,6 +13,10 @@ const (
 	StreamStatusToolCallData
 	// StreamStatusToolCallReady means the stream finished streaming the arguments for a tool call.
 	StreamStatusToolCallReady
-
-	// TODO: Add thinking related stream statuses?
+	// StreamStatusThinking means the stream produced more thinking content. The delta is available from Text().
+	StreamStatusThinking
+	// StreamStatusAudio means the stream produced more audio. The chunk is available from the Audio() method of AudioStream.
+	StreamStatusAudio
+	// StreamStatusRefusal means the stream produced more of a refusal to respond. The delta is available from Text().
+	StreamStatusRefusal
 )
@@ -0,0 +1,104 @@
+package llms
+
+import (
+	"context"
+	"slices"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// TrimPolicy describes how the message history is trimmed to fit the context
+// window of the model: the oldest messages are dropped until the estimated
+// size of the request is within MaxTokens. Messages are dropped whole turns at
+// a time, so the remaining history always starts at a user message and tool
+// results are never separated from their tool calls.
+type TrimPolicy struct {
+	// MaxTokens is the estimated number of input tokens that a request may
+	// have, including the system prompt and the tool definitions. It should
+	// be comfortably below the context window of the model, since token
+	// counts are estimated (see EstimateTokens) and the response needs room.
+	MaxTokens int
+	// KeepFirst is the number of messages at the start of the history that
+	// are never dropped, such as the task that started the conversation.
+	KeepFirst int
+	// KeepLast is the number of messages at the end of the history that are
+	// never dropped, even if the request doesn't fit within MaxTokens.
+	KeepLast int
+}
+
+// WithTrimPolicy makes the LLM trim the message history before every request
+// that wouldn't fit within the policy, so that long conversations don't fail
+// with context length errors. The trimmed history replaces the history of the
+// LLM, which is recorded in the audit log as AuditActionTrim and saved to the
+// history store if one has been configured.
+func (l *LLM) WithTrimPolicy(policy TrimPolicy) *LLM {
+	l.trimPolicy = &policy
+	return l
+}
+
+// trimHistory trims the message history according to the trim policy, if
+// there is one.
+func (l *LLM) trimHistory(ctx context.Context, systemPrompt content.Content) error {
+	if l.trimPolicy == nil {
+		return nil
+	}
+	policy := *l.trimPolicy
+	overhead, _ := EstimateCost(l.provider, nil, EstimateOptions{SystemPrompt: systemPrompt, Toolbox: l.toolbox})
+	policy.MaxTokens -= overhead.InputTokens
+	trimmed := TrimMessages(l.lastSentMessages, policy)
+	if len(trimmed) == len(l.lastSentMessages) {
+		return nil
+	}
+	return l.setHistory(ctx, AuditActionTrim, trimmed)
+}
+
+// TrimMessages returns the messages with the oldest ones dropped until their
+// estimated tokens are within the MaxTokens of the policy, which doesn't
+// account for a system prompt or tools here. System messages are always kept,
+// along with the first and last messages that the policy keeps. If the
+// messages can't be trimmed any further, as many as possible are dropped.
+func TrimMessages(messages []Message, policy TrimPolicy) []Message {
+	tokens := make([]int, len(messages))
+	total := 0
+	for i := range messages {
+		tokens[i] = EstimateMessageTokens(messages[i : i+1])
+		total += tokens[i]
+	}
+	if total <= policy.MaxTokens {
+		return messages
+	}
+
+	// The kept start of the history includes the results of any tool calls
+	// at its end.
+	head := min(max(policy.KeepFirst, 0), len(messages))
+	for head < len(messages) && messages[head].Role == "tool" {
+		head++
+	}
+	last := len(messages) - max(policy.KeepLast, 0)
+
+	// Find the first user message from which the rest of the history fits,
+	// dropping the messages between the head and it, except system messages.
+	start := -1
+	size := total
+	for i := head; i <= last && i < len(messages); i++ {
+		if messages[i].Role == "user" && i > head {
+			start = i
+			if size <= policy.MaxTokens {
+				break
+			}
+		}
+		if messages[i].Role != "system" {
+			size -= tokens[i]
+		}
+	}
+	if start < 0 {
+		return messages
+	}
+	trimmed := slices.Clone(messages[:head])
+	for _, msg := range messages[head:start] {
+		if msg.Role == "system" {
+			trimmed = append(trimmed, msg)
+		}
+	}
+	return append(trimmed, messages[start:]...)
+}
@@ -0,0 +1,78 @@
+package llms
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trimTestMessages returns a conversation of user messages, tool calls and
+// answers in which every message is about 100 estimated tokens.
+func trimTestMessages() []Message {
+	text := func(s string) content.Content { return content.FromText(s + strings.Repeat(".", 400-len(s))) }
+	return []Message{
+		{Role: "user", Content: text("task")},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "1", Name: strings.Repeat("x", 400)}}},
+		{Role: "tool", ToolCallID: "1", Content: text("result")},
+		{Role: "assistant", Content: text("answer 1")},
+		{Role: "system", Content: text("instructions")},
+		{Role: "user", Content: text("question 2")},
+		{Role: "assistant", Content: text("answer 2")},
+		{Role: "user", Content: text("question 3")},
+		{Role: "assistant", Content: text("answer 3")},
+	}
+}
+
+func TestTrimMessages(t *testing.T) {
+	messages := trimTestMessages()
+	roles := func(messages []Message) string {
+		var s []string
+		for _, msg := range messages {
+			s = append(s, msg.Role)
+		}
+		return strings.Join(s, " ")
+	}
+
+	assert.Equal(t, messages, TrimMessages(messages, TrimPolicy{MaxTokens: 1000}))
+
+	// Whole turns are dropped from the start, but system messages are kept.
+	trimmed := TrimMessages(messages, TrimPolicy{MaxTokens: 500})
+	assert.Equal(t, "system user assistant user assistant", roles(trimmed))
+	assert.Equal(t, messages[5:], trimmed[1:])
+
+	trimmed = TrimMessages(messages, TrimPolicy{MaxTokens: 300})
+	assert.Equal(t, "system user assistant", roles(trimmed))
+
+	// The first messages are kept along with the results of their tool calls.
+	trimmed = TrimMessages(messages, TrimPolicy{MaxTokens: 500, KeepFirst: 2})
+	assert.Equal(t, "user assistant tool system user assistant", roles(trimmed))
+
+	// The last messages are kept even if they don't fit.
+	trimmed = TrimMessages(messages, TrimPolicy{MaxTokens: 100, KeepLast: 3})
+	assert.Equal(t, "system user assistant user assistant", roles(trimmed))
+	assert.Equal(t, messages, TrimMessages(messages, TrimPolicy{MaxTokens: 100, KeepLast: 8}))
+}
+
+func TestWithTrimPolicy(t *testing.T) {
+	var events []AuditEvent
+	llm := New(&mockProvider{}).
+		WithTrimPolicy(TrimPolicy{MaxTokens: 400}).
+		WithAudit(func(event AuditEvent) { events = append(events, event) })
+	require.NoError(t, llm.SetHistory(trimTestMessages()))
+	for range llm.Chat("question 4") {
+	}
+	require.NoError(t, llm.Err())
+	history := llm.History()
+	assert.Equal(t, content.FromText("question 4"), history[len(history)-2].Content)
+	assert.Less(t, len(history), len(trimTestMessages()))
+	var trims int
+	for _, event := range events {
+		if event.Action == AuditActionTrim {
+			trims++
+		}
+	}
+	assert.Equal(t, 1, trims)
+}
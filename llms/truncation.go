@@ -0,0 +1,83 @@
+package llms
+
+import "github.com/blixt/go-llms/content"
+
+const UpdateTypeTruncation UpdateType = "truncation"
+
+// TruncationUpdate warns that the provider probably didn't see the whole
+// prompt, for example because a gateway silently dropped older messages to
+// make the prompt fit the context window of the model, so the response may
+// be missing context.
+type TruncationUpdate struct {
+	// Reason describes how the truncation was detected.
+	Reason string `json:"reason"`
+	// EstimatedTokens is the local estimate of the size of the prompt, and
+	// ReportedTokens the number of input tokens the provider reported.
+	EstimatedTokens int    `json:"estimated_tokens"`
+	ReportedTokens  int    `json:"reported_tokens"`
+	CorrelationID   string `json:"correlation_id,omitempty"`
+}
+
+func (u TruncationUpdate) Type() UpdateType {
+	return UpdateTypeTruncation
+}
+
+func init() {
+	RegisterUpdateType[TruncationUpdate]()
+}
+
+// TruncationStream is implemented by streams that know whether the provider
+// truncated the prompt.
+type TruncationStream interface {
+	ProviderStream
+	// Truncated reports whether the prompt was truncated, and how.
+	Truncated() (reason string, ok bool)
+}
+
+// minTruncationEstimate is the smallest estimated prompt that is checked for
+// truncation, since estimates of small prompts are too rough.
+const minTruncationEstimate = 1000
+
+// WithTruncationDetection makes the LLM send a TruncationUpdate when the
+// provider reports far fewer input tokens than the prompt is estimated to
+// have, which is a sign that it was silently truncated. The threshold is the
+// fraction of the estimate below which the prompt is considered truncated,
+// and defaults to 0.5 if it's zero. Since the estimate is rough, prompts
+// under a thousand tokens aren't checked. Truncation reported by streams
+// that implement TruncationStream is always sent.
+//
+// Providers that shrink the prompt on purpose, such as the ones of the
+// compress package, look truncated, and shouldn't be combined with this.
+func (l *LLM) WithTruncationDetection(threshold float64) *LLM {
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	l.truncationThreshold = threshold
+	return l
+}
+
+// detectTruncation returns a warning if the stream reports that the prompt
+// that was sent with the current history was truncated, or if its input
+// tokens are suspiciously few compared to the estimated size of the prompt.
+func (l *LLM) detectTruncation(stream ProviderStream, systemPrompt content.Content, reportedTokens int) (TruncationUpdate, bool) {
+	reason, truncated := "", false
+	if s, ok := stream.(TruncationStream); ok {
+		reason, truncated = s.Truncated()
+	}
+	if !truncated && (l.truncationThreshold <= 0 || reportedTokens <= 0) {
+		return TruncationUpdate{}, false
+	}
+	update := TruncationUpdate{
+		Reason:          reason,
+		EstimatedTokens: EstimateTokens(systemPrompt) + EstimateMessageTokens(l.lastSentMessages),
+		ReportedTokens:  reportedTokens,
+	}
+	if truncated {
+		return update, true
+	}
+	if update.EstimatedTokens < minTruncationEstimate || float64(reportedTokens) >= float64(update.EstimatedTokens)*l.truncationThreshold {
+		return update, false
+	}
+	update.Reason = "the provider reported far fewer input tokens than estimated"
+	return update, true
+}
@@ -0,0 +1,70 @@
+package llms
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// truncatingProvider returns streams that report that the prompt was
+// truncated.
+type truncatingProvider struct {
+	mockProvider
+}
+
+type truncatedStream struct {
+	ProviderStream
+}
+
+func (s truncatedStream) Truncated() (string, bool) {
+	return "middle-out compression", true
+}
+
+func (p *truncatingProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []Message, toolbox *tools.Toolbox) ProviderStream {
+	return truncatedStream{p.mockProvider.Generate(ctx, systemPrompt, messages, toolbox)}
+}
+
+func truncationUpdates(updates []Update) []TruncationUpdate {
+	var truncations []TruncationUpdate
+	for _, u := range updates {
+		if t, ok := u.(TruncationUpdate); ok {
+			truncations = append(truncations, t)
+		}
+	}
+	return truncations
+}
+
+func TestTruncationDetection(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The mock stream reports 10 input tokens.
+	long := strings.Repeat("word ", 2000)
+
+	updates := runTestChat(ctx, t, New(&mockProvider{}), long)
+	assert.Empty(t, truncationUpdates(updates), "Detection is off by default")
+
+	updates = runTestChat(ctx, t, New(&mockProvider{}).WithTruncationDetection(0), "Hello")
+	assert.Empty(t, truncationUpdates(updates), "Small prompts aren't checked")
+
+	updates = runTestChat(ctx, t, New(&mockProvider{}).WithTruncationDetection(0), long)
+	truncations := truncationUpdates(updates)
+	require.Len(t, truncations, 1)
+	assert.Equal(t, 2500, truncations[0].EstimatedTokens)
+	assert.Equal(t, 10, truncations[0].ReportedTokens)
+	assert.NotEmpty(t, truncations[0].Reason)
+	assert.NotEmpty(t, truncations[0].CorrelationID)
+
+	updates = runTestChat(ctx, t, New(&mockProvider{}).WithTruncationDetection(0.001), long)
+	assert.Empty(t, truncationUpdates(updates))
+
+	updates = runTestChat(ctx, t, New(&truncatingProvider{}), "Hello")
+	truncations = truncationUpdates(updates)
+	require.Len(t, truncations, 1)
+	assert.Equal(t, "middle-out compression", truncations[0].Reason)
+}
@@ -0,0 +1,105 @@
+package llms
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/blixt/go-llms/tools"
+)
+
+const UpdateTypeUICommand UpdateType = "ui_command"
+
+// UICommandUpdate asks the client to carry out a command that the model
+// called, such as opening a file in an editor. The chat waits until the client
+// acknowledges the command with AcknowledgeUICommand.
+type UICommandUpdate struct {
+	ToolCallID    string          `json:"tool_call_id"`
+	Name          string          `json:"name"`
+	Arguments     json.RawMessage `json:"arguments"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+}
+
+func (u UICommandUpdate) Type() UpdateType {
+	return UpdateTypeUICommand
+}
+
+func init() {
+	RegisterUpdateType[UICommandUpdate]()
+}
+
+// uiCommands keeps track of the UI commands of an LLM and the calls to them
+// that are waiting for the client.
+type uiCommands struct {
+	mu      sync.Mutex
+	names   map[string]bool
+	pending map[string]chan tools.Result
+}
+
+// AddUICommands adds tools that aren't run on the server, but are forwarded to
+// the client as a UICommandUpdate. The result of the tool is whatever the
+// client acknowledges it with, which lets the model drive a UI without the
+// server having to carry out the commands:
+//
+//	case llms.UICommandUpdate:
+//		result := ui.Run(update.Name, update.Arguments)
+//		llm.AcknowledgeUICommand(update.ToolCallID, tools.Success(result))
+func (l *LLM) AddUICommands(schemas ...tools.FunctionSchema) {
+	if l.uiCommands == nil {
+		l.uiCommands = &uiCommands{names: make(map[string]bool), pending: make(map[string]chan tools.Result)}
+	}
+	commands := l.uiCommands
+	for i := range schemas {
+		commands.names[schemas[i].Name] = true
+	}
+	l.AddExternalTools(schemas, func(r tools.Runner, params json.RawMessage) tools.Result {
+		toolCall, _ := GetToolCall(r.Context())
+		commands.mu.Lock()
+		ack := commands.pending[toolCall.ID]
+		commands.mu.Unlock()
+		defer func() {
+			commands.mu.Lock()
+			delete(commands.pending, toolCall.ID)
+			commands.mu.Unlock()
+		}()
+		select {
+		case result := <-ack:
+			return result
+		case <-r.Context().Done():
+			return tools.Error(fmt.Errorf("UI command was not acknowledged: %w", r.Context().Err()))
+		}
+	})
+}
+
+// AcknowledgeUICommand completes the UI command with the given tool call ID,
+// passing the result back to the model. Unlike the rest of LLM, it's safe to
+// call from any goroutine.
+func (l *LLM) AcknowledgeUICommand(toolCallID string, result tools.Result) error {
+	if l.uiCommands == nil {
+		return fmt.Errorf("no UI command is waiting for %q", toolCallID)
+	}
+	l.uiCommands.mu.Lock()
+	ack, ok := l.uiCommands.pending[toolCallID]
+	l.uiCommands.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no UI command is waiting for %q", toolCallID)
+	}
+	select {
+	case ack <- result:
+		return nil
+	default:
+		return fmt.Errorf("UI command %q has already been acknowledged", toolCallID)
+	}
+}
+
+// forward sends the UI command to the client if the tool call is one, and
+// starts waiting for the acknowledgment.
+func (c *uiCommands) forward(toolCall ToolCall, correlationID string, updateChan chan<- Update) {
+	if c == nil || !c.names[toolCall.Name] {
+		return
+	}
+	c.mu.Lock()
+	c.pending[toolCall.ID] = make(chan tools.Result, 1)
+	c.mu.Unlock()
+	updateChan <- UICommandUpdate{ToolCallID: toolCall.ID, Name: toolCall.Name, Arguments: toolCall.Arguments, CorrelationID: correlationID}
+}
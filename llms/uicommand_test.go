@@ -0,0 +1,49 @@
+package llms
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUICommands(t *testing.T) {
+	provider := &mockProvider{toolCallsToMake: []string{"open_file"}}
+	llm := New(provider)
+	llm.AddUICommands(tools.FunctionSchema{
+		Name:        "open_file",
+		Description: "Opens a file in the editor",
+		Parameters:  tools.ValueSchema{Type: "object"},
+	})
+	assert.Error(t, llm.AcknowledgeUICommand("open_file-id-0", tools.Success(nil)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var commands []UICommandUpdate
+	for update := range llm.ChatWithContext(ctx, "Open main.go") {
+		if command, ok := update.(UICommandUpdate); ok {
+			commands = append(commands, command)
+			go func() {
+				assert.NoError(t, llm.AcknowledgeUICommand(command.ToolCallID, tools.Success(map[string]string{"opened": "main.go"})))
+			}()
+		}
+	}
+	require.NoError(t, llm.Err())
+	require.Len(t, commands, 1)
+	assert.Equal(t, "open_file", commands[0].Name)
+	assert.JSONEq(t, `{"test_param":"test_value_open_file"}`, string(commands[0].Arguments))
+
+	// The acknowledgment is the result the model sees.
+	var result content.Content
+	for _, msg := range provider.messages {
+		if msg.Role == "tool" {
+			result = msg.Content
+		}
+	}
+	assert.Equal(t, content.Content{&content.JSON{Data: []byte(`{"opened":"main.go"}`)}}, result)
+	assert.Error(t, llm.AcknowledgeUICommand(commands[0].ToolCallID, tools.Success(nil)))
+}
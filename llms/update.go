@@ -11,15 +11,22 @@ const (
 	UpdateTypeToolStatus UpdateType = "tool_status"
 	UpdateTypeToolDone   UpdateType = "tool_done"
 	UpdateTypeText       UpdateType = "text"
+	UpdateTypeThinking   UpdateType = "thinking"
+	UpdateTypeAudio      UpdateType = "audio"
+	UpdateTypeRefusal    UpdateType = "refusal"
 )
 
+// Update is sent by the LLM while it works on a chat. Updates can be encoded
+// for transport with MarshalUpdate. The updates of this package carry the
+// correlation ID of the chat they belong to (see GetCorrelationID).
 type Update interface {
 	Type() UpdateType
 }
 
 type ToolStartUpdate struct {
-	ToolCallID string
-	Tool       tools.Tool
+	ToolCallID    string
+	Tool          tools.Tool
+	CorrelationID string
 }
 
 func (u ToolStartUpdate) Type() UpdateType {
@@ -27,9 +34,10 @@ func (u ToolStartUpdate) Type() UpdateType {
 }
 
 type ToolStatusUpdate struct {
-	ToolCallID string
-	Status     string
-	Tool       tools.Tool
+	ToolCallID    string
+	Status        string
+	Tool          tools.Tool
+	CorrelationID string
 }
 
 func (u ToolStatusUpdate) Type() UpdateType {
@@ -37,9 +45,10 @@ func (u ToolStatusUpdate) Type() UpdateType {
 }
 
 type ToolDoneUpdate struct {
-	ToolCallID string
-	Result     tools.Result
-	Tool       tools.Tool
+	ToolCallID    string
+	Result        tools.Result
+	Tool          tools.Tool
+	CorrelationID string
 }
 
 func (u ToolDoneUpdate) Type() UpdateType {
@@ -47,9 +56,42 @@ func (u ToolDoneUpdate) Type() UpdateType {
 }
 
 type TextUpdate struct {
-	Text string
+	Text          string `json:"text"`
+	CorrelationID string `json:"correlation_id,omitempty"`
 }
 
 func (u TextUpdate) Type() UpdateType {
 	return UpdateTypeText
 }
+
+type ThinkingUpdate struct {
+	Text          string `json:"text"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+func (u ThinkingUpdate) Type() UpdateType {
+	return UpdateTypeThinking
+}
+
+// AudioUpdate is a chunk of audio spoken by the model, in the format that was
+// requested from the provider. Its transcript is sent as text updates.
+type AudioUpdate struct {
+	Audio         []byte `json:"audio"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+func (u AudioUpdate) Type() UpdateType {
+	return UpdateTypeAudio
+}
+
+// RefusalUpdate is part of the explanation of a model that refuses to respond,
+// for example because the request goes against its safety policy. Refusals
+// aren't sent as text updates, since they don't follow the requested format.
+type RefusalUpdate struct {
+	Text          string `json:"text"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+func (u RefusalUpdate) Type() UpdateType {
+	return UpdateTypeRefusal
+}
@@ -0,0 +1,80 @@
+package llms
+
+// Usage describes what a single turn (one request to the provider) used.
+type Usage struct {
+	// CorrelationID is the correlation ID of the chat the turn was part of.
+	CorrelationID string
+
+	Company string
+	// Model is the model that served the response, which may be more specific
+	// than the model that was requested.
+	Model string
+
+	InputTokens  int
+	OutputTokens int
+	UsageDetails
+	// CostUSD is the cost of the turn based on the pricing of the model, or
+	// zero if the pricing is not known. See LookupPricing.
+	CostUSD float64
+
+	// PromptFingerprint identifies the full prompt (system prompt and message
+	// history) that was sent. See Fingerprint.
+	PromptFingerprint string
+	// SystemPromptFingerprint identifies just the system prompt, which is the
+	// most common candidate for a prompt caching breakpoint.
+	SystemPromptFingerprint string
+	// ResponseFingerprint identifies the message that was received.
+	ResponseFingerprint string
+}
+
+// UsageDetails breaks down the tokens of a turn, for the providers that report
+// it (see UsageDetailsStream).
+type UsageDetails struct {
+	// CachedInputTokens are the input tokens that were read from the prompt
+	// cache, which are included in the input tokens but cost less.
+	CachedInputTokens int
+	// CacheWriteInputTokens are the input tokens that were written to the
+	// prompt cache, which are included in the input tokens but may cost more.
+	CacheWriteInputTokens int
+	// ReasoningTokens are the output tokens that the model spent reasoning
+	// before it responded, which are included in the output tokens.
+	ReasoningTokens int
+}
+
+// WithUsageCallback makes the LLM call the provided function with the usage
+// of every turn, as soon as the turn is complete.
+func (l *LLM) WithUsageCallback(callback func(Usage)) *LLM {
+	l.usageCallback = callback
+	return l
+}
+
+// Usage returns the total number of tokens used by the LLM so far.
+func (l *LLM) Usage() (inputTokens, outputTokens int) {
+	return l.inputTokens, l.outputTokens
+}
+
+// UsageDetails returns the total breakdown of the tokens used by the LLM so
+// far.
+func (l *LLM) UsageDetails() UsageDetails {
+	return l.usageDetails
+}
+
+// CostUSD returns the total cost in USD of the LLM so far, for the models
+// with known pricing.
+func (l *LLM) CostUSD() float64 {
+	return l.costUSD
+}
+
+// reportUsage adds the usage of the turn to the totals and passes it to the
+// usage callback, if any.
+func (l *LLM) reportUsage(usage Usage) {
+	l.inputTokens += usage.InputTokens
+	l.outputTokens += usage.OutputTokens
+	l.usageDetails.CachedInputTokens += usage.CachedInputTokens
+	l.usageDetails.CacheWriteInputTokens += usage.CacheWriteInputTokens
+	l.usageDetails.ReasoningTokens += usage.ReasoningTokens
+	l.costUSD += usage.CostUSD
+	if l.usageCallback != nil {
+		l.usageCallback(usage)
+	}
+}
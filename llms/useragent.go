@@ -0,0 +1,60 @@
+package llms
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/blixt/go-llms"
+
+var userAgent struct {
+	mu  sync.RWMutex
+	app string
+}
+
+// SetUserAgent sets an identifier for the app, such as "my-app/1.2
+// (support@example.com)", which is added to the User-Agent header of all
+// requests made by the providers. Several providers ask for one to help with
+// support and abuse handling.
+func SetUserAgent(app string) {
+	userAgent.mu.Lock()
+	defer userAgent.mu.Unlock()
+	userAgent.app = app
+}
+
+// UserAgent returns the User-Agent header that providers send, which
+// identifies this library and its version, followed by the app identifier set
+// with SetUserAgent.
+func UserAgent() string {
+	userAgent.mu.RLock()
+	defer userAgent.mu.RUnlock()
+	if userAgent.app == "" {
+		return libraryAgent
+	}
+	return libraryAgent + " " + userAgent.app
+}
+
+var libraryAgent = "go-llms/" + moduleVersion()
+
+// moduleVersion returns the version of this module in the binary, or "dev" if
+// it's not known, as when running its own tests.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			if dep.Version != "" {
+				return dep.Version
+			}
+		}
+	}
+	return "dev"
+}
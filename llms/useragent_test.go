@@ -0,0 +1,14 @@
+package llms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserAgent(t *testing.T) {
+	t.Cleanup(func() { SetUserAgent("") })
+	assert.Equal(t, "go-llms/dev", UserAgent())
+	SetUserAgent("my-app/1.2")
+	assert.Equal(t, "go-llms/dev my-app/1.2", UserAgent())
+}
@@ -0,0 +1,164 @@
+package llms
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/blixt/go-llms/tools"
+)
+
+// HistoryValidation decides what happens when the message history that is
+// loaded from a history store breaks the invariants that providers expect.
+type HistoryValidation int
+
+const (
+	// ValidationReport makes the chat fail with an error that describes every
+	// problem with the history.
+	ValidationReport HistoryValidation = iota
+	// ValidationRepair repairs the history with RepairMessages and saves it
+	// before the chat continues.
+	ValidationRepair
+)
+
+// WithHistoryValidation makes the LLM check the message history it loads from
+// the history store with ValidateMessages, so that corrupted history is caught
+// before it's sent to the provider, which would likely reject it with a
+// confusing error.
+func (l *LLM) WithHistoryValidation(validation HistoryValidation) *LLM {
+	l.validateHistory = true
+	l.validation = validation
+	return l
+}
+
+// MessageError is a problem with the message at Index of a history.
+type MessageError struct {
+	Index int
+	Err   error
+}
+
+func (e *MessageError) Error() string {
+	return fmt.Sprintf("message %d: %v", e.Index, e.Err)
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateMessages checks that the messages form a history that providers
+// accept: roles are known, tool results follow the assistant message with the
+// matching tool call, every tool call gets exactly one result before the
+// conversation moves on, tool call IDs are unique, and assistant messages
+// don't directly follow each other. Tool calls without results are allowed at
+// the end, since that is how an interrupted turn looks. The returned error
+// joins a *MessageError for every problem.
+func ValidateMessages(messages []Message) error {
+	_, errs := checkMessages(messages)
+	return errors.Join(errs...)
+}
+
+// RepairMessages returns a copy of the messages with the problems reported by
+// ValidateMessages fixed, with as little change to the conversation as
+// possible: messages with unknown roles and tool results that don't match a
+// tool call are removed, tool calls without results get error results,
+// duplicate tool call IDs are replaced, and consecutive assistant messages
+// are merged. It also returns the problems that were fixed.
+func RepairMessages(messages []Message) ([]Message, error) {
+	repaired, errs := checkMessages(messages)
+	return repaired, errors.Join(errs...)
+}
+
+// openTurn is an assistant message with tool calls whose results are still
+// expected.
+type openTurn struct {
+	index    int
+	calls    []string
+	ids      map[string]string // Original ID to the ID in the repaired history.
+	answered map[string]bool
+}
+
+func checkMessages(messages []Message) (repaired []Message, errs []error) {
+	report := func(index int, format string, args ...any) {
+		errs = append(errs, &MessageError{Index: index, Err: fmt.Errorf(format, args...)})
+	}
+	seenIDs := make(map[string]bool)
+	var turn *openTurn
+	closeTurn := func() {
+		if turn == nil {
+			return
+		}
+		for _, id := range turn.calls {
+			if turn.answered[id] {
+				continue
+			}
+			report(turn.index, "tool call %q has no result", id)
+			repaired = append(repaired, Message{
+				Role:       "tool",
+				Content:    tools.Errorf("The tool call was interrupted and has no result.").Content(),
+				ToolCallID: id,
+			})
+		}
+		turn = nil
+	}
+
+	for i, msg := range messages {
+		switch msg.Role {
+		case "tool":
+			if turn == nil {
+				report(i, "tool result %q doesn't follow a tool call", msg.ToolCallID)
+				continue
+			}
+			id, ok := turn.ids[msg.ToolCallID]
+			if !ok {
+				report(i, "tool result for unknown tool call %q", msg.ToolCallID)
+				continue
+			}
+			if turn.answered[id] {
+				report(i, "duplicate tool result for tool call %q", msg.ToolCallID)
+				continue
+			}
+			turn.answered[id] = true
+			msg.ToolCallID = id
+			repaired = append(repaired, msg)
+		case "assistant":
+			closeTurn()
+			if n := len(repaired); n > 0 && repaired[n-1].Role == "assistant" {
+				// The previous assistant message has no tool calls, or the turn
+				// would have added their results after it.
+				report(i, "assistant message follows another assistant message")
+				prev := &repaired[n-1]
+				prev.Content = append(slices.Clone(prev.Content), msg.Content...)
+				prev.ToolCalls = msg.ToolCalls
+			} else {
+				repaired = append(repaired, msg)
+			}
+			target := &repaired[len(repaired)-1]
+			if len(target.ToolCalls) == 0 {
+				continue
+			}
+			target.ToolCalls = slices.Clone(target.ToolCalls)
+			turn = &openTurn{index: i, ids: make(map[string]string), answered: make(map[string]bool)}
+			for j, call := range target.ToolCalls {
+				id := call.ID
+				switch {
+				case id == "":
+					report(i, "tool call %q has no ID", call.Name)
+					id = fmt.Sprintf("call_repaired_%d_%d", i, j)
+				case seenIDs[id]:
+					report(i, "duplicate tool call ID %q", id)
+					id = fmt.Sprintf("call_repaired_%d_%d", i, j)
+				}
+				seenIDs[id] = true
+				target.ToolCalls[j].ID = id
+				turn.ids[call.ID] = id
+				turn.calls = append(turn.calls, id)
+			}
+		case "user", "system":
+			closeTurn()
+			repaired = append(repaired, msg)
+		default:
+			report(i, "unknown role %q", msg.Role)
+		}
+	}
+	return repaired, errs
+}
@@ -0,0 +1,93 @@
+package llms
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMessages(t *testing.T) {
+	valid := []Message{
+		{Role: "user", Content: content.FromText("Hi")},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "a", Name: "test_tool"}, {ID: "b", Name: "test_tool"}}},
+		{Role: "tool", ToolCallID: "b", Content: content.FromText("B")},
+		{Role: "tool", ToolCallID: "a", Content: content.FromText("A")},
+		{Role: "assistant", Content: content.FromText("Done")},
+		{Role: "user", Content: content.FromText("Again")},
+		// An interrupted turn is valid.
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "c", Name: "test_tool"}}},
+	}
+	require.NoError(t, ValidateMessages(valid))
+	repaired, err := RepairMessages(valid)
+	require.NoError(t, err)
+	assert.Equal(t, valid, repaired)
+
+	invalid := []Message{
+		{Role: "tool", ToolCallID: "x", Content: content.FromText("X")},
+		{Role: "user", Content: content.FromText("Hi")},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "a", Name: "test_tool"}, {ID: "b", Name: "test_tool"}}},
+		{Role: "tool", ToolCallID: "a", Content: content.FromText("A")},
+		{Role: "tool", ToolCallID: "a", Content: content.FromText("A again")},
+		{Role: "tool", ToolCallID: "z", Content: content.FromText("Z")},
+		{Role: "robot", Content: content.FromText("Beep")},
+		{Role: "assistant", Content: content.FromText("Let me check.")},
+		{Role: "assistant", Content: content.FromText(" Again."), ToolCalls: []ToolCall{{ID: "a", Name: "test_tool"}}},
+		{Role: "tool", ToolCallID: "a", Content: content.FromText("A2")},
+		{Role: "assistant", Content: content.FromText("Done")},
+	}
+	err = ValidateMessages(invalid)
+	require.Error(t, err)
+	var messageErr *MessageError
+	require.True(t, errors.As(err, &messageErr))
+	assert.Equal(t, 0, messageErr.Index)
+	assert.Equal(t, `message 0: tool result "x" doesn't follow a tool call
+message 4: duplicate tool result for tool call "a"
+message 5: tool result for unknown tool call "z"
+message 6: unknown role "robot"
+message 2: tool call "b" has no result
+message 8: assistant message follows another assistant message
+message 8: duplicate tool call ID "a"`, err.Error())
+
+	repaired, err = RepairMessages(invalid)
+	require.Error(t, err)
+	require.NoError(t, ValidateMessages(repaired))
+	require.Len(t, repaired, 7)
+	assert.Equal(t, "b", repaired[3].ToolCallID)
+	assert.Equal(t, "tool", repaired[3].Role)
+	assert.Equal(t, append(content.FromText("Let me check."), content.FromText(" Again.")...), repaired[4].Content)
+	assert.Equal(t, "call_repaired_8_0", repaired[4].ToolCalls[0].ID)
+	assert.Equal(t, "call_repaired_8_0", repaired[5].ToolCallID)
+	assert.Equal(t, content.FromText("A2"), repaired[5].Content)
+	// The original messages are left alone.
+	assert.Equal(t, "a", invalid[8].ToolCalls[0].ID)
+	assert.Equal(t, content.FromText("Let me check."), invalid[7].Content)
+}
+
+func TestHistoryValidation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	corrupted := []Message{
+		{Role: "user", Content: content.FromText("Hi")},
+		{Role: "tool", ToolCallID: "x", Content: content.FromText("X")},
+		{Role: "assistant", Content: content.FromText("Hello")},
+	}
+
+	store := &testHistoryStore{conversations: map[string][]Message{"conv": corrupted}}
+	provider := &mockProvider{}
+	llm := New(provider).WithHistoryStore(store, "conv").WithHistoryValidation(ValidationReport)
+	runTestChat(ctx, t, llm, "Test message")
+	require.ErrorContains(t, llm.Err(), `invalid history of conversation "conv": message 1: tool result "x" doesn't follow a tool call`)
+	assert.False(t, provider.generateCalled)
+
+	llm = New(provider).WithHistoryStore(store, "conv").WithHistoryValidation(ValidationRepair)
+	runTestChat(ctx, t, llm, "Test message")
+	require.NoError(t, llm.Err())
+	require.Len(t, provider.messages, 3)
+	assert.Equal(t, []string{"user", "assistant", "user"}, []string{provider.messages[0].Role, provider.messages[1].Role, provider.messages[2].Role})
+	require.NoError(t, ValidateMessages(store.conversations["conv"]))
+}
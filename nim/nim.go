@@ -0,0 +1,33 @@
+// Package nim provides access to models served by NVIDIA NIM, both hosted on
+// build.nvidia.com and self-hosted. NIM serves an OpenAI-compatible API, so
+// this package configures the openai provider for it.
+//
+// NIM model names are namespaced by publisher, for example
+// "meta/llama-3.3-70b-instruct" or "nvidia/llama-3.1-nemotron-70b-instruct".
+package nim
+
+import (
+	"strings"
+
+	"github.com/blixt/go-llms/openai"
+)
+
+const endpoint = "https://integrate.api.nvidia.com/v1/chat/completions"
+
+// New returns a provider for the given model hosted by NVIDIA. The API key
+// (which starts with "nvapi-") can be created on build.nvidia.com.
+func New(apiKey, model string) *openai.Model {
+	return openai.New(apiKey, model).
+		WithEndpoint(endpoint, "NVIDIA").
+		// The hosted API only streams when asked to with the Accept header.
+		WithHeader("Accept", "text/event-stream")
+}
+
+// NewSelfHosted returns a provider for a NIM container running at the given
+// base URL, for example "http://localhost:8000". Self-hosted NIMs don't need
+// an API key unless they're behind a gateway that does.
+func NewSelfHosted(baseURL, model string) *openai.Model {
+	return openai.New("", model).
+		WithEndpoint(strings.TrimSuffix(baseURL, "/")+"/v1/chat/completions", "NVIDIA").
+		WithHeader("Accept", "text/event-stream")
+}
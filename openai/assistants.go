@@ -0,0 +1,275 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Assistant runs a conversation on a thread of the Assistants API, with an
+// existing assistant. Tool calls are run with the given tools, and progress is
+// reported with the same updates as llms.LLM. Like llms.LLM, it is NOT thread
+// safe.
+//
+// Deprecated: OpenAI has deprecated the Assistants API in favor of the
+// Responses API, and plans to shut it down. It's only kept for applications
+// that still have assistants and threads to migrate. New code should use
+// llms.New with a model from New, keeping conversations with a history store.
+type Assistant struct {
+	accessToken  string
+	assistantID  string
+	endpoint     string
+	toolbox      *tools.Toolbox
+	threadID     string
+	pollInterval time.Duration
+	err          error
+}
+
+// NewAssistant returns a conversation with the assistant with the given ID. If
+// tools are provided, they replace the tools configured for the assistant.
+//
+// Deprecated: The Assistants API is deprecated by OpenAI. See Assistant.
+func NewAssistant(accessToken, assistantID string, allTools ...tools.Tool) *Assistant {
+	var toolbox *tools.Toolbox
+	if len(allTools) > 0 {
+		toolbox = tools.Box(allTools...)
+	}
+	return &Assistant{
+		accessToken:  accessToken,
+		assistantID:  assistantID,
+		endpoint:     "https://api.openai.com/v1",
+		toolbox:      toolbox,
+		pollInterval: 500 * time.Millisecond,
+	}
+}
+
+// WithEndpoint sets the base URL of the API.
+func (a *Assistant) WithEndpoint(endpoint string) *Assistant {
+	a.endpoint = strings.TrimSuffix(endpoint, "/")
+	return a
+}
+
+// WithThread continues the conversation on an existing thread. By default a
+// new thread is created by the first chat.
+func (a *Assistant) WithThread(threadID string) *Assistant {
+	a.threadID = threadID
+	return a
+}
+
+// WithPollInterval sets how often the state of a run is checked. The default
+// is 500 milliseconds.
+func (a *Assistant) WithPollInterval(interval time.Duration) *Assistant {
+	a.pollInterval = interval
+	return a
+}
+
+// ThreadID returns the ID of the thread of the conversation, which is empty
+// until the first chat has started.
+func (a *Assistant) ThreadID() string {
+	return a.threadID
+}
+
+// Err returns the error of the last chat, if any.
+func (a *Assistant) Err() error {
+	return a.err
+}
+
+// Chat adds a message to the thread and runs the assistant on it. Updates are
+// sent over the returned channel, which is closed when the run is done.
+func (a *Assistant) Chat(ctx context.Context, message string) <-chan llms.Update {
+	a.err = nil
+	updateChan := make(chan llms.Update)
+	go func() {
+		defer close(updateChan)
+		a.err = a.run(ctx, message, updateChan)
+	}()
+	return updateChan
+}
+
+type assistantRun struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	RequiredAction *struct {
+		SubmitToolOutputs struct {
+			ToolCalls []toolCall `json:"tool_calls"`
+		} `json:"submit_tool_outputs"`
+	} `json:"required_action"`
+	LastError *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"last_error"`
+}
+
+func (a *Assistant) run(ctx context.Context, message string, updateChan chan<- llms.Update) error {
+	if a.threadID == "" {
+		var thread struct {
+			ID string `json:"id"`
+		}
+		if err := a.do(ctx, "POST", "/threads", map[string]any{}, &thread); err != nil {
+			return fmt.Errorf("error creating thread: %w", err)
+		}
+		a.threadID = thread.ID
+	}
+	threadPath := "/threads/" + a.threadID
+	if err := a.do(ctx, "POST", threadPath+"/messages", map[string]any{"role": "user", "content": message}, nil); err != nil {
+		return fmt.Errorf("error adding message: %w", err)
+	}
+
+	payload := map[string]any{"assistant_id": a.assistantID}
+	if a.toolbox != nil {
+		payload["tools"] = Tools(a.toolbox)
+	}
+	var run assistantRun
+	if err := a.do(ctx, "POST", threadPath+"/runs", payload, &run); err != nil {
+		return fmt.Errorf("error creating run: %w", err)
+	}
+	runPath := threadPath + "/runs/" + run.ID
+	for {
+		switch run.Status {
+		case "completed":
+			return a.sendMessages(ctx, run.ID, updateChan)
+		case "requires_action":
+			if run.RequiredAction == nil {
+				return fmt.Errorf("run requires an unsupported action")
+			}
+			var outputs []map[string]string
+			for _, tc := range run.RequiredAction.SubmitToolOutputs.ToolCalls {
+				output := a.runToolCall(ctx, tc.ToLLM(), updateChan)
+				outputs = append(outputs, map[string]string{"tool_call_id": tc.ID, "output": output})
+			}
+			if err := a.do(ctx, "POST", runPath+"/submit_tool_outputs", map[string]any{"tool_outputs": outputs}, &run); err != nil {
+				return fmt.Errorf("error submitting tool outputs: %w", err)
+			}
+			continue
+		case "failed", "cancelled", "expired", "incomplete":
+			if run.LastError != nil {
+				return fmt.Errorf("run %s: %s: %s", run.Status, run.LastError.Code, run.LastError.Message)
+			}
+			return fmt.Errorf("run %s", run.Status)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.pollInterval):
+		}
+		if err := a.do(ctx, "GET", runPath, nil, &run); err != nil {
+			return fmt.Errorf("error getting run: %w", err)
+		}
+	}
+}
+
+// sendMessages sends the text of the messages that the run added to the
+// thread as text updates.
+func (a *Assistant) sendMessages(ctx context.Context, runID string, updateChan chan<- llms.Update) error {
+	var messages struct {
+		Data []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type string `json:"type"`
+				Text struct {
+					Value string `json:"value"`
+				} `json:"text"`
+			} `json:"content"`
+		} `json:"data"`
+	}
+	path := fmt.Sprintf("/threads/%s/messages?run_id=%s&order=asc", a.threadID, runID)
+	if err := a.do(ctx, "GET", path, nil, &messages); err != nil {
+		return fmt.Errorf("error listing messages: %w", err)
+	}
+	correlationID := llms.GetCorrelationID(ctx)
+	for _, msg := range messages.Data {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, c := range msg.Content {
+			if c.Type != "text" || c.Text.Value == "" {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case updateChan <- llms.TextUpdate{Text: c.Text.Value, CorrelationID: correlationID}:
+			}
+		}
+	}
+	return nil
+}
+
+// runToolCall runs the tool call like llms.LLM does, and returns its result as
+// the text that the Assistants API expects.
+func (a *Assistant) runToolCall(ctx context.Context, toolCall llms.ToolCall, updateChan chan<- llms.Update) string {
+	correlationID := llms.GetCorrelationID(ctx)
+	if a.toolbox == nil {
+		return fmt.Sprintf("Tool %q is not available.", toolCall.Name)
+	}
+	t := a.toolbox.Get(toolCall.Name)
+	if t != nil {
+		updateChan <- llms.ToolStartUpdate{ToolCallID: toolCall.ID, Tool: t, CorrelationID: correlationID}
+	}
+	accepted := []content.Type{content.TypeText, content.TypeJSON}
+	ctxWithValue := context.WithValue(ctx, llms.ToolCallContextKey, toolCall)
+	if t != nil {
+		ctxWithValue = tools.ContextWithResultTypes(ctxWithValue, tools.NegotiateResultTypes(t, accepted))
+	}
+	runner := tools.NewRunner(ctxWithValue, a.toolbox, func(status string) {
+		updateChan <- llms.ToolStatusUpdate{ToolCallID: toolCall.ID, Status: status, Tool: t, CorrelationID: correlationID}
+	})
+	result := a.toolbox.Run(runner, toolCall.Name, json.RawMessage(toolCall.Arguments))
+	updateChan <- llms.ToolDoneUpdate{ToolCallID: toolCall.ID, Result: result, Tool: t, CorrelationID: correlationID}
+
+	var parts []string
+	for _, item := range tools.ConvertContent(result.Content(), accepted) {
+		switch v := item.(type) {
+		case *content.Text:
+			parts = append(parts, v.Text)
+		case *content.JSON:
+			parts = append(parts, string(v.Data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// do sends a request to the Assistants API and decodes the response into v,
+// unless it's nil.
+func (a *Assistant) do(ctx context.Context, method, path string, payload, v any) error {
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error encoding JSON: %w", err)
+		}
+		body = bytes.NewReader(jsonData)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.endpoint+path, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.accessToken))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("OpenAI-Beta", "assistants=v2")
+	req.Header.Set("User-Agent", llms.UserAgent())
+	if id := llms.GetCorrelationID(ctx); id != "" {
+		req.Header.Set("X-Client-Request-Id", id)
+	}
+	resp, err := llms.HTTPClientFromContext(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
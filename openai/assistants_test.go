@@ -0,0 +1,77 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssistant(t *testing.T) {
+	var submitted []map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/threads", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "assistants=v2", r.Header.Get("OpenAI-Beta"))
+		w.Write([]byte(`{"id":"thread_1"}`))
+	})
+	mux.HandleFunc("POST /v1/threads/thread_1/messages", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "What's the weather in Paris?", payload["content"])
+		w.Write([]byte(`{"id":"msg_1"}`))
+	})
+	mux.HandleFunc("POST /v1/threads/thread_1/runs", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "asst_1", payload["assistant_id"])
+		assert.Len(t, payload["tools"], 1)
+		w.Write([]byte(`{"id":"run_1","status":"requires_action","required_action":{"type":"submit_tool_outputs","submit_tool_outputs":{"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}}`))
+	})
+	mux.HandleFunc("POST /v1/threads/thread_1/runs/run_1/submit_tool_outputs", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ToolOutputs []map[string]string `json:"tool_outputs"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		submitted = payload.ToolOutputs
+		w.Write([]byte(`{"id":"run_1","status":"queued"}`))
+	})
+	mux.HandleFunc("GET /v1/threads/thread_1/runs/run_1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"run_1","status":"completed"}`))
+	})
+	mux.HandleFunc("GET /v1/threads/thread_1/messages", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "run_1", r.URL.Query().Get("run_id"))
+		w.Write([]byte(`{"data":[{"role":"assistant","content":[{"type":"text","text":{"value":"It's sunny in Paris."}}]}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	type weatherParams struct {
+		City string `json:"city"`
+	}
+	weather := tools.Func("Weather", "Gets the weather", "get_weather", func(r tools.Runner, p weatherParams) tools.Result {
+		return tools.Success(map[string]string{"city": p.City, "weather": "sunny"})
+	})
+	assistant := NewAssistant("key", "asst_1", weather).WithEndpoint(server.URL + "/v1").WithPollInterval(time.Millisecond)
+	var types []llms.UpdateType
+	var text string
+	for update := range assistant.Chat(context.Background(), "What's the weather in Paris?") {
+		types = append(types, update.Type())
+		if u, ok := update.(llms.TextUpdate); ok {
+			text += u.Text
+		}
+	}
+	require.NoError(t, assistant.Err())
+	assert.Equal(t, "thread_1", assistant.ThreadID())
+	assert.Equal(t, []llms.UpdateType{llms.UpdateTypeToolStart, llms.UpdateTypeToolDone, llms.UpdateTypeText}, types)
+	assert.Equal(t, "It's sunny in Paris.", text)
+	require.Len(t, submitted, 1)
+	assert.Equal(t, "call_1", submitted[0]["tool_call_id"])
+	assert.JSONEq(t, `{"city":"Paris","weather":"sunny"}`, submitted[0]["output"])
+}
@@ -0,0 +1,271 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// BatchRequest is one chat completion request in a batch. It's sent with the
+// settings of the model that submits the batch, like Generate.
+type BatchRequest struct {
+	// CustomID identifies the request in the results. It must be unique
+	// within the batch.
+	CustomID     string
+	SystemPrompt content.Content
+	Messages     []llms.Message
+	Toolbox      *tools.Toolbox
+}
+
+// Batch is the state of a batch in the Batch API.
+type Batch struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	OutputFileID string `json:"output_file_id"`
+	ErrorFileID  string `json:"error_file_id"`
+	// RequestCounts tracks the progress of the batch.
+	RequestCounts struct {
+		Total     int `json:"total"`
+		Completed int `json:"completed"`
+		Failed    int `json:"failed"`
+	} `json:"request_counts"`
+}
+
+// Done reports whether the batch has stopped processing, successfully or not.
+func (b *Batch) Done() bool {
+	switch b.Status {
+	case "completed", "failed", "expired", "cancelled":
+		return true
+	}
+	return false
+}
+
+// BatchResult is the response to one request of a batch.
+type BatchResult struct {
+	CustomID string
+	// Message is the message of the assistant, if the request succeeded.
+	Message                   llms.Message
+	InputTokens, OutputTokens int
+	// Err is set if the request failed.
+	Err error
+}
+
+// SubmitBatch uploads the requests and starts processing them with the Batch
+// API, which costs half as much as regular requests but can take up to 24
+// hours. Use WaitForBatch to wait for the results.
+func (m *Model) SubmitBatch(ctx context.Context, requests []BatchRequest) (*Batch, error) {
+	var jsonl bytes.Buffer
+	encoder := json.NewEncoder(&jsonl)
+	for _, r := range requests {
+		line := map[string]any{
+			"custom_id": r.CustomID,
+			"method":    "POST",
+			"url":       "/v1/chat/completions",
+			"body":      m.requestBody(ctx, r.SystemPrompt, r.Messages, r.Toolbox, false),
+		}
+		if err := encoder.Encode(line); err != nil {
+			return nil, fmt.Errorf("error encoding request %q: %w", r.CustomID, err)
+		}
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("purpose", "batch")
+	part, err := form.CreateFormFile("file", "batch.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("error creating form: %w", err)
+	}
+	part.Write(jsonl.Bytes())
+	form.Close()
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := m.batchRequest(ctx, "POST", "/files", form.FormDataContentType(), &body, &file); err != nil {
+		return nil, fmt.Errorf("error uploading batch: %w", err)
+	}
+
+	payload, err := json.Marshal(map[string]any{
+		"input_file_id":     file.ID,
+		"endpoint":          "/v1/chat/completions",
+		"completion_window": "24h",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %w", err)
+	}
+	var batch Batch
+	if err := m.batchRequest(ctx, "POST", "/batches", "application/json", bytes.NewReader(payload), &batch); err != nil {
+		return nil, fmt.Errorf("error creating batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// GetBatch returns the current state of the batch.
+func (m *Model) GetBatch(ctx context.Context, id string) (*Batch, error) {
+	var batch Batch
+	if err := m.batchRequest(ctx, "GET", "/batches/"+id, "", nil, &batch); err != nil {
+		return nil, fmt.Errorf("error getting batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// WaitForBatch polls the batch at the given interval until it's done, and then
+// returns its results in no particular order. An error is returned if the
+// batch failed, expired or was cancelled before any requests completed.
+func (m *Model) WaitForBatch(ctx context.Context, id string, interval time.Duration) ([]BatchResult, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		batch, err := m.GetBatch(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if batch.Done() {
+			return m.BatchResults(ctx, batch)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// BatchResults downloads the results of a batch that is done.
+func (m *Model) BatchResults(ctx context.Context, batch *Batch) ([]BatchResult, error) {
+	if batch.OutputFileID == "" && batch.ErrorFileID == "" {
+		return nil, fmt.Errorf("batch %s is %s and has no results", batch.ID, batch.Status)
+	}
+	var results []BatchResult
+	for _, fileID := range []string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		var data bytes.Buffer
+		if err := m.batchRequest(ctx, "GET", "/files/"+fileID+"/content", "", nil, &data); err != nil {
+			return nil, fmt.Errorf("error downloading results: %w", err)
+		}
+		scanner := bufio.NewScanner(&data)
+		scanner.Buffer(nil, 64<<20)
+		for scanner.Scan() {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			result, err := decodeBatchResult(scanner.Bytes())
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("error reading results: %w", err)
+		}
+	}
+	return results, nil
+}
+
+func decodeBatchResult(line []byte) (BatchResult, error) {
+	var output struct {
+		CustomID string `json:"custom_id"`
+		Response *struct {
+			StatusCode int `json:"status_code"`
+			Body       struct {
+				Choices []struct {
+					Message struct {
+						Role      string     `json:"role"`
+						Content   *string    `json:"content"`
+						Refusal   *string    `json:"refusal"`
+						ToolCalls []toolCall `json:"tool_calls"`
+					} `json:"message"`
+				} `json:"choices"`
+				Usage usage `json:"usage"`
+				Error *struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"body"`
+		} `json:"response"`
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(line, &output); err != nil {
+		return BatchResult{}, fmt.Errorf("error decoding result: %w", err)
+	}
+	result := BatchResult{CustomID: output.CustomID}
+	switch {
+	case output.Error != nil:
+		result.Err = fmt.Errorf("%s: %s", output.Error.Code, output.Error.Message)
+	case output.Response == nil:
+		result.Err = fmt.Errorf("no response")
+	case output.Response.StatusCode != http.StatusOK:
+		result.Err = fmt.Errorf("%d %s", output.Response.StatusCode, http.StatusText(output.Response.StatusCode))
+		if e := output.Response.Body.Error; e != nil {
+			result.Err = fmt.Errorf("%w: %s", result.Err, e.Message)
+		}
+	case len(output.Response.Body.Choices) == 0:
+		result.Err = fmt.Errorf("response has no choices")
+	default:
+		body := output.Response.Body
+		msg := body.Choices[0].Message
+		result.Message.Role = msg.Role
+		if msg.Content != nil && *msg.Content != "" {
+			result.Message.Content.Append(*msg.Content)
+		}
+		if msg.Refusal != nil {
+			result.Message.Refusal = *msg.Refusal
+		}
+		for _, tc := range msg.ToolCalls {
+			result.Message.ToolCalls = append(result.Message.ToolCalls, tc.ToLLM())
+		}
+		result.InputTokens, result.OutputTokens = body.Usage.PromptTokens, body.Usage.CompletionTokens
+	}
+	return result, nil
+}
+
+// batchRequest sends a request to the files or batches API, which lives next
+// to the chat completions endpoint, and decodes the JSON response into v, or
+// copies the response into v if it's a buffer.
+func (m *Model) batchRequest(ctx context.Context, method, path, contentType string, body io.Reader, v any) error {
+	base, ok := strings.CutSuffix(m.endpoint, "/chat/completions")
+	if !ok {
+		return fmt.Errorf("endpoint %q doesn't support the Files and Batch APIs", m.endpoint)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, body)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if m.accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.accessToken))
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("User-Agent", llms.UserAgent())
+	for key, values := range m.headers {
+		req.Header[key] = values
+	}
+	resp, err := m.client(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+	if buf, ok := v.(*bytes.Buffer); ok {
+		_, err = buf.ReadFrom(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
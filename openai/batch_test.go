@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	var uploaded []map[string]any
+	var polls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/files", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "batch", r.FormValue("purpose"))
+		file, _, err := r.FormFile("file")
+		require.NoError(t, err)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			uploaded = append(uploaded, line)
+		}
+		w.Write([]byte(`{"id":"file-in"}`))
+	})
+	mux.HandleFunc("POST /v1/batches", func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		assert.Equal(t, "file-in", payload["input_file_id"])
+		assert.Equal(t, "/v1/chat/completions", payload["endpoint"])
+		w.Write([]byte(`{"id":"batch-1","status":"validating"}`))
+	})
+	mux.HandleFunc("GET /v1/batches/batch-1", func(w http.ResponseWriter, r *http.Request) {
+		if polls++; polls < 2 {
+			w.Write([]byte(`{"id":"batch-1","status":"in_progress"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"batch-1","status":"completed","output_file_id":"file-out","error_file_id":"file-err"}`))
+	})
+	mux.HandleFunc("GET /v1/files/file-out/content", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"custom_id":"a","response":{"status_code":200,"body":{"choices":[{"message":{"role":"assistant","content":"Paris"}}],"usage":{"prompt_tokens":12,"completion_tokens":1}}}}`+"\n")
+		io.WriteString(w, `{"custom_id":"b","response":{"status_code":200,"body":{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{}"}}]}}]}}}`+"\n")
+	})
+	mux.HandleFunc("GET /v1/files/file-err/content", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"custom_id":"c","response":{"status_code":400,"body":{"error":{"message":"Invalid model"}}}}`+"\n")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	m := New("key", "gpt-4.1").WithEndpoint(server.URL+"/v1/chat/completions", "Test").WithTemperature(0)
+	user := func(text string) []llms.Message {
+		return []llms.Message{{Role: "user", Content: content.FromText(text)}}
+	}
+	batch, err := m.SubmitBatch(context.Background(), []BatchRequest{
+		{CustomID: "a", SystemPrompt: content.FromText("Be brief."), Messages: user("Capital of France?")},
+		{CustomID: "b", Messages: user("Look it up")},
+		{CustomID: "c", Messages: user("Hi")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "batch-1", batch.ID)
+	require.Len(t, uploaded, 3)
+	assert.Equal(t, "a", uploaded[0]["custom_id"])
+	assert.Equal(t, "/v1/chat/completions", uploaded[0]["url"])
+	body := uploaded[0]["body"].(map[string]any)
+	assert.Equal(t, "gpt-4.1", body["model"])
+	assert.Equal(t, 0.0, body["temperature"])
+	assert.NotContains(t, body, "stream")
+	assert.Len(t, body["messages"], 2)
+
+	results, err := m.WaitForBatch(context.Background(), batch.ID, time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "a", results[0].CustomID)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, llms.Message{Role: "assistant", Content: content.FromText("Paris")}, results[0].Message)
+	assert.Equal(t, 12, results[0].InputTokens)
+	assert.Equal(t, 1, results[0].OutputTokens)
+	require.Len(t, results[1].Message.ToolCalls, 1)
+	assert.Equal(t, "lookup", results[1].Message.ToolCalls[0].Name)
+	assert.Empty(t, results[1].Message.Content)
+	assert.EqualError(t, results[2].Err, "400 Bad Request: Invalid model")
+}
@@ -0,0 +1,51 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// chatCompletion is a response to a chat completion request that wasn't
+// streamed. Its choices have the same fields as the deltas of a stream.
+type chatCompletion struct {
+	chatCompletionChunk
+	Choices []struct {
+		Index        int                 `json:"index"`
+		Message      chatCompletionDelta `json:"message"`
+		FinishReason *string             `json:"finish_reason"`
+		Logprobs     *choiceLogprobs     `json:"logprobs"`
+	} `json:"choices"`
+}
+
+// completionEvents reads a response that wasn't streamed and returns it as
+// the server-sent events of a stream with a single chunk, so that it is
+// processed like a streamed response.
+func completionEvents(r io.Reader) (io.Reader, error) {
+	var completion chatCompletion
+	if err := json.NewDecoder(r).Decode(&completion); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	chunk := completion.chatCompletionChunk
+	chunk.Choices = nil
+	for _, c := range completion.Choices {
+		// Tool calls of a complete message aren't numbered.
+		for i := range c.Message.ToolCalls {
+			c.Message.ToolCalls[i].Index = i
+		}
+		chunk.Choices = append(chunk.Choices, chatCompletionChoice{
+			Index:        c.Index,
+			Delta:        c.Message,
+			FinishReason: c.FinishReason,
+			Logprobs:     c.Logprobs,
+		})
+	}
+	data, err := json.Marshal(chunk)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %w", err)
+	}
+	var events bytes.Buffer
+	fmt.Fprintf(&events, "data: %s\n\ndata: [DONE]\n\n", data)
+	return &events, nil
+}
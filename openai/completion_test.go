@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateWithoutStreaming(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"id": "chatcmpl-1",
+			"object": "chat.completion",
+			"model": "gpt-4.1-2025-04-14",
+			"choices": [{
+				"index": 0,
+				"message": {
+					"role": "assistant",
+					"content": "Let me look that up.",
+					"tool_calls": [
+						{"id": "call_1", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"a\"}"}},
+						{"id": "call_2", "type": "function", "function": {"name": "lookup", "arguments": "{\"q\":\"b\"}"}}
+					]
+				},
+				"finish_reason": "tool_calls"
+			}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 30, "total_tokens": 42}
+		}`))
+	}))
+	defer server.Close()
+
+	m := New("key", "gpt-4.1").WithEndpoint(server.URL, "Test").WithStreaming(false)
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil).(*Stream)
+	var statuses []llms.StreamStatus
+	var ready []string
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+		if status == llms.StreamStatusToolCallReady {
+			ready = append(ready, stream.ToolCall().ID+" "+string(stream.ToolCall().Arguments))
+		}
+	}
+	require.NoError(t, stream.Err())
+	assert.NotContains(t, payload, "stream")
+	assert.NotContains(t, payload, "stream_options")
+	assert.Equal(t, []llms.StreamStatus{
+		llms.StreamStatusText,
+		llms.StreamStatusToolCallBegin, llms.StreamStatusToolCallBegin,
+		llms.StreamStatusToolCallReady, llms.StreamStatusToolCallReady,
+	}, statuses)
+	assert.Equal(t, []string{`call_1 {"q":"a"}`, `call_2 {"q":"b"}`}, ready)
+	assert.Equal(t, content.FromText("Let me look that up."), stream.Message().Content)
+	assert.Equal(t, "gpt-4.1-2025-04-14", stream.Model())
+	assert.Equal(t, llms.FinishReasonToolCalls, stream.FinishReason())
+	inputTokens, outputTokens := stream.Usage()
+	assert.Equal(t, 12, inputTokens)
+	assert.Equal(t, 30, outputTokens)
+}
@@ -0,0 +1,96 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// APIError is returned for requests that the API responded to with an error
+// status. Use errors.As to get it from the error of a stream.
+type APIError struct {
+	// StatusCode and Status are the HTTP status of the response, such as 429
+	// and "429 Too Many Requests".
+	StatusCode int
+	Status     string
+	// Type, Code, Message and Param are from the error in the response body,
+	// if it had one. Param names the request parameter the error is about.
+	Type    string
+	Code    string
+	Message string
+	Param   string
+	// RetryAfter is how long the API asked to wait before trying again, or 0
+	// if it didn't say.
+	RetryAfter time.Duration
+	// RateLimits are the rate limits reported with the response, if
+	// HasRateLimits is true.
+	RateLimits    llms.RateLimits
+	HasRateLimits bool
+}
+
+// HTTPStatus returns the status code of the response, so that APIError
+// implements llms.HTTPError.
+func (e *APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+// RetryDelay returns RetryAfter, so that APIError implements llms.HTTPError.
+func (e *APIError) RetryDelay() time.Duration {
+	return e.RetryAfter
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Status, e.Type, e.Message)
+}
+
+// newAPIError reads the error from a response with an error status.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header),
+	}
+	apiErr.RateLimits, apiErr.HasRateLimits = llms.ParseRateLimits(resp.Header)
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Param   string `json:"param"`
+			// Code is usually a string, but some compatible APIs use numbers.
+			Code any `json:"code"`
+		} `json:"error"`
+	}
+	if data, err := io.ReadAll(resp.Body); err == nil && json.Unmarshal(data, &body) == nil {
+		apiErr.Message = body.Error.Message
+		apiErr.Type = body.Error.Type
+		apiErr.Param = body.Error.Param
+		if body.Error.Code != nil {
+			apiErr.Code = fmt.Sprint(body.Error.Code)
+		}
+	}
+	return apiErr
+}
+
+// parseRetryAfter reads the retry-after-ms header that OpenAI sends, or the
+// standard Retry-After header as seconds or a date.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(t))
+	}
+	return 0
+}
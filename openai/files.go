@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// UploadedFile is a file stored with the Files API.
+type UploadedFile struct {
+	ID        string `json:"id"`
+	Filename  string `json:"filename"`
+	Bytes     int    `json:"bytes"`
+	Purpose   string `json:"purpose"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Content returns a content item that references the file, so that it can be
+// added to a message without sending the file again.
+func (f *UploadedFile) Content() content.Content {
+	return content.FromFile(f.ID)
+}
+
+// UploadFile uploads the file with the Files API, streaming it from the
+// reader. Files for use in messages, such as PDFs for document questions,
+// should have the purpose "user_data", which is the default.
+func (m *Model) UploadFile(ctx context.Context, filename string, r io.Reader, purpose string) (*UploadedFile, error) {
+	if purpose == "" {
+		purpose = "user_data"
+	}
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		if err := form.WriteField("purpose", purpose); err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		part, err := form.CreateFormFile("file", filename)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, r); err != nil {
+			writer.CloseWithError(fmt.Errorf("error reading file: %w", err))
+			return
+		}
+		writer.CloseWithError(form.Close())
+	}()
+	defer body.Close()
+	var file UploadedFile
+	if err := m.batchRequest(ctx, "POST", "/files", form.FormDataContentType(), body, &file); err != nil {
+		return nil, fmt.Errorf("error uploading file: %w", err)
+	}
+	return &file, nil
+}
+
+// GetFile returns information about an uploaded file.
+func (m *Model) GetFile(ctx context.Context, id string) (*UploadedFile, error) {
+	var file UploadedFile
+	if err := m.batchRequest(ctx, "GET", "/files/"+id, "", nil, &file); err != nil {
+		return nil, fmt.Errorf("error getting file: %w", err)
+	}
+	return &file, nil
+}
+
+// DeleteFile deletes an uploaded file.
+func (m *Model) DeleteFile(ctx context.Context, id string) error {
+	var result struct {
+		Deleted bool `json:"deleted"`
+	}
+	if err := m.batchRequest(ctx, "DELETE", "/files/"+id, "", nil, &result); err != nil {
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+	if !result.Deleted {
+		return fmt.Errorf("file %s was not deleted", id)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/files", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.Equal(t, "user_data", r.FormValue("purpose"))
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		data, _ := io.ReadAll(file)
+		assert.Equal(t, "report.pdf", header.Filename)
+		assert.Equal(t, "%PDF-1.7", string(data))
+		w.Write([]byte(`{"id":"file-abc","filename":"report.pdf","bytes":8,"purpose":"user_data","created_at":1700000000}`))
+	})
+	mux.HandleFunc("GET /v1/files/file-abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"file-abc","filename":"report.pdf","bytes":8,"purpose":"user_data"}`))
+	})
+	mux.HandleFunc("DELETE /v1/files/file-abc", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"file-abc","deleted":true}`))
+	})
+	mux.HandleFunc("GET /v1/files/file-missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"message":"No such File object: file-missing","type":"invalid_request_error"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	m := New("key", "gpt-4o").WithEndpoint(server.URL+"/v1/chat/completions", "Test")
+	ctx := context.Background()
+	uploaded, err := m.UploadFile(ctx, "report.pdf", strings.NewReader("%PDF-1.7"), "")
+	require.NoError(t, err)
+	assert.Equal(t, &UploadedFile{ID: "file-abc", Filename: "report.pdf", Bytes: 8, Purpose: "user_data", CreatedAt: 1700000000}, uploaded)
+	assert.Equal(t, contentList{{Type: "file", File: &file{FileID: "file-abc"}}}, convertContent(uploaded.Content()))
+
+	info, err := m.GetFile(ctx, "file-abc")
+	require.NoError(t, err)
+	assert.Equal(t, 8, info.Bytes)
+	require.NoError(t, m.DeleteFile(ctx, "file-abc"))
+
+	_, err = m.GetFile(ctx, "file-missing")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
@@ -0,0 +1,133 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+)
+
+// ImageOptions configures a request to the image generation API.
+type ImageOptions struct {
+	APIKey string
+	// Model defaults to "gpt-image-1". DALL·E models such as "dall-e-3" are
+	// also supported.
+	Model string
+	// Endpoint defaults to the OpenAI image generation endpoint.
+	Endpoint string
+	// Size is for example "1024x1024", "1536x1024" or "auto".
+	Size string
+	// Quality is for example "low", "medium" or "high" for gpt-image-1, and
+	// "standard" or "hd" for dall-e-3.
+	Quality string
+	// OutputFormat is "png", "jpeg" or "webp", for gpt-image-1 only. The
+	// default is "png".
+	OutputFormat string
+	// Background can be "transparent" for PNG and WebP images, for gpt-image-1
+	// only.
+	Background string
+}
+
+// Image is an image generated by GenerateImage.
+type Image struct {
+	Data []byte
+	// MediaType is for example "image/png".
+	MediaType string
+	// RevisedPrompt is the prompt that dall-e-3 rewrote the given prompt to,
+	// if it did.
+	RevisedPrompt string
+}
+
+// Content returns the image as a content item with a data URI, so that it can
+// be returned by a tool or added to a message.
+func (img *Image) Content() content.Content {
+	return content.Content{
+		&content.ImageURL{URL: fmt.Sprintf("data:%s;base64,%s", img.MediaType, base64.StdEncoding.EncodeToString(img.Data))},
+	}
+}
+
+// GenerateImage generates an image from the prompt with the image generation
+// API.
+func GenerateImage(ctx context.Context, prompt string, opts ImageOptions) (*Image, error) {
+	if opts.Model == "" {
+		opts.Model = "gpt-image-1"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://api.openai.com/v1/images/generations"
+	}
+	payload := map[string]any{
+		"model":  opts.Model,
+		"prompt": prompt,
+		"n":      1,
+	}
+	if opts.Size != "" {
+		payload["size"] = opts.Size
+	}
+	if opts.Quality != "" {
+		payload["quality"] = opts.Quality
+	}
+	if opts.OutputFormat != "" {
+		payload["output_format"] = opts.OutputFormat
+	}
+	if opts.Background != "" {
+		payload["background"] = opts.Background
+	}
+	if strings.HasPrefix(opts.Model, "dall-e") {
+		// DALL·E returns URLs by default, which expire after an hour.
+		payload["response_format"] = "b64_json"
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", opts.Endpoint, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", opts.APIKey))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", llms.UserAgent())
+
+	resp, err := llms.HTTPClientFromContext(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp)
+	}
+	var result struct {
+		Data []struct {
+			B64JSON       string `json:"b64_json"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+		OutputFormat string `json:"output_format"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	if len(result.Data) == 0 || result.Data[0].B64JSON == "" {
+		return nil, fmt.Errorf("response has no image")
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+	format := result.OutputFormat
+	if format == "" {
+		format = opts.OutputFormat
+	}
+	if format == "" {
+		format = "png"
+	}
+	return &Image{Data: data, MediaType: "image/" + format, RevisedPrompt: result.Data[0].RevisedPrompt}, nil
+}
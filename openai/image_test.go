@@ -0,0 +1,40 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateImage(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		payload = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		if payload["model"] == "dall-e-3" {
+			fmt.Fprint(w, `{"data":[{"b64_json":"AQID","revised_prompt":"A watercolor cat."}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"b64_json":"AQID"}],"output_format":"webp"}`)
+	}))
+	defer server.Close()
+
+	img, err := GenerateImage(context.Background(), "A cat", ImageOptions{APIKey: "key", Endpoint: server.URL, Size: "1024x1024", OutputFormat: "webp"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"model": "gpt-image-1", "prompt": "A cat", "n": 1.0, "size": "1024x1024", "output_format": "webp"}, payload)
+	assert.Equal(t, &Image{Data: []byte{1, 2, 3}, MediaType: "image/webp"}, img)
+	assert.Equal(t, content.Content{&content.ImageURL{URL: "data:image/webp;base64,AQID"}}, img.Content())
+
+	img, err = GenerateImage(context.Background(), "A cat", ImageOptions{APIKey: "key", Model: "dall-e-3", Endpoint: server.URL})
+	require.NoError(t, err)
+	assert.Equal(t, "b64_json", payload["response_format"])
+	assert.Equal(t, &Image{Data: []byte{1, 2, 3}, MediaType: "image/png", RevisedPrompt: "A watercolor cat."}, img)
+}
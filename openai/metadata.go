@@ -0,0 +1,41 @@
+package openai
+
+import (
+	"context"
+	"maps"
+)
+
+type metadataKey struct{}
+
+// ContextWithMetadata returns a context that tags requests with a key and
+// value, in addition to the tags of the context and of WithMetadata. Tags of
+// the context take precedence.
+func ContextWithMetadata(ctx context.Context, key, value string) context.Context {
+	metadata := maps.Clone(MetadataFromContext(ctx))
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata[key] = value
+	return context.WithValue(ctx, metadataKey{}, metadata)
+}
+
+// MetadataFromContext returns the tags set with ContextWithMetadata, or nil.
+func MetadataFromContext(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return metadata
+}
+
+// requestMetadata returns the tags of the model combined with the tags of the
+// context.
+func (m *Model) requestMetadata(ctx context.Context) map[string]string {
+	fromContext := MetadataFromContext(ctx)
+	if len(fromContext) == 0 {
+		return m.metadata
+	}
+	metadata := maps.Clone(m.metadata)
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	maps.Copy(metadata, fromContext)
+	return metadata
+}
@@ -4,10 +4,15 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/blixt/go-llms/content"
@@ -21,8 +26,36 @@ type Model struct {
 	endpoint    string
 	company     string
 	debug       bool
+	httpClient  *http.Client
 
 	maxCompletionTokens int
+	temperature         float64
+	topP                float64
+	frequencyPenalty    float64
+	presencePenalty     float64
+	parallelToolCalls   *bool
+	toolChoice          string
+	logprobs            bool
+	topLogprobs         int
+	seed                *int
+	stop                []string
+	systemRole          string
+	modalities          []string
+	audioVoice          string
+	audioFormat         string
+	store               *bool
+	metadata            map[string]string
+	strictTools         bool
+	strictFuncNames     []string
+	serviceTier         string
+	webSearch           map[string]any
+	hostedTools         []map[string]any
+
+	noStreaming      bool
+	noStreamOptions  bool
+	lenientToolCalls bool
+	headers          http.Header
+	extraBody        map[string]any
 }
 
 func New(accessToken, model string) *Model {
@@ -31,6 +64,11 @@ func New(accessToken, model string) *Model {
 		model:       model,
 		endpoint:    "https://api.openai.com/v1/chat/completions",
 		company:     "OpenAI",
+
+		temperature:      math.NaN(),
+		topP:             math.NaN(),
+		frequencyPenalty: math.NaN(),
+		presencePenalty:  math.NaN(),
 	}
 }
 
@@ -47,11 +85,245 @@ func (m *Model) WithEndpoint(endpoint, company string) *Model {
 	return m
 }
 
+// WithHTTPClient sets the HTTP client that requests are sent with, for
+// example to set timeouts, proxies or instrumented transports. Without one,
+// the client of the context is used (see llms.ContextWithHTTPClient), or else
+// http.DefaultClient.
+func (m *Model) WithHTTPClient(client *http.Client) *Model {
+	m.httpClient = client
+	return m
+}
+
+// client returns the HTTP client to send requests with.
+func (m *Model) client(ctx context.Context) *http.Client {
+	if m.httpClient != nil {
+		return m.httpClient
+	}
+	return llms.HTTPClientFromContext(ctx)
+}
+
+// WithHeader adds a header that is sent with every request, which is often
+// needed by OpenAI-compatible APIs and proxies. Calling it again with the same
+// key replaces the earlier value.
+func (m *Model) WithHeader(key, value string) *Model {
+	if m.headers == nil {
+		m.headers = make(http.Header)
+	}
+	m.headers.Set(key, value)
+	return m
+}
+
+// WithOrganization makes requests count against the given organization, for
+// API keys that belong to several.
+func (m *Model) WithOrganization(id string) *Model {
+	return m.WithHeader("OpenAI-Organization", id)
+}
+
+// WithProject makes requests count against the given project, for API keys
+// that have access to several.
+func (m *Model) WithProject(id string) *Model {
+	return m.WithHeader("OpenAI-Project", id)
+}
+
+// WithExtraBody adds a field to the JSON body of every request, for parameters
+// that are specific to an OpenAI-compatible API. Extra fields take precedence
+// over the fields set by this package.
+func (m *Model) WithExtraBody(key string, value any) *Model {
+	if m.extraBody == nil {
+		m.extraBody = make(map[string]any)
+	}
+	m.extraBody[key] = value
+	return m
+}
+
+// WithStreaming controls whether responses are streamed, which is the
+// default. Without streaming, the whole response is requested at once and
+// replayed by the stream, for proxies and pipelines that can't handle
+// server-sent events. Text then arrives in a single update.
+func (m *Model) WithStreaming(enabled bool) *Model {
+	m.noStreaming = !enabled
+	return m
+}
+
+// WithoutStreamOptions stops sending stream_options, which some
+// OpenAI-compatible servers reject. These servers usually don't report usage
+// for streamed responses either.
+func (m *Model) WithoutStreamOptions() *Model {
+	m.noStreamOptions = true
+	return m
+}
+
+// WithLenientToolCalls makes the stream accept tool calls from
+// OpenAI-compatible servers that don't number them properly or leave out
+// their IDs. A tool call with a new ID starts a new tool call regardless of
+// its index, and tool calls without an ID are given one.
+func (m *Model) WithLenientToolCalls() *Model {
+	m.lenientToolCalls = true
+	return m
+}
+
+// WithSystemRole sets the role that the system prompt is sent with. By default
+// it's "developer" for o-series reasoning models, which reject "system"
+// messages, and "system" for all other models.
+func (m *Model) WithSystemRole(role string) *Model {
+	m.systemRole = role
+	return m
+}
+
 func (m *Model) WithMaxCompletionTokens(maxCompletionTokens int) *Model {
 	m.maxCompletionTokens = maxCompletionTokens
 	return m
 }
 
+func (m *Model) WithTemperature(temperature float64) *Model {
+	m.temperature = temperature
+	return m
+}
+
+func (m *Model) WithTopP(topP float64) *Model {
+	m.topP = topP
+	return m
+}
+
+// WithFrequencyPenalty penalizes tokens by how often they have already
+// appeared, from -2.0 to 2.0. Positive values make repetition less likely.
+func (m *Model) WithFrequencyPenalty(penalty float64) *Model {
+	m.frequencyPenalty = penalty
+	return m
+}
+
+// WithPresencePenalty penalizes tokens that have already appeared, from -2.0
+// to 2.0. Positive values make the model more likely to move on to new topics.
+func (m *Model) WithPresencePenalty(penalty float64) *Model {
+	m.presencePenalty = penalty
+	return m
+}
+
+// WithParallelToolCalls controls whether the model may call more than one tool
+// in a single response. By default the API decides, which usually means it's
+// allowed. Disable it when tools must run strictly one at a time, with the
+// model seeing each result before it makes the next call.
+func (m *Model) WithParallelToolCalls(enabled bool) *Model {
+	m.parallelToolCalls = &enabled
+	return m
+}
+
+// WithToolChoice controls which tool the model calls when it starts responding
+// to the user: "auto" (the default), "none", "required" (any tool) or the
+// function name of a specific tool. It only applies to requests that don't
+// follow tool results, so the model is free to respond once the tool has run.
+func (m *Model) WithToolChoice(choice string) *Model {
+	m.toolChoice = choice
+	return m
+}
+
+// WithLogprobs makes the API return the log probability of every token in the
+// response, along with the topN most likely alternatives at each position (up
+// to 20, or none if topN is 0). They're available from Stream.Logprobs.
+func (m *Model) WithLogprobs(topN int) *Model {
+	m.logprobs = true
+	m.topLogprobs = topN
+	return m
+}
+
+// WithSeed makes sampling deterministic on a best effort basis, so that
+// repeated requests with the same seed and parameters should return the same
+// result. Changes to the backend are reflected in Stream.SystemFingerprint.
+func (m *Model) WithSeed(seed int) *Model {
+	m.seed = &seed
+	return m
+}
+
+// WithStopSequences makes the model stop generating when it would output any of
+// the given sequences, which aren't included in the response. OpenAI accepts
+// up to four.
+func (m *Model) WithStopSequences(sequences ...string) *Model {
+	m.stop = sequences
+	return m
+}
+
+// WithStore sets whether OpenAI stores the completions, which makes them show
+// up in the dashboard where they can be used for evals and distillation.
+func (m *Model) WithStore(enabled bool) *Model {
+	m.store = &enabled
+	return m
+}
+
+// WithMetadata tags every request with a key and value, which can be used to
+// filter stored completions in the dashboard. OpenAI accepts up to 16 pairs.
+// Tags for a single request can be added with ContextWithMetadata.
+func (m *Model) WithMetadata(key, value string) *Model {
+	if m.metadata == nil {
+		m.metadata = make(map[string]string)
+	}
+	m.metadata[key] = value
+	return m
+}
+
+// WithMetadataMap tags every request with all the keys and values of the map,
+// like calling WithMetadata for each of them. It has its own name because
+// WithMetadata already takes a single key and value. The map is copied, so
+// changing it later doesn't affect the model.
+func (m *Model) WithMetadataMap(metadata map[string]string) *Model {
+	for key, value := range metadata {
+		m.WithMetadata(key, value)
+	}
+	return m
+}
+
+// WithWebSearch makes search models, such as gpt-4o-search-preview, search
+// the web before responding. The context size is "low", "medium" or "high",
+// or empty for the default, and trades cost for the amount of context from
+// the search results. The pages that the response is based on are added to
+// its content as citations, which have a URL.
+func (m *Model) WithWebSearch(contextSize string) *Model {
+	m.webSearch = map[string]any{}
+	if contextSize != "" {
+		m.webSearch["search_context_size"] = contextSize
+	}
+	return m
+}
+
+// WithHostedTools adds tools that the provider runs itself, such as
+// {"type": "web_search_preview"}, to the function tools of the toolbox. They
+// are sent as is, for APIs and gateways that support them.
+func (m *Model) WithHostedTools(tools ...map[string]any) *Model {
+	m.hostedTools = append(m.hostedTools, tools...)
+	return m
+}
+
+// toolChoice returns the tool_choice value for the choice of WithToolChoice.
+func toolChoice(choice string) any {
+	switch choice {
+	case "auto", "none", "required":
+		return choice
+	}
+	return map[string]any{"type": "function", "function": map[string]any{"name": choice}}
+}
+
+// WithModalities sets the kinds of output the model should generate, such as
+// "text" and "audio" for audio-capable models. Audio also needs
+// WithAudioOutput.
+func (m *Model) WithModalities(modalities ...string) *Model {
+	m.modalities = modalities
+	return m
+}
+
+// WithAudioOutput sets the voice (such as "alloy") and format of the audio the
+// model generates. Streamed audio must be "pcm16", which is raw 16-bit little
+// endian samples at 24 kHz. Chunks of audio are reported with
+// llms.StreamStatusAudio and available from Stream.Audio, while the transcript
+// is streamed as text. If no modalities are set, this sets them to text and
+// audio.
+func (m *Model) WithAudioOutput(voice, format string) *Model {
+	m.audioVoice = voice
+	m.audioFormat = format
+	if m.modalities == nil {
+		m.modalities = []string{"text", "audio"}
+	}
+	return m
+}
+
 func (m *Model) Company() string {
 	return m.company
 }
@@ -60,11 +332,69 @@ func (m *Model) Model() string {
 	return m.model
 }
 
-func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+func (m *Model) String() string {
+	return llms.ProviderName(m)
+}
+
+// ToolResultTypes returns the types that can be sent in tool messages. Images
+// in tool results are sent in a user message after the tool message.
+func (m *Model) ToolResultTypes() []content.Type {
+	return []content.Type{content.TypeText, content.TypeJSON}
+}
+
+// Ping lists the models of the API, which checks that it's reachable and that
+// the access token is valid. Endpoints that don't follow the OpenAI URL layout
+// are checked with llms.PingWithGenerate instead.
+func (m *Model) Ping(ctx context.Context) error {
+	base, ok := strings.CutSuffix(m.endpoint, "/chat/completions")
+	if !ok {
+		return llms.PingWithGenerate(ctx, m)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if m.accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.accessToken))
+	}
+	req.Header.Set("User-Agent", llms.UserAgent())
+	for key, values := range m.headers {
+		req.Header[key] = values
+	}
+	resp, err := m.client(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+func (m *Model) systemPromptRole() string {
+	if m.systemRole != "" {
+		return m.systemRole
+	}
+	if isReasoningModel(m.model) {
+		return "developer"
+	}
+	return "system"
+}
+
+// isReasoningModel reports whether the model is one of the o-series models,
+// such as "o1", "o3-mini" or "o4-mini-2025-04-16".
+func isReasoningModel(model string) bool {
+	return len(model) >= 2 && model[0] == 'o' && model[1] >= '0' && model[1] <= '9'
+}
+
+// requestBody returns the body of a chat completion request, which is
+// streamed unless it's part of a batch.
+func (m *Model) requestBody(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox, stream bool) map[string]any {
 	var apiMessages []message
 	if systemPrompt != nil {
 		apiMessages = append(apiMessages, message{
-			Role:    "system",
+			Role:    m.systemPromptRole(),
 			Content: convertContent(systemPrompt),
 		})
 	}
@@ -75,20 +405,96 @@ func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, mess
 	}
 
 	payload := map[string]any{
-		"model":          m.model,
-		"messages":       apiMessages,
-		"stream":         true,
-		"stream_options": map[string]any{"include_usage": true},
+		"model":    m.model,
+		"messages": apiMessages,
+	}
+	if stream {
+		payload["stream"] = true
+		if !m.noStreamOptions {
+			payload["stream_options"] = map[string]any{"include_usage": true}
+		}
 	}
 
 	if m.maxCompletionTokens > 0 {
 		payload["max_completion_tokens"] = m.maxCompletionTokens
 	}
+	if !math.IsNaN(m.temperature) {
+		payload["temperature"] = m.temperature
+	}
+	if !math.IsNaN(m.topP) {
+		payload["top_p"] = m.topP
+	}
+	if !math.IsNaN(m.frequencyPenalty) {
+		payload["frequency_penalty"] = m.frequencyPenalty
+	}
+	if !math.IsNaN(m.presencePenalty) {
+		payload["presence_penalty"] = m.presencePenalty
+	}
+	if m.seed != nil {
+		payload["seed"] = *m.seed
+	}
+	if len(m.stop) > 0 {
+		payload["stop"] = m.stop
+	}
+	if len(m.modalities) > 0 {
+		payload["modalities"] = m.modalities
+	}
+	if m.audioVoice != "" || m.audioFormat != "" {
+		payload["audio"] = map[string]any{"voice": m.audioVoice, "format": m.audioFormat}
+	}
+	if m.store != nil {
+		payload["store"] = *m.store
+	}
+	if metadata := m.requestMetadata(ctx); len(metadata) > 0 {
+		payload["metadata"] = metadata
+	}
+	if tier := ServiceTierFromContext(ctx); tier != "" {
+		payload["service_tier"] = tier
+	} else if m.serviceTier != "" {
+		payload["service_tier"] = m.serviceTier
+	}
+	if prediction := PredictionFromContext(ctx); prediction != "" {
+		payload["prediction"] = map[string]any{"type": "content", "content": prediction}
+	}
+	if m.logprobs {
+		payload["logprobs"] = true
+		if m.topLogprobs > 0 {
+			payload["top_logprobs"] = m.topLogprobs
+		}
+	}
 
+	if m.webSearch != nil {
+		payload["web_search_options"] = m.webSearch
+	}
+	if toolbox != nil || len(m.hostedTools) > 0 {
+		var apiTools []any
+		if toolbox != nil {
+			for _, t := range m.tools(toolbox) {
+				apiTools = append(apiTools, t)
+			}
+		}
+		for _, t := range m.hostedTools {
+			apiTools = append(apiTools, t)
+		}
+		payload["tools"] = apiTools
+	}
 	if toolbox != nil {
-		payload["tools"] = Tools(toolbox)
+		if m.parallelToolCalls != nil {
+			payload["parallel_tool_calls"] = *m.parallelToolCalls
+		}
+		if m.toolChoice != "" && (len(messages) == 0 || messages[len(messages)-1].Role != "tool") {
+			payload["tool_choice"] = toolChoice(m.toolChoice)
+		}
+	}
+
+	for key, value := range m.extraBody {
+		payload[key] = value
 	}
+	return payload
+}
 
+func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	payload := m.requestBody(ctx, systemPrompt, messages, toolbox, !m.noStreaming)
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return &Stream{err: fmt.Errorf("error encoding JSON: %w", err)}
@@ -101,47 +507,75 @@ func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, mess
 	if m.accessToken != "" {
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.accessToken))
 	}
+	req.Header.Set("User-Agent", llms.UserAgent())
 	req.Header.Set("Content-Type", "application/json")
+	if id := llms.GetCorrelationID(ctx); id != "" {
+		// OpenAI logs this header with the request, for tracing.
+		req.Header.Set("X-Client-Request-Id", id)
+	}
+	for key, values := range m.headers {
+		req.Header[key] = values
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.client(ctx).Do(req)
 	if err != nil {
 		return &Stream{err: fmt.Errorf("error making request: %w", err)}
 	}
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
-		bodyBytes, readErr := io.ReadAll(resp.Body)
-
-		if readErr == nil && len(bodyBytes) > 0 {
-			var openAIError struct {
-				Error struct {
-					Message string `json:"message"`
-					Type    string `json:"type"`
-				} `json:"error"`
-			}
+		return &Stream{err: newAPIError(resp)}
+	}
 
-			if jsonErr := json.Unmarshal(bodyBytes, &openAIError); jsonErr == nil && openAIError.Error.Message != "" {
-				// Successfully parsed the OpenAI error format
-				return &Stream{err: fmt.Errorf("%s: %s: %s", resp.Status, openAIError.Error.Type, openAIError.Error.Message)}
-			}
-			// Body read okay, but JSON parsing failed or structure mismatch.
-			// Fall through to return status only.
+	var body io.Reader = resp.Body
+	if m.noStreaming {
+		defer resp.Body.Close()
+		body, err = completionEvents(resp.Body)
+		if err != nil {
+			return &Stream{err: err}
 		}
-		// Default fallback: Read error, empty body, or failed/unexpected JSON parse.
-		return &Stream{err: fmt.Errorf("%s", resp.Status)}
 	}
-
-	return &Stream{ctx: ctx, model: m.model, stream: resp.Body, debug: m.debug}
+	stream := &Stream{ctx: ctx, model: m.model, stream: body, debug: m.debug, lenientToolCalls: m.lenientToolCalls}
+	stream.rateLimits, stream.hasRateLimits = llms.ParseRateLimits(resp.Header)
+	return stream
 }
 
 type Stream struct {
-	ctx      context.Context
-	model    string
-	stream   io.Reader
-	debug    bool
-	err      error
-	message  llms.Message
-	lastText string
-	usage    *usage
+	ctx              context.Context
+	model            string
+	stream           io.Reader
+	debug            bool
+	lenientToolCalls bool
+	err              error
+	message          llms.Message
+	lastText         string
+	usage            *usage
+	logprobs         []TokenLogprob
+	fingerprint      string
+	serviceTier      string
+	finishReason     string
+	lastAudio        []byte
+	rateLimits       llms.RateLimits
+	hasRateLimits    bool
+
+	// toolCallPositions maps the index of a tool call in the API to its
+	// position in message.ToolCalls.
+	toolCallPositions map[int]int
+	// openToolCalls are the positions of tool calls that have begun but have
+	// not been reported as ready.
+	openToolCalls []int
+	// currentToolCall is the position of the tool call that the last status
+	// was about.
+	currentToolCall int
+
+	// otherChoices holds the choices other than the first one, for requests
+	// that ask for several (or gateways that return them anyway).
+	otherChoices map[int]*choice
+}
+
+// choice assembles a choice other than the first one, which is not streamed.
+type choice struct {
+	message           llms.Message
+	toolCallPositions map[int]int
 }
 
 func (s *Stream) Err() error {
@@ -152,15 +586,62 @@ func (s *Stream) Message() llms.Message {
 	return s.message
 }
 
+// Choices returns the message of every choice in the response, ordered by
+// index. The first choice is the one that was streamed, and is also returned
+// by Message.
+func (s *Stream) Choices() []llms.Message {
+	indices := make([]int, 0, len(s.otherChoices))
+	for index := range s.otherChoices {
+		indices = append(indices, index)
+	}
+	slices.Sort(indices)
+	messages := []llms.Message{s.message}
+	for _, index := range indices {
+		messages = append(messages, s.otherChoices[index].message)
+	}
+	return messages
+}
+
+// Model returns the model that served the response. This is the requested
+// model until the API reports otherwise, for example when an alias resolves to
+// a specific snapshot, or a router picks a different model.
+func (s *Stream) Model() string {
+	return s.model
+}
+
+// Logprobs returns the log probabilities of the tokens of the first choice
+// received so far, if they were requested with WithLogprobs.
+func (s *Stream) Logprobs() []TokenLogprob {
+	return s.logprobs
+}
+
+// SystemFingerprint identifies the backend configuration that served the
+// response. Responses to requests with the same seed are only expected to be
+// the same when the fingerprint is too.
+func (s *Stream) SystemFingerprint() string {
+	return s.fingerprint
+}
+
+// Audio returns the latest chunk of audio, if audio output was requested with
+// WithAudioOutput.
+func (s *Stream) Audio() []byte {
+	return s.lastAudio
+}
+
+// RateLimits returns the rate limits that the API reported with the response.
+func (s *Stream) RateLimits() (limits llms.RateLimits, ok bool) {
+	return s.rateLimits, s.hasRateLimits
+}
+
 func (s *Stream) Text() string {
 	return s.lastText
 }
 
 func (s *Stream) ToolCall() llms.ToolCall {
-	if len(s.message.ToolCalls) == 0 {
+	if s.currentToolCall >= len(s.message.ToolCalls) {
 		return llms.ToolCall{}
 	}
-	return s.message.ToolCalls[len(s.message.ToolCalls)-1]
+	return s.message.ToolCalls[s.currentToolCall]
 }
 
 func (s *Stream) Usage() (inputTokens, outputTokens int) {
@@ -170,9 +651,31 @@ func (s *Stream) Usage() (inputTokens, outputTokens int) {
 	return s.usage.PromptTokens, s.usage.CompletionTokens
 }
 
+// FinishReason returns why the model stopped generating the first choice,
+// once the stream is done: "stop", "length", "tool_calls" or
+// "content_filter".
+func (s *Stream) FinishReason() llms.FinishReason {
+	return llms.FinishReason(s.finishReason)
+}
+
+// UsageDetails returns how many of the prompt tokens were cached and how many
+// of the completion tokens were spent on reasoning.
+func (s *Stream) UsageDetails() llms.UsageDetails {
+	var details llms.UsageDetails
+	if s.usage == nil {
+		return details
+	}
+	if s.usage.PromptTokensDetails != nil {
+		details.CachedInputTokens = s.usage.PromptTokensDetails.CachedTokens
+	}
+	if s.usage.CompletionTokensDetails != nil {
+		details.ReasoningTokens = s.usage.CompletionTokensDetails.ReasoningTokens
+	}
+	return details
+}
+
 func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 	scanner := bufio.NewScanner(s.stream)
-	var activeToolCallIndex = -1 // Track the index of the tool call being processed
 
 	return func(yield func(llms.StreamStatus) bool) {
 		defer io.Copy(io.Discard, s.stream)
@@ -190,8 +693,11 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 				// If scanning fails (e.g., EOF or error), check for scanner error.
 				if err := scanner.Err(); err != nil {
 					s.err = fmt.Errorf("error scanning stream: %w", err)
+					return
 				}
-				return // Exit loop on scan failure or EOF
+				// Not every server sends [DONE] or a finish reason.
+				s.finishToolCalls(yield)
+				return // Exit loop on EOF
 			}
 
 			if s.debug {
@@ -204,12 +710,9 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 				continue
 			}
 			if line == "[DONE]" {
-				// Stream ended. If a tool call was active, mark it as ready.
-				if activeToolCallIndex != -1 {
-					if !yield(llms.StreamStatusToolCallReady) {
-						return
-					}
-					activeToolCallIndex = -1 // Reset active tool call
+				// Stream ended. Any tool calls still streaming are ready.
+				if !s.finishToolCalls(yield) {
+					return
 				}
 				continue // Continue the outer loop to check context or EOF
 			}
@@ -218,70 +721,206 @@ func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
 				s.err = fmt.Errorf("error unmarshalling chunk: %w", err)
 				return
 			}
-			if chunk.Usage != nil {
-				s.usage = chunk.Usage
+			if chunk.Model != "" {
+				s.model = chunk.Model
 			}
-			if len(chunk.Choices) < 1 {
-				continue
+			if chunk.SystemFingerprint != "" {
+				s.fingerprint = chunk.SystemFingerprint
 			}
-			delta := chunk.Choices[0].Delta
-			if delta.Role != "" {
-				s.message.Role = delta.Role
+			if chunk.ServiceTier != "" {
+				s.serviceTier = chunk.ServiceTier
+			}
+			if chunk.Usage != nil {
+				s.usage = chunk.Usage
 			}
-			// Content is nullable string in delta
-			if delta.Content != nil {
-				s.lastText = *delta.Content
-				if s.lastText != "" {
-					s.message.Content.Append(s.lastText)
-					if !yield(llms.StreamStatusText) {
+			for _, c := range chunk.Choices {
+				if c.Index != 0 {
+					s.addToChoice(c)
+					continue
+				}
+				if c.Logprobs != nil {
+					s.logprobs = append(s.logprobs, c.Logprobs.Content...)
+				}
+				if !s.processDelta(c.Delta, yield) {
+					return
+				}
+				// Tool calls are only ready once the message is finished,
+				// since the arguments of parallel tool calls can interleave
+				// across chunks, so any of them may still be receiving
+				// arguments until then.
+				if c.FinishReason != nil {
+					s.finishReason = *c.FinishReason
+					if !s.finishToolCalls(yield) {
 						return
 					}
 				}
 			}
+		}
+	}
+}
 
-			// Handle Tool Calls Delta
-			if len(delta.ToolCalls) > 0 {
-				for _, toolDelta := range delta.ToolCalls {
-					if toolDelta.Index >= len(s.message.ToolCalls) {
-						// This is a new tool call starting
-						if toolDelta.Index != len(s.message.ToolCalls) {
-							panic(fmt.Sprintf("tool call index mismatch: expected %d, got %d", len(s.message.ToolCalls), toolDelta.Index))
-						}
-						// If a previous tool call was active, mark it as ready now.
-						if activeToolCallIndex != -1 {
-							if !yield(llms.StreamStatusToolCallReady) {
-								return // Abort if yield fails
-							}
-						}
-						// Add the new tool call (converting from toolCallDelta)
-						llmToolCall := toolDelta.ToLLM()
-						s.message.ToolCalls = append(s.message.ToolCalls, llmToolCall)
-						activeToolCallIndex = toolDelta.Index // Mark new tool call as active
-						if !yield(llms.StreamStatusToolCallBegin) {
-							return // Abort if yield fails
-						}
-					} else {
-						// This is appending arguments to an existing tool call
-						if toolDelta.Function.Arguments != "" {
-							s.message.ToolCalls[toolDelta.Index].Arguments = append(s.message.ToolCalls[toolDelta.Index].Arguments, toolDelta.Function.Arguments...)
-							if !yield(llms.StreamStatusToolCallData) {
-								return // Abort if yield fails
-							}
-						}
-					}
-				}
+// processDelta adds a delta of the first choice to the message, yielding the
+// corresponding statuses. It returns false if the iteration should stop.
+func (s *Stream) processDelta(delta chatCompletionDelta, yield func(llms.StreamStatus) bool) bool {
+	if delta.Role != "" {
+		s.message.Role = delta.Role
+	}
+	// Reasoning models served by some OpenAI-compatible APIs (for
+	// example DeepSeek) stream their thinking separately.
+	if delta.ReasoningContent != nil && *delta.ReasoningContent != "" {
+		s.lastText = *delta.ReasoningContent
+		s.message.Content.AppendThinking(s.lastText)
+		if !yield(llms.StreamStatusThinking) {
+			return false
+		}
+	}
+	// Content is nullable string in delta
+	if delta.Content != nil {
+		s.lastText = *delta.Content
+		if s.lastText != "" {
+			s.message.Content.Append(s.lastText)
+			if !yield(llms.StreamStatusText) {
+				return false
 			}
-			// Check if the overall message is finished (might indicate last tool call is ready)
-			if chunk.Choices[0].FinishReason != nil && *chunk.Choices[0].FinishReason == "tool_calls" {
-				if activeToolCallIndex != -1 {
-					if !yield(llms.StreamStatusToolCallReady) {
-						return // Abort if yield fails
-					}
-					activeToolCallIndex = -1 // Reset active tool call
-				}
+		}
+	}
+	// Models with structured outputs explain refusals separately, since the
+	// explanation doesn't follow the schema.
+	if delta.Refusal != nil && *delta.Refusal != "" {
+		s.lastText = *delta.Refusal
+		s.message.Refusal += s.lastText
+		if !yield(llms.StreamStatusRefusal) {
+			return false
+		}
+	}
+	for _, a := range delta.Annotations {
+		if citation, ok := a.ToLLM(); ok {
+			s.message.Content = append(s.message.Content, citation)
+		}
+	}
+	if delta.Audio != nil {
+		if delta.Audio.Transcript != "" {
+			s.lastText = delta.Audio.Transcript
+			s.message.Content.Append(s.lastText)
+			if !yield(llms.StreamStatusText) {
+				return false
+			}
+		}
+		if delta.Audio.Data != "" {
+			audio, err := base64.StdEncoding.DecodeString(delta.Audio.Data)
+			if err != nil {
+				s.err = fmt.Errorf("error decoding audio: %w", err)
+				return false
 			}
+			s.lastAudio = audio
+			if !yield(llms.StreamStatusAudio) {
+				return false
+			}
+		}
+	}
+	if s.toolCallPositions == nil {
+		s.toolCallPositions = make(map[int]int)
+	}
+	for _, toolDelta := range delta.ToolCalls {
+		if s.lenientToolCalls {
+			toolDelta = s.normalizeToolCallDelta(toolDelta)
+		}
+		position, ok := s.toolCallPositions[toolDelta.Index]
+		if !ok {
+			// This is a new tool call starting.
+			s.message.ToolCalls = append(s.message.ToolCalls, toolDelta.ToLLM())
+			position = len(s.message.ToolCalls) - 1
+			s.toolCallPositions[toolDelta.Index] = position
+			s.openToolCalls = append(s.openToolCalls, position)
+			s.currentToolCall = position
+			if !yield(llms.StreamStatusToolCallBegin) {
+				return false
+			}
+			continue
+		}
+		// This is appending arguments to an existing tool call.
+		if toolDelta.Function.Arguments != "" {
+			if !slices.Contains(s.openToolCalls, position) {
+				s.err = fmt.Errorf("received arguments for tool call %d after it was complete", toolDelta.Index)
+				return false
+			}
+			toolCall := &s.message.ToolCalls[position]
+			toolCall.Arguments = append(toolCall.Arguments, toolDelta.Function.Arguments...)
+			s.currentToolCall = position
+			if !yield(llms.StreamStatusToolCallData) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// finishToolCalls yields StreamStatusToolCallReady for the tool calls that
+// are still open, in the order they began. It returns false if the iteration
+// should stop.
+func (s *Stream) finishToolCalls(yield func(llms.StreamStatus) bool) bool {
+	for len(s.openToolCalls) > 0 {
+		s.currentToolCall = s.openToolCalls[0]
+		s.openToolCalls = s.openToolCalls[1:]
+		if !yield(llms.StreamStatusToolCallReady) {
+			return false
 		}
 	}
+	return true
+}
+
+// addToChoice assembles a choice other than the first one.
+func (s *Stream) addToChoice(c chatCompletionChoice) {
+	if s.otherChoices == nil {
+		s.otherChoices = make(map[int]*choice)
+	}
+	ch, ok := s.otherChoices[c.Index]
+	if !ok {
+		ch = &choice{toolCallPositions: make(map[int]int)}
+		s.otherChoices[c.Index] = ch
+	}
+	if c.Delta.Role != "" {
+		ch.message.Role = c.Delta.Role
+	}
+	if c.Delta.ReasoningContent != nil && *c.Delta.ReasoningContent != "" {
+		ch.message.Content.AppendThinking(*c.Delta.ReasoningContent)
+	}
+	if c.Delta.Content != nil && *c.Delta.Content != "" {
+		ch.message.Content.Append(*c.Delta.Content)
+	}
+	if c.Delta.Refusal != nil {
+		ch.message.Refusal += *c.Delta.Refusal
+	}
+	for _, toolDelta := range c.Delta.ToolCalls {
+		if position, ok := ch.toolCallPositions[toolDelta.Index]; ok {
+			toolCall := &ch.message.ToolCalls[position]
+			toolCall.Arguments = append(toolCall.Arguments, toolDelta.Function.Arguments...)
+		} else {
+			ch.toolCallPositions[toolDelta.Index] = len(ch.message.ToolCalls)
+			ch.message.ToolCalls = append(ch.message.ToolCalls, toolDelta.ToLLM())
+		}
+	}
+}
+
+// normalizeToolCallDelta assigns the index (and if needed the ID) that a
+// tool call delta should have had, based on the tool calls seen so far.
+func (s *Stream) normalizeToolCallDelta(delta toolCallDelta) toolCallDelta {
+	calls := s.message.ToolCalls
+	switch {
+	case len(calls) == 0 || (delta.ID != "" && delta.ID != calls[len(calls)-1].ID):
+		delta.Index = len(calls)
+	case delta.ID == "" && delta.Function.Name != "" && delta.Function.Name != calls[len(calls)-1].Name:
+		// Without IDs, a different function name is the only sign of a new call.
+		delta.Index = len(calls)
+	default:
+		delta.Index = len(calls) - 1
+	}
+	if delta.Index == len(calls) && delta.ID == "" {
+		var id [12]byte
+		rand.Read(id[:])
+		delta.ID = "call_" + hex.EncodeToString(id[:])
+	}
+	return delta
 }
 
 func Tools(toolbox *tools.Toolbox) []Tool {
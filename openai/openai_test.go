@@ -0,0 +1,640 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStream creates a stream reading the given SSE lines.
+func newTestStream(lines ...string) *Stream {
+	var body strings.Builder
+	for _, line := range lines {
+		body.WriteString("data: " + line + "\n\n")
+	}
+	return &Stream{ctx: context.Background(), model: "test-model", stream: strings.NewReader(body.String())}
+}
+
+// collectStatuses iterates the stream and returns every status it yielded.
+func collectStatuses(s *Stream) []llms.StreamStatus {
+	var statuses []llms.StreamStatus
+	for status := range s.Iter() {
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func TestStreamReasoningContent(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Let me "}}]}`,
+		`{"choices":[{"index":0,"delta":{"reasoning_content":"think."}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"42"}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":7}}`,
+		`[DONE]`,
+	)
+	statuses := collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []llms.StreamStatus{llms.StreamStatusThinking, llms.StreamStatusThinking, llms.StreamStatusText}, statuses)
+
+	msg := stream.Message()
+	assert.Equal(t, "assistant", msg.Role)
+	require.Len(t, msg.Content, 2)
+	thinking, ok := msg.Content[0].(*content.Thinking)
+	require.True(t, ok, "First content item should be thinking")
+	assert.Equal(t, "Let me think.", thinking.Text)
+	text, ok := msg.Content[1].(*content.Text)
+	require.True(t, ok, "Second content item should be text")
+	assert.Equal(t, "42", text.Text)
+
+	// Reasoning must not be sent back to the API.
+	converted := messagesFromLLM(msg)
+	require.Len(t, converted, 1)
+	assert.Equal(t, contentList{{Type: "text", Text: ptr("42")}}, converted[0].Content)
+}
+
+func TestStreamLogprobs(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Yes"},"logprobs":{"content":[{"token":"Yes","logprob":-0.1,"bytes":[89,101,115],"top_logprobs":[{"token":"Yes","logprob":-0.1,"bytes":[89,101,115]},{"token":"No","logprob":-2.4,"bytes":[78,111]}]}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"."},"logprobs":{"content":[{"token":".","logprob":0,"bytes":[46]}]}}]}`,
+		`{"system_fingerprint":"fp_44709d6fcb","choices":[{"index":0,"delta":{},"finish_reason":"stop","logprobs":null}]}`,
+		`[DONE]`,
+	)
+	collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []TokenLogprob{
+		{Token: "Yes", Logprob: -0.1, Bytes: []int{89, 101, 115}, TopLogprobs: []TokenLogprob{
+			{Token: "Yes", Logprob: -0.1, Bytes: []int{89, 101, 115}},
+			{Token: "No", Logprob: -2.4, Bytes: []int{78, 111}},
+		}},
+		{Token: ".", Logprob: 0, Bytes: []int{46}},
+	}, stream.Logprobs())
+	assert.Equal(t, "fp_44709d6fcb", stream.SystemFingerprint())
+}
+
+func TestStreamAudio(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","audio":{"id":"audio_1","transcript":"Hi"}}}]}`,
+		`{"choices":[{"index":0,"delta":{"audio":{"data":"AAEC"}}}]}`,
+		`{"choices":[{"index":0,"delta":{"audio":{"transcript":" there","data":"AwQ="}},"finish_reason":"stop"}]}`,
+		`[DONE]`,
+	)
+	var audio []byte
+	var statuses []llms.StreamStatus
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+		if status == llms.StreamStatusAudio {
+			audio = append(audio, stream.Audio()...)
+		}
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []llms.StreamStatus{llms.StreamStatusText, llms.StreamStatusAudio, llms.StreamStatusText, llms.StreamStatusAudio}, statuses)
+	assert.Equal(t, []byte{0, 1, 2, 3, 4}, audio)
+	assert.Equal(t, content.FromText("Hi there"), stream.Message().Content)
+}
+
+func TestStreamPredictionTokens(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"func main() {}"},"finish_reason":"stop"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":12,"total_tokens":32,"completion_tokens_details":{"accepted_prediction_tokens":8,"rejected_prediction_tokens":3}}}`,
+		`[DONE]`,
+	)
+	collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	accepted, rejected := stream.PredictionTokens()
+	assert.Equal(t, 8, accepted)
+	assert.Equal(t, 3, rejected)
+}
+
+func TestStreamUsageDetails(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"42"},"finish_reason":"stop"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":2000,"completion_tokens":300,"total_tokens":2300,"prompt_tokens_details":{"cached_tokens":1536},"completion_tokens_details":{"reasoning_tokens":256}}}`,
+		`[DONE]`,
+	)
+	collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	assert.Equal(t, llms.UsageDetails{CachedInputTokens: 1536, ReasoningTokens: 256}, stream.UsageDetails())
+}
+
+func TestStreamRefusal(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":null,"refusal":""}}]}`,
+		`{"choices":[{"index":0,"delta":{"refusal":"I'm sorry, "}}]}`,
+		`{"choices":[{"index":0,"delta":{"refusal":"I can't help with that."},"finish_reason":"stop"}]}`,
+		`[DONE]`,
+	)
+	var refusal []string
+	for status := range stream.Iter() {
+		require.Equal(t, llms.StreamStatusRefusal, status)
+		refusal = append(refusal, stream.Text())
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []string{"I'm sorry, ", "I can't help with that."}, refusal)
+	assert.Equal(t, "I'm sorry, I can't help with that.", stream.Message().Refusal)
+	assert.Empty(t, stream.Message().Content)
+}
+
+func TestStreamFinishReason(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Once upon a"},"finish_reason":null}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"length"}]}`,
+		`[DONE]`,
+	)
+	collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	assert.Equal(t, llms.FinishReasonLength, stream.FinishReason())
+}
+
+func TestStreamBatchedToolCalls(t *testing.T) {
+	// A gateway that batches the deltas of two tool calls into one chunk, and
+	// numbers them with a gap.
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":1,"id":"a","type":"function","function":{"name":"first","arguments":"{\"x\":"}},{"index":3,"id":"b","type":"function","function":{"name":"second","arguments":""}},{"index":1,"function":{"arguments":"1}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":3,"function":{"arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`,
+		`[DONE]`,
+	)
+	var ready []string
+	for status := range stream.Iter() {
+		if status == llms.StreamStatusToolCallReady {
+			toolCall := stream.ToolCall()
+			ready = append(ready, toolCall.ID+" "+string(toolCall.Arguments))
+		}
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []string{`a {"x":1}`, `b {}`}, ready, "Each tool call should be ready once, with all of its arguments")
+}
+
+func TestStreamOutOfOrderToolCalls(t *testing.T) {
+	// Three parallel tool calls in one chunk, with the later indexes first
+	// and the arguments of the first call arriving after the others began.
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":2,"id":"c","type":"function","function":{"name":"third","arguments":"{}"}},{"index":0,"id":"a","type":"function","function":{"name":"first","arguments":""}},{"index":1,"id":"b","type":"function","function":{"name":"second","arguments":"{}"}},{"index":0,"function":{"arguments":"{\"x\":1}"}}]},"finish_reason":"tool_calls"}]}`,
+		`[DONE]`,
+	)
+	var statuses []llms.StreamStatus
+	var ready []string
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+		if status == llms.StreamStatusToolCallReady {
+			toolCall := stream.ToolCall()
+			ready = append(ready, toolCall.ID+" "+string(toolCall.Arguments))
+		}
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []llms.StreamStatus{
+		llms.StreamStatusToolCallBegin, llms.StreamStatusToolCallBegin, llms.StreamStatusToolCallBegin,
+		llms.StreamStatusToolCallData,
+		llms.StreamStatusToolCallReady, llms.StreamStatusToolCallReady, llms.StreamStatusToolCallReady,
+	}, statuses)
+	assert.Equal(t, []string{`c {}`, `a {"x":1}`, `b {}`}, ready)
+	assert.Len(t, stream.Message().ToolCalls, 3)
+
+	// The same interleaving, with the deltas split across chunks: the first
+	// call's arguments continue after the second call began.
+	stream = newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"first","arguments":"{\"x\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"b","type":"function","function":{"name":"second","arguments":"{}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		`[DONE]`,
+	)
+	ready = nil
+	for status := range stream.Iter() {
+		if status == llms.StreamStatusToolCallReady {
+			toolCall := stream.ToolCall()
+			ready = append(ready, toolCall.ID+" "+string(toolCall.Arguments))
+		}
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []string{`a {"x":1}`, `b {}`}, ready, "A tool call shouldn't be ready while its arguments may continue")
+}
+
+func TestStreamMultipleChoices(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":1,"delta":{"role":"assistant","content":"Second"}},{"index":0,"delta":{"role":"assistant","content":"First"}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":" answer"}},{"index":1,"delta":{"content":" answer"},"finish_reason":"stop"}]}`,
+		`{"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`[DONE]`,
+	)
+	statuses := collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	assert.Equal(t, []llms.StreamStatus{llms.StreamStatusText, llms.StreamStatusText}, statuses, "Only the first choice should be streamed")
+	assert.Equal(t, content.FromText("First answer"), stream.Message().Content)
+
+	choices := stream.Choices()
+	require.Len(t, choices, 2)
+	assert.Equal(t, content.FromText("First answer"), choices[0].Content)
+	assert.Equal(t, "assistant", choices[1].Role)
+	assert.Equal(t, content.FromText("Second answer"), choices[1].Content)
+}
+
+func TestPing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/v1/models", r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer server.Close()
+
+	m := New("good-key", "gpt-4.1").WithEndpoint(server.URL+"/v1/chat/completions", "Test")
+	assert.NoError(t, llms.Ping(context.Background(), m))
+	m = New("bad-key", "gpt-4.1").WithEndpoint(server.URL+"/v1/chat/completions", "Test")
+	assert.EqualError(t, llms.Ping(context.Background(), m), "401 Unauthorized")
+}
+
+func TestGenerateSamplingParameters(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	m := New("key", "gpt-4.1").WithEndpoint(server.URL, "Test").WithTemperature(0).WithTopP(0.9)
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	assert.Equal(t, 0.0, payload["temperature"])
+	assert.Equal(t, 0.9, payload["top_p"])
+	assert.NotContains(t, payload, "frequency_penalty")
+	assert.NotContains(t, payload, "presence_penalty")
+	assert.NotContains(t, payload, "logprobs")
+	assert.NotContains(t, payload, "seed")
+
+	m.WithFrequencyPenalty(0.5).WithPresencePenalty(-0.5).WithLogprobs(3).WithSeed(42).WithStopSequences("\n\n", "END")
+	stream = m.Generate(ContextWithPrediction(context.Background(), "package main"), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	assert.Equal(t, 0.5, payload["frequency_penalty"])
+	assert.Equal(t, -0.5, payload["presence_penalty"])
+	assert.Equal(t, true, payload["logprobs"])
+	assert.Equal(t, 3.0, payload["top_logprobs"])
+	assert.Equal(t, 42.0, payload["seed"])
+	assert.Equal(t, []any{"\n\n", "END"}, payload["stop"])
+	assert.Equal(t, map[string]any{"type": "content", "content": "package main"}, payload["prediction"])
+
+	m = New("key", "gpt-4o-audio-preview").WithEndpoint(server.URL, "Test").WithAudioOutput("alloy", "pcm16")
+	stream = m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	assert.Equal(t, []any{"text", "audio"}, payload["modalities"])
+	assert.Equal(t, map[string]any{"voice": "alloy", "format": "pcm16"}, payload["audio"])
+}
+
+func TestGenerateParallelToolCalls(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	toolbox := tools.Box(tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p struct{}) tools.Result {
+		return tools.Success(nil)
+	}))
+	generate := func(m *Model) {
+		stream := m.WithEndpoint(server.URL, "Test").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, toolbox)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(New("key", "gpt-4.1"))
+	assert.NotContains(t, payload, "parallel_tool_calls")
+	generate(New("key", "gpt-4.1").WithParallelToolCalls(false))
+	assert.Equal(t, false, payload["parallel_tool_calls"])
+}
+
+func TestGenerateToolChoice(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	toolbox := tools.Box(tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p struct{}) tools.Result {
+		return tools.Success(nil)
+	}))
+	generate := func(m *Model, messages ...llms.Message) {
+		stream := m.WithEndpoint(server.URL, "Test").Generate(context.Background(), nil, messages, toolbox)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+	user := llms.Message{Role: "user", Content: content.FromText("Hi")}
+
+	generate(New("key", "gpt-4.1").WithToolChoice("required"), user)
+	assert.Equal(t, "required", payload["tool_choice"])
+	generate(New("key", "gpt-4.1").WithToolChoice("lookup"), user)
+	assert.Equal(t, map[string]any{"type": "function", "function": map[string]any{"name": "lookup"}}, payload["tool_choice"])
+
+	// The choice doesn't apply once the tool has run.
+	generate(New("key", "gpt-4.1").WithToolChoice("lookup"), user,
+		llms.Message{Role: "assistant", ToolCalls: []llms.ToolCall{{ID: "1", Name: "lookup", Arguments: json.RawMessage("{}")}}},
+		llms.Message{Role: "tool", ToolCallID: "1", Content: content.FromText("ok")},
+	)
+	assert.NotContains(t, payload, "tool_choice")
+}
+
+func TestGenerateRateLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-ratelimit-limit-requests", "500")
+		w.Header().Set("x-ratelimit-remaining-requests", "499")
+		w.Header().Set("x-ratelimit-reset-requests", "120ms")
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	m := New("key", "gpt-4.1").WithEndpoint(server.URL, "Test")
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	limits, ok := stream.(llms.RateLimitStream).RateLimits()
+	assert.True(t, ok)
+	assert.Equal(t, 500, limits.LimitRequests)
+	assert.Equal(t, 499, limits.RemainingRequests)
+	assert.Equal(t, -1, limits.RemainingTokens)
+}
+
+func TestGenerateSystemRole(t *testing.T) {
+	var payload struct {
+		Messages []struct {
+			Role string `json:"role"`
+		} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	systemRole := func(m *Model) string {
+		stream := m.WithEndpoint(server.URL, "Test").Generate(context.Background(), content.FromText("Be brief."), []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+		require.Len(t, payload.Messages, 2)
+		return payload.Messages[0].Role
+	}
+	assert.Equal(t, "system", systemRole(New("key", "gpt-4.1")))
+	assert.Equal(t, "developer", systemRole(New("key", "o1")))
+	assert.Equal(t, "developer", systemRole(New("key", "o4-mini-2025-04-16")))
+	assert.Equal(t, "system", systemRole(New("key", "omni-moderation-latest")))
+	assert.Equal(t, "developer", systemRole(New("key", "gpt-4.1").WithSystemRole("developer")))
+}
+
+func TestGenerateStoreAndMetadata(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	generate := func(ctx context.Context, m *Model) {
+		stream := m.WithEndpoint(server.URL, "Test").Generate(ctx, nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(context.Background(), New("key", "gpt-4.1"))
+	assert.NotContains(t, payload, "store")
+	assert.NotContains(t, payload, "metadata")
+
+	m := New("key", "gpt-4.1").WithStore(true).WithMetadata("app", "docs").WithMetadata("env", "prod")
+	ctx := ContextWithMetadata(context.Background(), "env", "staging")
+	ctx = ContextWithMetadata(ctx, "user", "42")
+	generate(ctx, m)
+	assert.Equal(t, true, payload["store"])
+	assert.Equal(t, map[string]any{"app": "docs", "env": "staging", "user": "42"}, payload["metadata"])
+
+	generate(context.Background(), m)
+	assert.Equal(t, map[string]any{"app": "docs", "env": "prod"}, payload["metadata"])
+
+	m.WithMetadataMap(map[string]string{"env": "dev", "team": "search"})
+	generate(context.Background(), m)
+	assert.Equal(t, map[string]any{"app": "docs", "env": "dev", "team": "search"}, payload["metadata"])
+}
+
+func TestWithMetadataMap(t *testing.T) {
+	assert.Nil(t, New("key", "gpt-4.1").WithMetadataMap(nil).metadata, "An empty map shouldn't send metadata")
+
+	tags := map[string]string{"app": "docs", "env": "prod"}
+	m := New("key", "gpt-4.1").WithStore(true).WithMetadataMap(tags).WithMetadata("user", "42")
+	tags["env"] = "dev"
+	assert.Equal(t, map[string]string{"app": "docs", "env": "prod", "user": "42"}, m.metadata)
+}
+
+func TestStrictTools(t *testing.T) {
+	type searchParams struct {
+		Query   string   `json:"query"`
+		Limit   int      `json:"limit,omitempty"`
+		Filters []string `json:"filters,omitempty"`
+	}
+	search := tools.Func("Search", "Searches", "search", func(r tools.Runner, p searchParams) tools.Result {
+		return tools.SuccessFromString(p.Query)
+	})
+	tag := tools.Func("Tag", "Tags", "tag", func(r tools.Runner, p struct {
+		Tags map[string]string `json:"tags"`
+	}) tools.Result {
+		return tools.SuccessFromString("ok")
+	})
+	toolbox := tools.Box(search, tag)
+
+	encode := func(m *Model) map[string]json.RawMessage {
+		functions := map[string]json.RawMessage{}
+		for _, tool := range m.tools(toolbox) {
+			data, err := json.Marshal(tool)
+			require.NoError(t, err)
+			functions[tool.Function.Name] = data
+		}
+		return functions
+	}
+
+	plain := encode(New("key", "gpt-4.1"))
+	assert.NotContains(t, string(plain["search"]), "strict")
+
+	strict := encode(New("key", "gpt-4.1").WithStrictTools())
+	assert.JSONEq(t, `{
+		"type": "function",
+		"function": {
+			"name": "search",
+			"description": "Searches",
+			"strict": true,
+			"parameters": {
+				"type": "object",
+				"properties": {
+					"query": {"type": "string"},
+					"limit": {"type": ["integer", "null"]},
+					"filters": {"type": ["array", "null"], "items": {"type": "string"}}
+				},
+				"required": ["filters", "limit", "query"],
+				"additionalProperties": false
+			}
+		}
+	}`, string(strict["search"]))
+	// Maps can't be expressed in strict mode.
+	assert.NotContains(t, string(strict["tag"]), "strict")
+
+	selected := encode(New("key", "gpt-4.1").WithStrictTools("tag"))
+	assert.NotContains(t, string(selected["search"]), "strict")
+
+	// Optional arguments that are null are treated as left out.
+	result := toolbox.Run(tools.NopRunner, "search", json.RawMessage(`{"query":"go","limit":null,"filters":null}`))
+	require.NoError(t, result.Error())
+}
+
+func TestGenerateServiceTier(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte(`data: {"model":"o3","service_tier":"flex","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}` + "\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	generate := func(ctx context.Context, m *Model) *Stream {
+		stream := m.WithEndpoint(server.URL, "Test").Generate(ctx, nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil).(*Stream)
+		for range stream.Iter() {
+		}
+		require.NoError(t, stream.Err())
+		return stream
+	}
+
+	generate(context.Background(), New("key", "o3"))
+	assert.NotContains(t, payload, "service_tier")
+
+	m := New("key", "o3").WithServiceTier("flex")
+	stream := generate(context.Background(), m)
+	assert.Equal(t, "flex", payload["service_tier"])
+	assert.Equal(t, "flex", stream.ServiceTier())
+
+	generate(ContextWithServiceTier(context.Background(), "priority"), m)
+	assert.Equal(t, "priority", payload["service_tier"])
+}
+
+func TestGenerateHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	m := New("key", "gpt-4.1").WithEndpoint(server.URL, "Test").
+		WithOrganization("org-123").
+		WithProject("proj_456").
+		WithHeader("X-Proxy-Token", "secret")
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	assert.Equal(t, "Bearer key", header.Get("Authorization"))
+	assert.Equal(t, "org-123", header.Get("OpenAI-Organization"))
+	assert.Equal(t, "proj_456", header.Get("OpenAI-Project"))
+	assert.Equal(t, "secret", header.Get("X-Proxy-Token"))
+}
+
+func TestGenerateAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.Header().Set("Retry-After-Ms", "1500")
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached for requests","type":"requests","param":null,"code":"rate_limit_exceeded"}}`))
+	}))
+	defer server.Close()
+
+	stream := New("key", "gpt-4.1").WithEndpoint(server.URL, "Test").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	err := stream.Err()
+	require.Error(t, err)
+	assert.Equal(t, "429 Too Many Requests: requests: Rate limit reached for requests", err.Error())
+	assert.True(t, llms.IsRateLimitError(err))
+
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, "rate_limit_exceeded", apiErr.Code)
+	assert.Equal(t, "requests", apiErr.Type)
+	assert.Empty(t, apiErr.Param)
+	assert.Equal(t, 1500*time.Millisecond, apiErr.RetryAfter)
+	require.True(t, apiErr.HasRateLimits)
+	assert.Equal(t, 0, apiErr.RateLimits.RemainingRequests)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	header := http.Header{}
+	assert.Equal(t, time.Duration(0), parseRetryAfter(header))
+	header.Set("Retry-After", "3")
+	assert.Equal(t, 3*time.Second, parseRetryAfter(header))
+	header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	assert.InDelta(t, float64(time.Minute), float64(parseRetryAfter(header)), float64(2*time.Second))
+}
+
+func TestStreamURLCitations(t *testing.T) {
+	stream := newTestStream(
+		`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Go 1.24 is out."}}]}`,
+		`{"choices":[{"index":0,"delta":{"annotations":[{"type":"url_citation","url_citation":{"url":"https://go.dev/blog/go1.24","title":"Go 1.24 is released!","start_index":0,"end_index":15}}]},"finish_reason":"stop"}]}`,
+		`[DONE]`,
+	)
+	collectStatuses(stream)
+	require.NoError(t, stream.Err())
+	assert.Equal(t, content.Content{
+		&content.Text{Text: "Go 1.24 is out."},
+		&content.Citation{URL: "https://go.dev/blog/go1.24", DocumentTitle: "Go 1.24 is released!", Location: "url", Start: 0, End: 15},
+	}, stream.Message().Content)
+}
+
+func TestGenerateHostedTools(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	toolbox := tools.Box(tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p struct{}) tools.Result {
+		return tools.SuccessFromString("found")
+	}))
+	generate := func(m *Model, toolbox *tools.Toolbox) {
+		stream := m.WithEndpoint(server.URL, "Test").Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, toolbox)
+		require.NoError(t, stream.Err())
+		for range stream.Iter() {
+		}
+	}
+
+	generate(New("key", "gpt-4o-search-preview").WithWebSearch("low"), nil)
+	assert.Equal(t, map[string]any{"search_context_size": "low"}, payload["web_search_options"])
+	assert.NotContains(t, payload, "tools")
+
+	m := New("key", "gpt-4.1").WithHostedTools(map[string]any{"type": "web_search_preview"})
+	generate(m, nil)
+	assert.Equal(t, []any{map[string]any{"type": "web_search_preview"}}, payload["tools"])
+
+	generate(m, toolbox)
+	apiTools := payload["tools"].([]any)
+	require.Len(t, apiTools, 2)
+	assert.Equal(t, "function", apiTools[0].(map[string]any)["type"])
+	assert.Equal(t, map[string]any{"type": "web_search_preview"}, apiTools[1])
+}
@@ -0,0 +1,32 @@
+package openai
+
+import "context"
+
+type predictionKey struct{}
+
+// ContextWithPrediction returns a context that makes requests include the
+// expected output, such as the current version of a file that the model is
+// asked to edit. Parts of the response that match the prediction are
+// generated much faster. How much of it was used is reported by
+// Stream.PredictionTokens.
+func ContextWithPrediction(ctx context.Context, prediction string) context.Context {
+	return context.WithValue(ctx, predictionKey{}, prediction)
+}
+
+// PredictionFromContext returns the prediction set with ContextWithPrediction,
+// or an empty string.
+func PredictionFromContext(ctx context.Context) string {
+	prediction, _ := ctx.Value(predictionKey{}).(string)
+	return prediction
+}
+
+// PredictionTokens returns how many tokens of the prediction were accepted in
+// the response, and how many were rejected (which are still billed as output
+// tokens). Both are zero until the API reports usage.
+func (s *Stream) PredictionTokens() (accepted, rejected int) {
+	if s.usage == nil || s.usage.CompletionTokensDetails == nil {
+		return 0, 0
+	}
+	details := s.usage.CompletionTokensDetails
+	return details.AcceptedPredictionTokens, details.RejectedPredictionTokens
+}
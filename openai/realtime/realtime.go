@@ -0,0 +1,346 @@
+// Package realtime implements sessions with the OpenAI Realtime API, which
+// keeps a WebSocket open for low-latency speech and text conversations. The
+// session reports the same updates as llms.LLM, and runs the same tools.
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/internal/websocket"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+const (
+	UpdateTypeResponseDone llms.UpdateType = "realtime_response_done"
+	UpdateTypeError        llms.UpdateType = "realtime_error"
+)
+
+// ResponseDoneUpdate is sent when the model has finished a response, with the
+// tokens it used.
+type ResponseDoneUpdate struct {
+	InputTokens   int    `json:"input_tokens"`
+	OutputTokens  int    `json:"output_tokens"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+func (u ResponseDoneUpdate) Type() llms.UpdateType {
+	return UpdateTypeResponseDone
+}
+
+// ErrorUpdate is an error that the API reported for an event. The session
+// stays open, since most errors only affect the event that caused them.
+type ErrorUpdate struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+func (u ErrorUpdate) Type() llms.UpdateType {
+	return UpdateTypeError
+}
+
+func init() {
+	llms.RegisterUpdateType[ResponseDoneUpdate]()
+	llms.RegisterUpdateType[ErrorUpdate]()
+}
+
+// Options configures a session.
+type Options struct {
+	// Model defaults to "gpt-4o-realtime-preview".
+	Model string
+	// Endpoint defaults to the OpenAI Realtime endpoint.
+	Endpoint     string
+	Instructions string
+	// Voice is the voice the model speaks with, such as "alloy".
+	Voice string
+	// Modalities defaults to text and audio. Audio is always "pcm16", which is
+	// raw 16-bit little endian samples at 24 kHz, in both directions.
+	Modalities []string
+	// ManualTurns turns off the detection of when the user stops speaking, so
+	// that the user's turn ends with CommitAudio instead.
+	ManualTurns bool
+}
+
+// Session is an open connection to the Realtime API.
+type Session struct {
+	conn    *websocket.Conn
+	toolbox *tools.Toolbox
+	updates chan llms.Update
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// Connect opens a session and configures it with the options and tools. The
+// session lasts until Close is called or the context is done.
+func Connect(ctx context.Context, apiKey string, opts Options, allTools ...tools.Tool) (*Session, error) {
+	if opts.Model == "" {
+		opts.Model = "gpt-4o-realtime-preview"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "wss://api.openai.com/v1/realtime"
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiKey)
+	header.Set("OpenAI-Beta", "realtime=v1")
+	header.Set("User-Agent", llms.UserAgent())
+	conn, err := websocket.Dial(ctx, opts.Endpoint+"?model="+url.QueryEscape(opts.Model), header)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to Realtime API: %w", err)
+	}
+
+	sessionConfig := map[string]any{
+		"input_audio_format":  "pcm16",
+		"output_audio_format": "pcm16",
+	}
+	if opts.Instructions != "" {
+		sessionConfig["instructions"] = opts.Instructions
+	}
+	if opts.Voice != "" {
+		sessionConfig["voice"] = opts.Voice
+	}
+	if len(opts.Modalities) > 0 {
+		sessionConfig["modalities"] = opts.Modalities
+	}
+	if opts.ManualTurns {
+		sessionConfig["turn_detection"] = nil
+	}
+	var toolbox *tools.Toolbox
+	if len(allTools) > 0 {
+		toolbox = tools.Box(allTools...)
+		var apiTools []map[string]any
+		for _, t := range toolbox.All() {
+			schema := t.Schema()
+			apiTools = append(apiTools, map[string]any{
+				"type":        "function",
+				"name":        schema.Name,
+				"description": schema.Description,
+				"parameters":  schema.Parameters,
+			})
+		}
+		sessionConfig["tools"] = apiTools
+		sessionConfig["tool_choice"] = "auto"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Session{conn: conn, toolbox: toolbox, updates: make(chan llms.Update), ctx: ctx, cancel: cancel}
+	if err := s.send(map[string]any{"type": "session.update", "session": sessionConfig}); err != nil {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go s.readLoop()
+	return s, nil
+}
+
+// Updates returns the channel of updates of the session, which is closed when
+// the session ends. It must be read continuously, since the session waits for
+// every update to be received.
+func (s *Session) Updates() <-chan llms.Update {
+	return s.updates
+}
+
+// Err returns the error that ended the session, if any.
+func (s *Session) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// SendText adds a message from the user and asks the model to respond.
+func (s *Session) SendText(text string) error {
+	err := s.send(map[string]any{
+		"type": "conversation.item.create",
+		"item": map[string]any{
+			"type":    "message",
+			"role":    "user",
+			"content": []map[string]any{{"type": "input_text", "text": text}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return s.send(map[string]any{"type": "response.create"})
+}
+
+// AppendAudio streams a chunk of the user's speech, in pcm16. Unless
+// ManualTurns is set, the model responds on its own when the user stops
+// speaking.
+func (s *Session) AppendAudio(pcm []byte) error {
+	return s.send(map[string]any{"type": "input_audio_buffer.append", "audio": base64.StdEncoding.EncodeToString(pcm)})
+}
+
+// CommitAudio ends the user's turn and asks the model to respond, for sessions
+// with ManualTurns.
+func (s *Session) CommitAudio() error {
+	if err := s.send(map[string]any{"type": "input_audio_buffer.commit"}); err != nil {
+		return err
+	}
+	return s.send(map[string]any{"type": "response.create"})
+}
+
+// Close ends the session.
+func (s *Session) Close() error {
+	s.cancel()
+	return nil
+}
+
+func (s *Session) send(event map[string]any) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+	return s.conn.Write(websocket.OpText, data)
+}
+
+type serverEvent struct {
+	Type  string `json:"type"`
+	Delta string `json:"delta"`
+	// Name, CallID and Arguments are set for completed function calls.
+	Name      string `json:"name"`
+	CallID    string `json:"call_id"`
+	Arguments string `json:"arguments"`
+	Error     *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Response *struct {
+		Usage *struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"response"`
+}
+
+func (s *Session) readLoop() {
+	defer close(s.updates)
+	defer s.cancel()
+	correlationID := llms.GetCorrelationID(s.ctx)
+	// ranTools is set when tool results have been added to the conversation
+	// during the current response, which means the model should respond again
+	// once it's done.
+	var ranTools bool
+	for {
+		_, data, err := s.conn.Read()
+		if err != nil {
+			if s.ctx.Err() == nil && !errors.Is(err, websocket.ErrClosed) {
+				s.setErr(err)
+			}
+			return
+		}
+		var event serverEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			s.setErr(fmt.Errorf("error decoding event: %w", err))
+			return
+		}
+		var update llms.Update
+		switch event.Type {
+		case "response.text.delta", "response.audio_transcript.delta":
+			update = llms.TextUpdate{Text: event.Delta, CorrelationID: correlationID}
+		case "response.audio.delta":
+			audio, err := base64.StdEncoding.DecodeString(event.Delta)
+			if err != nil {
+				s.setErr(fmt.Errorf("error decoding audio: %w", err))
+				return
+			}
+			update = llms.AudioUpdate{Audio: audio, CorrelationID: correlationID}
+		case "response.function_call_arguments.done":
+			output := s.runToolCall(llms.ToolCall{ID: event.CallID, Name: event.Name, Arguments: json.RawMessage(event.Arguments)})
+			err := s.send(map[string]any{
+				"type": "conversation.item.create",
+				"item": map[string]any{"type": "function_call_output", "call_id": event.CallID, "output": output},
+			})
+			if err != nil {
+				s.setErr(err)
+				return
+			}
+			ranTools = true
+		case "response.done":
+			var done ResponseDoneUpdate
+			if event.Response != nil && event.Response.Usage != nil {
+				done.InputTokens, done.OutputTokens = event.Response.Usage.InputTokens, event.Response.Usage.OutputTokens
+			}
+			done.CorrelationID = correlationID
+			update = done
+			if ranTools {
+				ranTools = false
+				if err := s.send(map[string]any{"type": "response.create"}); err != nil {
+					s.setErr(err)
+					return
+				}
+			}
+		case "error":
+			if event.Error != nil {
+				update = ErrorUpdate{Code: event.Error.Code, Message: event.Error.Message, CorrelationID: correlationID}
+			}
+		}
+		if update != nil && !s.emit(update) {
+			return
+		}
+	}
+}
+
+// runToolCall runs the tool call like llms.LLM does, and returns its result as
+// the text that the Realtime API expects.
+func (s *Session) runToolCall(toolCall llms.ToolCall) string {
+	if s.toolbox == nil {
+		return fmt.Sprintf("Tool %q is not available.", toolCall.Name)
+	}
+	correlationID := llms.GetCorrelationID(s.ctx)
+	t := s.toolbox.Get(toolCall.Name)
+	if t != nil {
+		s.emit(llms.ToolStartUpdate{ToolCallID: toolCall.ID, Tool: t, CorrelationID: correlationID})
+	}
+	accepted := []content.Type{content.TypeText, content.TypeJSON}
+	ctx := context.WithValue(s.ctx, llms.ToolCallContextKey, toolCall)
+	if t != nil {
+		ctx = tools.ContextWithResultTypes(ctx, tools.NegotiateResultTypes(t, accepted))
+	}
+	runner := tools.NewRunner(ctx, s.toolbox, func(status string) {
+		s.emit(llms.ToolStatusUpdate{ToolCallID: toolCall.ID, Status: status, Tool: t, CorrelationID: correlationID})
+	})
+	result := s.toolbox.Run(runner, toolCall.Name, toolCall.Arguments)
+	s.emit(llms.ToolDoneUpdate{ToolCallID: toolCall.ID, Result: result, Tool: t, CorrelationID: correlationID})
+
+	var parts []string
+	for _, item := range tools.ConvertContent(result.Content(), accepted) {
+		switch v := item.(type) {
+		case *content.Text:
+			parts = append(parts, v.Text)
+		case *content.JSON:
+			parts = append(parts, string(v.Data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (s *Session) emit(update llms.Update) bool {
+	select {
+	case s.updates <- update:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func (s *Session) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
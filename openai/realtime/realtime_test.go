@@ -0,0 +1,96 @@
+package realtime
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blixt/go-llms/internal/websocket"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	received := make(chan map[string]any, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gpt-4o-realtime-preview", r.URL.Query().Get("model"))
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		conn, err := websocket.Upgrade(w, r)
+		require.NoError(t, err)
+		defer conn.Close()
+		read := func() map[string]any {
+			_, data, err := conn.Read()
+			require.NoError(t, err)
+			var event map[string]any
+			require.NoError(t, json.Unmarshal(data, &event))
+			received <- event
+			return event
+		}
+		send := func(event string) {
+			require.NoError(t, conn.Write(websocket.OpText, []byte(event)))
+		}
+		read() // session.update
+		read() // conversation.item.create
+		read() // response.create
+		send(`{"type":"response.function_call_arguments.done","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}`)
+		read() // function_call_output
+		send(`{"type":"response.done","response":{"usage":{"input_tokens":10,"output_tokens":5}}}`)
+		read() // response.create
+		send(`{"type":"response.audio_transcript.delta","delta":"It's sunny."}`)
+		send(`{"type":"response.audio.delta","delta":"` + base64.StdEncoding.EncodeToString([]byte{1, 2, 3}) + `"}`)
+		send(`{"type":"response.done","response":{"usage":{"input_tokens":20,"output_tokens":8}}}`)
+		conn.Read() // Wait for the client to close.
+	}))
+	defer server.Close()
+
+	type weatherParams struct {
+		City string `json:"city"`
+	}
+	weather := tools.Func("Weather", "Gets the weather", "get_weather", func(r tools.Runner, p weatherParams) tools.Result {
+		return tools.Success(map[string]string{"weather": "sunny"})
+	})
+	endpoint := "ws" + strings.TrimPrefix(server.URL, "http")
+	session, err := Connect(context.Background(), "key", Options{Endpoint: endpoint, Instructions: "Be brief.", Voice: "alloy"}, weather)
+	require.NoError(t, err)
+	require.NoError(t, session.SendText("What's the weather in Paris?"))
+
+	var types []llms.UpdateType
+	for update := range session.Updates() {
+		types = append(types, update.Type())
+		switch u := update.(type) {
+		case llms.TextUpdate:
+			assert.Equal(t, "It's sunny.", u.Text)
+		case llms.AudioUpdate:
+			assert.Equal(t, []byte{1, 2, 3}, u.Audio)
+		case ResponseDoneUpdate:
+			if u.InputTokens == 20 {
+				session.Close()
+			}
+		}
+	}
+	assert.NoError(t, session.Err())
+	assert.Equal(t, []llms.UpdateType{
+		llms.UpdateTypeToolStart, llms.UpdateTypeToolDone, UpdateTypeResponseDone,
+		llms.UpdateTypeText, llms.UpdateTypeAudio, UpdateTypeResponseDone,
+	}, types)
+
+	sessionUpdate := <-received
+	assert.Equal(t, "session.update", sessionUpdate["type"])
+	config := sessionUpdate["session"].(map[string]any)
+	assert.Equal(t, "Be brief.", config["instructions"])
+	assert.Equal(t, "alloy", config["voice"])
+	assert.Len(t, config["tools"], 1)
+	assert.Equal(t, "conversation.item.create", (<-received)["type"])
+	assert.Equal(t, "response.create", (<-received)["type"])
+	output := (<-received)["item"].(map[string]any)
+	assert.Equal(t, "function_call_output", output["type"])
+	assert.Equal(t, "call_1", output["call_id"])
+	assert.JSONEq(t, `{"weather":"sunny"}`, output["output"].(string))
+	assert.Equal(t, "response.create", (<-received)["type"])
+}
@@ -0,0 +1,34 @@
+package openai
+
+import "context"
+
+type serviceTierKey struct{}
+
+// WithServiceTier sets the processing tier of requests: "flex" for cheaper
+// but slower processing that may be unavailable at times, "priority" for
+// faster processing at a higher price, or "default". The tier that served a
+// response is reported by Stream.ServiceTier. The tier of a single request can
+// be set with ContextWithServiceTier.
+func (m *Model) WithServiceTier(tier string) *Model {
+	m.serviceTier = tier
+	return m
+}
+
+// ContextWithServiceTier returns a context that makes requests use the given
+// service tier, overriding the one set with WithServiceTier.
+func ContextWithServiceTier(ctx context.Context, tier string) context.Context {
+	return context.WithValue(ctx, serviceTierKey{}, tier)
+}
+
+// ServiceTierFromContext returns the service tier set with
+// ContextWithServiceTier, or an empty string.
+func ServiceTierFromContext(ctx context.Context) string {
+	tier, _ := ctx.Value(serviceTierKey{}).(string)
+	return tier
+}
+
+// ServiceTier returns the service tier that processed the response, as
+// reported by the API, which may differ from the requested one.
+func (s *Stream) ServiceTier() string {
+	return s.serviceTier
+}
@@ -0,0 +1,99 @@
+package openai
+
+import (
+	"encoding/json"
+	"slices"
+
+	"github.com/blixt/go-llms/tools"
+)
+
+// WithStrictTools enables strict mode for the functions with the given names,
+// or for all functions if no names are given. OpenAI then guarantees that the
+// arguments of tool calls conform to the schema of the function. Strict mode
+// requires every property to be required, so optional properties are made
+// nullable and may arrive as null instead of being left out. Functions whose
+// schemas can't be expressed in strict mode, such as those with map
+// parameters, are sent as regular functions.
+func (m *Model) WithStrictTools(funcNames ...string) *Model {
+	m.strictTools = true
+	m.strictFuncNames = funcNames
+	return m
+}
+
+// tools returns the tools of the toolbox, in strict mode if it's enabled for
+// them.
+func (m *Model) tools(toolbox *tools.Toolbox) []Tool {
+	apiTools := Tools(toolbox)
+	if !m.strictTools {
+		return apiTools
+	}
+	for i, t := range apiTools {
+		if len(m.strictFuncNames) > 0 && !slices.Contains(m.strictFuncNames, t.Function.Name) {
+			continue
+		}
+		if parameters, ok := strictSchema(t.Function.Parameters, false); ok {
+			apiTools[i].strictParameters = parameters
+		}
+	}
+	return apiTools
+}
+
+// strictSchema converts a schema to one that is valid in strict mode, where
+// every object lists all of its properties as required and doesn't allow any
+// other properties. It reports false if that isn't possible.
+func strictSchema(schema tools.ValueSchema, nullable bool) (map[string]any, bool) {
+	result := map[string]any{"type": schema.Type}
+	if nullable {
+		result["type"] = []string{schema.Type, "null"}
+	}
+	if schema.Description != "" {
+		result["description"] = schema.Description
+	}
+	switch schema.Type {
+	case "array":
+		if schema.Items == nil {
+			return nil, false
+		}
+		items, ok := strictSchema(*schema.Items, false)
+		if !ok {
+			return nil, false
+		}
+		result["items"] = items
+	case "object":
+		// Objects with arbitrary keys aren't supported in strict mode.
+		if schema.Properties == nil || schema.AdditionalProperties != nil {
+			return nil, false
+		}
+		properties := map[string]any{}
+		required := []string{}
+		for name, property := range *schema.Properties {
+			converted, ok := strictSchema(property, !slices.Contains(schema.Required, name))
+			if !ok {
+				return nil, false
+			}
+			properties[name] = converted
+			required = append(required, name)
+		}
+		slices.Sort(required)
+		result["properties"] = properties
+		result["required"] = required
+		result["additionalProperties"] = false
+	}
+	return result, true
+}
+
+func (t Tool) MarshalJSON() ([]byte, error) {
+	if t.strictParameters == nil {
+		type plain Tool
+		return json.Marshal(plain(t))
+	}
+	return json.Marshal(map[string]any{
+		"type": t.Type,
+		"function": map[string]any{
+			"name":        t.Function.Name,
+			"description": t.Function.Description,
+			"parameters":  t.strictParameters,
+			"strict":      true,
+		},
+	})
+}
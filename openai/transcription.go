@@ -0,0 +1,172 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// TranscriptionOptions configures a request to the audio transcription API.
+type TranscriptionOptions struct {
+	APIKey string
+	// Model defaults to "gpt-4o-transcribe".
+	Model string
+	// Endpoint defaults to the OpenAI transcription endpoint.
+	Endpoint string
+	// Filename tells the API what format the audio is in, for example
+	// "audio.mp3". It defaults to "audio.wav".
+	Filename string
+	// Language is the ISO-639-1 code of the spoken language, if known.
+	Language string
+	// Prompt can guide the style of the transcript or help with unusual words.
+	Prompt string
+}
+
+// Transcribe transcribes the audio with the audio transcription API and
+// returns the transcript. Set Model to "whisper-1" to use Whisper.
+func Transcribe(ctx context.Context, audio io.Reader, opts TranscriptionOptions) (string, error) {
+	resp, err := postTranscription(ctx, audio, opts, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return decodeTranscription(resp.Body)
+}
+
+// TranscribeStream transcribes the audio, calling onDelta with each piece of
+// the transcript as it becomes available, and returns the full transcript.
+// The audio is uploaded as it is read, so it can be a live recording. Models
+// that don't support streaming (such as whisper-1) call onDelta once with the
+// whole transcript.
+func TranscribeStream(ctx context.Context, audio io.Reader, opts TranscriptionOptions, onDelta func(delta string)) (string, error) {
+	fields := map[string]string{"stream": "true"}
+	resp, err := postTranscription(ctx, audio, opts, fields)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		text, err := decodeTranscription(resp.Body)
+		if err == nil && text != "" && onDelta != nil {
+			onDelta(text)
+		}
+		return text, err
+	}
+
+	var transcript strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type  string `json:"type"`
+			Delta string `json:"delta"`
+			Text  string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return "", fmt.Errorf("error unmarshalling transcription event: %w", err)
+		}
+		switch event.Type {
+		case "transcript.text.delta":
+			transcript.WriteString(event.Delta)
+			if onDelta != nil {
+				onDelta(event.Delta)
+			}
+		case "transcript.text.done":
+			return event.Text, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error scanning transcription stream: %w", err)
+	}
+	return transcript.String(), nil
+}
+
+// postTranscription uploads the audio as a multipart form, streaming it from
+// the reader, and returns the successful response.
+func postTranscription(ctx context.Context, audio io.Reader, opts TranscriptionOptions, fields map[string]string) (*http.Response, error) {
+	if opts.Model == "" {
+		opts.Model = "gpt-4o-transcribe"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://api.openai.com/v1/audio/transcriptions"
+	}
+	if opts.Filename == "" {
+		opts.Filename = "audio.wav"
+	}
+	allFields := map[string]string{"model": opts.Model}
+	if opts.Language != "" {
+		allFields["language"] = opts.Language
+	}
+	if opts.Prompt != "" {
+		allFields["prompt"] = opts.Prompt
+	}
+	for key, value := range fields {
+		allFields[key] = value
+	}
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		for key, value := range allFields {
+			if err := form.WriteField(key, value); err != nil {
+				writer.CloseWithError(err)
+				return
+			}
+		}
+		part, err := form.CreateFormFile("file", opts.Filename)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(part, audio); err != nil {
+			writer.CloseWithError(fmt.Errorf("error reading audio: %w", err))
+			return
+		}
+		writer.CloseWithError(form.Close())
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", opts.Endpoint, body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", opts.APIKey))
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("User-Agent", llms.UserAgent())
+
+	resp, err := llms.HTTPClientFromContext(ctx).Do(req)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body.Close()
+		return nil, newAPIError(resp)
+	}
+	return resp, nil
+}
+
+func decodeTranscription(r io.Reader) (string, error) {
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return "", fmt.Errorf("error decoding transcription: %w", err)
+	}
+	return result.Text, nil
+}
@@ -0,0 +1,107 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscribeStream(t *testing.T) {
+	var fields map[string]string
+	var audio string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.Equal(t, llms.UserAgent(), r.Header.Get("User-Agent"))
+		reader, err := r.MultipartReader()
+		require.NoError(t, err)
+		fields = make(map[string]string)
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			data, _ := io.ReadAll(part)
+			if part.FormName() == "file" {
+				assert.Equal(t, "speech.mp3", part.FileName())
+				audio = string(data)
+			} else {
+				fields[part.FormName()] = string(data)
+			}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"transcript.text.delta","delta":"Hello"}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"transcript.text.delta","delta":" world."}`+"\n\n")
+		fmt.Fprint(w, `data: {"type":"transcript.text.done","text":"Hello world."}`+"\n\n")
+	}))
+	defer server.Close()
+
+	var deltas []string
+	text, err := TranscribeStream(context.Background(), strings.NewReader("audio bytes"), TranscriptionOptions{
+		APIKey:   "key",
+		Endpoint: server.URL,
+		Filename: "speech.mp3",
+		Language: "en",
+	}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world.", text)
+	assert.Equal(t, []string{"Hello", " world."}, deltas)
+	assert.Equal(t, "audio bytes", audio)
+	assert.Equal(t, map[string]string{"model": "gpt-4o-transcribe", "stream": "true", "language": "en"}, fields)
+}
+
+func TestTranscribeStreamUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"text":"Hello world."}`)
+	}))
+	defer server.Close()
+
+	var deltas []string
+	text, err := TranscribeStream(context.Background(), strings.NewReader("audio"), TranscriptionOptions{Model: "whisper-1", Endpoint: server.URL}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world.", text)
+	assert.Equal(t, []string{"Hello world."}, deltas, "The whole transcript should be reported at once")
+}
+
+func TestTranscribe(t *testing.T) {
+	var fields map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseMultipartForm(1<<20))
+		fields = make(map[string]string)
+		for key, values := range r.MultipartForm.Value {
+			fields[key] = values[0]
+		}
+		if fields["model"] == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"The model does not exist","type":"invalid_request_error"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"text":"Hello world."}`)
+	}))
+	defer server.Close()
+
+	text, err := Transcribe(context.Background(), strings.NewReader("audio"), TranscriptionOptions{Model: "whisper-1", Endpoint: server.URL, Prompt: "Greetings."})
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world.", text)
+	assert.Equal(t, map[string]string{"model": "whisper-1", "prompt": "Greetings."}, fields)
+
+	_, err = Transcribe(context.Background(), strings.NewReader("audio"), TranscriptionOptions{Model: "missing", Endpoint: server.URL})
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, "404 Not Found: invalid_request_error: The model does not exist", err.Error())
+}
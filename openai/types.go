@@ -12,6 +12,10 @@ import (
 type Tool struct {
 	Type     string               `json:"type"`
 	Function tools.FunctionSchema `json:"function"`
+
+	// strictParameters replaces the parameters of the function when it's sent
+	// in strict mode.
+	strictParameters map[string]any
 }
 
 type imageURL struct {
@@ -19,10 +23,23 @@ type imageURL struct {
 	Detail string `json:"detail,omitempty"`
 }
 
+type inputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
+
 type contentPart struct {
-	Type     string    `json:"type"`
-	Text     *string   `json:"text,omitempty"`
-	ImageURL *imageURL `json:"image_url,omitempty"`
+	Type       string      `json:"type"`
+	Text       *string     `json:"text,omitempty"`
+	ImageURL   *imageURL   `json:"image_url,omitempty"`
+	InputAudio *inputAudio `json:"input_audio,omitempty"`
+	File       *file       `json:"file,omitempty"`
+}
+
+type file struct {
+	Filename string `json:"filename,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
 }
 
 type contentList []contentPart
@@ -46,6 +63,36 @@ func convertContent(c content.Content) contentList {
 			cp.Type = "text"
 			text := string(v.Data)
 			cp.Text = &text
+		case *content.Audio:
+			cp.Type = "input_audio"
+			cp.InputAudio = &inputAudio{Data: v.Data, Format: v.Format}
+		case *content.Document:
+			if v.MediaType == "text/plain" {
+				cp.Type = "text"
+				text := v.Data
+				if v.Title != "" {
+					text = v.Title + "\n\n" + text
+				}
+				cp.Text = &text
+			} else if v.URL != "" {
+				// Chat completions only accept files that are sent along or
+				// uploaded, so the model is told where the document is.
+				cp.Type = "text"
+				text := fmt.Sprintf("[Document %q at %s]", v.Title, v.URL)
+				cp.Text = &text
+			} else {
+				cp.Type = "file"
+				cp.File = &file{Filename: v.Title, FileData: fmt.Sprintf("data:%s;base64,%s", v.MediaType, v.Data)}
+			}
+		case *content.File:
+			cp.Type = "file"
+			cp.File = &file{FileID: v.ID}
+		case *content.Citation:
+			// Citations are only understood by the provider that made them.
+			continue
+		case *content.Thinking:
+			// Reasoning is not sent back to the API.
+			continue
 		default:
 			panic(fmt.Sprintf("unhandled content item type %T", item))
 		}
@@ -132,6 +179,10 @@ func messagesFromLLM(m llms.Message) []message {
 
 	apiRole := m.Role
 	apiContent := convertContent(m.Content)
+	if len(apiContent) == 0 && m.Refusal != "" {
+		// The refusal is the only thing the assistant said.
+		apiContent = contentList{{Type: "text", Text: &m.Refusal}}
+	}
 
 	if len(apiContent) == 0 && len(m.ToolCalls) == 0 {
 		return []message{}
@@ -168,6 +219,26 @@ type toolCallFunction struct {
 	Arguments string `json:"arguments"`
 }
 
+// UnmarshalJSON also accepts arguments encoded as a JSON object rather than a
+// string, which some OpenAI-compatible servers send.
+func (f *toolCallFunction) UnmarshalJSON(data []byte) error {
+	var value struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	f.Name = value.Name
+	f.Arguments = ""
+	if len(value.Arguments) > 0 && value.Arguments[0] == '"' {
+		return json.Unmarshal(value.Arguments, &f.Arguments)
+	} else if len(value.Arguments) > 0 && string(value.Arguments) != "null" {
+		f.Arguments = string(value.Arguments)
+	}
+	return nil
+}
+
 type toolCall struct {
 	ID       string           `json:"id"`
 	Type     string           `json:"type"`
@@ -198,16 +269,66 @@ func (t toolCallDelta) ToLLM() llms.ToolCall {
 }
 
 type chatCompletionDelta struct {
-	Role      string          `json:"role,omitempty"`
-	Content   *string         `json:"content,omitempty"`
-	ToolCalls []toolCallDelta `json:"tool_calls,omitempty"`
+	Role             string          `json:"role,omitempty"`
+	Content          *string         `json:"content,omitempty"`
+	ReasoningContent *string         `json:"reasoning_content,omitempty"`
+	Refusal          *string         `json:"refusal,omitempty"`
+	ToolCalls        []toolCallDelta `json:"tool_calls,omitempty"`
+	Audio            *audioDelta     `json:"audio,omitempty"`
+	Annotations      []annotation    `json:"annotations,omitempty"`
+}
+
+// annotation is a citation of a web page that a search model found.
+type annotation struct {
+	Type        string `json:"type"`
+	URLCitation *struct {
+		URL        string `json:"url"`
+		Title      string `json:"title"`
+		StartIndex int    `json:"start_index"`
+		EndIndex   int    `json:"end_index"`
+	} `json:"url_citation,omitempty"`
+}
+
+func (a annotation) ToLLM() (*content.Citation, bool) {
+	if a.Type != "url_citation" || a.URLCitation == nil {
+		return nil, false
+	}
+	return &content.Citation{
+		URL:           a.URLCitation.URL,
+		DocumentTitle: a.URLCitation.Title,
+		Location:      "url",
+		Start:         a.URLCitation.StartIndex,
+		End:           a.URLCitation.EndIndex,
+	}, true
+}
+
+type audioDelta struct {
+	ID         string `json:"id,omitempty"`
+	Data       string `json:"data,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
 }
 
 type chatCompletionChoice struct {
 	Index        int                 `json:"index"`
 	Delta        chatCompletionDelta `json:"delta"`
 	FinishReason *string             `json:"finish_reason"`
-	Logprobs     interface{}         `json:"logprobs"`
+	Logprobs     *choiceLogprobs     `json:"logprobs"`
+}
+
+type choiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob is the log probability of a token in a response.
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// Bytes is the UTF-8 encoding of the token, which is useful when a
+	// character is split over several tokens.
+	Bytes []int `json:"bytes"`
+	// TopLogprobs are the most likely tokens at this position, including the
+	// one that was picked, if they were requested with WithLogprobs.
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
 }
 
 type chatCompletionChunk struct {
@@ -216,12 +337,25 @@ type chatCompletionChunk struct {
 	Created           int64                  `json:"created"`
 	Model             string                 `json:"model"`
 	SystemFingerprint string                 `json:"system_fingerprint,omitempty"`
+	ServiceTier       string                 `json:"service_tier,omitempty"`
 	Choices           []chatCompletionChoice `json:"choices"`
 	Usage             *usage                 `json:"usage,omitempty"`
 }
 
 type usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens            int                      `json:"prompt_tokens"`
+	CompletionTokens        int                      `json:"completion_tokens"`
+	TotalTokens             int                      `json:"total_tokens"`
+	PromptTokensDetails     *promptTokensDetails     `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *completionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+type promptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+type completionTokensDetails struct {
+	ReasoningTokens          int `json:"reasoning_tokens"`
+	AcceptedPredictionTokens int `json:"accepted_prediction_tokens"`
+	RejectedPredictionTokens int `json:"rejected_prediction_tokens"`
 }
@@ -47,6 +47,53 @@ func TestMessagesFromLLM_OpenAI(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "User message - text and audio",
+			input: llms.Message{
+				Role:    "user",
+				Content: append(content.FromText("Transcribe this:"), content.FromAudio([]byte("RIFF"), "wav")...),
+			},
+			expected: []message{
+				{
+					Role: "user",
+					Content: contentList{
+						{Type: "text", Text: ptr("Transcribe this:")},
+						{Type: "input_audio", InputAudio: &inputAudio{Data: "UklGRg==", Format: "wav"}},
+					},
+				},
+			},
+		},
+		{
+			name: "User message - text and uploaded file",
+			input: llms.Message{
+				Role:    "user",
+				Content: append(content.FromText("Summarize this:"), content.FromFile("file-abc")...),
+			},
+			expected: []message{
+				{
+					Role: "user",
+					Content: contentList{
+						{Type: "text", Text: ptr("Summarize this:")},
+						{Type: "file", File: &file{FileID: "file-abc"}},
+					},
+				},
+			},
+		},
+		{
+			name: "Assistant message - refusal",
+			input: llms.Message{
+				Role:    "assistant",
+				Refusal: "I can't help with that.",
+			},
+			expected: []message{
+				{
+					Role: "assistant",
+					Content: contentList{
+						{Type: "text", Text: ptr("I can't help with that.")},
+					},
+				},
+			},
+		},
 		{
 			name: "Assistant message - text only",
 			input: llms.Message{
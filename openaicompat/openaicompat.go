@@ -0,0 +1,75 @@
+// Package openaicompat provides access to arbitrary servers that implement the
+// OpenAI chat completions API, such as vLLM, LiteLLM, and KoboldCpp. Few of
+// them implement every detail of the API, so Quirks can be used to avoid the
+// parts a server doesn't support.
+package openaicompat
+
+import (
+	"context"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/openai"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Quirks describe the ways in which a server differs from the OpenAI API.
+type Quirks struct {
+	// NoStreamOptions stops sending stream_options, for servers that reject
+	// requests containing it.
+	NoStreamOptions bool
+	// EstimateMissingUsage estimates token usage from the length of the
+	// request and response when the server doesn't report usage.
+	EstimateMissingUsage bool
+	// LenientToolCalls accepts tool calls that aren't numbered properly or that
+	// lack IDs, which some servers produce for parallel tool calls.
+	LenientToolCalls bool
+	// NoStreaming requests whole responses instead of streams, for servers
+	// and proxies that don't support server-sent events.
+	NoStreaming bool
+}
+
+type Model struct {
+	*openai.Model
+	quirks Quirks
+}
+
+// New returns a provider for the server at the given chat completions
+// endpoint, for example "http://localhost:8000/v1/chat/completions". The
+// API key may be empty for servers that don't need one.
+func New(endpoint, apiKey, model string, quirks Quirks) *Model {
+	m := openai.New(apiKey, model).WithEndpoint(endpoint, "OpenAI-compatible")
+	if quirks.NoStreamOptions {
+		m.WithoutStreamOptions()
+	}
+	if quirks.LenientToolCalls {
+		m.WithLenientToolCalls()
+	}
+	if quirks.NoStreaming {
+		m.WithStreaming(false)
+	}
+	return &Model{Model: m, quirks: quirks}
+}
+
+func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	s := m.Model.Generate(ctx, systemPrompt, messages, toolbox)
+	if !m.quirks.EstimateMissingUsage {
+		return s
+	}
+	inputTokens := llms.EstimateTokens(systemPrompt) + llms.EstimateMessageTokens(messages)
+	return &Stream{Stream: s.(*openai.Stream), inputTokens: inputTokens}
+}
+
+// Stream estimates usage when the server doesn't report it.
+type Stream struct {
+	*openai.Stream
+	inputTokens int
+}
+
+func (s *Stream) Usage() (inputTokens, outputTokens int) {
+	inputTokens, outputTokens = s.Stream.Usage()
+	if inputTokens > 0 || outputTokens > 0 {
+		return inputTokens, outputTokens
+	}
+	return s.inputTokens, llms.EstimateMessageTokens([]llms.Message{s.Message()})
+}
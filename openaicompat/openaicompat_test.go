@@ -0,0 +1,67 @@
+package openaicompat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuirks(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "No API key should be sent")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Both tool calls use index 0, the second has no ID, and the arguments
+		// of the second are an object rather than a string.
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"a","type":"function","function":{"name":"first","arguments":""}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"x\":1}"}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"type":"function","function":{"name":"second","arguments":{"y":2}}}]}}]}`+"\n\n")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	m := New(server.URL, "", "local-model", Quirks{
+		NoStreamOptions:      true,
+		EstimateMissingUsage: true,
+		LenientToolCalls:     true,
+	})
+	assert.Equal(t, "local-model", m.Model.Model())
+
+	stream := m.Generate(context.Background(), content.FromText("Be brief."), []llms.Message{{Role: "user", Content: content.FromText("Call the tools please")}}, nil)
+	require.NoError(t, stream.Err())
+	var statuses []llms.StreamStatus
+	for status := range stream.Iter() {
+		statuses = append(statuses, status)
+	}
+	require.NoError(t, stream.Err())
+
+	assert.NotContains(t, body, "stream_options")
+	assert.Equal(t, []llms.StreamStatus{
+		llms.StreamStatusToolCallBegin,
+		llms.StreamStatusToolCallData,
+		llms.StreamStatusToolCallBegin,
+		llms.StreamStatusToolCallReady,
+		llms.StreamStatusToolCallReady,
+	}, statuses)
+	toolCalls := stream.Message().ToolCalls
+	require.Len(t, toolCalls, 2)
+	assert.Equal(t, "a", toolCalls[0].ID)
+	assert.JSONEq(t, `{"x":1}`, string(toolCalls[0].Arguments))
+	assert.Equal(t, "second", toolCalls[1].Name)
+	assert.NotEmpty(t, toolCalls[1].ID, "A missing ID should be generated")
+	assert.JSONEq(t, `{"y":2}`, string(toolCalls[1].Arguments))
+
+	inputTokens, outputTokens := stream.Usage()
+	assert.Equal(t, 9, inputTokens, "Usage should be estimated from the request")
+	assert.Equal(t, 7, outputTokens, "Usage should be estimated from the response")
+}
@@ -0,0 +1,72 @@
+// Package openrouter provides access to the models available through
+// OpenRouter, which routes requests to many upstream providers using an
+// OpenAI-compatible API.
+package openrouter
+
+import (
+	"github.com/blixt/go-llms/openai"
+)
+
+const endpoint = "https://openrouter.ai/api/v1/chat/completions"
+
+// ProviderPreferences controls how OpenRouter picks the upstream provider for
+// a request. See https://openrouter.ai/docs/features/provider-routing
+type ProviderPreferences struct {
+	// Order lists the providers to try first, in order.
+	Order []string `json:"order,omitempty"`
+	// AllowFallbacks can be set to false to only use the providers in Order.
+	AllowFallbacks *bool `json:"allow_fallbacks,omitempty"`
+	// RequireParameters only uses providers that support all parameters in
+	// the request.
+	RequireParameters bool `json:"require_parameters,omitempty"`
+	// DataCollection is either "allow" or "deny".
+	DataCollection string `json:"data_collection,omitempty"`
+	// Only restricts routing to these providers.
+	Only []string `json:"only,omitempty"`
+	// Ignore excludes these providers.
+	Ignore []string `json:"ignore,omitempty"`
+	// Sort is "price", "throughput", or "latency".
+	Sort string `json:"sort,omitempty"`
+}
+
+// Model is an OpenRouter model. The model that actually served a response is
+// available from the Model method of the stream, since OpenRouter may fall
+// back to other models.
+//
+// Options of openai.Model that only apply to OpenAI, such as WithStore and
+// WithServiceTier, are still available but return the embedded *openai.Model.
+type Model struct {
+	*openai.Model
+	model string
+}
+
+// New returns a provider for the given OpenRouter model, such as
+// "anthropic/claude-3.7-sonnet".
+func New(apiKey, model string) *Model {
+	m := &Model{
+		Model: openai.New(apiKey, model).WithEndpoint(endpoint, "OpenRouter"),
+		model: model,
+	}
+	return m.WithAppInfo("https://github.com/blixt/go-llms", "go-llms")
+}
+
+// WithAppInfo sets the headers OpenRouter uses to attribute requests to an
+// app. The URL and name appear in the OpenRouter rankings.
+func (m *Model) WithAppInfo(siteURL, appName string) *Model {
+	m.Model.WithHeader("HTTP-Referer", siteURL)
+	m.Model.WithHeader("X-Title", appName)
+	return m
+}
+
+// WithFallbacks sets the models to try, in order, if the primary model is
+// unavailable or refuses the request.
+func (m *Model) WithFallbacks(models ...string) *Model {
+	m.Model.WithExtraBody("models", append([]string{m.model}, models...))
+	return m
+}
+
+// WithProviderPreferences controls which upstream providers serve requests.
+func (m *Model) WithProviderPreferences(preferences ProviderPreferences) *Model {
+	m.Model.WithExtraBody("provider", preferences)
+	return m
+}
@@ -0,0 +1,78 @@
+package openrouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouting(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": OPENROUTER PROCESSING\n\n")
+		fmt.Fprint(w, `data: {"model":"mistralai/mistral-large","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	allowFallbacks := false
+	m := New("key", "openai/gpt-4.1").
+		WithFallbacks("mistralai/mistral-large").
+		WithProviderPreferences(ProviderPreferences{Order: []string{"OpenAI"}, AllowFallbacks: &allowFallbacks})
+	m.WithEndpoint(server.URL, "OpenRouter")
+
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hello")}}, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+
+	assert.Equal(t, "Bearer key", header.Get("Authorization"))
+	assert.Equal(t, "https://github.com/blixt/go-llms", header.Get("HTTP-Referer"))
+	assert.Equal(t, "go-llms", header.Get("X-Title"))
+	assert.Equal(t, "openai/gpt-4.1", body["model"])
+	assert.Equal(t, []any{"openai/gpt-4.1", "mistralai/mistral-large"}, body["models"])
+	assert.Equal(t, map[string]any{"order": []any{"OpenAI"}, "allow_fallbacks": false}, body["provider"])
+
+	assert.Equal(t, "mistralai/mistral-large", stream.(*openai.Stream).Model(), "The model that served the response should be reported")
+	assert.Equal(t, content.FromText("Hi"), stream.Message().Content)
+}
+
+func TestChainedOptions(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	// Inherited and OpenRouter options can be mixed in one chain.
+	m := New("key", "openai/gpt-4.1").
+		WithMaxCompletionTokens(100).
+		WithProviderPreferences(ProviderPreferences{Sort: "price"}).
+		WithTemperature(0.5).
+		WithFallbacks("mistralai/mistral-large")
+	m.WithEndpoint(server.URL, "OpenRouter")
+
+	stream := m.Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hello")}}, nil)
+	for range stream.Iter() {
+	}
+	require.NoError(t, stream.Err())
+	assert.Equal(t, float64(100), body["max_completion_tokens"])
+	assert.Equal(t, 0.5, body["temperature"])
+	assert.Equal(t, map[string]any{"sort": "price"}, body["provider"])
+	assert.Equal(t, []any{"openai/gpt-4.1", "mistralai/mistral-large"}, body["models"])
+}
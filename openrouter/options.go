@@ -0,0 +1,133 @@
+package openrouter
+
+import "net/http"
+
+// The options of openai.Model that apply to OpenRouter are repeated here so
+// that they return *Model, and can be chained with the options of this
+// package.
+
+// WithDebug is like openai.Model.WithDebug.
+func (m *Model) WithDebug() *Model {
+	m.Model.WithDebug()
+	return m
+}
+
+// WithHTTPClient is like openai.Model.WithHTTPClient.
+func (m *Model) WithHTTPClient(client *http.Client) *Model {
+	m.Model.WithHTTPClient(client)
+	return m
+}
+
+// WithHeader is like openai.Model.WithHeader.
+func (m *Model) WithHeader(key, value string) *Model {
+	m.Model.WithHeader(key, value)
+	return m
+}
+
+// WithExtraBody is like openai.Model.WithExtraBody.
+func (m *Model) WithExtraBody(key string, value any) *Model {
+	m.Model.WithExtraBody(key, value)
+	return m
+}
+
+// WithStreaming is like openai.Model.WithStreaming.
+func (m *Model) WithStreaming(enabled bool) *Model {
+	m.Model.WithStreaming(enabled)
+	return m
+}
+
+// WithoutStreamOptions is like openai.Model.WithoutStreamOptions.
+func (m *Model) WithoutStreamOptions() *Model {
+	m.Model.WithoutStreamOptions()
+	return m
+}
+
+// WithLenientToolCalls is like openai.Model.WithLenientToolCalls.
+func (m *Model) WithLenientToolCalls() *Model {
+	m.Model.WithLenientToolCalls()
+	return m
+}
+
+// WithSystemRole is like openai.Model.WithSystemRole.
+func (m *Model) WithSystemRole(role string) *Model {
+	m.Model.WithSystemRole(role)
+	return m
+}
+
+// WithMaxCompletionTokens is like openai.Model.WithMaxCompletionTokens.
+func (m *Model) WithMaxCompletionTokens(maxCompletionTokens int) *Model {
+	m.Model.WithMaxCompletionTokens(maxCompletionTokens)
+	return m
+}
+
+// WithTemperature is like openai.Model.WithTemperature.
+func (m *Model) WithTemperature(temperature float64) *Model {
+	m.Model.WithTemperature(temperature)
+	return m
+}
+
+// WithTopP is like openai.Model.WithTopP.
+func (m *Model) WithTopP(topP float64) *Model {
+	m.Model.WithTopP(topP)
+	return m
+}
+
+// WithFrequencyPenalty is like openai.Model.WithFrequencyPenalty.
+func (m *Model) WithFrequencyPenalty(penalty float64) *Model {
+	m.Model.WithFrequencyPenalty(penalty)
+	return m
+}
+
+// WithPresencePenalty is like openai.Model.WithPresencePenalty.
+func (m *Model) WithPresencePenalty(penalty float64) *Model {
+	m.Model.WithPresencePenalty(penalty)
+	return m
+}
+
+// WithParallelToolCalls is like openai.Model.WithParallelToolCalls.
+func (m *Model) WithParallelToolCalls(enabled bool) *Model {
+	m.Model.WithParallelToolCalls(enabled)
+	return m
+}
+
+// WithToolChoice is like openai.Model.WithToolChoice.
+func (m *Model) WithToolChoice(choice string) *Model {
+	m.Model.WithToolChoice(choice)
+	return m
+}
+
+// WithLogprobs is like openai.Model.WithLogprobs.
+func (m *Model) WithLogprobs(topN int) *Model {
+	m.Model.WithLogprobs(topN)
+	return m
+}
+
+// WithSeed is like openai.Model.WithSeed.
+func (m *Model) WithSeed(seed int) *Model {
+	m.Model.WithSeed(seed)
+	return m
+}
+
+// WithStopSequences is like openai.Model.WithStopSequences.
+func (m *Model) WithStopSequences(sequences ...string) *Model {
+	m.Model.WithStopSequences(sequences...)
+	return m
+}
+
+// WithModalities is like openai.Model.WithModalities.
+func (m *Model) WithModalities(modalities ...string) *Model {
+	m.Model.WithModalities(modalities...)
+	return m
+}
+
+// WithAudioOutput is like openai.Model.WithAudioOutput.
+func (m *Model) WithAudioOutput(voice, format string) *Model {
+	m.Model.WithAudioOutput(voice, format)
+	return m
+}
+
+// WithStrictTools is like openai.Model.WithStrictTools.
+func (m *Model) WithStrictTools(funcNames ...string) *Model {
+	m.Model.WithStrictTools(funcNames...)
+	return m
+}
@@ -0,0 +1,132 @@
+// Package replay reads the turn records written by llms.NewRecordWriter and
+// lets a recorded conversation be stepped through turn by turn, or a chosen
+// turn be sent again to a live provider with a different system prompt,
+// history or tools. This helps with diagnosing why an agent misbehaved.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// Recording is a sequence of recorded turns.
+type Recording []llms.TurnRecord
+
+// Read reads a recording of one line of JSON per turn.
+func Read(r io.Reader) (Recording, error) {
+	var recording Recording
+	scanner := bufio.NewScanner(r)
+	// Turns include the full history, so lines can be long.
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record llms.TurnRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("failed to decode turn on line %d: %w", line, err)
+		}
+		recording = append(recording, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	return recording, nil
+}
+
+// ReadFile reads a recording from the file at the given path.
+func ReadFile(path string) (Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// History returns the message history after the turn at index i, which is
+// the state the LLM was in once the turn was done.
+func (r Recording) History(i int) []llms.Message {
+	record := r[i]
+	history := append([]llms.Message(nil), record.Messages...)
+	if record.Error != "" {
+		return history
+	}
+	history = append(history, record.Response)
+	return append(history, record.ToolResults...)
+}
+
+// Options changes the request of a turn before it's sent again. Nil fields
+// keep what was recorded.
+type Options struct {
+	SystemPrompt content.Content
+	Messages     []llms.Message
+	// Tools replaces the recorded tools. The recorded tools are only schemas,
+	// which is all the model sees, so tool calls are never run during a
+	// replay.
+	Tools []tools.Tool
+}
+
+// Step sends the request of a recorded turn to the provider again, with the
+// changes in opts, and returns a record of the new turn. Tools that the model
+// calls are not run; the calls are in the response of the returned record.
+func Step(ctx context.Context, provider llms.Provider, record llms.TurnRecord, opts Options) (llms.TurnRecord, error) {
+	systemPrompt, messages := record.SystemPrompt, record.Messages
+	if opts.SystemPrompt != nil {
+		systemPrompt = opts.SystemPrompt
+	}
+	if opts.Messages != nil {
+		messages = opts.Messages
+	}
+	var toolbox *tools.Toolbox
+	if opts.Tools != nil {
+		toolbox = tools.Box(opts.Tools...)
+	} else if len(record.Tools) > 0 {
+		toolbox = tools.Box()
+		for _, schema := range record.Tools {
+			toolbox.Add(tools.External(schema.Name, schema, func(r tools.Runner, params json.RawMessage) tools.Result {
+				return tools.Errorf("recorded tools can't be run")
+			}))
+		}
+	}
+
+	replayed := llms.TurnRecord{
+		Time:         time.Now(),
+		Turn:         record.Turn,
+		Company:      provider.Company(),
+		Model:        provider.Model(),
+		SystemPrompt: systemPrompt,
+		Messages:     messages,
+	}
+	if toolbox != nil {
+		for _, tool := range toolbox.All() {
+			replayed.Tools = append(replayed.Tools, tool.Schema())
+		}
+	}
+	stream := provider.Generate(ctx, systemPrompt, messages, toolbox)
+	if err := stream.Err(); err != nil {
+		replayed.Error = err.Error()
+		return replayed, err
+	}
+	for range stream.Iter() {
+	}
+	replayed.Response = stream.Message()
+	replayed.InputTokens, replayed.OutputTokens = stream.Usage()
+	if s, ok := stream.(llms.ModelStream); ok && s.Model() != "" {
+		replayed.Model = s.Model()
+	}
+	if err := stream.Err(); err != nil {
+		replayed.Error = err.Error()
+		return replayed, err
+	}
+	return replayed, nil
+}
@@ -0,0 +1,94 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedProvider replies with its messages in order, and remembers what it
+// was sent last.
+type scriptedProvider struct {
+	replies      []llms.Message
+	systemPrompt content.Content
+	toolbox      *tools.Toolbox
+}
+
+func (p *scriptedProvider) Company() string { return "Test" }
+func (p *scriptedProvider) Model() string   { return "test-model" }
+func (p *scriptedProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *scriptedProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	p.systemPrompt, p.toolbox = systemPrompt, toolbox
+	reply := p.replies[0]
+	p.replies = p.replies[1:]
+	return &scriptedStream{message: reply}
+}
+
+type scriptedStream struct {
+	message llms.Message
+}
+
+func (s *scriptedStream) Err() error              { return nil }
+func (s *scriptedStream) Message() llms.Message   { return s.message }
+func (s *scriptedStream) Text() string            { return "" }
+func (s *scriptedStream) ToolCall() llms.ToolCall { return s.message.ToolCalls[0] }
+func (s *scriptedStream) Usage() (int, int)       { return 10, 2 }
+
+func (s *scriptedStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		if len(s.message.ToolCalls) > 0 {
+			yield(llms.StreamStatusToolCallBegin)
+			yield(llms.StreamStatusToolCallReady)
+		}
+	}
+}
+
+type lookupParams struct {
+	Query string `json:"query"`
+}
+
+var lookup = tools.Func("Lookup", "Looks something up", "lookup", func(r tools.Runner, p lookupParams) tools.Result {
+	return tools.SuccessFromString("nothing found")
+})
+
+func TestRecordAndStep(t *testing.T) {
+	provider := &scriptedProvider{replies: []llms.Message{
+		{Role: "assistant", ToolCalls: []llms.ToolCall{{ID: "call_1", Name: "lookup", Arguments: json.RawMessage(`{"query":"cats"}`)}}},
+		{Role: "assistant", Content: content.FromText("I found nothing.")},
+	}}
+	var log bytes.Buffer
+	llm := llms.New(provider, lookup).WithRecorder(llms.NewRecordWriter(&log))
+	llm.SystemPrompt = func() content.Content { return content.FromText("Be helpful.") }
+	for range llm.Chat("Tell me about cats") {
+	}
+	require.NoError(t, llm.Err())
+
+	recording, err := Read(&log)
+	require.NoError(t, err)
+	require.Len(t, recording, 2)
+	assert.Equal(t, 1, recording[0].Turn)
+	assert.Len(t, recording[0].Messages, 1)
+	assert.Equal(t, "lookup", recording[0].Tools[0].Name)
+	assert.Equal(t, 10, recording[0].InputTokens)
+	history := recording.History(0)
+	require.Len(t, history, 3)
+	assert.Equal(t, "tool", history[2].Role)
+	assert.Equal(t, history, recording[1].Messages, "The history after a turn is what the next turn is sent")
+
+	// Send the first turn again with a different system prompt.
+	live := &scriptedProvider{replies: []llms.Message{{Role: "assistant", Content: content.FromText("Cats are great.")}}}
+	replayed, err := Step(context.Background(), live, recording[0], Options{SystemPrompt: content.FromText("Answer from memory.")})
+	require.NoError(t, err)
+	assert.Equal(t, content.FromText("Answer from memory."), live.systemPrompt)
+	assert.NotNil(t, live.toolbox.Get("lookup"), "The recorded tools should be offered again")
+	assert.Equal(t, content.FromText("Cats are great."), replayed.Response.Content)
+	assert.Equal(t, recording[0].Messages, replayed.Messages)
+}
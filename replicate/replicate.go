@@ -0,0 +1,361 @@
+// Package replicate provides access to language models hosted on Replicate.
+// Replicate models take a prompt rather than a list of messages and don't
+// support tools, so the conversation is formatted as a single prompt.
+package replicate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+type Model struct {
+	apiToken     string
+	model        string
+	endpoint     string
+	maxTokens    int
+	input        map[string]any
+	formatPrompt func(messages []llms.Message) string
+	debug        bool
+	httpClient   *http.Client
+}
+
+// New returns a provider for the Replicate model with the given name, such as
+// "meta/meta-llama-3-70b-instruct". A specific version can be selected by
+// appending its ID after a colon, as in "owner/name:version".
+func New(apiToken, model string) *Model {
+	return &Model{
+		apiToken:     apiToken,
+		model:        model,
+		endpoint:     "https://api.replicate.com/v1",
+		formatPrompt: FormatPrompt,
+	}
+}
+
+func (m *Model) WithDebug() *Model {
+	m.debug = true
+	return m
+}
+
+// WithHTTPClient sets the HTTP client that requests are sent with, for
+// example to set timeouts, proxies or instrumented transports. Without one,
+// the client of the context is used (see llms.ContextWithHTTPClient), or else
+// http.DefaultClient.
+func (m *Model) WithHTTPClient(client *http.Client) *Model {
+	m.httpClient = client
+	return m
+}
+
+// client returns the HTTP client to send requests with.
+func (m *Model) client(ctx context.Context) *http.Client {
+	if m.httpClient != nil {
+		return m.httpClient
+	}
+	return llms.HTTPClientFromContext(ctx)
+}
+
+// WithEndpoint sets the base URL of the API.
+func (m *Model) WithEndpoint(endpoint string) *Model {
+	m.endpoint = strings.TrimSuffix(endpoint, "/")
+	return m
+}
+
+func (m *Model) WithMaxTokens(maxTokens int) *Model {
+	m.maxTokens = maxTokens
+	return m
+}
+
+// WithInput sets an input of the model, such as "temperature". The inputs a
+// model accepts are listed on its page on Replicate.
+func (m *Model) WithInput(key string, value any) *Model {
+	if m.input == nil {
+		m.input = make(map[string]any)
+	}
+	m.input[key] = value
+	return m
+}
+
+// WithPromptFormatter sets the function that turns the conversation into the
+// prompt for the model. The default is FormatPrompt.
+func (m *Model) WithPromptFormatter(format func(messages []llms.Message) string) *Model {
+	m.formatPrompt = format
+	return m
+}
+
+func (m *Model) Company() string {
+	return "Replicate"
+}
+
+func (m *Model) Model() string {
+	return m.model
+}
+
+func (m *Model) String() string {
+	return llms.ProviderName(m)
+}
+
+// Ping looks up the model on Replicate, which checks that the API is reachable,
+// that the API token is valid and that the model exists.
+func (m *Model) Ping(ctx context.Context) error {
+	name, _, _ := strings.Cut(m.model, ":")
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/models/%s", m.endpoint, name), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	var model struct{}
+	return m.do(req, &model)
+}
+
+// FormatPrompt formats the conversation as a plain transcript. A conversation
+// that is a single user message is sent as is, which lets the prompt template
+// of the model (if it has one) do the formatting.
+func FormatPrompt(messages []llms.Message) string {
+	if len(messages) == 1 && messages[0].Role == "user" {
+		return textOf(messages[0].Content)
+	}
+	var b strings.Builder
+	for _, msg := range messages {
+		var speaker string
+		switch msg.Role {
+		case "user":
+			speaker = "User"
+		case "assistant":
+			speaker = "Assistant"
+		default:
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n\n", speaker, textOf(msg.Content))
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
+
+func textOf(c content.Content) string {
+	var parts []string
+	for _, item := range c {
+		switch v := item.(type) {
+		case *content.Text:
+			parts = append(parts, v.Text)
+		case *content.JSON:
+			parts = append(parts, string(v.Data))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+type prediction struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  any    `json:"error"`
+	URLs   struct {
+		Get    string `json:"get"`
+		Stream string `json:"stream"`
+	} `json:"urls"`
+	Metrics struct {
+		InputTokenCount  int `json:"input_token_count"`
+		OutputTokenCount int `json:"output_token_count"`
+	} `json:"metrics"`
+}
+
+func (m *Model) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	if toolbox != nil && len(toolbox.All()) > 0 {
+		return &Stream{err: fmt.Errorf("Replicate models do not support tools")}
+	}
+
+	input := map[string]any{"prompt": m.formatPrompt(messages)}
+	if systemPrompt != nil {
+		input["system_prompt"] = textOf(systemPrompt)
+	}
+	if m.maxTokens > 0 {
+		input["max_tokens"] = m.maxTokens
+	}
+	for key, value := range m.input {
+		input[key] = value
+	}
+	payload := map[string]any{"input": input, "stream": true}
+	url := fmt.Sprintf("%s/models/%s/predictions", m.endpoint, m.model)
+	if _, version, found := strings.Cut(m.model, ":"); found {
+		payload["version"] = version
+		url = m.endpoint + "/predictions"
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return &Stream{err: fmt.Errorf("error encoding JSON: %w", err)}
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return &Stream{err: fmt.Errorf("error creating request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var p prediction
+	if err := m.do(req, &p); err != nil {
+		return &Stream{err: fmt.Errorf("error creating prediction: %w", err)}
+	}
+	if p.URLs.Stream == "" {
+		return &Stream{err: fmt.Errorf("model %q does not support streaming", m.model)}
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", p.URLs.Stream, nil)
+	if err != nil {
+		return &Stream{err: fmt.Errorf("error creating request: %w", err)}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-store")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.apiToken))
+	req.Header.Set("User-Agent", llms.UserAgent())
+	resp, err := m.client(ctx).Do(req)
+	if err != nil {
+		return &Stream{err: fmt.Errorf("error making request: %w", err)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return &Stream{err: fmt.Errorf("error streaming prediction: %w", newAPIError(resp))}
+	}
+	return &Stream{ctx: ctx, model: m, getURL: p.URLs.Get, stream: resp.Body, debug: m.debug}
+}
+
+// do sends an authenticated request to the API and decodes the response.
+func (m *Model) do(req *http.Request, v any) error {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", m.apiToken))
+	req.Header.Set("User-Agent", llms.UserAgent())
+	resp, err := m.client(req.Context()).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type Stream struct {
+	ctx      context.Context
+	model    *Model
+	getURL   string
+	stream   io.ReadCloser
+	debug    bool
+	err      error
+	message  llms.Message
+	lastText string
+
+	inputTokens, outputTokens int
+}
+
+func (s *Stream) Err() error {
+	return s.err
+}
+
+func (s *Stream) Message() llms.Message {
+	return s.message
+}
+
+func (s *Stream) Text() string {
+	return s.lastText
+}
+
+func (s *Stream) ToolCall() llms.ToolCall {
+	return llms.ToolCall{}
+}
+
+func (s *Stream) Usage() (inputTokens, outputTokens int) {
+	return s.inputTokens, s.outputTokens
+}
+
+func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		defer s.stream.Close()
+		s.message.Role = "assistant"
+		scanner := bufio.NewScanner(s.stream)
+		var event string
+		var data []string
+		for scanner.Scan() {
+			if err := s.ctx.Err(); err != nil {
+				s.err = err
+				return
+			}
+			line := scanner.Text()
+			if s.debug {
+				fmt.Println(line)
+			}
+			if field, value, ok := strings.Cut(line, ":"); ok && line != "" {
+				value = strings.TrimPrefix(value, " ")
+				switch field {
+				case "event":
+					event = value
+				case "data":
+					data = append(data, value)
+				}
+				continue
+			} else if line != "" {
+				continue
+			}
+			// A blank line dispatches the event.
+			text := strings.Join(data, "\n")
+			currentEvent := event
+			event, data = "", nil
+			switch currentEvent {
+			case "output":
+				if text == "" {
+					continue
+				}
+				s.lastText = text
+				s.message.Content.Append(text)
+				if !yield(llms.StreamStatusText) {
+					return
+				}
+			case "error":
+				var apiError struct {
+					Detail string `json:"detail"`
+				}
+				if json.Unmarshal([]byte(text), &apiError) == nil && apiError.Detail != "" {
+					text = apiError.Detail
+				}
+				s.err = fmt.Errorf("prediction failed: %s", text)
+				return
+			case "done":
+				var done struct {
+					Reason string `json:"reason"`
+				}
+				json.Unmarshal([]byte(text), &done)
+				if done.Reason == "canceled" {
+					s.err = fmt.Errorf("prediction was canceled")
+					return
+				}
+				s.fetchMetrics()
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			s.err = fmt.Errorf("error scanning stream: %w", err)
+		}
+	}
+}
+
+// fetchMetrics gets the token counts of the finished prediction. Usage is left
+// at zero if they can't be fetched, since the output itself is complete.
+func (s *Stream) fetchMetrics() {
+	if s.getURL == "" {
+		return
+	}
+	req, err := http.NewRequestWithContext(s.ctx, "GET", s.getURL, nil)
+	if err != nil {
+		return
+	}
+	var p prediction
+	if err := s.model.do(req, &p); err != nil {
+		return
+	}
+	s.inputTokens = p.Metrics.InputTokenCount
+	s.outputTokens = p.Metrics.OutputTokenCount
+}
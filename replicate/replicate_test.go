@@ -0,0 +1,103 @@
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, events string, body *map[string]any) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/models/meta/llama/predictions", "/predictions":
+			require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+			fmt.Fprintf(w, `{"id":"p1","status":"starting","urls":{"get":"%[1]s/p1","stream":"%[1]s/p1/stream"}}`, server.URL)
+		case "/p1/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, events)
+		case "/p1":
+			fmt.Fprint(w, `{"id":"p1","status":"succeeded","metrics":{"input_token_count":12,"output_token_count":3}}`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	return server
+}
+
+func TestGenerate(t *testing.T) {
+	var body map[string]any
+	server := newTestServer(t, "event: output\nid: 1\ndata: Hello\n\nevent: output\ndata: ,\ndata:  world\n\n: ping\n\nevent: done\ndata: {}\n\n", &body)
+	defer server.Close()
+
+	m := New("token", "meta/llama").WithEndpoint(server.URL).WithMaxTokens(100).WithInput("temperature", 0.5)
+	stream := m.Generate(context.Background(), content.FromText("Be nice."), []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	require.NoError(t, stream.Err())
+	var texts []string
+	for status := range stream.Iter() {
+		require.Equal(t, llms.StreamStatusText, status)
+		texts = append(texts, stream.Text())
+	}
+	require.NoError(t, stream.Err())
+
+	assert.Equal(t, map[string]any{
+		"stream": true,
+		"input": map[string]any{
+			"prompt":        "Hi",
+			"system_prompt": "Be nice.",
+			"max_tokens":    float64(100),
+			"temperature":   0.5,
+		},
+	}, body)
+	assert.Equal(t, []string{"Hello", ",\n world"}, texts)
+	assert.Equal(t, llms.Message{Role: "assistant", Content: content.FromText("Hello,\n world")}, stream.Message())
+	inputTokens, outputTokens := stream.Usage()
+	assert.Equal(t, 12, inputTokens)
+	assert.Equal(t, 3, outputTokens)
+}
+
+func TestGenerateVersionAndError(t *testing.T) {
+	var body map[string]any
+	server := newTestServer(t, "event: output\ndata: Hel\n\nevent: error\ndata: {\"detail\":\"CUDA out of memory\"}\n\n", &body)
+	defer server.Close()
+
+	m := New("token", "meta/llama:abc123").WithEndpoint(server.URL)
+	messages := []llms.Message{
+		{Role: "user", Content: content.FromText("Hi")},
+		{Role: "assistant", Content: content.FromText("Hello!")},
+		{Role: "user", Content: content.FromText("How are you?")},
+	}
+	stream := m.Generate(context.Background(), nil, messages, nil)
+	require.NoError(t, stream.Err())
+	for range stream.Iter() {
+	}
+	assert.EqualError(t, stream.Err(), "prediction failed: CUDA out of memory")
+	assert.Equal(t, "abc123", body["version"])
+	assert.Equal(t, "User: Hi\n\nAssistant: Hello!\n\nUser: How are you?\n\nAssistant:", body["input"].(map[string]any)["prompt"])
+}
+
+func TestGenerateAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"detail":"Request was throttled."}`)
+	}))
+	defer server.Close()
+
+	stream := New("token", "meta/llama").WithEndpoint(server.URL).Generate(context.Background(), nil, []llms.Message{{Role: "user", Content: content.FromText("Hi")}}, nil)
+	assert.EqualError(t, stream.Err(), "error creating prediction: 429 Too Many Requests: Request was throttled.")
+	var httpErr llms.HTTPError
+	require.ErrorAs(t, stream.Err(), &httpErr)
+	assert.Equal(t, 429, httpErr.HTTPStatus())
+	assert.Equal(t, 3*time.Second, httpErr.RetryDelay())
+}
@@ -0,0 +1,218 @@
+// Package tenant lets a single service serve many customers with isolated
+// credentials, models, budgets and tools. The tenant of a chat is taken from
+// its context, and its profile is resolved for every request.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+)
+
+// ErrBudgetExceeded is returned when a tenant has spent its budget. It wraps
+// llms.ErrBudgetExceeded.
+var ErrBudgetExceeded = fmt.Errorf("tenant %w", llms.ErrBudgetExceeded)
+
+type contextKey struct{}
+
+// WithTenant returns a context that makes chats started with it run as the
+// given tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant of the context, or an empty string.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Profile holds the overrides for a tenant. Zero values keep the defaults.
+type Profile struct {
+	// Provider serves the tenant's requests, typically configured with the
+	// tenant's own API key, endpoint and model. If nil, the default provider
+	// is used.
+	Provider llms.Provider
+	// BudgetUSD is how much the tenant may spend, as priced by
+	// llms.LookupPricing. Zero means no limit.
+	BudgetUSD float64
+	// AllowedTools lists the function names of the tools the tenant may use.
+	// If nil, all tools are allowed.
+	AllowedTools []string
+}
+
+// Resolver returns the profile of a tenant. It's called for every request, so
+// it should cache anything that's expensive to look up or construct.
+type Resolver func(ctx context.Context, tenantID string) (Profile, error)
+
+// Router is a provider that applies the profile of the tenant in the context
+// to every request. Requests without a tenant use the default provider with
+// no restrictions.
+type Router struct {
+	base    llms.Provider
+	resolve Resolver
+
+	mu    sync.Mutex
+	spent map[string]float64
+}
+
+// NewRouter returns a provider that resolves the profile of the tenant of
+// every request. Company and Model report the default provider. Spending is
+// tracked in memory, so budgets apply per process.
+func NewRouter(base llms.Provider, resolve Resolver) *Router {
+	return &Router{base: base, resolve: resolve, spent: make(map[string]float64)}
+}
+
+func (r *Router) Company() string {
+	return r.base.Company()
+}
+
+func (r *Router) Model() string {
+	return r.base.Model()
+}
+
+func (r *Router) String() string {
+	return llms.ProviderName(r)
+}
+
+// Spent returns how much the tenant has spent through the router.
+func (r *Router) Spent(tenantID string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.spent[tenantID]
+}
+
+func (r *Router) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	tenantID := FromContext(ctx)
+	if tenantID == "" {
+		return r.base.Generate(ctx, systemPrompt, messages, toolbox)
+	}
+	profile, err := r.resolve(ctx, tenantID)
+	if err != nil {
+		return &Stream{err: fmt.Errorf("failed to resolve tenant %q: %w", tenantID, err)}
+	}
+	if profile.BudgetUSD > 0 && r.Spent(tenantID) >= profile.BudgetUSD {
+		return &Stream{err: fmt.Errorf("%w: %q has spent $%.2f of $%.2f", ErrBudgetExceeded, tenantID, r.Spent(tenantID), profile.BudgetUSD)}
+	}
+	provider := profile.Provider
+	if provider == nil {
+		provider = r.base
+	}
+	if profile.AllowedTools != nil && toolbox != nil {
+		var allowed []tools.Tool
+		for _, tool := range toolbox.All() {
+			if slices.Contains(profile.AllowedTools, tool.FuncName()) {
+				allowed = append(allowed, tool)
+			}
+		}
+		toolbox = nil
+		if len(allowed) > 0 {
+			toolbox = tools.Box(allowed...)
+		}
+	}
+	return &Stream{
+		ProviderStream: provider.Generate(ctx, systemPrompt, messages, toolbox),
+		router:         r,
+		tenantID:       tenantID,
+		model:          provider.Model(),
+		allowedTools:   profile.AllowedTools,
+	}
+}
+
+// Stream keeps track of the tenant's spending, and stops the response if the
+// model calls a tool the tenant isn't allowed to use.
+type Stream struct {
+	llms.ProviderStream
+	router       *Router
+	tenantID     string
+	model        string
+	allowedTools []string
+	err          error
+}
+
+func (s *Stream) Err() error {
+	if s.err != nil || s.ProviderStream == nil {
+		return s.err
+	}
+	return s.ProviderStream.Err()
+}
+
+// Model returns the model that served the response.
+func (s *Stream) Model() string {
+	if ms, ok := s.ProviderStream.(llms.ModelStream); ok && ms.Model() != "" {
+		return ms.Model()
+	}
+	return s.model
+}
+
+func (s *Stream) Audio() []byte {
+	if as, ok := s.ProviderStream.(llms.AudioStream); ok {
+		return as.Audio()
+	}
+	return nil
+}
+
+func (s *Stream) UsageDetails() llms.UsageDetails {
+	if us, ok := s.ProviderStream.(llms.UsageDetailsStream); ok {
+		return us.UsageDetails()
+	}
+	return llms.UsageDetails{}
+}
+
+func (s *Stream) RateLimits() (limits llms.RateLimits, ok bool) {
+	if rs, ok := s.ProviderStream.(llms.RateLimitStream); ok {
+		return rs.RateLimits()
+	}
+	return llms.RateLimits{}, false
+}
+
+func (s *Stream) Truncated() (reason string, ok bool) {
+	if ts, ok := s.ProviderStream.(llms.TruncationStream); ok {
+		return ts.Truncated()
+	}
+	return "", false
+}
+
+func (s *Stream) FinishReason() llms.FinishReason {
+	if fs, ok := s.ProviderStream.(llms.FinishReasonStream); ok {
+		return fs.FinishReason()
+	}
+	return ""
+}
+
+func (s *Stream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		if s.ProviderStream == nil {
+			return
+		}
+		defer s.recordCost()
+		for status := range s.ProviderStream.Iter() {
+			if status == llms.StreamStatusToolCallBegin && s.allowedTools != nil {
+				if name := s.ToolCall().Name; !slices.Contains(s.allowedTools, name) {
+					s.err = fmt.Errorf("tenant %q is not allowed to use tool %q", s.tenantID, name)
+					return
+				}
+			}
+			if !yield(status) {
+				return
+			}
+		}
+	}
+}
+
+func (s *Stream) recordCost() {
+	pricing, ok := llms.LookupPricing(s.Model())
+	if !ok {
+		return
+	}
+	inputTokens, outputTokens := s.Usage()
+	cost := pricing.CostWithDetails(inputTokens, outputTokens, s.UsageDetails())
+	s.router.mu.Lock()
+	defer s.router.mu.Unlock()
+	s.router.spent[s.tenantID] += cost
+}
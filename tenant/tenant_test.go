@@ -0,0 +1,134 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/tools"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider replies with a call to its tool, if any, and remembers the
+// tools it was offered.
+type fakeProvider struct {
+	model    string
+	toolCall string
+	cached   int
+	offered  []string
+}
+
+func (p *fakeProvider) Company() string { return "Test" }
+func (p *fakeProvider) Model() string   { return p.model }
+func (p *fakeProvider) String() string  { return llms.ProviderName(p) }
+
+func (p *fakeProvider) Generate(ctx context.Context, systemPrompt content.Content, messages []llms.Message, toolbox *tools.Toolbox) llms.ProviderStream {
+	p.offered = nil
+	if toolbox != nil {
+		for _, tool := range toolbox.All() {
+			p.offered = append(p.offered, tool.FuncName())
+		}
+	}
+	return &fakeStream{toolCall: p.toolCall, cached: p.cached}
+}
+
+type fakeStream struct {
+	toolCall string
+	cached   int
+	message  llms.Message
+}
+
+func (s *fakeStream) Err() error              { return nil }
+func (s *fakeStream) Message() llms.Message   { return s.message }
+func (s *fakeStream) Text() string            { return "" }
+func (s *fakeStream) Usage() (int, int)       { return 1_000_000, 0 }
+func (s *fakeStream) ToolCall() llms.ToolCall { return s.message.ToolCalls[len(s.message.ToolCalls)-1] }
+
+func (s *fakeStream) UsageDetails() llms.UsageDetails {
+	return llms.UsageDetails{CachedInputTokens: s.cached}
+}
+
+func (s *fakeStream) FinishReason() llms.FinishReason { return llms.FinishReasonStop }
+
+func (s *fakeStream) Iter() func(yield func(llms.StreamStatus) bool) {
+	return func(yield func(llms.StreamStatus) bool) {
+		s.message.Role = "assistant"
+		if s.toolCall == "" {
+			return
+		}
+		s.message.ToolCalls = append(s.message.ToolCalls, llms.ToolCall{ID: "1", Name: s.toolCall, Arguments: []byte("{}")})
+		yield(llms.StreamStatusToolCallBegin)
+	}
+}
+
+type noParams struct{}
+
+func tool(name string) tools.Tool {
+	return tools.Func(name, name, name, func(r tools.Runner, p noParams) tools.Result {
+		return tools.Success(map[string]any{})
+	})
+}
+
+func drain(stream llms.ProviderStream) error {
+	for range stream.Iter() {
+	}
+	return stream.Err()
+}
+
+func TestRouter(t *testing.T) {
+	base := &fakeProvider{model: "base-model"}
+	acme := &fakeProvider{model: "gpt-4o"}
+	router := NewRouter(base, func(ctx context.Context, tenantID string) (Profile, error) {
+		switch tenantID {
+		case "acme":
+			return Profile{Provider: acme, BudgetUSD: 4, AllowedTools: []string{"search"}}, nil
+		case "globex":
+			return Profile{}, nil
+		}
+		return Profile{}, errors.New("unknown tenant")
+	})
+	toolbox := tools.Box(tool("search"), tool("delete_everything"))
+
+	// Requests without a tenant go to the default provider.
+	require.NoError(t, drain(router.Generate(context.Background(), nil, nil, toolbox)))
+	assert.ElementsMatch(t, []string{"search", "delete_everything"}, base.offered)
+
+	// Tenants without overrides also use the default provider.
+	require.NoError(t, drain(router.Generate(WithTenant(context.Background(), "globex"), nil, nil, toolbox)))
+	assert.ElementsMatch(t, []string{"search", "delete_everything"}, base.offered)
+
+	// The tenant's provider is only offered the tenant's tools.
+	ctx := WithTenant(context.Background(), "acme")
+	stream := router.Generate(ctx, nil, nil, toolbox)
+	require.NoError(t, drain(stream))
+	assert.Equal(t, []string{"search"}, acme.offered)
+	assert.Equal(t, "gpt-4o", stream.(llms.ModelStream).Model())
+	assert.InDelta(t, 2.5, router.Spent("acme"), 1e-9)
+
+	// Calls to tools the tenant isn't allowed to use stop the response.
+	acme.toolCall = "delete_everything"
+	assert.EqualError(t, drain(router.Generate(ctx, nil, nil, toolbox)), `tenant "acme" is not allowed to use tool "delete_everything"`)
+	assert.InDelta(t, 5, router.Spent("acme"), 1e-9)
+
+	// Once the budget is spent, requests fail before reaching the provider.
+	assert.ErrorIs(t, drain(router.Generate(ctx, nil, nil, toolbox)), ErrBudgetExceeded)
+	assert.Equal(t, llms.StopCauseBudget, llms.StopCauseOf(drain(router.Generate(ctx, nil, nil, toolbox))))
+
+	assert.ErrorContains(t, drain(router.Generate(WithTenant(context.Background(), "initech"), nil, nil, toolbox)), "unknown tenant")
+}
+
+func TestRouterCachedTokens(t *testing.T) {
+	acme := &fakeProvider{model: "gpt-4o", cached: 1_000_000}
+	router := NewRouter(&fakeProvider{model: "base-model"}, func(ctx context.Context, tenantID string) (Profile, error) {
+		return Profile{Provider: acme}, nil
+	})
+
+	stream := router.Generate(WithTenant(context.Background(), "acme"), nil, nil, nil)
+	require.NoError(t, drain(stream))
+	assert.Equal(t, llms.UsageDetails{CachedInputTokens: 1_000_000}, stream.(llms.UsageDetailsStream).UsageDetails())
+	assert.Equal(t, llms.FinishReasonStop, stream.(llms.FinishReasonStream).FinishReason())
+	assert.InDelta(t, 1.25, router.Spent("acme"), 1e-9, "Cached input tokens should be charged at the cached price")
+}
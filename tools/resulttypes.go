@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/blixt/go-llms/content"
+)
+
+// ResultTyper is implemented by tools that declare which content types their
+// results may contain. See WithResultTypes.
+type ResultTyper interface {
+	ResultTypes() []content.Type
+}
+
+// WithResultTypes returns the tool with a declaration of the content types its
+// results may contain, for tools that can return the same result in several
+// forms. While the tool runs, ResultTypesFromContext returns the declared types
+// that the provider accepts, so the tool can pick the best form.
+func WithResultTypes(t Tool, types ...content.Type) Tool {
+	return &typedTool{Tool: t, types: types}
+}
+
+type typedTool struct {
+	Tool
+	types []content.Type
+}
+
+func (t *typedTool) ResultTypes() []content.Type {
+	return t.types
+}
+
+type resultTypesKey struct{}
+
+// ContextWithResultTypes returns a context that tells the tools run with it
+// which result content types they can use.
+func ContextWithResultTypes(ctx context.Context, types []content.Type) context.Context {
+	return context.WithValue(ctx, resultTypesKey{}, types)
+}
+
+// ResultTypesFromContext returns the result content types that the tool can
+// use, or nil if they aren't known, in which case any type may be used.
+func ResultTypesFromContext(ctx context.Context) []content.Type {
+	types, _ := ctx.Value(resultTypesKey{}).([]content.Type)
+	return types
+}
+
+// NegotiateResultTypes returns the types in accepted which the tool declared
+// (see WithResultTypes) in the order they were declared, which may be none of
+// them. If the tool doesn't declare its types, or accepted is nil, accepted is
+// returned as is.
+func NegotiateResultTypes(t Tool, accepted []content.Type) []content.Type {
+	typer, ok := t.(ResultTyper)
+	if !ok || accepted == nil {
+		return accepted
+	}
+	types := []content.Type{}
+	for _, declared := range typer.ResultTypes() {
+		if slices.Contains(accepted, declared) {
+			types = append(types, declared)
+		}
+	}
+	return types
+}
+
+// ConvertContent converts the content of a tool result to the accepted types
+// where possible: JSON becomes text and text becomes {"output": ...} JSON, and
+// thinking is dropped. Images are left as they are, since providers that
+// can't take images in tool results send them in a message of their own. If
+// accepted is nil, the content is returned as is.
+func ConvertContent(c content.Content, accepted []content.Type) content.Content {
+	if accepted == nil {
+		return c
+	}
+	converted := make(content.Content, 0, len(c))
+	for _, item := range c {
+		if slices.Contains(accepted, item.Type()) {
+			converted = append(converted, item)
+			continue
+		}
+		switch v := item.(type) {
+		case *content.JSON:
+			if slices.Contains(accepted, content.TypeText) {
+				converted = append(converted, &content.Text{Text: string(v.Data)})
+				continue
+			}
+		case *content.Text:
+			if slices.Contains(accepted, content.TypeJSON) {
+				data, _ := json.Marshal(map[string]string{"output": v.Text})
+				converted = append(converted, &content.JSON{Data: data})
+				continue
+			}
+		case *content.Thinking:
+			continue
+		}
+		converted = append(converted, item)
+	}
+	return converted
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/blixt/go-llms/content"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertContent(t *testing.T) {
+	c := content.Content{
+		&content.Text{Text: "hello"},
+		&content.JSON{Data: json.RawMessage(`{"a":1}`)},
+		&content.ImageURL{URL: "https://example.com/cat.png"},
+		&content.Thinking{Text: "hmm"},
+	}
+
+	assert.Equal(t, c, ConvertContent(c, nil), "Unknown types should leave the content as is")
+
+	assert.Equal(t, content.Content{
+		&content.Text{Text: "hello"},
+		&content.Text{Text: `{"a":1}`},
+		&content.ImageURL{URL: "https://example.com/cat.png"},
+	}, ConvertContent(c, []content.Type{content.TypeText}))
+
+	assert.Equal(t, content.Content{
+		&content.JSON{Data: json.RawMessage(`{"output":"hello"}`)},
+		&content.JSON{Data: json.RawMessage(`{"a":1}`)},
+		&content.ImageURL{URL: "https://example.com/cat.png"},
+	}, ConvertContent(c, []content.Type{content.TypeJSON}))
+}
+
+func TestNegotiateResultTypes(t *testing.T) {
+	screenshot := WithResultTypes(Func("Screenshot", "Takes a screenshot", "screenshot", func(r Runner, p struct{}) Result {
+		if slices.Contains(ResultTypesFromContext(r.Context()), content.TypeImageURL) {
+			return SuccessWithContent("Screenshot", content.Content{&content.ImageURL{URL: "data:image/png;base64,AAAA"}})
+		}
+		return SuccessFromString("A window with a cat in it")
+	}), content.TypeImageURL, content.TypeText)
+
+	assert.Equal(t, []content.Type{content.TypeImageURL, content.TypeText}, screenshot.(ResultTyper).ResultTypes())
+	assert.Equal(t, []content.Type{content.TypeText}, NegotiateResultTypes(screenshot, []content.Type{content.TypeText, content.TypeJSON}))
+	assert.Nil(t, NegotiateResultTypes(screenshot, nil))
+
+	ctx := ContextWithResultTypes(context.Background(), []content.Type{content.TypeImageURL})
+	result := screenshot.Run(NewRunner(ctx, nil, func(string) {}), json.RawMessage(`{}`))
+	assert.Equal(t, content.TypeImageURL, result.Content()[0].Type())
+	result = screenshot.Run(NopRunner, json.RawMessage(`{}`))
+	assert.Equal(t, content.TypeJSON, result.Content()[0].Type())
+}
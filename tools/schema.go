@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 )
 
@@ -119,6 +120,9 @@ func validateParameters(schema ValueSchema, jsonData json.RawMessage) error {
 		if !found {
 			continue // Ignoring extra fields
 		}
+		if val == nil && !slices.Contains(schema.Required, key) {
+			continue // Optional fields may be null, like in strict mode
+		}
 		if err := validateField(fieldSchema, val); err != nil {
 			return fmt.Errorf("%s: %w", key, err)
 		}
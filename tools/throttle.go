@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"sync"
+	"time"
+)
+
+// throttledRunner reports at most one status per interval to the runner it
+// wraps. The latest status reported in between is sent when the interval ends.
+type throttledRunner struct {
+	Runner
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	pending string
+	waiting bool
+	timer   *time.Timer
+	done    bool
+}
+
+func newThrottledRunner(r Runner, interval time.Duration) *throttledRunner {
+	return &throttledRunner{Runner: r, interval: interval}
+}
+
+func (r *throttledRunner) Report(status string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.done {
+		return
+	}
+	if wait := r.interval - time.Since(r.last); wait > 0 {
+		r.pending, r.waiting = status, true
+		if r.timer == nil {
+			r.timer = time.AfterFunc(wait, r.sendPending)
+		}
+		return
+	}
+	r.last = time.Now()
+	r.Runner.Report(status)
+}
+
+func (r *throttledRunner) sendPending() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timer = nil
+	if r.done || !r.waiting {
+		return
+	}
+	r.last = time.Now()
+	r.waiting = false
+	r.Runner.Report(r.pending)
+}
+
+// flush reports the pending status, if any, and stops reporting statuses. It's
+// called when the tool is done, so that its final status isn't lost and
+// nothing is reported after the tool's result.
+func (r *throttledRunner) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if r.waiting {
+		r.waiting = false
+		r.Runner.Report(r.pending)
+	}
+	r.done = true
+}
@@ -3,9 +3,12 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/blixt/go-llms/content"
 	"github.com/stretchr/testify/assert"
@@ -267,3 +270,58 @@ func TestToolFunctionReport(t *testing.T) {
 	resultJSON := extractJSONFromResult(t, result)
 	assert.JSONEq(t, `{"name":"Alice","age":28,"email":"alice@example.com","isAdmin":true}`, string(resultJSON))
 }
+
+func TestToolboxStatusInterval(t *testing.T) {
+	chatty := Func("Chatty", "Reports a lot of statuses", "chatty", func(r Runner, p struct{}) Result {
+		for i := 1; i <= 100; i++ {
+			r.Report(fmt.Sprintf("step %d", i))
+		}
+		return SuccessFromString("done")
+	})
+	quiet := Func("Quiet", "Reports a few statuses", "quiet", func(r Runner, p struct{}) Result {
+		r.Report("one")
+		r.Report("two")
+		return SuccessFromString("done")
+	})
+	toolbox := Box(chatty, quiet).WithStatusInterval(time.Hour).WithToolStatusInterval("quiet", 0)
+	assert.Equal(t, time.Hour, toolbox.StatusInterval("chatty"))
+	assert.Equal(t, time.Duration(0), toolbox.StatusInterval("quiet"))
+
+	run := func(funcName string) []string {
+		var statuses []string
+		runner := NewRunner(context.Background(), toolbox, func(status string) {
+			statuses = append(statuses, status)
+		})
+		result := toolbox.Run(runner, funcName, json.RawMessage(`{}`))
+		require.NoError(t, result.Error())
+		return statuses
+	}
+	// The first status is reported right away, and the latest one when the
+	// tool is done.
+	assert.Equal(t, []string{"step 1", "step 100"}, run("chatty"))
+	assert.Equal(t, []string{"one", "two"}, run("quiet"))
+}
+
+func TestToolboxStatusIntervalElapsed(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []string
+	tool := Func("Slow", "Reports statuses over time", "slow", func(r Runner, p struct{}) Result {
+		r.Report("a")
+		r.Report("b")
+		r.Report("c")
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return len(statuses) == 2
+		}, time.Second, time.Millisecond)
+		return SuccessFromString("done")
+	})
+	toolbox := Box(tool).WithStatusInterval(10 * time.Millisecond)
+	runner := NewRunner(context.Background(), toolbox, func(status string) {
+		mu.Lock()
+		defer mu.Unlock()
+		statuses = append(statuses, status)
+	})
+	toolbox.Run(runner, "slow", json.RawMessage(`{}`))
+	assert.Equal(t, []string{"a", "c"}, statuses)
+}
@@ -3,10 +3,13 @@ package tools
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 type Toolbox struct {
-	tools map[string]Tool
+	tools           map[string]Tool
+	statusInterval  time.Duration
+	statusIntervals map[string]time.Duration
 }
 
 // Box returns a new Toolbox containing the given tools.
@@ -42,6 +45,36 @@ func (t *Toolbox) Get(funcName string) Tool {
 	return t.tools[funcName]
 }
 
+// WithStatusInterval throttles the status updates of all tools in the
+// toolbox, so that at most one is reported per interval. Statuses that are
+// reported in between are coalesced, and only the latest one is reported at
+// the end of the interval, or when the tool is done. This keeps chatty tools
+// from flooding the update channel.
+func (t *Toolbox) WithStatusInterval(interval time.Duration) *Toolbox {
+	t.statusInterval = interval
+	return t
+}
+
+// WithToolStatusInterval throttles the status updates of a single tool like
+// WithStatusInterval, overriding the interval of the toolbox. An interval of
+// zero turns throttling off for the tool.
+func (t *Toolbox) WithToolStatusInterval(funcName string, interval time.Duration) *Toolbox {
+	if t.statusIntervals == nil {
+		t.statusIntervals = make(map[string]time.Duration)
+	}
+	t.statusIntervals[funcName] = interval
+	return t
+}
+
+// StatusInterval returns the interval that the status updates of the tool with
+// the given function name are throttled to, or zero if they aren't.
+func (t *Toolbox) StatusInterval(funcName string) time.Duration {
+	if interval, ok := t.statusIntervals[funcName]; ok {
+		return interval
+	}
+	return t.statusInterval
+}
+
 // Run runs the tool with the given name and parameters, which should be provided as a JSON string.
 func (t *Toolbox) Run(r Runner, funcName string, params json.RawMessage) Result {
 	tool := t.Get(funcName)
@@ -49,5 +82,10 @@ func (t *Toolbox) Run(r Runner, funcName string, params json.RawMessage) Result
 		err := fmt.Errorf("tool %q not found", funcName)
 		return Error(err)
 	}
+	if interval := t.StatusInterval(funcName); interval > 0 {
+		throttled := newThrottledRunner(r, interval)
+		defer throttled.flush()
+		r = throttled
+	}
 	return tool.Run(r, params)
 }
@@ -0,0 +1,137 @@
+// Package voice turns spoken utterances into chat turns, so that voice chat
+// apps only need to capture audio and play back (or display) the replies.
+package voice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/openai"
+)
+
+const UpdateTypeTranscript llms.UpdateType = "transcript"
+
+// TranscriptUpdate reports progress on transcribing an utterance. While the
+// utterance is being transcribed Text is the newly transcribed text. The last
+// update for each utterance has Final set and Text is the full transcript,
+// which is what gets sent to the LLM.
+type TranscriptUpdate struct {
+	Text          string `json:"text"`
+	Final         bool   `json:"final,omitempty"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+func init() {
+	llms.RegisterUpdateType[TranscriptUpdate]()
+}
+
+func (u TranscriptUpdate) Type() llms.UpdateType {
+	return UpdateTypeTranscript
+}
+
+// Transcriber transcribes one utterance of audio, calling onDelta with pieces
+// of the transcript as they become available, and returns the full transcript.
+type Transcriber func(ctx context.Context, audio io.Reader, onDelta func(delta string)) (string, error)
+
+// OpenAI returns a Transcriber that uses the OpenAI transcription API.
+func OpenAI(opts openai.TranscriptionOptions) Transcriber {
+	return func(ctx context.Context, audio io.Reader, onDelta func(string)) (string, error) {
+		return openai.TranscribeStream(ctx, audio, opts, onDelta)
+	}
+}
+
+// Pipeline transcribes utterances and chats with the LLM using the
+// transcripts.
+type Pipeline struct {
+	llm        *llms.LLM
+	transcribe Transcriber
+	err        error
+}
+
+func NewPipeline(llm *llms.LLM, transcribe Transcriber) *Pipeline {
+	return &Pipeline{llm: llm, transcribe: transcribe}
+}
+
+// Run transcribes each utterance as it arrives and sends the final transcript
+// to the LLM, one utterance at a time. Every utterance is read until EOF, so a
+// reader that is still being recorded gets transcribed while it's recorded.
+// The returned channel carries transcript updates followed by the updates of
+// the chat, and is closed when the utterances channel is closed or an error
+// occurs. Check Err afterwards.
+func (p *Pipeline) Run(ctx context.Context, utterances <-chan io.Reader) <-chan llms.Update {
+	p.err = nil
+	if llms.GetCorrelationID(ctx) == "" {
+		ctx = llms.ContextWithCorrelationID(ctx, p.llm.CorrelationID())
+	}
+	correlationID := llms.GetCorrelationID(ctx)
+	updateChan := make(chan llms.Update)
+	go func() {
+		defer close(updateChan)
+		send := func(update llms.Update) bool {
+			select {
+			case updateChan <- update:
+				return true
+			case <-ctx.Done():
+				p.err = ctx.Err()
+				return false
+			}
+		}
+		for {
+			var audio io.Reader
+			select {
+			case a, ok := <-utterances:
+				if !ok {
+					return
+				}
+				audio = a
+			case <-ctx.Done():
+				p.err = ctx.Err()
+				return
+			}
+			transcript, err := p.transcribe(ctx, audio, func(delta string) {
+				send(TranscriptUpdate{Text: delta, CorrelationID: correlationID})
+			})
+			if closer, ok := audio.(io.Closer); ok {
+				closer.Close()
+			}
+			if err != nil {
+				p.err = fmt.Errorf("error transcribing audio: %w", err)
+				return
+			}
+			if ctx.Err() != nil {
+				p.err = ctx.Err()
+				return
+			}
+			transcript = strings.TrimSpace(transcript)
+			if !send(TranscriptUpdate{Text: transcript, Final: true, CorrelationID: correlationID}) {
+				return
+			}
+			if transcript == "" {
+				// Nothing was said, so there's nothing to reply to.
+				continue
+			}
+			updates := p.llm.ChatWithContext(ctx, transcript)
+			for update := range updates {
+				if !send(update) {
+					// Drain the chat so it can wind down after the cancellation.
+					for range updates {
+					}
+					return
+				}
+			}
+			if err := p.llm.Err(); err != nil {
+				p.err = err
+				return
+			}
+		}
+	}()
+	return updateChan
+}
+
+// Err returns the error that stopped the last run, if any.
+func (p *Pipeline) Err() error {
+	return p.err
+}
@@ -0,0 +1,86 @@
+package voice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/blixt/go-llms/openai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"choices":[{"index":0,"delta":{"role":"assistant","content":"Hi there!"}}]}`+"\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	llm := llms.New(openai.New("key", "gpt-4.1").WithEndpoint(server.URL, "Test"))
+
+	transcribe := func(ctx context.Context, audio io.Reader, onDelta func(string)) (string, error) {
+		data, err := io.ReadAll(audio)
+		if err != nil {
+			return "", err
+		}
+		words := strings.Fields(string(data))
+		for i, word := range words {
+			if i > 0 {
+				word = " " + word
+			}
+			onDelta(word)
+		}
+		return string(data), nil
+	}
+
+	utterances := make(chan io.Reader, 2)
+	utterances <- strings.NewReader("  ")
+	utterances <- strings.NewReader("Hello you")
+	close(utterances)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pipeline := NewPipeline(llm, transcribe)
+	var updates []llms.Update
+	for update := range pipeline.Run(llms.ContextWithCorrelationID(ctx, "req-1"), utterances) {
+		updates = append(updates, update)
+	}
+	require.NoError(t, pipeline.Err())
+
+	assert.Equal(t, []llms.Update{
+		TranscriptUpdate{Text: "", Final: true, CorrelationID: "req-1"},
+		TranscriptUpdate{Text: "Hello", CorrelationID: "req-1"},
+		TranscriptUpdate{Text: " you", CorrelationID: "req-1"},
+		TranscriptUpdate{Text: "Hello you", Final: true, CorrelationID: "req-1"},
+		llms.TextUpdate{Text: "Hi there!", CorrelationID: "req-1"},
+	}, updates)
+}
+
+func TestPipelineTranscriptionError(t *testing.T) {
+	llm := llms.New(openai.New("key", "gpt-4.1"))
+	transcribe := func(ctx context.Context, audio io.Reader, onDelta func(string)) (string, error) {
+		return "", fmt.Errorf("bad audio")
+	}
+	utterances := make(chan io.Reader, 1)
+	utterances <- strings.NewReader("audio")
+
+	pipeline := NewPipeline(llm, transcribe)
+	for range pipeline.Run(context.Background(), utterances) {
+	}
+	assert.ErrorContains(t, pipeline.Err(), "bad audio")
+}
+
+func TestTranscriptUpdateMarshalling(t *testing.T) {
+	data, err := llms.MarshalUpdate(TranscriptUpdate{Text: "Hello", Final: true})
+	require.NoError(t, err)
+	update, err := llms.UnmarshalUpdate(data)
+	require.NoError(t, err)
+	assert.Equal(t, TranscriptUpdate{Text: "Hello", Final: true}, update)
+}
@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+)
+
+// ErrClosed is reported to the error handler for events that are sent after
+// the sink was closed, which are dropped.
+var ErrClosed = errors.New("webhook sink is closed")
+
+// Event is a single entry in a batch delivered by a Sink. It has either an
+// update of the chat, encoded with llms.MarshalUpdate, or a summary of a turn.
+type Event struct {
+	ConversationID string          `json:"conversation_id,omitempty"`
+	Time           time.Time       `json:"time"`
+	Update         json.RawMessage `json:"update,omitempty"`
+	Turn           *Turn           `json:"turn,omitempty"`
+}
+
+// Turn summarizes one turn of a conversation.
+type Turn struct {
+	Company           string  `json:"company"`
+	Model             string  `json:"model"`
+	InputTokens       int     `json:"input_tokens"`
+	OutputTokens      int     `json:"output_tokens"`
+	CostUSD           float64 `json:"cost_usd,omitempty"`
+	PromptFingerprint string  `json:"prompt_fingerprint,omitempty"`
+}
+
+// Batch is the body of the requests a Sink sends.
+type Batch struct {
+	Events []Event `json:"events"`
+}
+
+// Sink collects conversation events and POSTs them to a webhook in batches.
+// Requests are retried when the webhook fails, and signed when a secret is
+// set (see Verify). It's safe for concurrent use, so one sink can serve every
+// conversation in a process.
+type Sink struct {
+	url           string
+	secret        []byte
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+	onError       func(error)
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	mu        sync.Mutex
+	events    []Event
+	closed    bool
+	full      chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+func NewSink(url string) *Sink {
+	return &Sink{
+		url:           url,
+		batchSize:     100,
+		flushInterval: 5 * time.Second,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// WithSecret makes the sink sign its requests with the secret.
+func (s *Sink) WithSecret(secret string) *Sink {
+	s.secret = []byte(secret)
+	return s
+}
+
+// WithBatchSize sets how many events are collected before they're sent. The
+// default is 100.
+func (s *Sink) WithBatchSize(n int) *Sink {
+	s.batchSize = max(1, n)
+	return s
+}
+
+// WithFlushInterval sets how long events may wait for a batch to fill up. The
+// default is five seconds, which is kept for intervals that aren't positive.
+func (s *Sink) WithFlushInterval(d time.Duration) *Sink {
+	if d > 0 {
+		s.flushInterval = d
+	}
+	return s
+}
+
+// WithErrorHandler sets a function that's called with batches that couldn't
+// be delivered. By default they're dropped.
+func (s *Sink) WithErrorHandler(onError func(error)) *Sink {
+	s.onError = onError
+	return s
+}
+
+// Forward passes the updates of a chat through unchanged, while sending them
+// to the webhook. Use it on the channel returned by the Chat methods.
+func (s *Sink) Forward(conversationID string, updates <-chan llms.Update) <-chan llms.Update {
+	out := make(chan llms.Update)
+	go func() {
+		defer close(out)
+		for update := range updates {
+			s.Send(conversationID, update)
+			out <- update
+		}
+	}()
+	return out
+}
+
+// UsageCallback returns a function for LLM.WithUsageCallback that sends a
+// summary of every turn to the webhook.
+func (s *Sink) UsageCallback(conversationID string) func(llms.Usage) {
+	return func(u llms.Usage) {
+		s.add(Event{ConversationID: conversationID, Turn: &Turn{
+			Company:           u.Company,
+			Model:             u.Model,
+			InputTokens:       u.InputTokens,
+			OutputTokens:      u.OutputTokens,
+			CostUSD:           u.CostUSD,
+			PromptFingerprint: u.PromptFingerprint,
+		}})
+	}
+}
+
+// Send queues an update for the webhook. Updates that can't be encoded are
+// reported to the error handler.
+func (s *Sink) Send(conversationID string, update llms.Update) {
+	data, err := llms.MarshalUpdate(update)
+	if err != nil {
+		s.reportError(err)
+		return
+	}
+	s.add(Event{ConversationID: conversationID, Update: data})
+}
+
+// Close sends the remaining events and stops the sink. The context limits how
+// long the final delivery may take. Events sent after Close are dropped and
+// reported to the error handler as ErrClosed. Closing more than once is
+// harmless.
+func (s *Sink) Close(ctx context.Context) error {
+	s.start()
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.stopped
+	return s.flush(ctx)
+}
+
+func (s *Sink) add(e Event) {
+	s.start()
+	e.Time = time.Now().UTC()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.reportError(ErrClosed)
+		return
+	}
+	s.events = append(s.events, e)
+	full := len(s.events) >= s.batchSize
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Sink) start() {
+	s.startOnce.Do(func() {
+		go s.loop()
+	})
+}
+
+func (s *Sink) loop() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.full:
+		case <-s.stop:
+			return
+		}
+		if err := s.flush(context.Background()); err != nil {
+			s.reportError(err)
+		}
+	}
+}
+
+// flush sends the collected events, in batches of at most batchSize.
+func (s *Sink) flush(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		n := min(len(s.events), s.batchSize)
+		events := s.events[:n:n]
+		s.events = s.events[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		body, err := json.Marshal(Batch{Events: events})
+		if err != nil {
+			return err
+		}
+		if err := Post(ctx, s.client, s.url, s.secret, body); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Sink) reportError(err error) {
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blixt/go-llms/llms"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches []Batch
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, Verify([]byte("secret"), body, r.Header.Get(SignatureHeader)))
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			// Fail the first attempt to exercise the retry.
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch Batch
+		assert.NoError(t, json.Unmarshal(body, &batch))
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	sink := NewSink(server.URL).WithSecret("secret").WithBatchSize(2).WithFlushInterval(time.Hour)
+	updates := make(chan llms.Update, 2)
+	updates <- llms.TextUpdate{Text: "Hello"}
+	updates <- llms.TextUpdate{Text: " there"}
+	close(updates)
+	var forwarded []llms.Update
+	for update := range sink.Forward("c1", updates) {
+		forwarded = append(forwarded, update)
+	}
+	assert.Len(t, forwarded, 2)
+	sink.UsageCallback("c1")(llms.Usage{Company: "Test", Model: "test-model", InputTokens: 5, OutputTokens: 2})
+	require.NoError(t, sink.Close(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	var events []Event
+	for _, batch := range batches {
+		assert.LessOrEqual(t, len(batch.Events), 2)
+		events = append(events, batch.Events...)
+	}
+	require.Len(t, events, 3)
+	update, err := llms.UnmarshalUpdate(events[0].Update)
+	require.NoError(t, err)
+	assert.Equal(t, llms.TextUpdate{Text: "Hello"}, update)
+	assert.Equal(t, "c1", events[2].ConversationID)
+	assert.Equal(t, &Turn{Company: "Test", Model: "test-model", InputTokens: 5, OutputTokens: 2}, events[2].Turn)
+}
+
+func TestSinkClose(t *testing.T) {
+	var mu sync.Mutex
+	var events []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch Batch
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, batch.Events...)
+	}))
+	defer server.Close()
+
+	var errs []error
+	sink := NewSink(server.URL).WithFlushInterval(0).WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+	sink.Send("c1", llms.TextUpdate{Text: "Hello"})
+	require.NoError(t, sink.Close(context.Background()))
+	require.NoError(t, sink.Close(context.Background()), "Closing twice should be harmless")
+
+	sink.Send("c1", llms.TextUpdate{Text: "Too late"})
+	assert.Equal(t, []error{ErrClosed}, errs, "Events sent after Close should be reported")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, events, 1)
+}
@@ -0,0 +1,74 @@
+// Package webhook delivers conversation events to external systems, such as
+// CRMs and analytics pipelines, by POSTing them to a webhook as JSON.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader is the header that carries the signature of signed requests.
+const SignatureHeader = "X-Signature-256"
+
+// Sign returns the signature of the body, which is "sha256=" followed by the
+// hex encoded HMAC-SHA256 of the body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature (the value of SignatureHeader) matches
+// the body, for use by the receiving end of a webhook.
+func Verify(secret, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Post sends the JSON body to the URL, signing it if secret isn't empty. It
+// tries up to three times, with a short backoff, as long as the server fails
+// or asks to slow down.
+func Post(ctx context.Context, client *http.Client, url string, secret, body []byte) error {
+	var err error
+	for attempt := range 3 {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		var retry bool
+		retry, err = post(ctx, client, url, secret, body)
+		if !retry {
+			break
+		}
+	}
+	return err
+}
+
+func post(ctx context.Context, client *http.Client, url string, secret, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("error calling webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook responded with %s", resp.Status)
+}